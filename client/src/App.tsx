@@ -3,12 +3,14 @@ import { LandingPage } from './pages/LandingPage';
 import { LaunchpadApp } from './pages/LaunchpadApp';
 import { LoginPage } from './pages/auth/LoginPage';
 import { ResetPasswordPage } from './pages/auth/ResetPasswordPage';
+import { ConfirmEmailChangePage } from './pages/auth/ConfirmEmailChangePage';
 import { ProtectedRoute } from './components/shared/ProtectedRoute';
 import { CourseLayout } from './components/shared/CourseLayout';
 import { DashboardPage } from './pages/dashboard/DashboardPage';
 import { LessonPage } from './pages/dashboard/LessonPage';
 import ClaimAccountPage from './pages/ClaimAccountPage';
 import { Cohort4WaitlistPage } from './pages/Cohort4WaitlistPage';
+import { WaitlistClaimPage } from './pages/WaitlistClaimPage';
 import AdminPortalPage from './pages/admin/AdminPortalPage';
 import { GivingPage } from './pages/dashboard/GivingPage';
 import { VolunteerPage } from './pages/dashboard/VolunteerPage';
@@ -29,8 +31,10 @@ export default function App() {
       <Route path="/launchpad" element={<LaunchpadApp />} />
       <Route path="/login" element={<LoginPage />} />
       <Route path="/reset-password" element={<ResetPasswordPage />} />
+      <Route path="/change-email" element={<ConfirmEmailChangePage />} />
       <Route path="/register" element={<ClaimAccountPage />} />
       <Route path="/cohort4-waitlist" element={<Cohort4WaitlistPage />} />
+      <Route path="/waitlist/claim" element={<WaitlistClaimPage />} />
 
       {/* --- PROTECTED LMS ROUTES --- */}
       <Route element={<ProtectedRoute />}>
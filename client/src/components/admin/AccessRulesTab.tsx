@@ -0,0 +1,240 @@
+import React, { useState, useEffect } from 'react';
+import { KeyRound, PlusCircle, Pencil, Trash2, Save, RotateCcw, Loader2 } from 'lucide-react';
+import { API_BASE_URL } from '../../config';
+import { getAuthSession, fetchLMS } from '../../lib/api';
+import { CustomDropdown } from './CustomDropdown';
+
+interface AccessRule {
+  id: string;
+  program_name: string;
+  applies_to: string;
+  lesson_id: string | null;
+  kind: string;
+  config: Record<string, unknown>;
+}
+
+interface LessonOption {
+  id: string;
+  program_name: string;
+  module_title: string;
+  title: string;
+}
+
+interface RuleDraft {
+  id?: string;
+  applies_to: string;
+  lesson_id: string;
+  kind: string;
+  config: string;
+}
+
+const inputCls = 'w-full bg-white/5 border border-white/8 rounded-xl px-4 py-2.5 text-sm text-white placeholder:text-slate-600 focus:outline-none focus:border-indigo-500/60 transition-colors';
+
+const KINDS = [
+  { label: 'Prerequisite lessons', value: 'prerequisite' },
+  { label: 'Required review', value: 'review' },
+  { label: 'Required quiz', value: 'quiz' },
+  { label: 'Fixed dates', value: 'absolute_window' },
+  { label: 'Around live session', value: 'relative_window' },
+  { label: 'Drip schedule', value: 'drip' },
+];
+
+const APPLIES_TO = [
+  { label: 'All lessons', value: 'all' },
+  { label: 'Midpoint lesson', value: 'midpoint' },
+  { label: 'After the midpoint', value: 'after_midpoint' },
+  { label: 'One lesson', value: 'lesson' },
+];
+
+// Starting config for each kind; hours are relative to the config's anchor
+const CONFIG_TEMPLATES: Record<string, object> = {
+  prerequisite: { previous: true },
+  review: { review_types: ['mid_cohort', 'mid_video', 'mid_google'] },
+  quiz: { previous: true, min_percent: 70 },
+  absolute_window: { opens_at: new Date().toISOString(), closes_at: null },
+  relative_window: { anchor: 'live_end', close_hours: 48 },
+  drip: { anchor: 'enrolled', start_hours: 0, interval_hours: 24 },
+};
+
+async function authHeaders() {
+  const session = await getAuthSession();
+  return { 'Content-Type': 'application/json', Authorization: `Bearer ${session?.access_token}` };
+}
+
+// Per-program rules that decide when each lesson unlocks for a learner
+export const AccessRulesTab = () => {
+  const [lessons, setLessons] = useState<LessonOption[]>([]);
+  const [program, setProgram] = useState('');
+  const [rules, setRules] = useState<AccessRule[]>([]);
+  const [isLoading, setIsLoading] = useState(false);
+  const [draft, setDraft] = useState<RuleDraft | null>(null);
+  const [isSaving, setIsSaving] = useState(false);
+  const [error, setError] = useState('');
+
+  useEffect(() => {
+    fetchLMS('/admin/lessons')
+      .then(data => setLessons(data || []))
+      .catch(err => console.error("Failed to load lessons:", err));
+  }, []);
+
+  const loadRules = async (name: string) => {
+    if (!name) { setRules([]); return; }
+    setIsLoading(true);
+    try {
+      const data = await fetchLMS(`/admin/access-rules?program=${encodeURIComponent(name)}`);
+      setRules(data || []);
+    } catch (err) {
+      console.error("Failed to load access rules:", err);
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  useEffect(() => { setDraft(null); loadRules(program); }, [program]);
+
+  const programs = Array.from(new Set(lessons.map(l => l.program_name))).sort();
+  const programLessons = lessons.filter(l => l.program_name === program);
+  const lessonTitle = (id: string | null) => programLessons.find(l => l.id === id)?.title || 'Unknown lesson';
+
+  const startEdit = (rule?: AccessRule) => {
+    setError('');
+    setDraft(rule
+      ? { id: rule.id, applies_to: rule.applies_to, lesson_id: rule.lesson_id || '', kind: rule.kind, config: JSON.stringify(rule.config, null, 2) }
+      : { applies_to: 'all', lesson_id: '', kind: 'prerequisite', config: JSON.stringify(CONFIG_TEMPLATES.prerequisite, null, 2) });
+  };
+
+  const handleSave = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!draft) return;
+    let config: unknown;
+    try {
+      config = JSON.parse(draft.config);
+    } catch {
+      setError('The config is not valid JSON.');
+      return;
+    }
+    setIsSaving(true);
+    setError('');
+    try {
+      const res = await fetch(`${API_BASE_URL}/admin/access-rules${draft.id ? `?id=${draft.id}` : ''}`, {
+        method: draft.id ? 'PUT' : 'POST',
+        headers: await authHeaders(),
+        body: JSON.stringify({
+          program_name: program,
+          applies_to: draft.applies_to,
+          lesson_id: draft.applies_to === 'lesson' ? draft.lesson_id : null,
+          kind: draft.kind,
+          config,
+        }),
+      });
+      if (!res.ok) { setError(await res.text()); return; }
+      setDraft(null);
+      await loadRules(program);
+    } catch (err) {
+      setError('Failed to save the access rule. Please retry.');
+    } finally {
+      setIsSaving(false);
+    }
+  };
+
+  const handleDelete = async (rule: AccessRule) => {
+    if (!window.confirm('Delete this access rule? Lessons it locked will open immediately.')) return;
+    const res = await fetch(`${API_BASE_URL}/admin/access-rules?id=${rule.id}`, { method: 'DELETE', headers: await authHeaders() });
+    if (!res.ok) { alert(await res.text()); return; }
+    setRules(prev => prev.filter(r => r.id !== rule.id));
+  };
+
+  const kindLabel = (kind: string) => KINDS.find(k => k.value === kind)?.label || kind;
+  const scopeLabel = (rule: AccessRule) => rule.applies_to === 'lesson'
+    ? lessonTitle(rule.lesson_id)
+    : APPLIES_TO.find(a => a.value === rule.applies_to)?.label || rule.applies_to;
+
+  return (
+    <div className="space-y-6">
+      <div className="flex flex-col md:flex-row md:items-center justify-between gap-4">
+        <div>
+          <h2 className="text-2xl font-bold text-white flex items-center gap-3">
+            <KeyRound className="text-pink-500" size={24} />
+            Lesson Access Rules
+          </h2>
+          <p className="text-slate-400 text-sm">Rules on a single lesson replace program-wide rules of the same kind.</p>
+        </div>
+        <div className="flex items-center gap-3">
+          <CustomDropdown
+            value={program}
+            onChange={val => setProgram(String(val))}
+            options={[{ label: 'Select a program', value: '' }, ...programs.map(p => ({ label: p, value: p }))]}
+          />
+          <button onClick={() => startEdit()} disabled={!program} className="flex items-center gap-2 px-4 py-2.5 bg-pink-600 hover:bg-pink-500 text-white text-sm font-bold rounded-xl transition-colors shrink-0 disabled:opacity-40">
+            <PlusCircle size={15} /> Rule
+          </button>
+        </div>
+      </div>
+
+      {draft && (
+        <form onSubmit={handleSave} className="bg-white/5 border border-white/10 rounded-2xl p-6 space-y-4">
+          <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+            <CustomDropdown
+              value={draft.kind}
+              onChange={val => setDraft({ ...draft, kind: String(val), config: JSON.stringify(CONFIG_TEMPLATES[String(val)] || {}, null, 2) })}
+              options={KINDS}
+            />
+            <CustomDropdown
+              value={draft.applies_to}
+              onChange={val => setDraft({ ...draft, applies_to: String(val) })}
+              options={APPLIES_TO}
+            />
+            {draft.applies_to === 'lesson' && (
+              <CustomDropdown
+                value={draft.lesson_id}
+                onChange={val => setDraft({ ...draft, lesson_id: String(val) })}
+                options={[{ label: 'Select a lesson', value: '' }, ...programLessons.map(l => ({ label: `${l.module_title} · ${l.title}`, value: l.id }))]}
+              />
+            )}
+          </div>
+          <textarea
+            value={draft.config}
+            onChange={e => setDraft({ ...draft, config: e.target.value })}
+            rows={6}
+            className={`${inputCls} font-mono text-xs`}
+          />
+          <p className="text-xs text-slate-500">
+            Optional "message" overrides the text learners see. Lesson ids for "lesson_ids" are listed in Curriculum Data.
+          </p>
+          {error && <p className="text-sm text-red-400">{error}</p>}
+          <div className="flex gap-3">
+            <button type="button" onClick={() => setDraft(null)} className="flex items-center gap-2 px-4 py-2.5 rounded-xl border border-white/10 text-slate-400 text-sm font-semibold hover:bg-white/5 transition-colors">
+              <RotateCcw size={14} /> Cancel
+            </button>
+            <button type="submit" disabled={isSaving} className="flex-1 flex items-center justify-center gap-2 py-2.5 bg-pink-600 hover:bg-pink-500 text-white text-sm font-bold rounded-xl transition-colors disabled:opacity-60">
+              {isSaving ? <Loader2 size={14} className="animate-spin" /> : <Save size={14} />} {draft.id ? 'Update Rule' : 'Create Rule'}
+            </button>
+          </div>
+        </form>
+      )}
+
+      {!program ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-12 text-center text-slate-500">Select a program to see its rules.</div>
+      ) : isLoading ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-6 h-40 animate-pulse" />
+      ) : rules.length === 0 ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-12 text-center text-slate-500">No access rules. Every lesson is open.</div>
+      ) : (
+        <div className="space-y-3">
+          {rules.map(rule => (
+            <div key={rule.id} className="bg-white/5 border border-white/5 rounded-2xl p-5 flex items-center justify-between gap-4">
+              <div className="min-w-0">
+                <p className="text-white font-bold truncate">{kindLabel(rule.kind)} <span className="text-slate-500 font-normal">· {scopeLabel(rule)}</span></p>
+                <p className="text-xs text-slate-500 font-mono truncate">{JSON.stringify(rule.config)}</p>
+              </div>
+              <div className="flex items-center gap-2 shrink-0">
+                <button onClick={() => startEdit(rule)} className="p-2 rounded-lg text-slate-400 hover:text-white hover:bg-white/10 transition-colors"><Pencil size={15} /></button>
+                <button onClick={() => handleDelete(rule)} className="p-2 rounded-lg text-slate-400 hover:text-red-400 hover:bg-red-500/10 transition-colors"><Trash2 size={15} /></button>
+              </div>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+};
@@ -2,6 +2,7 @@ import { useState, useEffect } from 'react';
 import { motion } from 'framer-motion';
 import { Shield, RefreshCw, LogOut, Users, ArrowLeft, Mail, Phone, MapPin } from 'lucide-react';
 import { API_BASE_URL } from '../../config';
+import { getAuthSession } from '../../lib/api';
 
 interface ClanStat {
   id: number;
@@ -27,7 +28,6 @@ interface Participant {
 
 export const AdminDashboard = () => {
   // Auth State
-  const [secret, setSecret] = useState('');
   const [isAuthenticated, setIsAuthenticated] = useState(false);
 
   // Data State
@@ -39,28 +39,32 @@ export const AdminDashboard = () => {
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState('');
 
-  // 1. Auto-Login on Mount
+  // 1. Use the signed-in staff session on mount
   useEffect(() => {
-    const savedSecret = localStorage.getItem('soulmate_admin_secret');
-    if (savedSecret) {
-      setSecret(savedSecret);
-      fetchStats(savedSecret);
-    }
+    localStorage.removeItem('soulmate_admin_secret'); // left over from the shared-secret login
+    fetchStats();
   }, []);
 
+  const authHeaders = async (): Promise<Record<string, string> | null> => {
+    const session = await getAuthSession();
+    return session ? { 'Authorization': `Bearer ${session.access_token}` } : null;
+  };
+
   // 2. Fetch Dashboard Stats
-  const fetchStats = async (key: string) => {
+  const fetchStats = async () => {
     setLoading(true);
     setError('');
     try {
-      const res = await fetch(`${API_BASE_URL}/admin/stats`, {
-        headers: { 'X-Admin-Secret': key }
-      });
+      const headers = await authHeaders();
+      if (!headers) {
+        setIsAuthenticated(false);
+        return;
+      }
+      const res = await fetch(`${API_BASE_URL}/admin/stats`, { headers });
 
-      if (res.status === 401) {
-        setError('Invalid Secret Key');
+      if (res.status === 401 || res.status === 403) {
+        setError('This account does not have access to clan stats.');
         setIsAuthenticated(false);
-        localStorage.removeItem('soulmate_admin_secret'); // Clear invalid secret
         return;
       }
       if (!res.ok) throw new Error('Failed to fetch');
@@ -68,9 +72,6 @@ export const AdminDashboard = () => {
       const data = await res.json();
       setStats(data || []);
       setIsAuthenticated(true);
-
-      // Save valid secret for persistence
-      localStorage.setItem('soulmate_admin_secret', key);
     } catch (err) {
       setError('Network Error or Server Offline');
     } finally {
@@ -84,7 +85,7 @@ export const AdminDashboard = () => {
     setSelectedClan(clan);
     try {
       const res = await fetch(`${API_BASE_URL}/admin/participants?clan_id=${clan.id}`, {
-        headers: { 'X-Admin-Secret': secret }
+        headers: (await authHeaders()) ?? {}
       });
       const data = await res.json();
       setParticipants(data || []);
@@ -95,17 +96,10 @@ export const AdminDashboard = () => {
     }
   };
 
-  const handleLogin = (e: React.FormEvent) => {
-    e.preventDefault();
-    fetchStats(secret);
-  };
-
   const handleLogout = () => {
     setIsAuthenticated(false);
-    setSecret('');
     setStats([]);
     setSelectedClan(null);
-    localStorage.removeItem('soulmate_admin_secret');
   };
 
   // --- VIEW 1: LOGIN SCREEN ---
@@ -122,23 +116,16 @@ export const AdminDashboard = () => {
           </div>
         </div>
         <h2 className="text-2xl font-bold text-white">Mission Control</h2>
-        <form onSubmit={handleLogin} className="space-y-4">
-          <input
-            type="password"
-            value={secret}
-            onChange={(e) => setSecret(e.target.value)}
-            placeholder="Enter Admin Secret"
-            className="w-full bg-white/5 border border-white/10 rounded-xl p-3 text-white focus:ring-2 focus:ring-indigo-500 outline-none text-center tracking-widest"
-          />
-          <button
-            type="submit"
-            disabled={loading}
-            className="w-full py-3 bg-indigo-600 text-white font-bold rounded-xl hover:bg-indigo-500 transition-all"
+        <div className="space-y-4">
+          <p className="text-slate-400 text-sm">Sign in with a staff account to view clan stats.</p>
+          <a
+            href="/login"
+            className="block w-full py-3 bg-indigo-600 text-white font-bold rounded-xl hover:bg-indigo-500 transition-all"
           >
-            {loading ? 'Verifying...' : 'Access Dashboard'}
-          </button>
+            {loading ? 'Verifying...' : 'Sign In'}
+          </a>
           {error && <p className="text-red-400 text-sm">{error}</p>}
-        </form>
+        </div>
       </motion.div>
     );
   }
@@ -229,7 +216,7 @@ export const AdminDashboard = () => {
         </h1>
         <div className="flex gap-4">
           <button
-            onClick={() => fetchStats(secret)}
+            onClick={() => fetchStats()}
             className="p-2 bg-white/10 rounded-lg hover:bg-white/20 transition-colors"
           >
             <RefreshCw size={20} className={loading ? "animate-spin" : ""} />
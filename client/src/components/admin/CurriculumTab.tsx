@@ -8,6 +8,7 @@ import { API_BASE_URL } from '../../config';
 import { getAuthSession } from '../../lib/api';
 import { useToast, ToastContainer } from '../shared/Toast';
 import { CustomDropdown } from './CustomDropdown';
+import { QuizQuestionsEditor, type QuizQuestion } from './QuizQuestionsEditor';
 
 // ─── Types ────────────────────────────────────────────────────────────────────
 
@@ -32,7 +33,28 @@ interface Lesson {
   scheduled_start_time?: string;
   live_duration_minutes?: number;
   quiz_title?: string;
-  quiz_question?: string;
+  quiz_questions?: QuizQuestion[];
+  quiz_policy?: QuizPolicy | null;
+  quiz_bank_id?: string | null;
+  quiz_draw_count?: number;
+}
+
+interface QuestionBankOption {
+  id: string;
+  name: string;
+  topic: string;
+  module_title?: string | null;
+  questions: QuizQuestion[];
+}
+
+// When the quiz opens relative to the premiere, how long it runs, and how attempts count
+interface QuizPolicy {
+  open_offset_minutes: number;
+  duration_minutes: number;
+  max_attempts: number;
+  attempt_scoring: 'best' | 'latest';
+  late_minutes: number;
+  late_penalty_percent: number;
 }
 
 type PanelMode =
@@ -243,8 +265,8 @@ const DeleteModal = ({
           <div>
             <h3 className="font-bold text-white text-base">Delete {target.kind === 'module' ? 'Module' : 'Lesson'}?</h3>
             <p className="text-slate-400 text-sm mt-1">
-              <span className="text-white font-medium">"{target.title}"</span> will be permanently removed.
-              {target.kind === 'module' && <span className="text-red-400"> All lessons inside it will also be deleted.</span>}
+              <span className="text-white font-medium">"{target.title}"</span> will be moved to the trash, where it can be restored.
+              {target.kind === 'module' && <span className="text-red-400"> All lessons inside it will be hidden with it.</span>}
             </p>
           </div>
           <button onClick={onCancel} className="ml-auto text-slate-500 hover:text-white transition-colors flex-shrink-0"><X size={16} /></button>
@@ -340,7 +362,13 @@ const blankLesson = {
   scheduled_start_time: '',
   live_duration_minutes: 0,
   quiz_title: '',
-  quiz_question: '',
+  quiz_questions: [] as QuizQuestion[],
+  quiz_policy: {
+    open_offset_minutes: 0, duration_minutes: 3, max_attempts: 1,
+    attempt_scoring: 'best', late_minutes: 0, late_penalty_percent: 0,
+  } as QuizPolicy,
+  quiz_bank_id: '',
+  quiz_draw_count: 5,
 };
 
 const LessonForm = ({
@@ -365,14 +393,32 @@ const LessonForm = ({
       scheduled_start_time: initial.scheduled_start_time ? toLocalDatetimeInput(initial.scheduled_start_time) : '',
       live_duration_minutes: initial.live_duration_minutes ?? 0,
       quiz_title: initial.quiz_title || '',
-      quiz_question: initial.quiz_question || '',
+      quiz_questions: initial.quiz_questions || [],
+      quiz_policy: initial.quiz_policy || blankLesson.quiz_policy,
+      quiz_bank_id: initial.quiz_bank_id || '',
+      quiz_draw_count: initial.quiz_draw_count || blankLesson.quiz_draw_count,
     }
     : { ...blankLesson });
   const [saving, setSaving] = useState(false);
 
+  const [banks, setBanks] = useState<QuestionBankOption[]>([]);
+
+  useEffect(() => {
+    const fetchBanks = async () => {
+      const headers = await authHeaders();
+      const res = await fetch(`${API_BASE_URL}/admin/question-banks`, { headers });
+      if (res.ok) setBanks(await res.json() || []);
+    };
+    fetchBanks();
+  }, []);
+
+  const setPolicy = (patch: Partial<QuizPolicy>) =>
+    setForm({ ...form, quiz_policy: { ...form.quiz_policy, ...patch } });
+
   const handle = async (e: React.FormEvent) => {
     e.preventDefault();
     if (!form.module_id) { toast.error('Please select a parent module.'); return; }
+    if (form.quiz_title && !form.quiz_bank_id && form.quiz_questions.length === 0) { toast.error('Add at least one quiz question.'); return; }
     setSaving(true);
     try {
       const headers = await authHeaders();
@@ -384,6 +430,7 @@ const LessonForm = ({
         scheduled_start_time: form.scheduled_start_time ? fromLocalDatetimeInput(form.scheduled_start_time) : null
       };
       const res = await fetch(url, { method, headers, body: JSON.stringify(payload) });
+      if (res.status === 400) { toast.error(await res.text()); return; }
       if (!res.ok) throw new Error(await res.text());
       toast.success(mode === 'edit' ? 'Lesson updated!' : 'Lesson created!');
       onSave();
@@ -433,12 +480,68 @@ const LessonForm = ({
           </Field>
         </div>
         <Field label="Quiz Title (Optional)">
-          <div className="text-[10px] text-slate-400 mb-1 leading-tight">If provided, enables a 10-min quiz window before video playback.</div>
+          <div className="text-[10px] text-slate-400 mb-1 leading-tight">If provided, the quiz opens around the premiere start using the window below.</div>
           <input type="text" value={form.quiz_title} onChange={e => setForm({ ...form, quiz_title: e.target.value })} className={inputCls} placeholder="e.g. Class Assessment" />
         </Field>
         {form.quiz_title && (
-          <Field label="Quiz Question (Optional)">
-            <textarea value={form.quiz_question || ''} onChange={e => setForm({ ...form, quiz_question: e.target.value })} rows={2} className={`${inputCls} resize-none`} placeholder="e.g. What is your expectation for today's class?" />
+          <div className="grid grid-cols-2 md:grid-cols-3 gap-4">
+            <Field label="Opens (mins after start)">
+              <input type="number" value={form.quiz_policy.open_offset_minutes} onChange={e => setPolicy({ open_offset_minutes: Number(e.target.value) })} className={inputCls} />
+            </Field>
+            <Field label="Open For (mins)">
+              <input type="number" min={1} value={form.quiz_policy.duration_minutes} onChange={e => setPolicy({ duration_minutes: Number(e.target.value) })} className={inputCls} />
+            </Field>
+            <Field label="Max Attempts">
+              <input type="number" min={1} max={20} value={form.quiz_policy.max_attempts} onChange={e => setPolicy({ max_attempts: Number(e.target.value) })} className={inputCls} />
+            </Field>
+            <Field label="Score Counted">
+              <CustomDropdown
+                value={form.quiz_policy.attempt_scoring}
+                onChange={val => setPolicy({ attempt_scoring: val as QuizPolicy['attempt_scoring'] })}
+                options={[{ label: 'Best attempt', value: 'best' }, { label: 'Latest attempt', value: 'latest' }]}
+              />
+            </Field>
+            <Field label="Late Window (mins)">
+              <input type="number" min={0} value={form.quiz_policy.late_minutes} onChange={e => setPolicy({ late_minutes: Number(e.target.value) })} className={inputCls} placeholder="0 = no late submissions" />
+            </Field>
+            <Field label="Late Penalty (%)">
+              <input type="number" min={0} max={100} value={form.quiz_policy.late_penalty_percent} onChange={e => setPolicy({ late_penalty_percent: Number(e.target.value) })} className={inputCls} />
+            </Field>
+          </div>
+        )}
+        {form.quiz_title && (
+          <div className="grid grid-cols-2 gap-4">
+            <Field label="Questions From">
+              <CustomDropdown
+                value={form.quiz_bank_id}
+                onChange={val => setForm({ ...form, quiz_bank_id: String(val) })}
+                options={[
+                  { label: 'This quiz only', value: '' },
+                  ...banks.map(b => ({
+                    label: `${b.name}${b.topic ? ` · ${b.topic}` : ''} (${b.questions.length})`,
+                    value: b.id
+                  }))
+                ]}
+              />
+            </Field>
+            {form.quiz_bank_id && (
+              <Field label="Questions Per Learner">
+                <input type="number" min={1} value={form.quiz_draw_count} onChange={e => setForm({ ...form, quiz_draw_count: Number(e.target.value) })} className={inputCls} />
+              </Field>
+            )}
+          </div>
+        )}
+        {form.quiz_title && form.quiz_bank_id && (
+          <div className="text-[10px] text-slate-400 leading-tight">
+            Each learner gets their own random draw, with shuffled options, for every attempt.
+          </div>
+        )}
+        {form.quiz_title && !form.quiz_bank_id && (
+          <Field label="Quiz Questions">
+            <QuizQuestionsEditor
+              questions={form.quiz_questions}
+              onChange={quiz_questions => setForm({ ...form, quiz_questions })}
+            />
           </Field>
         )}
         <Field label="Description">
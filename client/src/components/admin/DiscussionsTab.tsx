@@ -68,7 +68,7 @@ export const DiscussionsTab = () => {
                 <div className="p-3 bg-red-500/10 rounded-2xl"><AlertTriangle size={24} /></div>
                 <h3 className="text-xl font-bold text-white">Delete Comment?</h3>
               </div>
-              <p className="text-slate-400 text-sm mb-6 pb-4 border-b border-white/5">Are you sure you want to remove this comment? This action is permanent.</p>
+              <p className="text-slate-400 text-sm mb-6 pb-4 border-b border-white/5">Are you sure you want to remove this comment? It will be moved to the trash.</p>
               <div className="flex justify-end gap-3 font-bold">
                 <button onClick={() => setDeleteModal({ isOpen: false, id: '', text: '', isDeleting: false })} className="px-5 py-2.5 rounded-xl text-slate-500 hover:text-white transition-colors">Cancel</button>
                 <button 
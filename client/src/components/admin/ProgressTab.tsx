@@ -19,6 +19,8 @@ export const ProgressTab = () => {
   } | null>(null);
   const [feedbackDraft, setFeedbackDraft] = useState('');
   const [isSavingFeedback, setIsSavingFeedback] = useState(false);
+  const [grantingId, setGrantingId] = useState<string | null>(null);
+  const [grantedIds, setGrantedIds] = useState<string[]>([]);
 
   useEffect(() => { setCurrentPage(1); }, [progressFilter, itemsPerPage]);
 
@@ -64,6 +66,20 @@ export const ProgressTab = () => {
     } catch (err) { console.error(err); } finally { setIsSavingFeedback(false); }
   };
 
+  // Gives the learner one more attempt at this quiz, reopened for them for 24 hours
+  const grantExtraAttempt = async (sub: any) => {
+    setGrantingId(sub.id);
+    try {
+      const session = await getAuthSession();
+      const res = await fetch(`${API_BASE_URL}/admin/submissions/${sub.id}/extra-attempt`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', 'Authorization': `Bearer ${session?.access_token}` },
+        body: JSON.stringify({ reopen_hours: 24 }),
+      });
+      if (res.ok) setGrantedIds(prev => [...prev, sub.id]);
+    } catch (err) { console.error(err); } finally { setGrantingId(null); }
+  };
+
   return (
     <motion.div initial={{ opacity: 0, y: 10 }} animate={{ opacity: 1, y: 0 }}>
 
@@ -175,6 +191,13 @@ export const ProgressTab = () => {
                               className="px-4 py-2 bg-emerald-500/10 text-emerald-400 hover:bg-emerald-500 hover:text-white rounded-lg transition-colors text-xs font-bold flex items-center gap-2 w-max">
                               {sub.score != null ? `${Math.round((sub.score / Math.max(1, sub.total_questions)) * 100)}% (${sub.score}/${sub.total_questions})` : 'Quiz Submitted'}
                             </button>
+                            {(sub.attempt > 1 || sub.is_late) && (
+                              <div className="mt-1.5 text-[11px] text-slate-500">
+                                Attempt {sub.attempt}
+                                {sub.is_late && <span className="text-amber-400"> · late (raw {sub.raw_score}/{sub.total_questions})</span>}
+                                {sub.counted && <span className="text-emerald-400"> · counts</span>}
+                              </div>
+                            )}
                           ) : isUrl(sub.submission_url) ? (
                             <a href={sub.submission_url} target="_blank" rel="noopener noreferrer"
                               className="px-4 py-2 bg-pink-500/10 text-pink-400 hover:bg-pink-500 hover:text-white rounded-lg transition-colors inline-block text-xs font-bold">
@@ -189,7 +212,19 @@ export const ProgressTab = () => {
                         </td>
                         <td className="p-5">
                           {sub.type === 'quiz' ? (
-                            <span className="px-3 py-1.5 rounded-lg text-xs font-bold text-slate-500 bg-white/5 border border-white/5">Auto-Graded</span>
+                            <div className="flex flex-wrap items-center gap-2">
+                              <span className="px-3 py-1.5 rounded-lg text-xs font-bold text-slate-500 bg-white/5 border border-white/5">Auto-Graded</span>
+                              {sub.counted && (
+                                <button
+                                  onClick={() => grantExtraAttempt(sub)}
+                                  disabled={grantingId === sub.id || grantedIds.includes(sub.id)}
+                                  title="Allow one more attempt and reopen the quiz for this learner for 24 hours"
+                                  className="px-3 py-1.5 rounded-lg text-xs font-bold bg-white/5 text-slate-400 hover:bg-white/10 hover:text-white transition-colors disabled:opacity-50"
+                                >
+                                  {grantedIds.includes(sub.id) ? 'Attempt Granted' : '+1 Attempt'}
+                                </button>
+                              )}
+                            </div>
                           ) : (
                             <button
                               onClick={() => openFeedbackModal(sub)}
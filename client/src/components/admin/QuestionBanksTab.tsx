@@ -0,0 +1,184 @@
+import React, { useState, useEffect } from 'react';
+import { Library, PlusCircle, Pencil, Trash2, Save, RotateCcw, Loader2 } from 'lucide-react';
+import { API_BASE_URL } from '../../config';
+import { getAuthSession, fetchLMS } from '../../lib/api';
+import { CustomDropdown } from './CustomDropdown';
+import { QuizQuestionsEditor, blankQuestion, type QuizQuestion } from './QuizQuestionsEditor';
+
+interface QuestionBank {
+  id: string;
+  name: string;
+  module_id: string | null;
+  module_title: string | null;
+  program_name: string | null;
+  topic: string;
+  questions: QuizQuestion[];
+  quiz_count: number;
+}
+
+interface ModuleOption {
+  id: string;
+  program_name: string;
+  title: string;
+}
+
+interface BankDraft {
+  id?: string;
+  name: string;
+  module_id: string;
+  topic: string;
+  questions: QuizQuestion[];
+}
+
+const inputCls = 'w-full bg-white/5 border border-white/8 rounded-xl px-4 py-2.5 text-sm text-white placeholder:text-slate-600 focus:outline-none focus:border-indigo-500/60 transition-colors';
+
+async function authHeaders() {
+  const session = await getAuthSession();
+  return { 'Content-Type': 'application/json', Authorization: `Bearer ${session?.access_token}` };
+}
+
+// Reusable pools of quiz questions; a lesson quiz can draw a random set from one per learner
+export const QuestionBanksTab = () => {
+  const [banks, setBanks] = useState<QuestionBank[]>([]);
+  const [modules, setModules] = useState<ModuleOption[]>([]);
+  const [isLoading, setIsLoading] = useState(true);
+  const [moduleFilter, setModuleFilter] = useState('');
+  const [topicFilter, setTopicFilter] = useState('');
+  const [draft, setDraft] = useState<BankDraft | null>(null);
+  const [isSaving, setIsSaving] = useState(false);
+  const [error, setError] = useState('');
+
+  const loadBanks = async () => {
+    try {
+      const [bankData, moduleData] = await Promise.all([
+        fetchLMS('/admin/question-banks'),
+        fetchLMS('/admin/modules'),
+      ]);
+      setBanks(bankData || []);
+      setModules(moduleData || []);
+    } catch (err) {
+      console.error("Failed to load question banks:", err);
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  useEffect(() => { loadBanks(); }, []);
+
+  const startEdit = (bank?: QuestionBank) => {
+    setError('');
+    setDraft(bank
+      ? { id: bank.id, name: bank.name, module_id: bank.module_id || '', topic: bank.topic, questions: bank.questions }
+      : { name: '', module_id: moduleFilter, topic: '', questions: [blankQuestion()] });
+  };
+
+  const handleSave = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!draft) return;
+    setIsSaving(true);
+    setError('');
+    try {
+      const res = await fetch(`${API_BASE_URL}/admin/question-banks${draft.id ? `?id=${draft.id}` : ''}`, {
+        method: draft.id ? 'PUT' : 'POST',
+        headers: await authHeaders(),
+        body: JSON.stringify({ name: draft.name, module_id: draft.module_id, topic: draft.topic, questions: draft.questions }),
+      });
+      if (!res.ok) { setError(await res.text()); return; }
+      setDraft(null);
+      await loadBanks();
+    } catch (err) {
+      setError('Failed to save the question bank. Please retry.');
+    } finally {
+      setIsSaving(false);
+    }
+  };
+
+  const handleDelete = async (bank: QuestionBank) => {
+    if (!window.confirm(`Delete "${bank.name}" and its ${bank.questions.length} questions? This cannot be undone.`)) return;
+    const res = await fetch(`${API_BASE_URL}/admin/question-banks?id=${bank.id}`, { method: 'DELETE', headers: await authHeaders() });
+    if (!res.ok) { alert(await res.text()); return; }
+    setBanks(prev => prev.filter(b => b.id !== bank.id));
+  };
+
+  const visibleBanks = banks.filter(b =>
+    (!moduleFilter || b.module_id === moduleFilter) &&
+    (!topicFilter.trim() || b.topic.toLowerCase().includes(topicFilter.trim().toLowerCase())));
+  const moduleOptions = modules.map(m => ({ label: `${m.program_name} · ${m.title}`, value: m.id }));
+
+  return (
+    <div className="space-y-6">
+      <div className="flex flex-col md:flex-row md:items-center justify-between gap-4">
+        <div>
+          <h2 className="text-2xl font-bold text-white flex items-center gap-3">
+            <Library className="text-pink-500" size={24} />
+            Question Banks
+          </h2>
+          <p className="text-slate-400 text-sm">Lesson quizzes can draw a different random set from a bank for every learner and attempt.</p>
+        </div>
+        <div className="flex items-center gap-3">
+          <input type="text" value={topicFilter} onChange={e => setTopicFilter(e.target.value)} className={`${inputCls} md:w-44`} placeholder="Filter by topic" />
+          <CustomDropdown
+            value={moduleFilter}
+            onChange={val => setModuleFilter(String(val))}
+            options={[{ label: 'All modules', value: '' }, ...moduleOptions]}
+          />
+          <button onClick={() => startEdit()} className="flex items-center gap-2 px-4 py-2.5 bg-pink-600 hover:bg-pink-500 text-white text-sm font-bold rounded-xl transition-colors shrink-0">
+            <PlusCircle size={15} /> Bank
+          </button>
+        </div>
+      </div>
+
+      {draft && (
+        <form onSubmit={handleSave} className="bg-white/5 border border-white/10 rounded-2xl p-6 space-y-4">
+          <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+            <input required type="text" value={draft.name} onChange={e => setDraft({ ...draft, name: e.target.value })} className={inputCls} placeholder="Bank name" />
+            <CustomDropdown
+              value={draft.module_id}
+              onChange={val => setDraft({ ...draft, module_id: String(val) })}
+              options={[{ label: 'No module', value: '' }, ...moduleOptions]}
+            />
+            <input type="text" value={draft.topic} onChange={e => setDraft({ ...draft, topic: e.target.value })} className={inputCls} placeholder="Topic, e.g. Communication" />
+          </div>
+          <QuizQuestionsEditor questions={draft.questions} onChange={questions => setDraft({ ...draft, questions })} />
+          {error && <p className="text-sm text-red-400">{error}</p>}
+          <div className="flex gap-3">
+            <button type="button" onClick={() => setDraft(null)} className="flex items-center gap-2 px-4 py-2.5 rounded-xl border border-white/10 text-slate-400 text-sm font-semibold hover:bg-white/5 transition-colors">
+              <RotateCcw size={14} /> Cancel
+            </button>
+            <button type="submit" disabled={isSaving} className="flex-1 flex items-center justify-center gap-2 py-2.5 bg-pink-600 hover:bg-pink-500 text-white text-sm font-bold rounded-xl transition-colors disabled:opacity-60">
+              {isSaving ? <Loader2 size={14} className="animate-spin" /> : <Save size={14} />} {draft.id ? 'Update Bank' : 'Create Bank'}
+            </button>
+          </div>
+        </form>
+      )}
+
+      {isLoading ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-6 h-40 animate-pulse" />
+      ) : visibleBanks.length === 0 ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-12 text-center text-slate-500">No question banks yet.</div>
+      ) : (
+        <div className="space-y-3">
+          {visibleBanks.map(bank => (
+            <div key={bank.id} className="bg-white/5 border border-white/5 rounded-2xl p-5 flex items-center justify-between gap-4">
+              <div className="min-w-0">
+                <p className="text-white font-bold truncate">{bank.name}</p>
+                <p className="text-xs text-slate-500">
+                  {bank.module_title ? `${bank.program_name} · ${bank.module_title}` : 'No module'}
+                  {bank.topic && ` · ${bank.topic}`}
+                  {` · ${bank.questions.length} questions`}
+                  {bank.quiz_count > 0 && ` · used by ${bank.quiz_count} ${bank.quiz_count === 1 ? 'quiz' : 'quizzes'}`}
+                </p>
+              </div>
+              <div className="flex items-center gap-2 shrink-0">
+                <button onClick={() => startEdit(bank)} className="p-2 rounded-lg text-slate-400 hover:text-white hover:bg-white/10 transition-colors"><Pencil size={15} /></button>
+                <button onClick={() => handleDelete(bank)} disabled={bank.quiz_count > 0} title={bank.quiz_count > 0 ? 'In use by a quiz' : 'Delete'} className="p-2 rounded-lg text-slate-400 hover:text-red-400 hover:bg-red-500/10 transition-colors disabled:opacity-30">
+                  <Trash2 size={15} />
+                </button>
+              </div>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+};
@@ -0,0 +1,345 @@
+import React, { useState, useEffect, useCallback } from 'react';
+import { BarChart3, ArrowLeft, AlertTriangle, Clock, Users, CheckCircle2, RotateCcw } from 'lucide-react';
+import { fetchLMS, postLMS } from '../../lib/api';
+import { CustomDropdown } from './CustomDropdown';
+
+interface QuizSummary {
+  lesson_id: string;
+  lesson_title: string;
+  module_title: string;
+  program_name: string;
+  quiz_title: string;
+  learners: number;
+  average_percent: number | null;
+  below_threshold: number;
+}
+
+interface TimeStats {
+  samples: number;
+  median_seconds: number;
+  p90_seconds: number;
+  average_seconds: number;
+}
+
+interface QuestionStats {
+  id: string;
+  kind: string;
+  prompt: string;
+  shown: number;
+  answered: number;
+  graded: number;
+  correct: number;
+  correct_rate: number | null;
+  answers: { answer: string; count: number; correct: boolean }[];
+}
+
+interface CohortStats {
+  cohort_id: string | null;
+  cohort_name: string;
+  learners: number;
+  average_percent: number | null;
+  time_to_submit: TimeStats | null;
+}
+
+interface LearnerScore {
+  user_id: string;
+  name: string;
+  email: string;
+  cohort_name: string | null;
+  score: number;
+  total_questions: number;
+  percent: number;
+  attempts: number;
+}
+
+interface QuizAnalytics {
+  quiz_title: string;
+  lesson_title: string;
+  learners: number;
+  attempts: number;
+  average_percent: number | null;
+  median_percent: number | null;
+  score_distribution: { from: number; to: number; count: number }[];
+  time_to_submit: TimeStats | null;
+  questions: QuestionStats[];
+  cohorts: CohortStats[];
+  below_threshold: LearnerScore[];
+}
+
+const formatDuration = (seconds: number) => {
+  if (seconds < 60) return `${Math.round(seconds)}s`;
+  const m = Math.floor(seconds / 60);
+  return m < 60 ? `${m}m ${Math.round(seconds % 60)}s` : `${Math.floor(m / 60)}h ${m % 60}m`;
+};
+
+const rateColor = (rate: number | null) =>
+  rate === null ? 'bg-slate-500' : rate < 50 ? 'bg-red-500' : rate < 75 ? 'bg-amber-500' : 'bg-emerald-500';
+
+// Facilitator view of how each lesson's quiz went, down to single questions and answers
+export const QuizAnalyticsTab = () => {
+  const [quizzes, setQuizzes] = useState<QuizSummary[]>([]);
+  const [isLoading, setIsLoading] = useState(true);
+  const [threshold, setThreshold] = useState(50);
+  const [selected, setSelected] = useState<QuizSummary | null>(null);
+  const [cohortId, setCohortId] = useState('');
+  const [cohortOptions, setCohortOptions] = useState<{ label: string; value: string }[]>([]);
+  const [analytics, setAnalytics] = useState<QuizAnalytics | null>(null);
+  const [isLoadingDetail, setIsLoadingDetail] = useState(false);
+  const [grantEmail, setGrantEmail] = useState('');
+  const [grantStatus, setGrantStatus] = useState('');
+
+  useEffect(() => {
+    fetchLMS(`/admin/quiz-analytics?threshold=${threshold}`)
+      .then(data => setQuizzes(data || []))
+      .catch(err => console.error("Failed to load quiz analytics:", err))
+      .finally(() => setIsLoading(false));
+  }, [threshold]);
+
+  const loadDetail = useCallback(async (lessonId: string, cohort: string) => {
+    setIsLoadingDetail(true);
+    try {
+      const data: QuizAnalytics = await fetchLMS(`/admin/quiz-analytics/${lessonId}?threshold=${threshold}&cohort_id=${cohort}`);
+      setAnalytics(data);
+      // The unfiltered breakdown names every cohort that took the quiz
+      if (!cohort) {
+        setCohortOptions(data.cohorts.filter(c => c.cohort_id).map(c => ({ label: c.cohort_name, value: c.cohort_id as string })));
+      }
+    } catch (err) {
+      console.error("Failed to load quiz analytics:", err);
+    } finally {
+      setIsLoadingDetail(false);
+    }
+  }, [threshold]);
+
+  useEffect(() => {
+    if (selected) loadDetail(selected.lesson_id, cohortId);
+  }, [selected, cohortId, loadDetail]);
+
+  // One more attempt, reopened for 24 hours, for any learner, including those who never submitted
+  const grantAttempt = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!selected || !grantEmail.trim()) return;
+    setGrantStatus('');
+    try {
+      await postLMS('/admin/quiz-attempt-grants', { lesson_id: selected.lesson_id, email: grantEmail.trim(), reopen_hours: 24 });
+      setGrantStatus(`Extra attempt granted to ${grantEmail.trim()}.`);
+      setGrantEmail('');
+    } catch (err: any) {
+      setGrantStatus(err.message);
+    }
+  };
+
+  const openQuiz = (quiz: QuizSummary) => {
+    setAnalytics(null);
+    setCohortId('');
+    setGrantStatus('');
+    setSelected(quiz);
+  };
+
+  const thresholdInput = (
+    <label className="flex items-center gap-2 text-xs text-slate-400 shrink-0">
+      Flag below
+      <input
+        type="number" min={0} max={100} value={threshold}
+        onChange={e => setThreshold(Math.min(100, Math.max(0, Number(e.target.value))))}
+        className="w-16 bg-white/5 border border-white/8 rounded-lg px-2 py-1.5 text-sm text-white focus:outline-none focus:border-indigo-500/60"
+      />
+      %
+    </label>
+  );
+
+  if (!selected) {
+    return (
+      <div className="space-y-6">
+        <div className="flex flex-col md:flex-row md:items-center justify-between gap-4">
+          <div>
+            <h2 className="text-2xl font-bold text-white flex items-center gap-3">
+              <BarChart3 className="text-pink-500" size={24} />
+              Quiz Analytics
+            </h2>
+            <p className="text-slate-400 text-sm">Each learner's counted attempt, per lesson. Open a quiz to see which questions didn't land.</p>
+          </div>
+          {thresholdInput}
+        </div>
+
+        {isLoading ? (
+          <div className="bg-white/5 border border-white/5 rounded-2xl p-6 h-40 animate-pulse" />
+        ) : quizzes.length === 0 ? (
+          <div className="bg-white/5 border border-white/5 rounded-2xl p-12 text-center text-slate-500">No lesson quizzes yet.</div>
+        ) : (
+          <div className="bg-white/5 border border-white/5 rounded-2xl overflow-hidden">
+            <table className="w-full text-left text-sm">
+              <thead className="bg-black/20 text-slate-400 text-xs uppercase tracking-wider">
+                <tr>
+                  <th className="px-5 py-3">Lesson</th>
+                  <th className="px-5 py-3">Learners</th>
+                  <th className="px-5 py-3">Average</th>
+                  <th className="px-5 py-3">Below {threshold}%</th>
+                </tr>
+              </thead>
+              <tbody className="divide-y divide-white/5">
+                {quizzes.map(q => (
+                  <tr key={q.lesson_id} onClick={() => openQuiz(q)} className="hover:bg-white/5 cursor-pointer transition-colors">
+                    <td className="px-5 py-3">
+                      <p className="text-white font-semibold">{q.lesson_title}</p>
+                      <p className="text-xs text-slate-500">{q.program_name} · {q.module_title}</p>
+                    </td>
+                    <td className="px-5 py-3 text-slate-300">{q.learners}</td>
+                    <td className="px-5 py-3 text-slate-300">{q.average_percent === null ? '—' : `${q.average_percent}%`}</td>
+                    <td className={`px-5 py-3 font-bold ${q.below_threshold > 0 ? 'text-red-400' : 'text-slate-500'}`}>{q.below_threshold}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </div>
+    );
+  }
+
+  const maxBucket = Math.max(1, ...(analytics?.score_distribution.map(b => b.count) || []));
+
+  return (
+    <div className="space-y-6">
+      <div className="flex flex-col md:flex-row md:items-center justify-between gap-4">
+        <div>
+          <button onClick={() => setSelected(null)} className="flex items-center gap-1 text-xs text-slate-400 hover:text-white mb-2 transition-colors">
+            <ArrowLeft size={14} /> All quizzes
+          </button>
+          <h2 className="text-2xl font-bold text-white">{selected.lesson_title}</h2>
+          <p className="text-slate-400 text-sm">{selected.program_name} · {selected.module_title}</p>
+        </div>
+        <div className="flex items-center gap-3">
+          <CustomDropdown
+            value={cohortId}
+            onChange={val => setCohortId(String(val))}
+            options={[{ label: 'All cohorts', value: '' }, ...cohortOptions]}
+          />
+          {thresholdInput}
+        </div>
+      </div>
+
+      {!analytics || isLoadingDetail ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-6 h-60 animate-pulse" />
+      ) : (
+        <>
+          <div className="grid grid-cols-2 md:grid-cols-4 gap-4">
+            <div className="bg-white/5 border border-white/5 rounded-2xl p-5">
+              <p className="text-xs text-slate-500 flex items-center gap-1.5"><Users size={13} /> Learners</p>
+              <p className="text-2xl font-bold text-white">{analytics.learners}</p>
+              <p className="text-xs text-slate-500">{analytics.attempts} attempts</p>
+            </div>
+            <div className="bg-white/5 border border-white/5 rounded-2xl p-5">
+              <p className="text-xs text-slate-500 flex items-center gap-1.5"><CheckCircle2 size={13} /> Average</p>
+              <p className="text-2xl font-bold text-white">{analytics.average_percent === null ? '—' : `${analytics.average_percent}%`}</p>
+              <p className="text-xs text-slate-500">median {analytics.median_percent === null ? '—' : `${analytics.median_percent}%`}</p>
+            </div>
+            <div className="bg-white/5 border border-white/5 rounded-2xl p-5">
+              <p className="text-xs text-slate-500 flex items-center gap-1.5"><Clock size={13} /> Time to submit</p>
+              <p className="text-2xl font-bold text-white">{analytics.time_to_submit ? formatDuration(analytics.time_to_submit.median_seconds) : '—'}</p>
+              <p className="text-xs text-slate-500">{analytics.time_to_submit ? `median · 90% within ${formatDuration(analytics.time_to_submit.p90_seconds)}` : 'no timed attempts'}</p>
+            </div>
+            <div className="bg-white/5 border border-white/5 rounded-2xl p-5">
+              <p className="text-xs text-slate-500 flex items-center gap-1.5"><AlertTriangle size={13} /> Below {threshold}%</p>
+              <p className={`text-2xl font-bold ${analytics.below_threshold.length > 0 ? 'text-red-400' : 'text-white'}`}>{analytics.below_threshold.length}</p>
+            </div>
+          </div>
+
+          <div className="bg-white/5 border border-white/5 rounded-2xl p-5">
+            <h3 className="text-sm font-bold text-white mb-4">Score Distribution</h3>
+            <div className="flex items-end gap-2 h-32">
+              {analytics.score_distribution.map(b => (
+                <div key={b.from} className="flex-1 flex flex-col items-center justify-end h-full gap-1">
+                  <span className="text-[10px] text-slate-400">{b.count || ''}</span>
+                  <div className={`w-full rounded-t-md ${b.from < threshold ? 'bg-red-500/60' : 'bg-pink-500/60'}`} style={{ height: `${(b.count / maxBucket) * 100}%` }} />
+                  <span className="text-[10px] text-slate-500">{b.from}</span>
+                </div>
+              ))}
+            </div>
+          </div>
+
+          {!cohortId && analytics.cohorts.length > 1 && (
+            <div className="bg-white/5 border border-white/5 rounded-2xl p-5">
+              <h3 className="text-sm font-bold text-white mb-3">By Cohort</h3>
+              <div className="space-y-2">
+                {analytics.cohorts.map(c => (
+                  <div key={c.cohort_id || 'none'} className="flex items-center justify-between text-sm">
+                    <span className="text-slate-300">{c.cohort_name}</span>
+                    <span className="text-slate-500 text-xs">
+                      {c.learners} learners · {c.average_percent === null ? '—' : `${c.average_percent}%`} average
+                      {c.time_to_submit && ` · ${formatDuration(c.time_to_submit.median_seconds)} median`}
+                    </span>
+                  </div>
+                ))}
+              </div>
+            </div>
+          )}
+
+          <div className="space-y-3">
+            <h3 className="text-sm font-bold text-white">Questions <span className="text-slate-500 font-normal">(weakest first)</span></h3>
+            {analytics.questions.length === 0 && (
+              <div className="bg-white/5 border border-white/5 rounded-2xl p-8 text-center text-slate-500">No graded answers yet.</div>
+            )}
+            {analytics.questions.map(q => (
+              <div key={q.id} className="bg-white/5 border border-white/5 rounded-2xl p-5 space-y-3">
+                <div className="flex items-start justify-between gap-4">
+                  <p className="text-white font-semibold">{q.prompt}</p>
+                  <span className="text-xs text-slate-400 shrink-0">
+                    {q.correct_rate === null ? 'Free response' : `${q.correct_rate}% correct`} · {q.answered}/{q.shown} answered
+                  </span>
+                </div>
+                {q.correct_rate !== null && (
+                  <div className="h-1.5 bg-white/10 rounded-full overflow-hidden">
+                    <div className={`h-full ${rateColor(q.correct_rate)}`} style={{ width: `${q.correct_rate}%` }} />
+                  </div>
+                )}
+                <div className="space-y-1">
+                  {q.answers.map(a => (
+                    <div key={a.answer} className="flex items-center justify-between text-xs">
+                      <span className={a.correct ? 'text-emerald-400 font-semibold' : 'text-slate-400'}>{a.answer}</span>
+                      <span className="text-slate-500">{a.count}</span>
+                    </div>
+                  ))}
+                </div>
+              </div>
+            ))}
+          </div>
+
+          {analytics.below_threshold.length > 0 && (
+            <div className="bg-white/5 border border-red-500/20 rounded-2xl p-5">
+              <h3 className="text-sm font-bold text-white mb-3">Learners Below {threshold}%</h3>
+              <div className="divide-y divide-white/5">
+                {analytics.below_threshold.map(l => (
+                  <div key={l.user_id} className="flex items-center justify-between py-2 text-sm">
+                    <div>
+                      <p className="text-slate-200">{l.name}</p>
+                      <p className="text-xs text-slate-500">{l.email}{l.cohort_name && ` · ${l.cohort_name}`}</p>
+                    </div>
+                    <span className="text-red-400 font-bold text-xs">
+                      {l.score}/{l.total_questions} ({l.percent}%) · {l.attempts} {l.attempts === 1 ? 'attempt' : 'attempts'}
+                    </span>
+                  </div>
+                ))}
+              </div>
+            </div>
+          )}
+
+          <form onSubmit={grantAttempt} className="bg-white/5 border border-white/5 rounded-2xl p-5 space-y-3">
+            <h3 className="text-sm font-bold text-white">Grant an Extra Attempt</h3>
+            <p className="text-xs text-slate-500">Reopens this quiz for the learner for 24 hours, even if they missed the window.</p>
+            <div className="flex gap-3">
+              <input
+                type="email" required value={grantEmail} onChange={e => setGrantEmail(e.target.value)} placeholder="Learner email"
+                className="flex-1 bg-white/5 border border-white/8 rounded-xl px-4 py-2.5 text-sm text-white placeholder:text-slate-600 focus:outline-none focus:border-indigo-500/60"
+              />
+              <button type="submit" className="flex items-center gap-2 px-4 py-2.5 bg-pink-600 hover:bg-pink-500 text-white text-sm font-bold rounded-xl transition-colors">
+                <RotateCcw size={14} /> Grant
+              </button>
+            </div>
+            {grantStatus && <p className="text-xs text-slate-400">{grantStatus}</p>}
+          </form>
+        </>
+      )}
+    </div>
+  );
+};
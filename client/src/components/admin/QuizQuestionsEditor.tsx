@@ -0,0 +1,152 @@
+import { PlusCircle, Trash2, X } from 'lucide-react';
+import { CustomDropdown } from './CustomDropdown';
+
+// Shared by lesson quizzes (Curriculum tab) and question banks
+
+export type QuizKind = 'single_choice' | 'multiple_choice' | 'true_false' | 'short_answer';
+
+// answer_key holds the correct option(s), or accepted short answers (empty = not scored)
+export interface QuizQuestion {
+  id?: string;
+  kind: QuizKind;
+  prompt: string;
+  options: string[];
+  answer_key: string[];
+}
+
+const inputCls = 'w-full bg-white/5 border border-white/8 rounded-xl px-4 py-2.5 text-sm text-white placeholder:text-slate-600 focus:outline-none focus:border-indigo-500/60 transition-colors';
+
+const QUIZ_KINDS: { label: string; value: QuizKind }[] = [
+  { label: 'Single choice', value: 'single_choice' },
+  { label: 'Multiple choice', value: 'multiple_choice' },
+  { label: 'True / False', value: 'true_false' },
+  { label: 'Short answer', value: 'short_answer' },
+];
+
+export const blankQuestion = (kind: QuizKind = 'single_choice'): QuizQuestion => ({
+  kind,
+  prompt: '',
+  options: kind === 'true_false' ? ['True', 'False'] : kind === 'short_answer' ? [] : ['', ''],
+  answer_key: [],
+});
+
+export const QuizQuestionsEditor = ({
+  questions, onChange,
+}: {
+  questions: QuizQuestion[];
+  onChange: (questions: QuizQuestion[]) => void;
+}) => {
+  const update = (idx: number, patch: Partial<QuizQuestion>) =>
+    onChange(questions.map((q, i) => (i === idx ? { ...q, ...patch } : q)));
+
+  const changeKind = (idx: number, kind: QuizKind) => {
+    const fresh = blankQuestion(kind);
+    const q = questions[idx];
+    // Keep the options when switching between the two choice kinds
+    const keepOptions = kind.endsWith('_choice') && q.kind.endsWith('_choice');
+    update(idx, {
+      kind,
+      options: keepOptions ? q.options : fresh.options,
+      answer_key: keepOptions && kind === 'multiple_choice' ? q.answer_key : [],
+    });
+  };
+
+  const toggleCorrect = (idx: number, opt: string) => {
+    const q = questions[idx];
+    if (q.kind !== 'multiple_choice') { update(idx, { answer_key: [opt] }); return; }
+    update(idx, {
+      answer_key: q.answer_key.includes(opt) ? q.answer_key.filter(a => a !== opt) : [...q.answer_key, opt],
+    });
+  };
+
+  const editOption = (idx: number, optIdx: number, value: string) => {
+    const q = questions[idx];
+    const old = q.options[optIdx];
+    update(idx, {
+      options: q.options.map((o, i) => (i === optIdx ? value : o)),
+      answer_key: q.answer_key.map(a => (a === old ? value : a)),
+    });
+  };
+
+  const removeOption = (idx: number, optIdx: number) => {
+    const q = questions[idx];
+    update(idx, {
+      options: q.options.filter((_, i) => i !== optIdx),
+      answer_key: q.answer_key.filter(a => a !== q.options[optIdx]),
+    });
+  };
+
+  return (
+    <div className="space-y-3">
+      {questions.map((q, idx) => (
+        <div key={q.id || idx} className="space-y-3 p-4 rounded-xl border border-white/8 bg-white/[0.02]">
+          <div className="flex items-center gap-2">
+            <span className="text-[10px] font-bold text-slate-500 uppercase tracking-widest shrink-0">Q{idx + 1}</span>
+            <CustomDropdown
+              value={q.kind}
+              onChange={val => changeKind(idx, val as QuizKind)}
+              options={QUIZ_KINDS}
+              className="flex-1"
+            />
+            <button type="button" onClick={() => onChange(questions.filter((_, i) => i !== idx))} className="p-2 rounded-lg text-slate-500 hover:text-red-400 hover:bg-red-500/10 transition-colors">
+              <Trash2 size={14} />
+            </button>
+          </div>
+          <input required type="text" value={q.prompt} onChange={e => update(idx, { prompt: e.target.value })} className={inputCls} placeholder="Question prompt" />
+
+          {q.kind === 'short_answer' ? (
+            <>
+              <div className="text-[10px] text-slate-400 leading-tight">Accepted answers, one per line. Matching ignores case and spacing. Leave empty for an unscored free response.</div>
+              <textarea
+                value={q.answer_key.join('\n')}
+                onChange={e => update(idx, { answer_key: e.target.value.split('\n') })}
+                rows={2}
+                className={`${inputCls} resize-none`}
+              />
+            </>
+          ) : (
+            <div className="space-y-2">
+              <div className="text-[10px] text-slate-400 leading-tight">
+                {q.kind === 'multiple_choice' ? 'Tick every correct option.' : 'Select the correct option.'}
+              </div>
+              {q.options.map((opt, optIdx) => (
+                <div key={optIdx} className="flex items-center gap-2">
+                  <input
+                    type={q.kind === 'multiple_choice' ? 'checkbox' : 'radio'}
+                    name={`correct-${idx}`}
+                    checked={opt !== '' && q.answer_key.includes(opt)}
+                    onChange={() => toggleCorrect(idx, opt)}
+                    disabled={opt === ''}
+                    className="accent-pink-500 shrink-0"
+                  />
+                  <input
+                    required
+                    type="text"
+                    value={opt}
+                    readOnly={q.kind === 'true_false'}
+                    onChange={e => editOption(idx, optIdx, e.target.value)}
+                    className={inputCls}
+                    placeholder={`Option ${optIdx + 1}`}
+                  />
+                  {q.kind !== 'true_false' && q.options.length > 2 && (
+                    <button type="button" onClick={() => removeOption(idx, optIdx)} className="p-2 rounded-lg text-slate-500 hover:text-white transition-colors">
+                      <X size={14} />
+                    </button>
+                  )}
+                </div>
+              ))}
+              {q.kind !== 'true_false' && (
+                <button type="button" onClick={() => update(idx, { options: [...q.options, ''] })} className="text-xs font-semibold text-indigo-300 hover:text-indigo-200">
+                  + Add option
+                </button>
+              )}
+            </div>
+          )}
+        </div>
+      ))}
+      <button type="button" onClick={() => onChange([...questions, blankQuestion()])} className="flex items-center gap-2 text-xs font-semibold text-pink-400 hover:text-pink-300">
+        <PlusCircle size={14} /> Add question
+      </button>
+    </div>
+  );
+};
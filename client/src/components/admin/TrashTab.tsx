@@ -0,0 +1,115 @@
+import { useState, useEffect } from 'react';
+import { Trash2, RotateCcw, Calendar, Loader2 } from 'lucide-react';
+import { fetchLMS, postLMS } from '../../lib/api';
+
+interface TrashItem {
+  type: 'user' | 'module' | 'lesson' | 'comment';
+  id: string;
+  source?: string;
+  label: string;
+  detail: string;
+  deleted_at: string;
+  purge_at: string | null;
+}
+
+const TYPE_LABELS: Record<TrashItem['type'], string> = {
+  user: 'Participant',
+  module: 'Module',
+  lesson: 'Lesson',
+  comment: 'Comment',
+};
+
+export const TrashTab = () => {
+  const [items, setItems] = useState<TrashItem[]>([]);
+  const [isLoading, setIsLoading] = useState(true);
+  const [filter, setFilter] = useState<'' | TrashItem['type']>('');
+  const [restoringId, setRestoringId] = useState<string | null>(null);
+
+  const loadTrash = async () => {
+    try {
+      const data = await fetchLMS('/admin/trash');
+      setItems(data || []);
+    } catch (err) {
+      console.error("Failed to load trash:", err);
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  useEffect(() => { loadTrash(); }, []);
+
+  const handleRestore = async (item: TrashItem) => {
+    setRestoringId(item.id);
+    try {
+      await postLMS('/admin/trash/restore', { type: item.type, id: item.id, source: item.source });
+      setItems(prev => prev.filter(i => !(i.type === item.type && i.id === item.id)));
+    } catch (err) {
+      alert("Failed to restore item.");
+    } finally {
+      setRestoringId(null);
+    }
+  };
+
+  const formatDate = (dateStr: string) => new Intl.DateTimeFormat('en-GB', { day: '2-digit', month: 'short', year: 'numeric' }).format(new Date(dateStr));
+
+  const visibleItems = items.filter(i => !filter || i.type === filter);
+
+  return (
+    <div className="space-y-6">
+      <div className="flex flex-col md:flex-row md:items-center justify-between gap-4">
+        <div>
+          <h2 className="text-2xl font-bold text-white flex items-center gap-3">
+            <Trash2 className="text-pink-500" size={24} />
+            Trash
+          </h2>
+          <p className="text-slate-400 text-sm">Deleted participants, curriculum and comments can be restored until they are purged.</p>
+        </div>
+        <select
+          value={filter}
+          onChange={(e) => setFilter(e.target.value as typeof filter)}
+          className="bg-white/5 border border-white/10 rounded-xl px-4 py-2 text-sm text-white focus:outline-none focus:border-pink-500/50"
+        >
+          <option value="">All items</option>
+          {Object.entries(TYPE_LABELS).map(([value, label]) => <option key={value} value={value}>{label}s</option>)}
+        </select>
+      </div>
+
+      {isLoading ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-6 h-40 animate-pulse" />
+      ) : visibleItems.length === 0 ? (
+        <div className="bg-white/5 border border-white/5 rounded-2xl p-12 text-center flex flex-col items-center justify-center space-y-3">
+          <div className="w-16 h-16 bg-white/5 rounded-full flex items-center justify-center text-slate-600">
+            <Trash2 size={32} />
+          </div>
+          <p className="text-slate-400 font-medium">The trash is empty.</p>
+        </div>
+      ) : (
+        <div className="bg-white/5 border border-white/5 rounded-2xl divide-y divide-white/5">
+          {visibleItems.map(item => (
+            <div key={`${item.type}-${item.id}`} className="flex flex-col sm:flex-row sm:items-center justify-between gap-3 p-4">
+              <div className="min-w-0 space-y-1">
+                <div className="flex items-center gap-2">
+                  <span className="text-[10px] font-bold text-slate-500 uppercase tracking-widest bg-white/5 px-2 py-1 rounded">{TYPE_LABELS[item.type]}</span>
+                  <span className="text-white font-bold text-sm truncate">{item.label}</span>
+                </div>
+                <p className="text-slate-400 text-xs truncate">{item.detail}</p>
+                <div className="flex items-center gap-1 text-[10px] text-slate-500">
+                  <Calendar size={10} />
+                  Deleted {formatDate(item.deleted_at)}{item.purge_at && <> · purged {formatDate(item.purge_at)}</>}
+                </div>
+              </div>
+              <button
+                onClick={() => handleRestore(item)}
+                disabled={restoringId === item.id}
+                className="flex items-center gap-2 px-4 py-2 bg-pink-500/10 hover:bg-pink-500/20 text-pink-400 text-sm font-bold rounded-xl transition-all disabled:opacity-50 shrink-0"
+              >
+                {restoringId === item.id ? <Loader2 size={16} className="animate-spin" /> : <RotateCcw size={16} />}
+                Restore
+              </button>
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+};
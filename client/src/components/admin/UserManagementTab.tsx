@@ -2,7 +2,7 @@ import { useState, useEffect, useMemo } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { Trash2, AlertTriangle, Filter, ChevronLeft, ChevronRight, UserPlus, X, Save, Phone, Instagram, Users, Search, RotateCcw, Book } from 'lucide-react';
 import { API_BASE_URL } from '../../config';
-import { getAuthSession } from '../../lib/api';
+import { getAuthSession, postLMS } from '../../lib/api';
 import { CustomDropdown } from './CustomDropdown';
 
 export const UserManagementTab = () => {
@@ -35,6 +35,7 @@ export const UserManagementTab = () => {
   const [selectedUser, setSelectedUser] = useState<any | null>(null);
   const [modules, setModules] = useState<any[]>([]);
   const [resetModal, setResetModal] = useState({ isOpen: false, type: 'all', moduleId: '', isResetting: false });
+  const [emailEdit, setEmailEdit] = useState<{ value: string, isSaving: boolean } | null>(null);
 
   useEffect(() => { setCurrentPage(1); }, [userFilter, statusFilter, itemsPerPage, searchTerm]);
 
@@ -114,6 +115,22 @@ export const UserManagementTab = () => {
     }
   };
 
+  // A claimed user's login moves with the email; an unclaimed registration just gets corrected
+  const handleChangeEmail = async () => {
+    if (!emailEdit || !selectedUser) return;
+    setEmailEdit({ ...emailEdit, isSaving: true });
+    try {
+      await postLMS('/admin/users/email', { id: selectedUser.id, source: selectedUser.source, email: emailEdit.value.trim() });
+      const email = emailEdit.value.trim();
+      setUsers(prev => prev.map(u => u.id === selectedUser.id ? { ...u, email } : u));
+      setSelectedUser({ ...selectedUser, email });
+      setEmailEdit(null);
+    } catch (err) {
+      alert("Error changing email: " + err);
+      setEmailEdit(prev => prev && { ...prev, isSaving: false });
+    }
+  };
+
   const handleResetProgress = async () => {
     setResetModal(prev => ({ ...prev, isResetting: true }));
     try {
@@ -168,7 +185,7 @@ export const UserManagementTab = () => {
           <div className="fixed inset-0 z-[60] flex items-center justify-center p-4 bg-black/60 backdrop-blur-sm">
             <motion.div initial={{ scale: 0.95, opacity: 0 }} animate={{ scale: 1, opacity: 1 }} exit={{ scale: 0.95, opacity: 0 }} className="bg-[#13132b] border border-white/10 rounded-2xl p-6 max-w-md w-full shadow-2xl">
               <div className="flex items-center gap-4 mb-4 text-red-400"><div className="p-3 bg-red-500/10 rounded-full"><AlertTriangle size={24} /></div><h3 className="text-xl font-bold text-white">Delete User?</h3></div>
-              <p className="text-slate-300 mb-6">Delete <strong className="text-white">{deleteModal.userName}</strong>? This action cannot be undone.</p>
+              <p className="text-slate-300 mb-6">Delete <strong className="text-white">{deleteModal.userName}</strong>? They will be moved to the trash and can be restored from there.</p>
               <div className="flex gap-3 justify-end">
                 <button onClick={() => setDeleteModal({ isOpen: false, userId: '', source: '', userName: '', isDeleting: false })} className="px-5 py-2.5 rounded-xl text-sm font-bold text-slate-300 hover:bg-white/5">Cancel</button>
                 <button onClick={confirmDelete} disabled={deleteModal.isDeleting} className="px-5 py-2.5 rounded-xl text-sm font-bold bg-red-500 hover:bg-red-600 text-white">{deleteModal.isDeleting ? "Deleting..." : "Yes, Delete"}</button>
@@ -330,7 +347,7 @@ export const UserManagementTab = () => {
               <div className={`absolute top-0 left-0 right-0 h-32 bg-gradient-to-r ${selectedUser.source === 'Couples Launchpad' ? 'from-blue-600/20 to-indigo-600/10' : 'from-purple-600/20 to-pink-600/10'} opacity-50`} />
               
               <button 
-                onClick={() => setSelectedUser(null)}
+                onClick={() => { setSelectedUser(null); setEmailEdit(null); }}
                 className="absolute top-6 right-6 p-2 bg-black/20 hover:bg-black/40 text-slate-400 hover:text-white rounded-xl transition-all z-20"
               >
                 <X size={20} />
@@ -366,7 +383,18 @@ export const UserManagementTab = () => {
                          <div className="w-8 h-8 rounded-lg bg-blue-500/10 text-blue-400 flex items-center justify-center font-bold text-lg leading-none">@</div>
                          <div className="flex flex-col">
                            <span className="text-[10px] font-bold text-slate-500 uppercase tracking-widest">Email</span>
-                           <span className="text-sm text-slate-200">{selectedUser.email}</span>
+                           {emailEdit ? (
+                             <div className="flex items-center gap-2">
+                               <input type="email" autoFocus className="bg-white/5 border border-white/10 rounded-lg px-2 py-1 text-sm text-white focus:outline-none focus:border-blue-500/50" value={emailEdit.value} onChange={e => setEmailEdit({ ...emailEdit, value: e.target.value })} />
+                               <button onClick={handleChangeEmail} disabled={emailEdit.isSaving} className="p-1 text-green-400 hover:bg-green-500/10 rounded disabled:opacity-50"><Save size={14} /></button>
+                               <button onClick={() => setEmailEdit(null)} className="p-1 text-slate-500 hover:bg-white/5 rounded"><X size={14} /></button>
+                             </div>
+                           ) : (
+                             <span className="text-sm text-slate-200">
+                               {selectedUser.email}
+                               <button onClick={() => setEmailEdit({ value: selectedUser.email, isSaving: false })} className="ml-2 text-[10px] font-bold text-blue-400 hover:text-blue-300 uppercase tracking-widest">Change</button>
+                             </span>
+                           )}
                          </div>
                        </div>
                        <div className="flex items-center gap-3">
@@ -545,7 +573,7 @@ export const UserManagementTab = () => {
                         <td className="p-5 text-center text-slate-500 font-mono text-xs">{serialNum}</td>
                         <td className="p-5 font-medium text-white whitespace-nowrap">
                           <button 
-                            onClick={() => setSelectedUser(user)}
+                            onClick={() => { setSelectedUser(user); setEmailEdit(null); }}
                             className="hover:text-blue-400 transition-colors text-left font-bold border-b border-transparent hover:border-blue-400/30"
                           >
                             {user.full_name}
@@ -3,10 +3,14 @@ import { ChevronLeft } from 'lucide-react';
 
 interface RegistrationDataProps {
   onNext: (data: any) => void;
-  onBack: () => void;
+  onBack?: () => void;
+  initial?: Record<string, string>; // prefills the form, e.g. from a waitlist offer
+  lockEmail?: boolean;
+  submitLabel?: string;
+  errors?: Record<string, string>; // server-side validation messages keyed by field name
 }
 
-export const RegistrationData = ({ onNext, onBack }: RegistrationDataProps) => {
+export const RegistrationData = ({ onNext, onBack, initial, lockEmail, submitLabel, errors }: RegistrationDataProps) => {
 
   const handleSubmit = (e: React.FormEvent<HTMLFormElement>) => {
     e.preventDefault();
@@ -21,101 +25,115 @@ export const RegistrationData = ({ onNext, onBack }: RegistrationDataProps) => {
       animate={{ opacity: 1, y: 0 }}
       className="max-w-2xl w-full p-8 glass-card rounded-3xl space-y-6 max-h-[85vh] overflow-y-auto custom-scrollbar relative"
     >
-      <button
-        type="button"
-        onClick={onBack}
-        className="text-slate-400 hover:text-white flex items-center gap-1 text-sm transition-colors mb-2"
-      >
-        <ChevronLeft size={16} /> Back
-      </button>
+      {onBack && (
+        <button
+          type="button"
+          onClick={onBack}
+          className="text-slate-400 hover:text-white flex items-center gap-1 text-sm transition-colors mb-2"
+        >
+          <ChevronLeft size={16} /> Back
+        </button>
+      )}
 
       <div className="space-y-2">
         <h2 className="text-3xl font-bold text-white">Your Details</h2>
         <p className="text-slate-400">Please provide accurate information for your cohort placement.</p>
+        {errors && Object.keys(errors).length > 0 && <p className="text-sm text-red-400">Please correct the highlighted fields.</p>}
       </div>
 
       <form className="space-y-6" onSubmit={handleSubmit}>
         <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Full Name</label>
-            <input name="full_name" type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Enter name" required />
+            <input name="full_name" defaultValue={initial?.full_name} type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Enter name" required />
+            {errors?.full_name && <p className="text-xs text-red-400">{errors.full_name}</p>}
           </div>
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Email Address</label>
-            <input name="email" type="email" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="email@example.com" required />
+            <input name="email" defaultValue={initial?.email} readOnly={lockEmail} type="email" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="email@example.com" required />
+            {errors?.email && <p className="text-xs text-red-400">{errors.email}</p>}
           </div>
         </div>
 
         <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">WhatsApp Number</label>
-            <input name="whatsapp_number" type="tel" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="+234..." required />
+            <input name="whatsapp_number" defaultValue={initial?.whatsapp_number} type="tel" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="+234..." required />
+            {errors?.whatsapp_number && <p className="text-xs text-red-400">{errors.whatsapp_number}</p>}
           </div>
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Gender</label>
-            <select name="gender" className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
+            <select name="gender" defaultValue={initial?.gender} className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
               <option value="male">Male</option>
               <option value="female">Female</option>
             </select>
+            {errors?.gender && <p className="text-xs text-red-400">{errors.gender}</p>}
           </div>
         </div>
 
         <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Country</label>
-            <input name="country" type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Nigeria" required />
+            <input name="country" defaultValue={initial?.country} type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Nigeria" required />
+            {errors?.country && <p className="text-xs text-red-400">{errors.country}</p>}
           </div>
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Location / State</label>
-            <input name="state" type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Lagos" required />
+            <input name="state" defaultValue={initial?.state} type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Lagos" required />
+            {errors?.state && <p className="text-xs text-red-400">{errors.state}</p>}
           </div>
         </div>
 
         <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Age Group</label>
-            <select name="age_group" className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
+            <select name="age_group" defaultValue={initial?.age_group} className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
               <option value="18-25">18-25</option>
               <option value="26-30">26-30</option>
               <option value="31-35">31-35</option>
               <option value="36-40">36-40</option>
               <option value="40+">40 and above</option>
             </select>
+            {errors?.age_group && <p className="text-xs text-red-400">{errors.age_group}</p>}
           </div>
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Religion</label>
-            <select name="religion" className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
+            <select name="religion" defaultValue={initial?.religion} className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
               <option value="Christian">Christian</option>
               <option value="Muslim">Muslim</option>
               <option value="Traditional">Traditional Worshiper</option>
               <option value="Atheist">Atheist</option>
             </select>
+            {errors?.religion && <p className="text-xs text-red-400">{errors.religion}</p>}
           </div>
         </div>
 
         <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Instagram Handle</label>
-            <input name="instagram_handle" type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="@username" required />
+            <input name="instagram_handle" defaultValue={initial?.instagram_handle} type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="@username" required />
+            {errors?.instagram_handle && <p className="text-xs text-red-400">{errors.instagram_handle}</p>}
           </div>
           <div className="space-y-2">
             <label className="text-sm font-medium text-slate-300">Church Name (If Christian)</label>
-            <input name="church_name" type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Assembly name" />
+            <input name="church_name" defaultValue={initial?.church_name} type="text" className="w-full bg-white/5 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none transition-all text-white" placeholder="Assembly name" />
+            {errors?.church_name && <p className="text-xs text-red-400">{errors.church_name}</p>}
           </div>
         </div>
 
         <div className="space-y-2">
           <label className="text-sm font-medium text-slate-300">Relationship Status</label>
-          <select name="relationship_status" className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
+          <select name="relationship_status" defaultValue={initial?.relationship_status} className="w-full bg-slate-900 border border-white/10 rounded-xl p-3 focus:ring-2 focus:ring-indigo-500 outline-none text-white">
             <option value="single">Single</option>
             <option value="single-parent">Single Mum/Dad</option>
             <option value="divorced">Divorced</option>
             <option value="widowed">Widow/Widower</option>
           </select>
+          {errors?.relationship_status && <p className="text-xs text-red-400">{errors.relationship_status}</p>}
         </div>
 
         <button type="submit" className="w-full py-4 bg-indigo-600 text-white font-semibold rounded-xl hover:bg-indigo-500 transition-all shadow-lg shadow-indigo-500/20">
-          Continue to Final Verification
+          {submitLabel || 'Continue to Final Verification'}
         </button>
       </form>
     </motion.div>
@@ -1,4 +1,4 @@
-import { useState } from 'react';
+import { useState, useEffect } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { ArrowRight, ArrowLeft, AlertCircle } from 'lucide-react';
 
@@ -6,11 +6,39 @@ interface WizardProps {
   onSubmit: (data: any) => void;
   onReject: (msg: string) => void;
   onBack: () => void;
+  errors?: Record<string, string>; // server-side validation messages keyed by field name
 }
 
-export const LaunchpadWizard = ({ onSubmit, onReject, onBack }: WizardProps) => {
+// Which step each field is on, and how to name it when listing server errors
+const FIELDS: Record<string, { step: number; label: string }> = {
+  full_name: { step: 1, label: 'Full Name' },
+  gender: { step: 1, label: 'Gender' },
+  email: { step: 1, label: 'Email' },
+  whatsapp_number: { step: 1, label: 'WhatsApp Number' },
+  country_city: { step: 1, label: 'Country & City' },
+  religion: { step: 1, label: 'Religion' },
+  denomination: { step: 1, label: 'Denomination' },
+  referral_source: { step: 1, label: 'How you heard about us' },
+  instagram_handle: { step: 1, label: 'Instagram Handle' },
+  wedding_date: { step: 2, label: 'Wedding Date' },
+  partner_registered: { step: 2, label: 'Partner Registered' },
+  spouse_name: { step: 2, label: "Spouse's Name" },
+  spouse_whatsapp: { step: 2, label: "Spouse's WhatsApp" },
+  attended_before: { step: 3, label: 'Attended Before' },
+  agreed_to_feedback: { step: 3, label: 'Feedback' },
+  agreed_to_participation: { step: 3, label: 'Participation' },
+};
+
+export const LaunchpadWizard = ({ onSubmit, onReject, onBack, errors }: WizardProps) => {
   const [step, setStep] = useState(1);
 
+  // Send the user back to the earliest step with a rejected field
+  useEffect(() => {
+    if (!errors) return;
+    const steps = Object.keys(errors).map(f => FIELDS[f]?.step).filter(Boolean) as number[];
+    if (steps.length > 0) setStep(Math.min(...steps));
+  }, [errors]);
+
   // Track if they clicked the Instagram link
   const [hasClickedIG, setHasClickedIG] = useState(false);
   // NEW: State for the custom warning popup
@@ -71,22 +99,31 @@ export const LaunchpadWizard = ({ onSubmit, onReject, onBack }: WizardProps) =>
 
       <div className="space-y-6">
 
+        {errors && Object.keys(errors).length > 0 && (
+          <div className="p-4 rounded-xl bg-red-500/10 border border-red-500/30 text-sm text-red-200 space-y-1">
+            <p className="font-bold">Please correct the highlighted fields:</p>
+            <ul className="list-disc pl-5">
+              {Object.entries(errors).map(([field, msg]) => <li key={field}>{FIELDS[field]?.label || field}: {msg}</li>)}
+            </ul>
+          </div>
+        )}
+
         {/* --- STEP 1: PERSONAL --- */}
         {step === 1 && (
           <div className="space-y-4 animate-in fade-in slide-in-from-right-4 duration-300">
             <h2 className="text-2xl font-bold text-white">Personal Details</h2>
             <div className="grid grid-cols-2 gap-4">
-               <input name="full_name" placeholder="Full Name" onChange={handleChange} className="col-span-2 p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-               <select name="gender" onChange={handleChange} className="p-3 rounded-xl bg-white/5 border border-white/10 text-white [&>option]:text-black focus:ring-2 focus:ring-pink-500 outline-none">
+               <input name="full_name" defaultValue={formData.full_name} placeholder="Full Name" onChange={handleChange} className={`col-span-2 p-3 rounded-xl bg-white/5 border ${errors?.full_name ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+               <select name="gender" defaultValue={formData.gender} onChange={handleChange} className="p-3 rounded-xl bg-white/5 border border-white/10 text-white [&>option]:text-black focus:ring-2 focus:ring-pink-500 outline-none">
                  <option value="Female">Female</option>
                  <option value="Male">Male</option>
                </select>
-               <input name="email" type="email" placeholder="Email" onChange={handleChange} className="p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-               <input name="whatsapp_number" placeholder="WhatsApp Number" onChange={handleChange} className="col-span-2 p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-               <input name="country_city" placeholder="Country & City" onChange={handleChange} className="col-span-2 p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-               <input name="religion" placeholder="Religion" onChange={handleChange} className="p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-               <input name="denomination" placeholder="Denomination (if Christian)" onChange={handleChange} className="p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-               <input name="referral_source" placeholder="How did you hear about us?" onChange={handleChange} className="col-span-2 p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
+               <input name="email" defaultValue={formData.email} type="email" placeholder="Email" onChange={handleChange} className={`p-3 rounded-xl bg-white/5 border ${errors?.email ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+               <input name="whatsapp_number" defaultValue={formData.whatsapp_number} placeholder="WhatsApp Number" onChange={handleChange} className={`col-span-2 p-3 rounded-xl bg-white/5 border ${errors?.whatsapp_number ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+               <input name="country_city" defaultValue={formData.country_city} placeholder="Country & City" onChange={handleChange} className={`col-span-2 p-3 rounded-xl bg-white/5 border ${errors?.country_city ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+               <input name="religion" defaultValue={formData.religion} placeholder="Religion" onChange={handleChange} className={`p-3 rounded-xl bg-white/5 border ${errors?.religion ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+               <input name="denomination" defaultValue={formData.denomination} placeholder="Denomination (if Christian)" onChange={handleChange} className={`p-3 rounded-xl bg-white/5 border ${errors?.denomination ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+               <input name="referral_source" defaultValue={formData.referral_source} placeholder="How did you hear about us?" onChange={handleChange} className={`col-span-2 p-3 rounded-xl bg-white/5 border ${errors?.referral_source ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
             </div>
 
             <div className={`p-4 rounded-xl border transition-all duration-300 ${hasClickedIG ? 'bg-green-500/10 border-green-500/30' : 'bg-indigo-500/20 border-indigo-500/30'} space-y-2`}>
@@ -99,7 +136,7 @@ export const LaunchpadWizard = ({ onSubmit, onReject, onBack }: WizardProps) =>
                 >
                   {hasClickedIG ? '✅ Link Clicked! Thank you.' : 'Click here to follow @coupleslaunchpad'}
                 </a>
-                <input name="instagram_handle" placeholder="Your Instagram Handle (@name)" onChange={handleChange} className="w-full mt-2 p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
+                <input name="instagram_handle" defaultValue={formData.instagram_handle} placeholder="Your Instagram Handle (@name)" onChange={handleChange} className={`w-full mt-2 p-3 rounded-xl bg-white/5 border ${errors?.instagram_handle ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
             </div>
           </div>
         )}
@@ -110,11 +147,11 @@ export const LaunchpadWizard = ({ onSubmit, onReject, onBack }: WizardProps) =>
             <h2 className="text-2xl font-bold text-white">Marriage Readiness</h2>
             <div className="space-y-4">
                 <label className="block text-sm text-slate-300">When is the Big Day?</label>
-                <input name="wedding_date" type="date" onChange={handleChange} className="w-full p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
+                <input name="wedding_date" defaultValue={formData.wedding_date} type="date" onChange={handleChange} className={`w-full p-3 rounded-xl bg-white/5 border ${errors?.wedding_date ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
 
                 <div className="space-y-2">
                     <p className="text-sm text-slate-300">Has your partner registered yet?</p>
-                    <select name="partner_registered" onChange={handleChange} className="w-full p-3 rounded-xl bg-white/5 border border-white/10 text-white [&>option]:text-black focus:ring-2 focus:ring-pink-500 outline-none">
+                    <select name="partner_registered" defaultValue={formData.partner_registered} onChange={handleChange} className="w-full p-3 rounded-xl bg-white/5 border border-white/10 text-white [&>option]:text-black focus:ring-2 focus:ring-pink-500 outline-none">
                         <option value="No">No, they are about to!</option>
                         <option value="Yes">Yes, they have registered.</option>
                     </select>
@@ -124,8 +161,8 @@ export const LaunchpadWizard = ({ onSubmit, onReject, onBack }: WizardProps) =>
                     NOTE: It takes two to build! Ensure your spouse registers immediately after you.
                 </div>
 
-                <input name="spouse_name" placeholder="Spouse's Full Name" onChange={handleChange} className="w-full p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
-                <input name="spouse_whatsapp" placeholder="Spouse's WhatsApp Number" onChange={handleChange} className="w-full p-3 rounded-xl bg-white/5 border border-white/10 text-white focus:ring-2 focus:ring-pink-500 outline-none" />
+                <input name="spouse_name" defaultValue={formData.spouse_name} placeholder="Spouse's Full Name" onChange={handleChange} className={`w-full p-3 rounded-xl bg-white/5 border ${errors?.spouse_name ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
+                <input name="spouse_whatsapp" defaultValue={formData.spouse_whatsapp} placeholder="Spouse's WhatsApp Number" onChange={handleChange} className={`w-full p-3 rounded-xl bg-white/5 border ${errors?.spouse_whatsapp ? 'border-red-500/60' : 'border-white/10'} text-white focus:ring-2 focus:ring-pink-500 outline-none`} />
             </div>
           </div>
         )}
@@ -137,17 +174,17 @@ export const LaunchpadWizard = ({ onSubmit, onReject, onBack }: WizardProps) =>
 
             <div className="space-y-4">
                 <label className="flex items-start gap-3 p-4 bg-white/5 rounded-xl cursor-pointer hover:bg-white/10">
-                    <input type="checkbox" name="attended_before" onChange={handleChange} className="mt-1 w-5 h-5 accent-pink-500" />
+                    <input type="checkbox" name="attended_before" defaultChecked={formData.attended_before} onChange={handleChange} className="mt-1 w-5 h-5 accent-pink-500" />
                     <span className="text-sm text-slate-300">I have attended Couples' Launchpad before.</span>
                 </label>
 
                 <label className="flex items-start gap-3 p-4 bg-white/5 rounded-xl cursor-pointer hover:bg-white/10">
-                    <input type="checkbox" name="agreed_to_feedback" onChange={handleChange} className="mt-1 w-5 h-5 accent-pink-500" />
+                    <input type="checkbox" name="agreed_to_feedback" defaultChecked={formData.agreed_to_feedback} onChange={handleChange} className="mt-1 w-5 h-5 accent-pink-500" />
                     <span className="text-sm text-slate-300">I agree to provide honest feedback during and after the cohort.</span>
                 </label>
 
                 <label className="flex items-start gap-3 p-4 bg-white/5 rounded-xl cursor-pointer hover:bg-white/10">
-                    <input type="checkbox" name="agreed_to_participation" onChange={handleChange} className="mt-1 w-5 h-5 accent-pink-500" />
+                    <input type="checkbox" name="agreed_to_participation" defaultChecked={formData.agreed_to_participation} onChange={handleChange} className="mt-1 w-5 h-5 accent-pink-500" />
                     <span className="text-sm text-slate-300">I agree to actively participate in sessions (Tue/Thu 8PM).</span>
                 </label>
             </div>
@@ -1,14 +1,18 @@
 import React, { useState } from 'react';
-import { useNavigate, Link } from 'react-router-dom';
+import { useNavigate, Link, useSearchParams } from 'react-router-dom';
 import { motion, AnimatePresence } from 'framer-motion';
 import { Mail, Lock, ArrowRight, Loader2, CheckCircle, KeyRound, Heart } from 'lucide-react';
 import { API_BASE_URL } from '../config';
 
 export default function ClaimAccountPage() {
   const navigate = useNavigate();
-  
+  const [searchParams] = useSearchParams();
+
+  // Set when arriving from the emailed RFASM claim link
+  const claimToken = searchParams.get('claim') || '';
+
   // Program Selection
-  const [activeTab, setActiveTab] = useState<'launchpad' | 'rfasm'>('launchpad');
+  const [activeTab, setActiveTab] = useState<'launchpad' | 'rfasm'>(claimToken ? 'rfasm' : 'launchpad');
 
   // Unified State
   const [email, setEmail] = useState('');
@@ -22,8 +26,10 @@ export default function ClaimAccountPage() {
   const [isLoading, setIsLoading] = useState(false);
   const [error, setError] = useState('');
   const [success, setSuccess] = useState(false);
+  const [linkSent, setLinkSent] = useState(false);
 
   // --- RFASM Flow ---
+  // Step 1 emails a claim link to prove the address is theirs; the link brings them back here to set a password
   const handleRFASMSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setIsLoading(true);
@@ -33,7 +39,29 @@ export default function ClaimAccountPage() {
       const response = await fetch(`${API_BASE_URL}/auth/claim`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ email, password })
+        body: JSON.stringify({ email })
+      });
+      const data = await response.json();
+      if (!response.ok) throw new Error(data.message || 'Failed to send claim link.');
+
+      setLinkSent(true);
+    } catch (err: any) {
+      setError(err.message);
+    } finally {
+      setIsLoading(false);
+    }
+  };
+
+  const handleRFASMRedeem = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setIsLoading(true);
+    setError('');
+
+    try {
+      const response = await fetch(`${API_BASE_URL}/auth/claim`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ claimToken, password })
       });
       const data = await response.json();
       if (!response.ok) throw new Error(data.message || 'Failed to claim account.');
@@ -140,6 +168,65 @@ export default function ClaimAccountPage() {
                 <h3 className="text-xl font-bold text-white">Account Claimed!</h3>
                 <p className="text-slate-400">You can now access the portal. Redirecting to login...</p>
               </motion.div>
+            ) : activeTab === 'rfasm' && claimToken ? (
+              <motion.form
+                key="rfasm-redeem"
+                initial={{ opacity: 0, x: 20 }}
+                animate={{ opacity: 1, x: 0 }}
+                exit={{ opacity: 0, x: -20 }}
+                onSubmit={handleRFASMRedeem}
+                className="space-y-6"
+              >
+                {error && (
+                  <div className="p-4 bg-red-500/10 border border-red-500/20 rounded-xl text-red-400 text-sm text-center">
+                    {error}
+                  </div>
+                )}
+                <p className="text-sm text-slate-400 text-center">Choose a password to finish claiming your account.</p>
+                <div className="space-y-2">
+                  <label className="text-sm font-bold text-slate-300 ml-1">Create Password</label>
+                  <div className="relative">
+                    <Lock className="absolute left-4 top-1/2 -translate-y-1/2 text-slate-500" size={20} />
+                    <input
+                      type="password"
+                      required
+                      minLength={6}
+                      value={password}
+                      onChange={(e) => setPassword(e.target.value)}
+                      className="w-full bg-[#1a1a3a] border border-white/10 rounded-xl py-3.5 pl-12 pr-4 text-white focus:outline-none focus:border-indigo-500 transition-colors"
+                      placeholder="Minimum 6 characters"
+                    />
+                  </div>
+                </div>
+                <button
+                  type="submit"
+                  disabled={isLoading}
+                  className="w-full bg-gradient-to-r from-indigo-500 to-blue-600 text-white font-bold rounded-xl py-4 flex items-center justify-center gap-2 hover:scale-[1.02] transition-transform disabled:opacity-70 disabled:hover:scale-100 shadow-lg shadow-indigo-500/25"
+                >
+                  {isLoading ? <Loader2 className="animate-spin" /> : 'Claim Account'}
+                  {!isLoading && <ArrowRight size={20} />}
+                </button>
+              </motion.form>
+            ) : activeTab === 'rfasm' && linkSent ? (
+              <motion.div
+                key="rfasm-sent"
+                initial={{ scale: 0.9, opacity: 0 }}
+                animate={{ scale: 1, opacity: 1 }}
+                className="text-center py-8 space-y-4"
+              >
+                <div className="w-16 h-16 bg-indigo-500/20 text-indigo-400 rounded-full flex items-center justify-center mx-auto mb-4">
+                  <Mail size={32} />
+                </div>
+                <h3 className="text-xl font-bold text-white">Check Your Inbox</h3>
+                <p className="text-slate-400">We sent a link to <strong className="text-white">{email}</strong>. Open it within an hour to choose your password.</p>
+                <button
+                  type="button"
+                  onClick={() => setLinkSent(false)}
+                  className="text-slate-400 hover:text-white text-sm font-bold py-2 transition-colors"
+                >
+                  Use a different email
+                </button>
+              </motion.div>
             ) : activeTab === 'rfasm' ? (
               <motion.form 
                 key="rfasm"
@@ -156,6 +243,7 @@ export default function ClaimAccountPage() {
                 )}
                 <div className="space-y-2">
                   <label className="text-sm font-bold text-slate-300 ml-1">Your Registered Email</label>
+                  <p className="text-xs text-slate-400 ml-1 mb-2">We will email you a link to confirm it's yours.</p>
                   <div className="relative">
                     <Mail className="absolute left-4 top-1/2 -translate-y-1/2 text-slate-500" size={20} />
                     <input
@@ -168,27 +256,12 @@ export default function ClaimAccountPage() {
                     />
                   </div>
                 </div>
-                <div className="space-y-2">
-                  <label className="text-sm font-bold text-slate-300 ml-1">Create Password</label>
-                  <div className="relative">
-                    <Lock className="absolute left-4 top-1/2 -translate-y-1/2 text-slate-500" size={20} />
-                    <input
-                      type="password"
-                      required
-                      minLength={6}
-                      value={password}
-                      onChange={(e) => setPassword(e.target.value)}
-                      className="w-full bg-[#1a1a3a] border border-white/10 rounded-xl py-3.5 pl-12 pr-4 text-white focus:outline-none focus:border-indigo-500 transition-colors"
-                      placeholder="Minimum 6 characters"
-                    />
-                  </div>
-                </div>
                 <button
                   type="submit"
                   disabled={isLoading}
                   className="w-full bg-gradient-to-r from-indigo-500 to-blue-600 text-white font-bold rounded-xl py-4 flex items-center justify-center gap-2 hover:scale-[1.02] transition-transform disabled:opacity-70 disabled:hover:scale-100 shadow-lg shadow-indigo-500/25"
                 >
-                  {isLoading ? <Loader2 className="animate-spin" /> : 'Claim Account'}
+                  {isLoading ? <Loader2 className="animate-spin" /> : 'Send Claim Link'}
                   {!isLoading && <ArrowRight size={20} />}
                 </button>
               </motion.form>
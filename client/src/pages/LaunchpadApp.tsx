@@ -9,9 +9,11 @@ import { LogIn } from 'lucide-react';
 export const LaunchpadApp = () => {
   const [view, setView] = useState<'welcome' | 'form' | 'success' | 'rejected'>('welcome');
   const [rejectMsg, setRejectMsg] = useState('');
+  const [fieldErrors, setFieldErrors] = useState<Record<string, string>>({});
   const [successLinks, setSuccessLinks] = useState({ whatsapp: '', telegram: '' });
 
   const handleRegister = async (data: any) => {
+    setFieldErrors({});
     try {
         const res = await fetch(`${API_BASE_URL}/launchpad/register`, {
             method: 'POST',
@@ -22,8 +24,11 @@ export const LaunchpadApp = () => {
         if (res.ok) {
             setSuccessLinks({ whatsapp: result.whatsapp_link, telegram: result.telegram_link });
             setView('success');
+        } else if (result.errors) {
+            // Field problems (422) and duplicates (409) stay on the wizard with the fields marked
+            setFieldErrors(result.errors);
         } else {
-            setRejectMsg(result || "Registration failed.");
+            setRejectMsg(result.message || "Registration failed.");
             setView('rejected');
         }
     } catch (err) {
@@ -85,6 +90,7 @@ export const LaunchpadApp = () => {
         {view === 'form' && (
             <LaunchpadWizard
                 onSubmit={handleRegister}
+                errors={fieldErrors}
                 onReject={(msg) => { setRejectMsg(msg); setView('rejected'); }}
                 onBack={() => setView('welcome')}
             />
@@ -29,6 +29,7 @@ export function SoulmateApp() {
   });
 
   const [rejectionMessage, setRejectionMessage] = useState<string>('');
+  const [fieldErrors, setFieldErrors] = useState<Record<string, string>>({});
   const [isLoading, setIsLoading] = useState(false);
 
   useEffect(() => {
@@ -69,6 +70,10 @@ export function SoulmateApp() {
         const clanInfo = { name: result.clan_name, link: result.whatsapp_link };
         setAssignedClan(clanInfo);
         setStep('success');
+      } else if (result.errors) {
+        // Field problems (422) and duplicates (409) go back to the form with the fields marked
+        setFieldErrors(result.errors);
+        setStep('registration');
       } else {
         handleReject(result.message || "An error occurred during registration.");
       }
@@ -99,7 +104,7 @@ export function SoulmateApp() {
         {step === 'admin' && <AdminDashboard />}
         {step === 'welcome' && <WelcomeScreen onStart={() => setStep('gatekeeper')} />}
         {step === 'gatekeeper' && <GatekeeperStep onValidated={() => setStep('registration')} onReject={handleReject} onBack={() => setStep('welcome')} />}
-        {step === 'registration' && <RegistrationData onNext={(data) => { setFormData(data); setStep('social-lock'); }} onBack={() => setStep('gatekeeper')} />}
+        {step === 'registration' && <RegistrationData initial={formData || undefined} errors={fieldErrors} onNext={(data) => { setFormData(data); setFieldErrors({}); setStep('social-lock'); }} onBack={() => setStep('gatekeeper')} />}
 
         {step === 'social-lock' && (
           <div className="relative w-full max-w-md">
@@ -0,0 +1,139 @@
+import { useState, useEffect } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { AlertCircle, Loader2 } from 'lucide-react';
+import { API_BASE_URL } from '../config';
+import { RegistrationData } from '../components/form/RegistrationData';
+
+interface Offer {
+  full_name: string;
+  email: string;
+  whatsapp_number: string;
+  country: string;
+  religion: string;
+  cohort: string;
+  expires_at: string;
+}
+
+// Landing page for the link emailed when a waitlisted person is offered a held clan seat
+export const WaitlistClaimPage = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [offer, setOffer] = useState<Offer | null>(null);
+  const [status, setStatus] = useState<'loading' | 'form' | 'submitting' | 'done' | 'error'>(token ? 'loading' : 'error');
+  const [message, setMessage] = useState(token ? '' : 'This link is missing its token.');
+  const [fieldErrors, setFieldErrors] = useState<Record<string, string>>({});
+  const [clan, setClan] = useState<{ name: string; link: string } | null>(null);
+
+  useEffect(() => {
+    if (!token) return;
+    fetch(`${API_BASE_URL}/waitlist/offer?token=${encodeURIComponent(token)}`)
+      .then(async res => {
+        const data = await res.json();
+        if (!res.ok || !data.success) throw new Error(data.message || 'This offer is invalid or has expired.');
+        setOffer(data);
+        setStatus('form');
+      })
+      .catch(err => {
+        setMessage(err.message);
+        setStatus('error');
+      });
+  }, [token]);
+
+  const handleSubmit = async (data: Record<string, string>) => {
+    setStatus('submitting');
+    setFieldErrors({});
+    setMessage('');
+    try {
+      const res = await fetch(`${API_BASE_URL}/waitlist/claim`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ ...data, token }),
+      });
+      const result = await res.json();
+      if (result.success) {
+        setClan({ name: result.clan_name, link: result.whatsapp_link });
+        setStatus('done');
+        return;
+      }
+      if (result.errors) {
+        // Validation problems can be fixed in the form; anything else ends the claim
+        setFieldErrors(result.errors);
+        setStatus('form');
+        return;
+      }
+      setMessage(result.message || 'Failed to claim your seat.');
+      setStatus('error');
+    } catch (err) {
+      setMessage('Network error. Please try again later.');
+      setStatus('form');
+    }
+  };
+
+  return (
+    <main className="min-h-screen w-full flex items-center justify-center p-4 relative overflow-hidden">
+      <div className="bg-mesh fixed inset-0 pointer-events-none" />
+      <div className="bg-beam fixed inset-0 pointer-events-none" />
+
+      <div className="relative z-10 w-full flex flex-col items-center space-y-6">
+        {status === 'loading' && <Loader2 className="w-10 h-10 text-indigo-500 animate-spin" />}
+
+        {(status === 'form' || status === 'submitting') && offer && (
+          <>
+            <div className="max-w-2xl w-full p-6 glass-card rounded-3xl text-white space-y-1">
+              <h1 className="text-2xl font-bold">A seat is waiting for you{offer.cohort && ` in ${offer.cohort}`}</h1>
+              <p className="text-slate-300 text-sm">
+                Confirm your details to claim it before {new Date(offer.expires_at).toLocaleString()}.
+              </p>
+              {message && <p className="text-sm text-red-300 pt-2">{message}</p>}
+            </div>
+            <div className="relative w-full flex justify-center">
+              {status === 'submitting' && (
+                <div className="absolute inset-0 bg-black/50 backdrop-blur-sm z-50 flex flex-col items-center justify-center rounded-3xl">
+                  <Loader2 className="w-10 h-10 text-indigo-500 animate-spin" />
+                  <p className="text-white mt-4 font-medium">Claiming your seat...</p>
+                </div>
+              )}
+              <RegistrationData
+                initial={{ ...offer }}
+                lockEmail
+                submitLabel="Claim My Seat"
+                errors={fieldErrors}
+                onNext={handleSubmit}
+              />
+            </div>
+          </>
+        )}
+
+        {status === 'error' && (
+          <div className="max-w-md w-full p-8 glass-card rounded-3xl text-white space-y-5">
+            <div className="flex items-center gap-3 p-4 bg-red-500/10 border border-red-500/20 rounded-xl text-red-200 text-sm">
+              <AlertCircle size={18} className="text-red-400 flex-shrink-0" />
+              <p>{message}</p>
+            </div>
+            <Link to="/" className="block text-center text-slate-400 hover:text-white text-sm underline underline-offset-4">Return to Home</Link>
+          </div>
+        )}
+
+        {status === 'done' && clan && (
+          <div className="max-w-md w-full p-10 glass-card rounded-3xl text-center text-white space-y-6">
+            <div className="w-16 h-16 bg-green-500/10 rounded-full flex items-center justify-center mx-auto">
+              <span className="text-3xl">🎉</span>
+            </div>
+            <div>
+              <h2 className="text-3xl font-bold">You're In!</h2>
+              <p className="mt-2 text-slate-300">Welcome to the journey.</p>
+            </div>
+            <div className="p-6 bg-white/5 rounded-2xl border border-white/10 space-y-2">
+              <p className="text-xs uppercase tracking-widest text-slate-400">Your Assigned Group</p>
+              <h3 className="text-xl font-bold text-indigo-300">{clan.name}</h3>
+            </div>
+            <a href={clan.link} target="_blank" rel="noopener noreferrer" className="block w-full py-4 bg-[#25D366] hover:bg-[#20bd5a] text-white font-bold rounded-xl transition-all shadow-lg shadow-green-900/20">
+              Join WhatsApp Clan Now
+            </a>
+            <p className="text-xs text-slate-500">A confirmation email has also been sent to you.</p>
+          </div>
+        )}
+      </div>
+    </main>
+  );
+};
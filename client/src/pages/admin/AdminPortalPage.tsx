@@ -1,18 +1,22 @@
 import { useState, useEffect } from 'react';
 import { useNavigate, useSearchParams } from 'react-router-dom';
-import { Users, GraduationCap, BookOpen, LogOut, ShieldAlert, Menu, X, Heart, Star, MessageSquare } from 'lucide-react';
-import { supabase } from '../../config';
+import { Users, GraduationCap, BookOpen, LogOut, ShieldAlert, Menu, X, Heart, Star, MessageSquare, Trash2, Library, BarChart3, KeyRound } from 'lucide-react';
+import { supabase, API_BASE_URL } from '../../config';
 
 // Import our newly created components
 import { UserManagementTab } from '../../components/admin/UserManagementTab';
 import { ProgressTab } from '../../components/admin/ProgressTab';
 import { CurriculumTab } from '../../components/admin/CurriculumTab';
+import { QuestionBanksTab } from '../../components/admin/QuestionBanksTab';
+import { QuizAnalyticsTab } from '../../components/admin/QuizAnalyticsTab';
+import { AccessRulesTab } from '../../components/admin/AccessRulesTab';
 import { DiscussionsTab } from '../../components/admin/DiscussionsTab';
 import { GivingTab } from '../../components/admin/GivingTab';
 import { ReviewsTab } from '../../components/admin/ReviewsTab';
 import { QATab } from '../../components/admin/QATab';
+import { TrashTab } from '../../components/admin/TrashTab';
 
-type AdminTab = 'users' | 'progress' | 'curriculum' | 'discussions' | 'giving' | 'reviews' | 'qa';
+type AdminTab = 'users' | 'progress' | 'quiz-analytics' | 'curriculum' | 'access-rules' | 'question-banks' | 'discussions' | 'giving' | 'reviews' | 'qa' | 'trash';
 
 export default function AdminPortalPage() {
   const navigate = useNavigate();
@@ -33,8 +37,10 @@ export default function AdminPortalPage() {
       const { data: { session } } = await supabase.auth.getSession();
       if (!session) return navigate('/login');
       
-      const allowedAdmins = ['asejik@gmail.com', 'temitopeayenigba@gmail.com', 'winneridigbe@gmail.com', 'adedejiolaide11@gmail.com'];
-      if (!allowedAdmins.includes(session.user.email || '')) {
+      // Any staff role opens the portal; the API enforces each tab's permission
+      const res = await fetch(`${API_BASE_URL}/lms/roles`, { headers: { 'Authorization': `Bearer ${session.access_token}` } });
+      const data = res.ok ? await res.json() : { roles: [] };
+      if (!data.roles || data.roles.length === 0) {
         return navigate('/dashboard');
       }
       
@@ -80,11 +86,15 @@ export default function AdminPortalPage() {
         <nav className="flex-1 p-4 space-y-2">
           <button onClick={() => handleTabChange('users')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'users' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><Users size={18} />User Management</button>
           <button onClick={() => handleTabChange('progress')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'progress' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><GraduationCap size={18} />LMS Progress</button>
+          <button onClick={() => handleTabChange('quiz-analytics')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'quiz-analytics' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><BarChart3 size={18} />Quiz Analytics</button>
           <button onClick={() => handleTabChange('curriculum')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'curriculum' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><BookOpen size={18} />Curriculum Data</button>
+          <button onClick={() => handleTabChange('access-rules')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'access-rules' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><KeyRound size={18} />Access Rules</button>
+          <button onClick={() => handleTabChange('question-banks')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'question-banks' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><Library size={18} />Question Banks</button>
           <button onClick={() => handleTabChange('discussions')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'discussions' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><ShieldAlert size={18} />Discussions</button>
           <button onClick={() => handleTabChange('giving')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'giving' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><Heart size={18} />Giving Commitments</button>
           <button onClick={() => handleTabChange('reviews')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'reviews' ? 'bg-amber-500/10 text-amber-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><Star size={18} />Program Reviews</button>
           <button onClick={() => handleTabChange('qa')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'qa' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><MessageSquare size={18} />Student Q&A</button>
+          <button onClick={() => handleTabChange('trash')} className={`w-full flex items-center gap-3 px-4 py-3 rounded-xl transition-all ${activeTab === 'trash' ? 'bg-pink-500/10 text-pink-400 font-bold' : 'text-slate-400 hover:bg-white/5 hover:text-white'}`}><Trash2 size={18} />Trash</button>
         </nav>
         <div className="p-4 border-t border-white/5">
           <button onClick={handleLogout} className="w-full flex items-center gap-3 px-4 py-3 text-slate-400 hover:text-white hover:bg-white/5 rounded-xl transition-all"><LogOut size={18} />Log Out</button>
@@ -109,11 +119,15 @@ export default function AdminPortalPage() {
           </div>
           <div className={activeTab === 'users' ? 'block' : 'hidden'}><UserManagementTab /></div>
           <div className={activeTab === 'progress' ? 'block' : 'hidden'}><ProgressTab /></div>
+          <div className={activeTab === 'quiz-analytics' ? 'block' : 'hidden'}><QuizAnalyticsTab /></div>
           <div className={activeTab === 'curriculum' ? 'block' : 'hidden'}><CurriculumTab /></div>
+          <div className={activeTab === 'access-rules' ? 'block' : 'hidden'}><AccessRulesTab /></div>
+          <div className={activeTab === 'question-banks' ? 'block' : 'hidden'}><QuestionBanksTab /></div>
           <div className={activeTab === 'discussions' ? 'block' : 'hidden'}><DiscussionsTab /></div>
           <div className={activeTab === 'giving' ? 'block' : 'hidden'}><GivingTab /></div>
           <div className={activeTab === 'reviews' ? 'block' : 'hidden'}><ReviewsTab /></div>
           <div className={activeTab === 'qa' ? 'block' : 'hidden'}><QATab /></div>
+          <div className={activeTab === 'trash' ? 'block' : 'hidden'}><TrashTab /></div>
         </div>
       </div>
     </div>
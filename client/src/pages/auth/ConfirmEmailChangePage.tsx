@@ -0,0 +1,97 @@
+import { useState, useEffect } from 'react';
+import { Link, useSearchParams } from 'react-router-dom';
+import { motion } from 'framer-motion';
+import { AlertCircle, CheckCircle2, ArrowRight } from 'lucide-react';
+import { API_BASE_URL, supabase } from '../../config';
+import { clearSessionCache } from '../../lib/api';
+
+// Landing page for the link emailed to a participant's new address
+export const ConfirmEmailChangePage = () => {
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [status, setStatus] = useState<'working' | 'done' | 'error'>(token ? 'working' : 'error');
+  const [message, setMessage] = useState(token ? '' : 'This link is missing its token.');
+
+  useEffect(() => {
+    if (!token) return;
+    const confirm = async () => {
+      try {
+        const response = await fetch(`${API_BASE_URL}/auth/email-change/confirm`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ token })
+        });
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.message || 'Failed to change your email.');
+
+        // The old session still carries the old address, so start fresh
+        await supabase.auth.signOut();
+        clearSessionCache();
+        setMessage(data.message);
+        setStatus('done');
+      } catch (err: any) {
+        setMessage(err.message);
+        setStatus('error');
+      }
+    };
+    confirm();
+  }, [token]);
+
+  return (
+    <div className="min-h-screen bg-[#0a0a16] text-white flex flex-col items-center justify-center p-6 font-sans relative overflow-hidden">
+      <div className="absolute inset-0 pointer-events-none">
+        <div className="absolute top-[-20%] left-[-10%] w-[600px] h-[600px] bg-indigo-900/20 rounded-full blur-[120px] animate-pulse" style={{ animationDuration: '8s' }} />
+        <div className="absolute bottom-[-20%] right-[-10%] w-[600px] h-[600px] bg-pink-900/10 rounded-full blur-[120px] animate-pulse" style={{ animationDuration: '10s' }} />
+      </div>
+
+      <motion.div
+        initial={{ opacity: 0, y: 20 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 0.6, ease: 'easeOut' }}
+        className="relative z-10 w-full max-w-md"
+      >
+        <div className="text-center mb-8 space-y-4">
+          <img src="/logo.png" alt="TAi Logo" className="h-12 mx-auto object-contain" />
+          <h1 className="font-heading text-3xl font-bold">Change Email</h1>
+        </div>
+
+        <div className="bg-white/5 backdrop-blur-xl border border-white/10 p-8 rounded-3xl shadow-2xl text-center space-y-5">
+          {status === 'working' && (
+            <div className="py-6 space-y-3">
+              <div className="w-8 h-8 border-2 border-white/20 border-t-pink-400 rounded-full animate-spin mx-auto" />
+              <p className="text-slate-400 text-sm">Confirming your new email…</p>
+            </div>
+          )}
+
+          {status === 'done' && (
+            <>
+              <div className="flex justify-center">
+                <div className="w-16 h-16 rounded-2xl bg-green-500/10 border border-green-500/20 flex items-center justify-center">
+                  <CheckCircle2 size={32} className="text-green-400" />
+                </div>
+              </div>
+              <p className="text-slate-300 text-sm">{message}</p>
+            </>
+          )}
+
+          {status === 'error' && (
+            <div className="flex items-center gap-3 p-4 bg-red-500/10 border border-red-500/20 rounded-xl text-red-200 text-sm text-left">
+              <AlertCircle size={18} className="text-red-400 flex-shrink-0" />
+              <p>{message}</p>
+            </div>
+          )}
+
+          {status !== 'working' && (
+            <Link
+              to="/login"
+              className="w-full py-4 bg-white/10 hover:bg-white/15 border border-white/10 rounded-xl font-bold flex items-center justify-center gap-2 transition-all group"
+            >
+              Go to login
+              <ArrowRight size={18} className="text-pink-400 group-hover:translate-x-1 transition-transform" />
+            </Link>
+          )}
+        </div>
+      </motion.div>
+    </div>
+  );
+};
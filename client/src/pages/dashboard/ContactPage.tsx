@@ -1,5 +1,7 @@
 import { motion } from 'framer-motion';
 import { Mail, Instagram, Facebook, Calendar, Phone, ExternalLink, ShieldCheck } from 'lucide-react';
+import { YourDataCard } from './components/YourDataCard';
+import { AccountEmailCard } from './components/AccountEmailCard';
 
 export const ContactPage = () => {
   return (
@@ -114,6 +116,10 @@ export const ContactPage = () => {
 
       </div>
 
+      <AccountEmailCard />
+
+      <YourDataCard />
+
       {/* FOOTER QUOTE */}
       <motion.div 
         initial={{ opacity: 0 }}
@@ -7,11 +7,13 @@ import { ModuleAccordion } from './components/ModuleAccordion';
 import { DashboardVideoCard, IntroVideoCard } from './components/DashboardVideoCard';
 import { AnnouncementBanner } from './components/AnnouncementBanner';
 
+export interface LessonLock { kind: string; message: string; until?: string; }
 export interface DashboardLesson {
   id: string; title: string; estimated_time: string;
   is_completed: boolean; scheduled_start_time?: string | null;
   progress: number; last_watched_seconds: number;
   has_feedback: boolean;
+  is_locked: boolean; lock_reasons: LessonLock[] | null; closing_at?: string | null;
 }
 export interface DashboardModule { id: string; title: string; lessons: DashboardLesson[]; }
 export interface DashboardData {
@@ -141,8 +143,6 @@ export const DashboardPage = () => {
         curriculum={data.curriculum}
         nextLessonId={data.next_lesson?.id}
         currentTime={currentTime}
-        requiresMidReview={requiresMidReview}
-        totalLessons={data.cohort.total_lessons}
       />
     </div>
   );
@@ -2,6 +2,7 @@ import { useState, useEffect, useCallback } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
 import { ChevronLeft, Lock } from 'lucide-react';
 import { fetchLMS } from '../../lib/api';
+import type { LessonLock } from './DashboardPage';
 import { VideoPlayerUI } from './components/VideoPlayerUI';
 import { LessonTabs } from './components/LessonTabs';
 import { QuizOverlay } from './components/QuizOverlay';
@@ -11,6 +12,7 @@ export interface LessonData {
   assignmentPrompt: string; is_completed: boolean; is_locked: boolean;
   scheduled_start_time?: string | null; last_watched_seconds: number;
   progress: number; closing_at?: string | null; has_quiz: boolean;
+  lock_reasons: LessonLock[] | null;
 }
 
 export const LessonPage = () => {
@@ -55,36 +57,46 @@ export const LessonPage = () => {
 
   if (isLoading) return <div className="flex items-center justify-center min-h-[50vh]"><div className="w-8 h-8 border-4 border-blue-500/30 border-t-blue-500 rounded-full animate-spin" /></div>;
   if (!lesson) {
-    const isCheckpointError = errorMsg?.includes('Checkpoint Required');
-    
     return (
       <div className="flex flex-col items-center justify-center min-h-[50vh] px-4 space-y-6 text-center">
-        {isCheckpointError ? (
-          <>
-            <div className="w-20 h-20 bg-amber-500/10 rounded-full flex items-center justify-center text-amber-500 mb-2">
-              <Lock size={40} />
-            </div>
-            <h2 className="text-2xl font-bold text-white max-w-md leading-relaxed">
-              Attend to your Mid-Program Feedback to Proceed
-            </h2>
-            <button onClick={() => navigate('/dashboard')} className="px-8 py-4 bg-amber-500 hover:bg-amber-400 text-black font-bold rounded-xl shadow-lg transition-all mt-4">
-              Go to Dashboard
-            </button>
-          </>
-        ) : (
-          <button onClick={() => navigate('/dashboard')} className="px-6 py-2 bg-white/10 hover:bg-white/20 text-white rounded-lg">
-            Back
-          </button>
-        )}
+        {errorMsg && <p className="text-slate-400">{errorMsg}</p>}
+        <button onClick={() => navigate('/dashboard')} className="px-6 py-2 bg-white/10 hover:bg-white/20 text-white rounded-lg">
+          Back
+        </button>
       </div>
     );
   }
 
   if (lesson.is_locked) {
+    const reasons = lesson.lock_reasons || [];
+    if (reasons.some(r => r.kind === 'review')) {
+      return (
+        <div className="flex flex-col items-center justify-center min-h-[50vh] px-4 space-y-6 text-center">
+          <div className="w-20 h-20 bg-amber-500/10 rounded-full flex items-center justify-center text-amber-500 mb-2">
+            <Lock size={40} />
+          </div>
+          <h2 className="text-2xl font-bold text-white max-w-md leading-relaxed">
+            Attend to your Mid-Program Feedback to Proceed
+          </h2>
+          <button onClick={() => navigate('/dashboard')} className="px-8 py-4 bg-amber-500 hover:bg-amber-400 text-black font-bold rounded-xl shadow-lg transition-all mt-4">
+            Go to Dashboard
+          </button>
+        </div>
+      );
+    }
+
     return (
       <div className="flex flex-col items-center justify-center min-h-[60vh] space-y-6 text-center">
         <div className="w-24 h-24 bg-red-500/10 rounded-full flex items-center justify-center text-red-500"><Lock size={48} /></div>
         <h2 className="text-3xl font-bold text-white mb-3">Lesson Locked</h2>
+        <div className="space-y-2 max-w-md">
+          {reasons.map((r, i) => (
+            <p key={i} className="text-slate-400">
+              {r.message}
+              {r.until && <span className="block text-xs text-slate-500 mt-1">Opens {new Date(r.until).toLocaleString()}</span>}
+            </p>
+          ))}
+        </div>
         <button onClick={() => navigate('/dashboard')} className="px-8 py-4 bg-blue-600 hover:bg-blue-500 text-white font-bold rounded-xl">Return to Dashboard</button>
       </div>
     );
@@ -0,0 +1,68 @@
+import { useState } from 'react';
+import { motion } from 'framer-motion';
+import { Mail, Loader2, Send } from 'lucide-react';
+import { postLMS } from '../../../lib/api';
+
+// Lets a participant move their login to a new address; the change happens once they open the emailed link
+export const AccountEmailCard = () => {
+  const [email, setEmail] = useState('');
+  const [isSending, setIsSending] = useState(false);
+  const [message, setMessage] = useState('');
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setIsSending(true);
+    setMessage('');
+    try {
+      const data = await postLMS('/lms/account/email', { email: email.trim() });
+      setMessage(data?.message || 'Check your new inbox for a confirmation link.');
+      setEmail('');
+    } catch (err: any) {
+      setMessage(err.message?.includes('already used')
+        ? 'That email is already used by another account.'
+        : 'We could not start the change. Please check the address and try again.');
+    } finally {
+      setIsSending(false);
+    }
+  };
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 20 }}
+      animate={{ opacity: 1, y: 0 }}
+      transition={{ delay: 0.35 }}
+      className="bg-[#111827] border border-white/5 rounded-3xl p-8 space-y-6 shadow-2xl"
+    >
+      <div className="space-y-2">
+        <h2 className="text-2xl font-bold text-white tracking-tight flex items-center gap-3">
+          <Mail className="text-slate-400" size={24} />
+          Login Email
+        </h2>
+        <p className="text-slate-400 leading-relaxed">
+          Changing address? Enter the new one and we'll email it a confirmation link. Your registration, progress and history move with your login.
+        </p>
+      </div>
+
+      <form onSubmit={handleSubmit} className="flex flex-col sm:flex-row gap-3">
+        <input
+          type="email"
+          required
+          placeholder="New email address"
+          value={email}
+          onChange={(e) => setEmail(e.target.value)}
+          className="flex-1 bg-white/5 border border-white/10 rounded-xl px-4 py-3 text-white placeholder-slate-500 focus:outline-none focus:border-pink-500/50"
+        />
+        <button
+          type="submit"
+          disabled={isSending || !email.trim()}
+          className="inline-flex items-center justify-center gap-2 px-6 py-3 bg-white/5 border border-white/10 hover:bg-white/10 text-white font-bold rounded-xl transition-all disabled:opacity-50"
+        >
+          {isSending ? <Loader2 size={18} className="animate-spin" /> : <Send size={18} />}
+          Send link
+        </button>
+      </form>
+
+      {message && <p className="text-sm text-slate-300">{message}</p>}
+    </motion.div>
+  );
+};
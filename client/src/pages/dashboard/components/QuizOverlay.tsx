@@ -1,69 +1,40 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useRef, useCallback } from 'react';
 import { motion, AnimatePresence } from 'framer-motion';
 import { Loader2, CheckCircle, Clock, X } from 'lucide-react';
 import { API_BASE_URL } from '../../../config';
 import { getAuthSession, postLMS } from '../../../lib/api';
 
+interface QuizQuestion {
+  id: string;
+  kind: 'single_choice' | 'multiple_choice' | 'true_false' | 'short_answer';
+  prompt: string;
+  options: string[];
+}
+
+// Answer keys never reach the browser; the server grades the submission
 interface Quiz {
   id: string;
   lesson_id: string;
   title: string;
-  question?: string;
+  questions: QuizQuestion[];
   created_at: string;
   expires_at: string;
-}
-
-interface ParsedQuestion {
-  id: string;
-  questionText: string;
-  options: string[];
-  correctAnswer?: string;
-}
-
-function parseQuestions(rawText: string): ParsedQuestion[] {
-  if (!rawText) return [{ id: 'q1', questionText: "What is the main expectation for today's class?", options: [] }];
-  
-  const blocks = rawText.trim().split(/\n\s*\n/);
-  return blocks.map((block, i) => {
-    const lines = block.split('\n').map(l => l.trim()).filter(l => l);
-    const firstOptionIdx = lines.findIndex(l => /^[A-Ea-e][\.\)]\s/.test(l));
-    
-    if (firstOptionIdx > 0) {
-      let correctAnswer = '';
-      const rawOptions = lines.slice(firstOptionIdx);
-      const cleanedOptions = rawOptions.map(opt => {
-        if (opt.endsWith('*')) {
-          const clean = opt.slice(0, -1).trim();
-          correctAnswer = clean;
-          return clean;
-        }
-        return opt;
-      });
-      return { id: `q${i + 1}`, questionText: lines.slice(0, firstOptionIdx).join(' '), options: cleanedOptions, correctAnswer };
-    } else {
-      return { id: `q${i + 1}`, questionText: lines.join(' '), options: [] };
-    }
-  });
+  attempt: number;
+  max_attempts: number;
+  is_late: boolean;
+  late_penalty_percent?: number;
 }
 
 export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
   const [quiz, setQuiz] = useState<Quiz | null>(null);
   const [isOpen, setIsOpen] = useState(false);
-  const [answers, setAnswers] = useState<{ [key: string]: string }>({});
+  const [answers, setAnswers] = useState<{ [key: string]: string | string[] }>({});
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [isSubmitted, setIsSubmitted] = useState(false);
   
-  const [parsedQuestions, setParsedQuestions] = useState<ParsedQuestion[]>([]);
   const [currentIdx, setCurrentIdx] = useState(0);
   const [timeRemaining, setTimeRemaining] = useState<number | null>(null);
-
-  useEffect(() => {
-    if (quiz?.question) {
-      setParsedQuestions(parseQuestions(quiz.question));
-    } else if (quiz) {
-      setParsedQuestions(parseQuestions(''));
-    }
-  }, [quiz]);
+  const questions = quiz?.questions || [];
 
   const [hasTimedOut, setHasTimedOut] = useState(false);
 
@@ -93,64 +64,83 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
     return () => clearInterval(interval);
   }, [quiz, isSubmitted]);
 
-  useEffect(() => {
-    // Check if a quiz is active
-    const checkQuiz = async () => {
-      try {
-        const session = await getAuthSession();
-        const res = await fetch(`${API_BASE_URL}/lms/lessons/${lessonId}/quiz`, {
-          headers: { 'Authorization': `Bearer ${session?.access_token}` }
-        });
-        
-        if (res.status === 200) {
-          const data = await res.json();
-          if (data.status === 'waiting') return; // Not started yet
-          if (data.status === 'expired') {
-             setIsOpen(false);
-             return;
-          }
-          if (data.status === 'submitted') {
-            setIsOpen(false);
-            return;
-          }
-          if (data.id) {
-            setQuiz(data);
-            setIsOpen(true);
-          }
+  // Once a quiz is on screen, polling stops until the learner starts another attempt
+  const loadedRef = useRef(false);
+
+  // Check if a quiz is active
+  const checkQuiz = useCallback(async () => {
+    if (loadedRef.current) return;
+    try {
+      const session = await getAuthSession();
+      const res = await fetch(`${API_BASE_URL}/lms/lessons/${lessonId}/quiz`, {
+        headers: { 'Authorization': `Bearer ${session?.access_token}` }
+      });
+      
+      if (res.status === 200) {
+        const data = await res.json();
+        if (data.status === 'waiting') return; // Not started yet
+        if (data.status === 'expired') {
+           setIsOpen(false);
+           return;
+        }
+        if (data.status === 'submitted') {
+          setIsOpen(false);
+          return;
+        }
+        if (data.id) {
+          loadedRef.current = true;
+          setQuiz(data);
+          setIsOpen(true);
         }
-      } catch (e) {
-        console.error("Failed to check quiz:", e);
       }
-    };
+    } catch (e) {
+      console.error("Failed to check quiz:", e);
+    }
+  }, [lessonId]);
 
+  useEffect(() => {
     // Check immediately, then poll every 5s just in case
     checkQuiz();
     const interval = setInterval(checkQuiz, 5000);
     return () => clearInterval(interval);
-  }, [lessonId]);
+  }, [checkQuiz]);
 
-  const [scoreInfo, setScoreInfo] = useState<{ score: number, total: number, percentage: number } | null>(null);
+  const [scoreInfo, setScoreInfo] = useState<{
+    score: number, total: number, percentage: number,
+    rawScore: number, isLate: boolean, penalty: number, attemptsRemaining: number,
+  } | null>(null);
+
+  const startNextAttempt = () => {
+    setAnswers({});
+    setCurrentIdx(0);
+    setScoreInfo(null);
+    setHasTimedOut(false);
+    setIsSubmitted(false);
+    setQuiz(null);
+    loadedRef.current = false;
+    checkQuiz();
+  };
 
   const handleSubmit = async (e?: React.FormEvent, isTimeout = false) => {
     if (e) e.preventDefault();
     if (!isTimeout && Object.keys(answers).length === 0 && timeRemaining && timeRemaining > 0) return;
     setIsSubmitting(true);
-    
-    let correctCount = 0;
-    parsedQuestions.forEach(q => {
-      if (q.correctAnswer && answers[q.id] === q.correctAnswer) {
-        correctCount++;
-      }
-    });
-    const total = parsedQuestions.length;
-    const percentage = total > 0 ? Math.round((correctCount / total) * 100) : 0;
-    
-    setScoreInfo({ score: correctCount, total, percentage });
 
     try {
-      await postLMS(`/lms/lessons/${lessonId}/quiz`, { answers, score: correctCount, total_questions: total });
+      const result = await postLMS(`/lms/lessons/${lessonId}/quiz`, { answers });
+      const score = result?.score || 0;
+      const total = result?.total_questions || 0;
+      const attemptsRemaining = result?.attempts_remaining || 0;
+      setScoreInfo({
+        score, total, percentage: total > 0 ? Math.round((score / total) * 100) : 0,
+        rawScore: result?.raw_score ?? score,
+        isLate: !!result?.is_late,
+        penalty: result?.late_penalty_percent || 0,
+        attemptsRemaining,
+      });
       setIsSubmitted(true);
-      setTimeout(() => setIsOpen(false), 15000); // Leave open a bit longer so they can read their score
+      // Leave open a bit longer so they can read their score; stay open if they can try again
+      if (attemptsRemaining === 0) setTimeout(() => setIsOpen(false), 15000);
     } catch (err) {
       console.error(err);
     } finally {
@@ -167,18 +157,33 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
   };
 
   const handleNext = () => {
-    if (currentIdx < parsedQuestions.length - 1) setCurrentIdx(prev => prev + 1);
+    if (currentIdx < questions.length - 1) setCurrentIdx(prev => prev + 1);
   };
 
   const handlePrev = () => {
     if (currentIdx > 0) setCurrentIdx(prev => prev - 1);
   };
 
-  if (!isOpen || !quiz || parsedQuestions.length === 0) return null;
+  if (!isOpen || !quiz || questions.length === 0) return null;
 
-  const currentQ = parsedQuestions[currentIdx];
-  const isLast = currentIdx === parsedQuestions.length - 1;
+  const currentQ = questions[currentIdx];
+  const isLast = currentIdx === questions.length - 1;
   const currentAnswer = answers[currentQ.id] || '';
+  const hasAnswer = currentAnswer.length > 0;
+  const isSelected = (opt: string) =>
+    Array.isArray(currentAnswer) ? currentAnswer.includes(opt) : currentAnswer === opt;
+
+  const chooseOption = (opt: string) => {
+    if (currentQ.kind !== 'multiple_choice') {
+      setAnswers({ ...answers, [currentQ.id]: opt });
+      return;
+    }
+    const chosen = Array.isArray(currentAnswer) ? currentAnswer : [];
+    setAnswers({
+      ...answers,
+      [currentQ.id]: chosen.includes(opt) ? chosen.filter(o => o !== opt) : [...chosen, opt]
+    });
+  };
 
   return (
     <AnimatePresence>
@@ -226,10 +231,23 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
                           You scored {scoreInfo.score} out of {scoreInfo.total} correct
                         </div>
                       </div>
+                      {scoreInfo.isLate && scoreInfo.penalty > 0 && (
+                        <p className="text-amber-400 text-xs md:text-sm">
+                          Submitted late: {scoreInfo.penalty}% off your {scoreInfo.rawScore}/{scoreInfo.total}.
+                        </p>
+                      )}
                       <p className={`font-medium text-sm md:text-base ${remark.color}`}>{remark.text}</p>
                     </>
                   );
                 })()}
+                {scoreInfo && scoreInfo.attemptsRemaining > 0 && (
+                  <button
+                    onClick={startNextAttempt}
+                    className="px-6 py-3 bg-white/10 hover:bg-white/20 text-white text-sm font-bold rounded-xl transition-all"
+                  >
+                    Try again ({scoreInfo.attemptsRemaining} {scoreInfo.attemptsRemaining === 1 ? 'attempt' : 'attempts'} left)
+                  </button>
+                )}
               </div>
             ) : (
               <div className="space-y-5 md:space-y-6 flex-1 overflow-y-auto pr-2 custom-scrollbar">
@@ -241,12 +259,22 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
                   </div>
                 </div>
 
+                {quiz.is_late && (
+                  <p className="text-amber-400 text-xs md:text-sm bg-amber-500/10 border border-amber-500/20 rounded-xl px-4 py-2">
+                    The quiz window has closed. You can still submit, but late answers lose {quiz.late_penalty_percent || 0}% of the score.
+                  </p>
+                )}
+
                 <div className="space-y-4">
                   <div className="flex items-center justify-between text-xs font-semibold text-slate-500 uppercase tracking-wider">
-                    <span>Question {currentIdx + 1} of {parsedQuestions.length}</span>
+                    <span>
+                      Question {currentIdx + 1} of {questions.length}
+                      {quiz.max_attempts > 1 && ` · Attempt ${quiz.attempt} of ${quiz.max_attempts}`}
+                    </span>
+                    {currentQ.kind === 'multiple_choice' && <span className="normal-case">Select all that apply</span>}
                   </div>
                   <label className="block text-sm sm:text-base font-medium text-slate-200 leading-relaxed">
-                    {currentQ.questionText}
+                    {currentQ.prompt}
                   </label>
                   
                   {currentQ.options.length > 0 ? (
@@ -254,9 +282,9 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
                       {currentQ.options.map((opt, i) => (
                         <button
                           key={i}
-                          onClick={() => setAnswers({ ...answers, [currentQ.id]: opt })}
+                          onClick={() => chooseOption(opt)}
                           className={`w-full text-left px-4 py-3 rounded-xl border transition-all text-sm md:text-base leading-snug ${
-                            currentAnswer === opt 
+                            isSelected(opt)
                               ? 'bg-pink-500/20 border-pink-500 text-white'
                               : 'bg-white/5 border-white/10 text-slate-300 hover:bg-white/10'
                           }`}
@@ -268,7 +296,7 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
                   ) : (
                     <textarea 
                       required
-                      value={currentAnswer}
+                      value={typeof currentAnswer === 'string' ? currentAnswer : ''}
                       onChange={(e) => setAnswers({ ...answers, [currentQ.id]: e.target.value })}
                       rows={4}
                       className="w-full mt-4 bg-[#1e293b] border border-white/10 rounded-xl px-4 py-3 text-sm md:text-base text-white placeholder-slate-500 focus:outline-none focus:border-pink-500 focus:ring-1 focus:ring-pink-500 resize-none"
@@ -289,7 +317,7 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
                   {isLast ? (
                     <button 
                       onClick={() => handleSubmit()}
-                      disabled={isSubmitting || !currentAnswer}
+                      disabled={isSubmitting || !hasAnswer}
                       className="flex-[2] py-3 bg-gradient-to-r from-pink-500 to-rose-500 hover:from-pink-400 hover:to-rose-400 text-white text-sm md:text-base font-bold rounded-xl transition-all disabled:opacity-50 flex items-center justify-center gap-2"
                     >
                       {isSubmitting ? <Loader2 className="animate-spin" /> : 'Submit Quiz'}
@@ -297,7 +325,7 @@ export const QuizOverlay = ({ lessonId }: { lessonId: string }) => {
                   ) : (
                     <button 
                       onClick={handleNext}
-                      disabled={!currentAnswer}
+                      disabled={!hasAnswer}
                       className="flex-[2] py-3 bg-white/10 hover:bg-white/20 text-white text-sm md:text-base font-bold rounded-xl transition-all disabled:opacity-50"
                     >
                       Next
@@ -0,0 +1,101 @@
+import { useState } from 'react';
+import { motion } from 'framer-motion';
+import { Download, Trash2, Loader2, Lock } from 'lucide-react';
+import { API_BASE_URL } from '../../../config';
+import { getAuthSession, postLMS } from '../../../lib/api';
+
+// Lets a participant download everything stored about them, or ask for it to be erased
+export const YourDataCard = () => {
+  const [isExporting, setIsExporting] = useState(false);
+  const [isRequesting, setIsRequesting] = useState(false);
+  const [confirmErase, setConfirmErase] = useState(false);
+  const [message, setMessage] = useState('');
+
+  const handleExport = async () => {
+    setIsExporting(true);
+    setMessage('');
+    try {
+      const session = await getAuthSession();
+      const res = await fetch(`${API_BASE_URL}/lms/privacy/export`, {
+        headers: { 'Authorization': `Bearer ${session?.access_token}` }
+      });
+      if (!res.ok) throw new Error(await res.text());
+
+      const url = URL.createObjectURL(await res.blob());
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = 'my-data.zip';
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      console.error("Data export failed:", err);
+      setMessage('We could not prepare your download. Please try again.');
+    } finally {
+      setIsExporting(false);
+    }
+  };
+
+  const handleErasure = async () => {
+    setIsRequesting(true);
+    try {
+      const data = await postLMS('/lms/privacy/erasure', {});
+      setMessage(data?.message || 'Your request has been received.');
+      setConfirmErase(false);
+    } catch (err) {
+      setMessage('We could not file your request. Please try again.');
+    } finally {
+      setIsRequesting(false);
+    }
+  };
+
+  return (
+    <motion.div
+      initial={{ opacity: 0, y: 20 }}
+      animate={{ opacity: 1, y: 0 }}
+      transition={{ delay: 0.4 }}
+      className="bg-[#111827] border border-white/5 rounded-3xl p-8 space-y-6 shadow-2xl"
+    >
+      <div className="space-y-2">
+        <h2 className="text-2xl font-bold text-white tracking-tight flex items-center gap-3">
+          <Lock className="text-slate-400" size={24} />
+          Your Data
+        </h2>
+        <p className="text-slate-400 leading-relaxed">
+          Download a copy of everything we hold about you: your registration, progress, submissions, comments, questions and reviews. You can also ask us to permanently delete it; an administrator reviews every request.
+        </p>
+      </div>
+
+      <div className="flex flex-col sm:flex-row gap-3">
+        <button
+          onClick={handleExport}
+          disabled={isExporting}
+          className="inline-flex items-center justify-center gap-2 px-6 py-3 bg-white/5 border border-white/10 hover:bg-white/10 text-white font-bold rounded-xl transition-all disabled:opacity-50"
+        >
+          {isExporting ? <Loader2 size={18} className="animate-spin" /> : <Download size={18} />}
+          Download my data
+        </button>
+
+        {confirmErase ? (
+          <button
+            onClick={handleErasure}
+            disabled={isRequesting}
+            className="inline-flex items-center justify-center gap-2 px-6 py-3 bg-red-600 hover:bg-red-500 text-white font-bold rounded-xl transition-all disabled:opacity-50"
+          >
+            {isRequesting ? <Loader2 size={18} className="animate-spin" /> : <Trash2 size={18} />}
+            Yes, delete my account and data
+          </button>
+        ) : (
+          <button
+            onClick={() => setConfirmErase(true)}
+            className="inline-flex items-center justify-center gap-2 px-6 py-3 bg-red-500/10 border border-red-500/20 hover:bg-red-500/20 text-red-400 font-bold rounded-xl transition-all"
+          >
+            <Trash2 size={18} />
+            Request deletion
+          </button>
+        )}
+      </div>
+
+      {message && <p className="text-sm text-slate-300">{message}</p>}
+    </motion.div>
+  );
+};
@@ -51,6 +51,8 @@ func InitTables() {
 
 		-- Ensure columns exist in program_settings
 		ALTER TABLE public.program_settings ADD COLUMN IF NOT EXISTS intro_video_id TEXT;
+		-- Default clan assignment strategy when a cohort doesn't set its own (first_fit, round_robin, least_filled, balanced_demographics)
+		ALTER TABLE public.program_settings ADD COLUMN IF NOT EXISTS clan_strategy TEXT;
 
 		-- Ensure updated_at exists in lesson_progress
 		ALTER TABLE public.lesson_progress ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ DEFAULT NOW();
@@ -85,22 +87,494 @@ func InitTables() {
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		);
 
-		-- Cohort 4 Waitlist table (new - does not affect any existing tables)
-		CREATE TABLE IF NOT EXISTS public.cohort4_waitlist (
+		-- Programs registry: one row per program with every name it is stored under.
+		-- enrollment_key is the value kept in program_reviews / questions / giving_commitments and sent
+		-- as ?program=, module_name matches modules / cohorts / program_settings, and registration_table
+		-- is where sign-ups live (a user is enrolled when their email appears there).
+		CREATE TABLE IF NOT EXISTS public.programs (
+			slug TEXT PRIMARY KEY,
+			enrollment_key TEXT NOT NULL UNIQUE,
+			display_name TEXT NOT NULL,
+			module_name TEXT NOT NULL UNIQUE,
+			registration_table TEXT NOT NULL,
+			certificate_min_completion NUMERIC NOT NULL DEFAULT 0.66,
+			certificate_requires_final_review BOOLEAN NOT NULL DEFAULT TRUE,
+			checkpoint_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			checkpoint_min_review_chars INT NOT NULL DEFAULT 0,
+			lesson_lock_hours INT NOT NULL DEFAULT 48,
+			pre_checkpoint_lock_hours INT,
+			sort_order INT NOT NULL DEFAULT 0,
+			aliases TEXT[] NOT NULL DEFAULT '{}'
+		);
+		INSERT INTO public.programs (slug, enrollment_key, display_name, module_name, registration_table,
+			certificate_min_completion, certificate_requires_final_review, checkpoint_min_review_chars, pre_checkpoint_lock_hours, sort_order, aliases)
+		VALUES
+			('launchpad', 'launchpad', 'Couples'' Launchpad', 'Couples Launchpad', 'couples_launchpad', 1.0, FALSE, 20, 120, 1, ARRAY['Couples'' Launchpad 5.0', 'clp']),
+			('soulmate', 'Ready for a Soulmate', 'Ready for a Soulmate', 'Ready for a Soulmate', 'participants', 0.66, TRUE, 0, NULL, 2, ARRAY['rfasm'])
+		ON CONFLICT (slug) DO NOTHING;
+
+		-- Cohorts: every intake of a program (e.g. "Ready for a Soulmate (Cohort 3)") with its own
+		-- registration window, clans, lesson schedule, announcements, waitlist and email copy.
+		-- program_name matches programs.module_name (and modules.program_name).
+		CREATE TABLE IF NOT EXISTS public.cohorts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			program_name TEXT NOT NULL,
+			name TEXT NOT NULL,
+			slug TEXT NOT NULL UNIQUE,
+			opens_at TIMESTAMPTZ,
+			closes_at TIMESTAMPTZ,
+			clan_strategy TEXT,
+			whatsapp_link TEXT,
+			telegram_link TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+
+		-- The waitlist used to be Cohort 4 only; it now holds entries for any cohort
+		DO $$
+		BEGIN
+			IF to_regclass('public.waitlist') IS NULL AND to_regclass('public.cohort4_waitlist') IS NOT NULL THEN
+				ALTER TABLE public.cohort4_waitlist RENAME TO waitlist;
+			END IF;
+		END $$;
+		CREATE TABLE IF NOT EXISTS public.waitlist (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			full_name TEXT NOT NULL,
 			nationality TEXT,
 			whatsapp_number TEXT NOT NULL,
-			email TEXT NOT NULL UNIQUE,
+			email TEXT NOT NULL,
 			religion TEXT,
 			denomination TEXT,
 			created_at TIMESTAMPTZ DEFAULT NOW()
 		);
 
+		-- Waitlist promotion: an entry moves waiting -> offered -> promoted (or expired)
+		ALTER TABLE public.waitlist ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'waiting';
+
+		-- Cohort membership
+		ALTER TABLE public.participants ADD COLUMN IF NOT EXISTS cohort_id UUID REFERENCES public.cohorts(id);
+		ALTER TABLE public.couples_launchpad ADD COLUMN IF NOT EXISTS cohort_id UUID REFERENCES public.cohorts(id);
+		ALTER TABLE public.clans ADD COLUMN IF NOT EXISTS cohort_id UUID REFERENCES public.cohorts(id);
+		ALTER TABLE public.waitlist ADD COLUMN IF NOT EXISTS cohort_id UUID REFERENCES public.cohorts(id);
+		ALTER TABLE public.announcements ADD COLUMN IF NOT EXISTS cohort_id UUID REFERENCES public.cohorts(id);
+
+		-- WhatsApp numbers normalized to E.164 next to the raw input, for duplicate detection
+		ALTER TABLE public.participants ADD COLUMN IF NOT EXISTS whatsapp_e164 TEXT;
+		ALTER TABLE public.couples_launchpad ADD COLUMN IF NOT EXISTS whatsapp_e164 TEXT;
+		ALTER TABLE public.couples_launchpad ADD COLUMN IF NOT EXISTS spouse_whatsapp_e164 TEXT;
+		ALTER TABLE public.waitlist ADD COLUMN IF NOT EXISTS whatsapp_e164 TEXT;
+		CREATE INDEX IF NOT EXISTS participants_whatsapp_e164_idx ON public.participants (whatsapp_e164);
+		CREATE INDEX IF NOT EXISTS couples_launchpad_whatsapp_e164_idx ON public.couples_launchpad (whatsapp_e164);
+		CREATE INDEX IF NOT EXISTS waitlist_whatsapp_e164_idx ON public.waitlist (whatsapp_e164);
+
+		-- Email is unique per cohort waitlist rather than globally
+		ALTER TABLE public.waitlist DROP CONSTRAINT IF EXISTS cohort4_waitlist_email_key;
+		CREATE UNIQUE INDEX IF NOT EXISTS waitlist_cohort_email_key ON public.waitlist (cohort_id, lower(email));
+
+		-- Per-cohort live schedule overriding lessons.scheduled_start_time / live_duration_minutes
+		CREATE TABLE IF NOT EXISTS public.lesson_schedules (
+			cohort_id UUID NOT NULL REFERENCES public.cohorts(id) ON DELETE CASCADE,
+			lesson_id UUID NOT NULL REFERENCES public.lessons(id) ON DELETE CASCADE,
+			scheduled_start_time TIMESTAMPTZ,
+			live_duration_minutes INT,
+			PRIMARY KEY (cohort_id, lesson_id)
+		);
+
+		-- First boot with cohorts: describe the intakes that were hardcoded and attach existing rows
+		DO $$
+		DECLARE
+			rfasm_current UUID;
+			rfasm_next UUID;
+			clp_current UUID;
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM public.cohorts) THEN
+				INSERT INTO public.cohorts (program_name, name, slug, opens_at)
+				VALUES ('Ready for a Soulmate', 'Ready for a Soulmate (Cohort 3)', 'rfasm-cohort-3', NOW())
+				RETURNING id INTO rfasm_current;
+
+				INSERT INTO public.cohorts (program_name, name, slug)
+				VALUES ('Ready for a Soulmate', 'Ready for a Soulmate (Cohort 4)', 'rfasm-cohort-4')
+				RETURNING id INTO rfasm_next;
+
+				INSERT INTO public.cohorts (program_name, name, slug, opens_at, whatsapp_link, telegram_link)
+				VALUES ('Couples Launchpad', 'Couples'' Launchpad 5.0', 'launchpad-5', NOW(),
+					'https://chat.whatsapp.com/JpuFSjuo8lBHRSUtDtDwsT?mode=gi_t', 'https://t.me/+Ybj-HVY5KLw5MzY0')
+				RETURNING id INTO clp_current;
+
+				UPDATE public.participants SET cohort_id = rfasm_current WHERE cohort_id IS NULL;
+				UPDATE public.clans SET cohort_id = rfasm_current WHERE cohort_id IS NULL;
+				UPDATE public.couples_launchpad SET cohort_id = clp_current WHERE cohort_id IS NULL;
+				UPDATE public.waitlist SET cohort_id = rfasm_next WHERE cohort_id IS NULL;
+				UPDATE public.announcements
+				SET cohort_id = CASE WHEN target_program ILIKE '%launchpad%' THEN clp_current ELSE rfasm_current END
+				WHERE cohort_id IS NULL;
+			END IF;
+		END $$;
+
+		-- Soft delete: admin deletes set deleted_at; the row stays in the trash until it is
+		-- restored or purged after TRASH_RETENTION_DAYS. Programs added later get the column
+		-- on their registration table when they are saved.
+		ALTER TABLE public.participants ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		ALTER TABLE public.couples_launchpad ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		ALTER TABLE public.modules ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		ALTER TABLE public.lessons ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		ALTER TABLE public.lesson_comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		DO $$
+		DECLARE
+			t TEXT;
+		BEGIN
+			FOR t IN SELECT DISTINCT registration_table FROM public.programs LOOP
+				IF to_regclass(format('public.%I', t)) IS NOT NULL THEN
+					EXECUTE format('ALTER TABLE public.%I ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ', t);
+				END IF;
+			END LOOP;
+		END $$;
+
+		-- Registrations point at their login by id, so a changed email doesn't orphan anyone's
+		-- history. Claims link the row; the insert trigger links people who already have a login
+		-- (e.g. registering for a second program); the backfill catches everything older.
+		CREATE OR REPLACE FUNCTION public.link_registration_user() RETURNS trigger AS $$
+		BEGIN
+			IF NEW.user_id IS NULL THEN
+				SELECT id INTO NEW.user_id FROM auth.users WHERE lower(email) = lower(NEW.email) LIMIT 1;
+			END IF;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		DO $$
+		DECLARE
+			t TEXT;
+		BEGIN
+			FOR t IN SELECT registration_table FROM public.programs UNION SELECT unnest(ARRAY['participants', 'couples_launchpad']) LOOP
+				IF to_regclass(format('public.%I', t)) IS NOT NULL THEN
+					EXECUTE format('ALTER TABLE public.%I ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES auth.users(id) ON DELETE SET NULL', t);
+					EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON public.%I (user_id)', t || '_user_id_idx', t);
+					EXECUTE format('DROP TRIGGER IF EXISTS link_registration_user ON public.%I', t);
+					EXECUTE format('CREATE TRIGGER link_registration_user BEFORE INSERT ON public.%I FOR EACH ROW EXECUTE FUNCTION public.link_registration_user()', t);
+					EXECUTE format('UPDATE public.%I r SET user_id = au.id FROM auth.users au WHERE r.user_id IS NULL AND lower(r.email) = lower(au.email)', t);
+				END IF;
+			END LOOP;
+		END $$;
+
+		-- Which cohort(s) each login belongs to, per program
+		CREATE OR REPLACE VIEW public.user_cohorts AS
+			SELECT p.user_id, c.program_name, c.id AS cohort_id, c.name AS cohort_name, p.created_at AS registered_at
+			FROM public.participants p
+			JOIN public.cohorts c ON c.id = p.cohort_id
+			WHERE p.user_id IS NOT NULL AND p.deleted_at IS NULL
+			UNION ALL
+			SELECT cl.user_id, c.program_name, c.id, c.name, cl.created_at
+			FROM public.couples_launchpad cl
+			JOIN public.cohorts c ON c.id = cl.cohort_id
+			WHERE cl.user_id IS NOT NULL AND cl.deleted_at IS NULL;
+
+		-- A held clan seat offered to a waitlisted person. The seat is counted in
+		-- clans.current_count while the offer is pending so nobody else can take it.
+		CREATE TABLE IF NOT EXISTS public.waitlist_offers (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			waitlist_id UUID NOT NULL REFERENCES public.waitlist(id) ON DELETE CASCADE,
+			clan_id BIGINT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			status TEXT NOT NULL DEFAULT 'pending',
+			expires_at TIMESTAMPTZ NOT NULL,
+			claimed_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS waitlist_offers_pending_idx ON public.waitlist_offers (expires_at) WHERE status = 'pending';
+
+		-- Transactional outbox: emails and Sheets webhooks written with the row they describe and
+		-- delivered by a background dispatcher. status is pending -> delivered, or dead after the last retry.
+		CREATE TABLE IF NOT EXISTS public.outbox (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			kind TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			delivered_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS outbox_due_idx ON public.outbox (next_attempt_at) WHERE status = 'pending';
+
+		-- Staff roles; what each role may do is defined in handlers/roles.go
+		CREATE TABLE IF NOT EXISTS public.user_roles (
+			user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+			role TEXT NOT NULL CHECK (role IN ('super_admin', 'program_admin', 'facilitator', 'clan_leader', 'grader')),
+			granted_by UUID REFERENCES auth.users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (user_id, role)
+		);
+
+		-- Bootstrap: the previously hardcoded admins become super admins, only while no roles exist
+		-- so that a later revocation is not undone on restart
+		INSERT INTO public.user_roles (user_id, role)
+		SELECT id, 'super_admin' FROM auth.users
+		WHERE email IN ('asejik@gmail.com', 'temitopeayenigba@gmail.com', 'winneridigbe@gmail.com', 'adedejiolaide11@gmail.com')
+		AND NOT EXISTS (SELECT 1 FROM public.user_roles);
+
+		-- Scoped machine credentials for admin endpoints; only the SHA-256 of each key is stored
+		CREATE TABLE IF NOT EXISTS public.api_keys (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name TEXT NOT NULL,
+			key_prefix TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			permissions TEXT[] NOT NULL DEFAULT '{}',
+			created_by UUID REFERENCES auth.users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			expires_at TIMESTAMPTZ,
+			last_used_at TIMESTAMPTZ,
+			revoked_at TIMESTAMPTZ
+		);
+
+		-- Spouse OTPs: only a keyed hash of each code is stored, with its wrong-guess count
+		-- and the requesting IP for throttling. Plaintext codes from before are voided.
+		CREATE TABLE IF NOT EXISTS public.verification_codes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			spouse_email TEXT NOT NULL,
+			code TEXT,
+			expires_at TIMESTAMPTZ NOT NULL,
+			used BOOLEAN NOT NULL DEFAULT FALSE
+		);
+		ALTER TABLE public.verification_codes ADD COLUMN IF NOT EXISTS code_hash TEXT;
+		ALTER TABLE public.verification_codes ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+		ALTER TABLE public.verification_codes ADD COLUMN IF NOT EXISTS requester_ip TEXT;
+		ALTER TABLE public.verification_codes ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ DEFAULT NOW();
+		ALTER TABLE public.verification_codes ALTER COLUMN code DROP NOT NULL;
+		UPDATE public.verification_codes SET code = NULL, used = true WHERE code IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS verification_codes_email_idx ON public.verification_codes (lower(spouse_email), created_at);
+		CREATE INDEX IF NOT EXISTS verification_codes_ip_idx ON public.verification_codes (requester_ip, created_at);
+
+		-- One-time links proving an RFASM participant owns their email before their login is created
+		CREATE TABLE IF NOT EXISTS public.account_claims (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			email TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			requester_ip TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS account_claims_email_idx ON public.account_claims (lower(email), created_at);
+		CREATE INDEX IF NOT EXISTS account_claims_ip_idx ON public.account_claims (requester_ip, created_at);
+
+		-- One-time links proving a participant owns the address they want to move their login to
+		CREATE TABLE IF NOT EXISTS public.email_changes (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID NOT NULL REFERENCES auth.users(id) ON DELETE CASCADE,
+			new_email TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			requester_ip TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			used_at TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS email_changes_email_idx ON public.email_changes (lower(new_email), created_at);
+		CREATE INDEX IF NOT EXISTS email_changes_ip_idx ON public.email_changes (requester_ip, created_at);
+
+		-- Append-only record of privileged admin actions. No FK on actor_id so records
+		-- outlive the user; only the retention purge (audit.allow_purge) may delete rows
+		-- and only a personal data erasure (audit.allow_redact) may blank snapshots.
+		CREATE TABLE IF NOT EXISTS public.audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			actor_id UUID,
+			actor_label TEXT,
+			action TEXT NOT NULL,
+			target_type TEXT NOT NULL,
+			target_id TEXT,
+			before JSONB,
+			after JSONB,
+			ip TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON public.audit_log (actor_id, id DESC);
+		CREATE INDEX IF NOT EXISTS audit_log_target_idx ON public.audit_log (target_type, target_id, id DESC);
+		CREATE INDEX IF NOT EXISTS audit_log_created_idx ON public.audit_log (created_at);
+
+		CREATE OR REPLACE FUNCTION public.audit_log_append_only() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' AND current_setting('audit.allow_purge', true) = 'on' THEN
+				RETURN OLD;
+			END IF;
+			IF TG_OP = 'UPDATE' AND current_setting('audit.allow_redact', true) = 'on'
+				AND (NEW.id, NEW.action, NEW.target_type, NEW.target_id, NEW.created_at)
+					IS NOT DISTINCT FROM (OLD.id, OLD.action, OLD.target_type, OLD.target_id, OLD.created_at) THEN
+				RETURN NEW;
+			END IF;
+			RAISE EXCEPTION 'audit_log is append-only';
+		END;
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS audit_log_append_only ON public.audit_log;
+		CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON public.audit_log
+			FOR EACH ROW EXECUTE FUNCTION public.audit_log_append_only();
+		DROP TRIGGER IF EXISTS audit_log_no_truncate ON public.audit_log;
+		CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON public.audit_log
+			FOR EACH STATEMENT EXECUTE FUNCTION public.audit_log_append_only();
+
+		-- Right-to-erasure requests. A participant (or an admin for them) files one, a super admin
+		-- approves, and everything tied to the email and login is deleted in one job. The email is
+		-- cleared when it completes; email_hash still shows whose request it was.
+		CREATE TABLE IF NOT EXISTS public.erasure_requests (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			user_id UUID,
+			email TEXT,
+			email_hash TEXT NOT NULL,
+			reason TEXT,
+			status TEXT NOT NULL DEFAULT 'pending',
+			requested_by UUID,
+			decided_by UUID,
+			decided_at TIMESTAMPTZ,
+			completed_at TIMESTAMPTZ,
+			summary JSONB,
+			last_error TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS erasure_requests_open_key ON public.erasure_requests (email_hash) WHERE status IN ('pending', 'failed');
+
+		-- Structured quiz questions. answer_key holds the correct option(s), or accepted short
+		-- answers, and is only ever read by the server when grading and by the admin API.
+		CREATE TABLE IF NOT EXISTS public.quiz_questions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			quiz_id UUID NOT NULL REFERENCES public.quizzes(id) ON DELETE CASCADE,
+			sort_order INT NOT NULL DEFAULT 0,
+			kind TEXT NOT NULL CHECK (kind IN ('single_choice', 'multiple_choice', 'true_false', 'short_answer')),
+			prompt TEXT NOT NULL,
+			options JSONB NOT NULL DEFAULT '[]',
+			answer_key JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS quiz_questions_quiz_idx ON public.quiz_questions (quiz_id, sort_order);
+
+		-- Per-quiz timing and attempt policy. The defaults match the original fixed rule: one
+		-- attempt, open for 3 minutes from the lesson's scheduled start, no late window.
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS open_offset_minutes INT NOT NULL DEFAULT 0;
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS duration_minutes INT NOT NULL DEFAULT 3;
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS max_attempts INT NOT NULL DEFAULT 1;
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS attempt_scoring TEXT NOT NULL DEFAULT 'best' CHECK (attempt_scoring IN ('best', 'latest'));
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS late_minutes INT NOT NULL DEFAULT 0;
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS late_penalty_percent INT NOT NULL DEFAULT 0 CHECK (late_penalty_percent BETWEEN 0 AND 100);
+
+		-- Each submission is one attempt. score is after any late penalty; raw_score is before it.
+		ALTER TABLE public.quiz_submissions ADD COLUMN IF NOT EXISTS attempt INT NOT NULL DEFAULT 1;
+		ALTER TABLE public.quiz_submissions ADD COLUMN IF NOT EXISTS raw_score INT;
+		ALTER TABLE public.quiz_submissions ADD COLUMN IF NOT EXISTS is_late BOOLEAN NOT NULL DEFAULT false;
+		ALTER TABLE public.quiz_submissions DROP CONSTRAINT IF EXISTS quiz_submissions_quiz_id_user_id_key;
+		-- One-time numbering of submissions made before attempts existed. attempt_numbered is FALSE
+		-- only on rows that existed when it was added; later rows default to TRUE. Only learners
+		-- with duplicate attempt numbers are renumbered, so live attempts never move.
+		ALTER TABLE public.quiz_submissions ADD COLUMN IF NOT EXISTS attempt_numbered BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE public.quiz_submissions ALTER COLUMN attempt_numbered SET DEFAULT TRUE;
+		UPDATE public.quiz_submissions s SET attempt = n.rn
+		FROM (
+			SELECT id, row_number() OVER (PARTITION BY quiz_id, user_id ORDER BY submitted_at, id) AS rn
+			FROM public.quiz_submissions
+			WHERE NOT attempt_numbered AND (quiz_id, user_id) IN (
+				SELECT quiz_id, user_id FROM public.quiz_submissions
+				GROUP BY quiz_id, user_id HAVING COUNT(*) > COUNT(DISTINCT attempt)
+			)
+		) n
+		WHERE s.id = n.id AND s.attempt <> n.rn;
+		UPDATE public.quiz_submissions SET attempt_numbered = TRUE WHERE NOT attempt_numbered;
+		CREATE UNIQUE INDEX IF NOT EXISTS quiz_submissions_attempt_key ON public.quiz_submissions (quiz_id, user_id, attempt);
+
+		-- Extra attempts granted to one learner. reopen_until lets them take the quiz again
+		-- after its window has closed.
+		CREATE TABLE IF NOT EXISTS public.quiz_attempt_grants (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			quiz_id UUID NOT NULL REFERENCES public.quizzes(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL,
+			extra_attempts INT NOT NULL DEFAULT 1,
+			reopen_until TIMESTAMPTZ,
+			granted_by UUID,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS quiz_attempt_grants_user_idx ON public.quiz_attempt_grants (quiz_id, user_id);
+
+		-- Reusable question banks, tagged by module and topic. Bank questions live in
+		-- quiz_questions with bank_id set instead of quiz_id. A quiz with a bank_id draws
+		-- draw_count of them per attempt instead of using its own questions.
+		CREATE TABLE IF NOT EXISTS public.question_banks (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name TEXT NOT NULL,
+			module_id UUID REFERENCES public.modules(id) ON DELETE SET NULL,
+			topic TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS question_banks_module_idx ON public.question_banks (module_id, topic);
+		ALTER TABLE public.quiz_questions ADD COLUMN IF NOT EXISTS bank_id UUID REFERENCES public.question_banks(id) ON DELETE CASCADE;
+		ALTER TABLE public.quiz_questions ALTER COLUMN quiz_id DROP NOT NULL;
+		ALTER TABLE public.quiz_questions DROP CONSTRAINT IF EXISTS quiz_questions_owner_check;
+		ALTER TABLE public.quiz_questions ADD CONSTRAINT quiz_questions_owner_check CHECK ((quiz_id IS NULL) <> (bank_id IS NULL));
+		CREATE INDEX IF NOT EXISTS quiz_questions_bank_idx ON public.quiz_questions (bank_id, sort_order);
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS bank_id UUID REFERENCES public.question_banks(id) ON DELETE RESTRICT;
+		ALTER TABLE public.quizzes ADD COLUMN IF NOT EXISTS draw_count INT NOT NULL DEFAULT 0;
+
+		-- The exact questions (order, option order and answer keys) one learner got for one
+		-- attempt. Written when the attempt is first shown and used for grading and review.
+		CREATE TABLE IF NOT EXISTS public.quiz_variants (
+			quiz_id UUID NOT NULL REFERENCES public.quizzes(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL,
+			attempt INT NOT NULL,
+			seed TEXT NOT NULL,
+			questions JSONB NOT NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (quiz_id, user_id, attempt)
+		);
+
+		-- Lesson access rules: per program (applies_to all / midpoint / after_midpoint) or per lesson.
+		-- kind picks what config holds: prerequisite lessons, a required review or quiz, an absolute
+		-- or live-relative availability window, or a drip schedule. program_name matches modules.program_name.
+		CREATE TABLE IF NOT EXISTS public.lesson_access_rules (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			program_name TEXT NOT NULL,
+			applies_to TEXT NOT NULL DEFAULT 'all' CHECK (applies_to IN ('all', 'midpoint', 'after_midpoint', 'lesson')),
+			lesson_id UUID REFERENCES public.lessons(id) ON DELETE CASCADE,
+			kind TEXT NOT NULL CHECK (kind IN ('prerequisite', 'review', 'quiz', 'absolute_window', 'relative_window', 'drip')),
+			config JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			CHECK ((applies_to = 'lesson') = (lesson_id IS NOT NULL))
+		);
+		CREATE INDEX IF NOT EXISTS lesson_access_rules_program_idx ON public.lesson_access_rules (program_name);
+
+		-- First boot with access rules: turn each program's checkpoint and rewatch window settings
+		-- (checkpoint_enabled, lesson_lock_hours, pre_checkpoint_lock_hours) into rules. Those columns
+		-- are not read after this. Programs added later start without rules.
+		ALTER TABLE public.programs ADD COLUMN IF NOT EXISTS access_rules_seeded BOOLEAN NOT NULL DEFAULT FALSE;
+		ALTER TABLE public.programs ALTER COLUMN access_rules_seeded SET DEFAULT TRUE;
+		INSERT INTO public.lesson_access_rules (program_name, applies_to, kind, config)
+		SELECT module_name, 'all', 'relative_window',
+			jsonb_build_object('anchor', 'live_end', 'close_hours', CASE WHEN lesson_lock_hours > 0 THEN lesson_lock_hours ELSE 48 END)
+		FROM public.programs WHERE NOT access_rules_seeded
+		UNION ALL
+		SELECT module_name, 'midpoint', 'relative_window', jsonb_build_object('anchor', 'live_end', 'close_hours', pre_checkpoint_lock_hours)
+		FROM public.programs WHERE NOT access_rules_seeded AND pre_checkpoint_lock_hours IS NOT NULL
+		UNION ALL
+		SELECT module_name, 'after_midpoint', 'review', jsonb_build_object(
+			'review_types', jsonb_build_array('mid_cohort', 'mid_video', 'mid_google'),
+			'message', 'Checkpoint Required. Please complete your Mid-Program Review to unlock the second half of the curriculum.')
+		FROM public.programs WHERE NOT access_rules_seeded AND checkpoint_enabled;
+		UPDATE public.programs SET access_rules_seeded = TRUE WHERE NOT access_rules_seeded;
+
 		-- Enable RLS to satisfy Supabase security advisor
 		-- Note: This does not affect our backend queries which connect via direct Postgres pool
 		ALTER TABLE public.giving_commitments ENABLE ROW LEVEL SECURITY;
 		ALTER TABLE public.questions ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.waitlist_offers ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.cohorts ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.lesson_schedules ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.programs ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.outbox ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.user_roles ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.api_keys ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.verification_codes ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.account_claims ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.email_changes ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.audit_log ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.erasure_requests ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.quiz_questions ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.quiz_attempt_grants ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.question_banks ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.quiz_variants ENABLE ROW LEVEL SECURITY;
+		ALTER TABLE public.lesson_access_rules ENABLE ROW LEVEL SECURITY;
 	`)
 	if err != nil {
 		fmt.Printf("⚠️  Table Init Failed: %v\n", err)
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/supabase"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// verifiedSessionTTL bounds how long a token verified over HTTP is trusted
+// without asking Supabase again
+const verifiedSessionTTL = time.Minute
+
+// errTokenRejected marks a token that verified cryptographically but must not
+// be accepted (expired, wrong audience or issuer); asking Supabase won't help
+var errTokenRejected = errors.New("access token rejected")
+
+// jwtAudience is the aud Supabase puts on user sessions
+func jwtAudience() string {
+	if aud := strings.TrimSpace(os.Getenv("SUPABASE_JWT_AUDIENCE")); aud != "" {
+		return aud
+	}
+	return "authenticated"
+}
+
+// jwtIssuer is the iss Supabase puts on user sessions, or "" to skip the check
+func jwtIssuer() string {
+	if iss := strings.TrimSpace(os.Getenv("SUPABASE_JWT_ISSUER")); iss != "" {
+		return iss
+	}
+	if base := strings.TrimSuffix(strings.TrimSpace(os.Getenv("SUPABASE_URL")), "/"); base != "" {
+		return base + "/auth/v1"
+	}
+	return ""
+}
+
+// verifyAccessTokenLocally checks a Supabase access token without a network call
+// (apart from an occasional JWKS refresh). HS256 tokens use SUPABASE_JWT_SECRET;
+// RS256 and ES256 tokens use the project's published keys, looked up by kid.
+func verifyAccessTokenLocally(ctx context.Context, tokenStr string) (string, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		jwt.WithAudience(jwtAudience()),
+		jwt.WithExpirationRequired(),
+	}
+	if iss := jwtIssuer(); iss != "" {
+		opts = append(opts, jwt.WithIssuer(iss))
+	}
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			secret := strings.TrimSpace(os.Getenv("SUPABASE_JWT_SECRET"))
+			if secret == "" {
+				return nil, fmt.Errorf("SUPABASE_JWT_SECRET not set")
+			}
+			return []byte(secret), nil
+		default:
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				return nil, fmt.Errorf("asymmetric token without kid")
+			}
+			auth := supabase.Default()
+			if !auth.Configured() {
+				return nil, supabase.ErrNotConfigured
+			}
+			return auth.JWKS().Key(ctx, kid)
+		}
+	}, opts...)
+	if err != nil {
+		// The signature checked out (claims are only validated after it), so these are final
+		if errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenInvalidAudience) ||
+			errors.Is(err, jwt.ErrTokenInvalidIssuer) || errors.Is(err, jwt.ErrTokenMalformed) {
+			return "", fmt.Errorf("%w: %v", errTokenRejected, err)
+		}
+		return "", err
+	}
+
+	sub, err := token.Claims.GetSubject()
+	if err != nil || sub == "" {
+		return "", fmt.Errorf("%w: missing or invalid sub claim", errTokenRejected)
+	}
+	return sub, nil
+}
+
+// verifiedSessions remembers tokens Supabase confirmed over HTTP
+var (
+	verifiedSessions   = map[[32]byte]verifiedSession{}
+	verifiedSessionsMu sync.Mutex
+)
+
+type verifiedSession struct {
+	userID  string
+	expires time.Time
+}
+
+func cachedSession(token string) (string, bool) {
+	key := sha256.Sum256([]byte(token))
+	verifiedSessionsMu.Lock()
+	defer verifiedSessionsMu.Unlock()
+	s, ok := verifiedSessions[key]
+	if !ok || time.Now().After(s.expires) {
+		return "", false
+	}
+	return s.userID, true
+}
+
+// rememberSession caches a verified token until the sooner of verifiedSessionTTL
+// and its own exp
+func rememberSession(token, userID string) {
+	expires := time.Now().Add(verifiedSessionTTL)
+	if claims, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{}); err == nil {
+		if exp, err := claims.Claims.GetExpirationTime(); err == nil && exp != nil && exp.Before(expires) {
+			expires = exp.Time
+		}
+	}
+
+	key := sha256.Sum256([]byte(token))
+	verifiedSessionsMu.Lock()
+	defer verifiedSessionsMu.Unlock()
+	if len(verifiedSessions) > 10000 {
+		now := time.Now()
+		for k, s := range verifiedSessions {
+			if now.After(s.expires) {
+				delete(verifiedSessions, k)
+			}
+		}
+	}
+	verifiedSessions[key] = verifiedSession{userID: userID, expires: expires}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/asejik/soulmate-reg/server/services"
+	"github.com/asejik/soulmate-reg/server/supabase"
+)
+
+// accountClaimTTL is how long an emailed claim link stays usable
+const accountClaimTTL = time.Hour
+
+// accountClaimURL builds the link that proves the participant can read their mailbox
+func accountClaimURL(token string) string {
+	return clientURL("/register?claim=" + token)
+}
+
+// startAccountClaim emails a one-time claim link to a Ready for a Soulmate participant.
+// Nothing is created in Supabase until the link is redeemed.
+func startAccountClaim(w http.ResponseWriter, r *http.Request, email string) {
+	ip := clientIP(r)
+	wait, err := sendThrottle(r.Context(), db.Pool, "account_claims", "email", email, ip)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to send claim link."})
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"message": "A claim link was sent recently. Please check your inbox or wait before trying again."})
+		return
+	}
+
+	var name string
+	db.Pool.QueryRow(r.Context(), `SELECT COALESCE(full_name, '') FROM public.participants WHERE lower(email) = lower($1) LIMIT 1`, email).Scan(&name)
+
+	token, err := newLinkToken()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to send claim link."})
+		return
+	}
+	expiresAt := time.Now().UTC().Add(accountClaimTTL)
+
+	// A new link replaces any still outstanding, and is only stored if its email is queued
+	tx, err := db.Pool.Begin(r.Context())
+	if err == nil {
+		defer tx.Rollback(r.Context())
+		_, err = tx.Exec(r.Context(), `
+			UPDATE public.account_claims SET expires_at = NOW()
+			WHERE lower(email) = lower($1) AND used_at IS NULL AND expires_at > NOW()
+		`, email)
+	}
+	if err == nil {
+		_, err = tx.Exec(r.Context(), `
+			INSERT INTO public.account_claims (email, token_hash, requester_ip, expires_at)
+			VALUES ($1, $2, $3, $4)
+		`, email, hashLinkToken(token), ip, expiresAt)
+	}
+	if err == nil {
+		err = enqueueOutbox(r.Context(), tx, outboxAccountClaimEmail, services.AccountClaimEmailData{
+			Name:      name,
+			Email:     email,
+			ClaimLink: accountClaimURL(token),
+			ExpiresAt: expiresAt,
+		})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to send claim link."})
+		return
+	}
+	notifyOutbox()
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":       "We've emailed you a link to confirm your address. Open it to choose your password.",
+		"pending_claim": true,
+	})
+}
+
+// redeemAccountClaim creates the Auth user for the email a claim link was sent to
+func redeemAccountClaim(w http.ResponseWriter, r *http.Request, token, password string) {
+	if len(password) < 6 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Password must be at least 6 characters."})
+		return
+	}
+
+	// Validate and consume in one statement so a link can only ever be redeemed once
+	var id, email string
+	err := db.Pool.QueryRow(r.Context(), `
+		UPDATE public.account_claims SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id::text, email
+	`, hashLinkToken(token)).Scan(&id, &email)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "This claim link is invalid, expired or already used. Please request a new one."})
+		return
+	}
+
+	if err := createAuthUser(r.Context(), email, password); err != nil {
+		// Hand the link back so a transient failure doesn't cost the participant their link
+		db.Pool.Exec(r.Context(), "UPDATE public.account_claims SET used_at = NULL WHERE id::text = $1", id)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": claimFailedMessage(err)})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Account successfully claimed! You can now log in.",
+		"email":   email,
+	})
+}
+
+// createAuthUser creates a confirmed Supabase login and links the email's registrations to it
+func createAuthUser(ctx context.Context, email, password string) error {
+	user, err := supabase.Default().CreateUser(ctx, supabase.CreateUserParams{
+		Email:        email,
+		Password:     password,
+		EmailConfirm: true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := linkRegistrations(ctx, db.Pool, user.ID, email); err != nil {
+		// The login exists either way; the startup backfill links it if this didn't
+		log.Printf("[Claim] Failed to link registrations for %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+// linkRegistrations points every unlinked registration for email at the login userID
+func linkRegistrations(ctx context.Context, q execer, userID, email string) error {
+	programs, err := loadPrograms(ctx)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for _, p := range programs {
+		if seen[p.RegistrationTable] {
+			continue
+		}
+		seen[p.RegistrationTable] = true
+		_, err := q.Exec(ctx, "UPDATE "+p.registrationIdent()+" SET user_id = $1 WHERE user_id IS NULL AND lower(email) = lower($2)", userID, email)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimFailedMessage explains a failed createAuthUser to the participant
+func claimFailedMessage(err error) string {
+	if supabase.IsEmailExists(err) {
+		return "This account has already been claimed. Please log in or reset your password."
+	}
+	return "Failed to create account. You may have already claimed it, or your password is too weak (min 6 characters)."
+}
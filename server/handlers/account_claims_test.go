@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateAuthUser(t *testing.T) {
+	srv := newAuthFake(t)
+
+	// An empty registry keeps linkRegistrations off the database
+	programsMutex.Lock()
+	programsCache, programsCacheTs = []Program{}, time.Now()
+	programsMutex.Unlock()
+	t.Cleanup(func() {
+		programsMutex.Lock()
+		programsCache = nil
+		programsMutex.Unlock()
+	})
+
+	ctx := context.Background()
+	if err := createAuthUser(ctx, "Ada@Example.com", "secret1"); err != nil {
+		t.Fatalf("createAuthUser: %v", err)
+	}
+	u, ok := srv.UserByEmail("ada@example.com")
+	if !ok {
+		t.Fatal("no user was created")
+	}
+	if u.EmailConfirmedAt == nil {
+		t.Error("claimed account should be confirmed")
+	}
+	if !srv.CheckPassword("ada@example.com", "secret1") {
+		t.Error("claimed account doesn't accept its password")
+	}
+
+	err := createAuthUser(ctx, "ada@example.com", "secret2")
+	if err == nil {
+		t.Fatal("claiming the same email twice succeeded")
+	}
+	if msg := claimFailedMessage(err); !strings.Contains(msg, "already been claimed") {
+		t.Errorf("claimFailedMessage = %q", msg)
+	}
+}
@@ -3,9 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 
 	"github.com/asejik/soulmate-reg/server/db"
 )
@@ -37,19 +38,27 @@ type Participant struct {
 
 // --- Middleware ---
 
-// AdminAuth protects routes by checking the X-Admin-Secret header
-func AdminAuth(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		secret := r.Header.Get("X-Admin-Secret")
-		expected := os.Getenv("ADMIN_SECRET")
+// AdminAuth authenticates every admin route. A bearer token starting with
+// apiKeyPrefix must be a live API key; anything else must be a Supabase JWT,
+// after which the caller's roles are loaded. Routes then declare what they
+// need with RequirePermission.
+func AdminAuth(next http.Handler) http.Handler {
+	userPipeline := LMSAuth(LoadRoles(next))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !strings.HasPrefix(token, apiKeyPrefix) {
+			userPipeline.ServeHTTP(w, r)
+			return
+		}
 
-		// Secure comparison: If env is empty or secret is wrong, deny.
-		if expected == "" || secret != expected {
-			http.Error(w, "Unauthorized Access", http.StatusUnauthorized)
+		key, err := lookupAPIKey(r.Context(), token)
+		if err != nil {
+			http.Error(w, "Invalid, expired or revoked API key", http.StatusUnauthorized)
 			return
 		}
-		next(w, r)
-	}
+		log.Printf("[AdminAuth] API key %q (%s) %s %s", key.Name, key.Prefix, r.Method, r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey, key)))
+	})
 }
 
 // --- Handlers ---
@@ -101,7 +110,7 @@ func GetClanParticipants(w http.ResponseWriter, r *http.Request) {
 		SELECT id, full_name, email, whatsapp_number, gender, country, state,
 		       age_group, religion, church_name, instagram_handle, relationship_status, clan_id
 		FROM participants
-		WHERE clan_id = $1
+		WHERE clan_id = $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC LIMIT 2000
 	`, clanID)
 
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// apiKeyPrefix marks a bearer token as an API key rather than a Supabase JWT
+const apiKeyPrefix = "tak_"
+
+const apiKeyCtxKey contextKey = "api_key"
+
+// apiKey is a machine caller authenticated by AdminAuth. It has no user identity,
+// only the permissions it was created with.
+type apiKey struct {
+	ID          string
+	Name        string
+	Prefix      string
+	Permissions []string
+}
+
+func (k *apiKey) can(perm string) bool {
+	if k == nil {
+		return false
+	}
+	for _, p := range k.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func apiKeyFrom(ctx context.Context) *apiKey {
+	k, _ := ctx.Value(apiKeyCtxKey).(*apiKey)
+	return k
+}
+
+// apiKeyGrantable reports whether a permission may be given to an API key.
+// Managing roles and keys always needs a person.
+func apiKeyGrantable(perm string) bool {
+	if perm == PermRolesManage || perm == PermAPIKeysManage {
+		return false
+	}
+	for _, p := range allPermissions() {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupAPIKey resolves a presented key, rejecting revoked and expired ones
+func lookupAPIKey(ctx context.Context, key string) (*apiKey, error) {
+	k := &apiKey{}
+	err := db.Pool.QueryRow(ctx, `
+		SELECT id::text, name, key_prefix, permissions FROM public.api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, hashAPIKey(key)).Scan(&k.ID, &k.Name, &k.Prefix, &k.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	// Minute resolution is plenty and keeps busy integrations from writing on every call
+	db.Pool.Exec(ctx, `
+		UPDATE public.api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, k.ID)
+	return k, nil
+}
+
+// --- Admin: API key management ---
+
+type APIKeyInfo struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	KeyPrefix   string     `json:"key_prefix"`
+	Permissions []string   `json:"permissions"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// GetAdminAPIKeys lists keys without their secrets
+func GetAdminAPIKeys(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT k.id::text, k.name, k.key_prefix, k.permissions, COALESCE(u.email, ''), k.created_at, k.expires_at, k.last_used_at, k.revoked_at
+		FROM public.api_keys k
+		LEFT JOIN auth.users u ON u.id = k.created_by
+		ORDER BY k.created_at DESC
+	`)
+	if err != nil {
+		http.Error(w, "Failed to fetch API keys", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	keys := []APIKeyInfo{}
+	for rows.Next() {
+		var k APIKeyInfo
+		if err := rows.Scan(&k.ID, &k.Name, &k.KeyPrefix, &k.Permissions, &k.CreatedBy, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err == nil {
+			keys = append(keys, k)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// CreateAdminAPIKey issues a key. The plaintext is returned once and never stored.
+func CreateAdminAPIKey(w http.ResponseWriter, r *http.Request) {
+	creatorID := r.Context().Value(userIDKey).(string)
+
+	var req struct {
+		Name          string   `json:"name"`
+		Permissions   []string `json:"permissions"`
+		ExpiresInDays int      `json:"expires_in_days"` // 0 = never
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	errs := fieldErrors{}
+	req.Name = strings.TrimSpace(req.Name)
+	errs.required("name", req.Name, "Name")
+	errs.maxLen("name", req.Name, maxTextLen)
+	if len(req.Permissions) == 0 {
+		errs.add("permissions", "Choose at least one permission.")
+	}
+	for _, p := range req.Permissions {
+		if !apiKeyGrantable(p) {
+			errs.add("permissions", "Permission "+p+" can't be given to an API key.")
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		errs.add("expires_in_days", "Must be zero (never) or a number of days.")
+	}
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		http.Error(w, "Failed to generate key", http.StatusInternalServerError)
+		return
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var id string
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		INSERT INTO public.api_keys AS k (name, key_prefix, key_hash, permissions, created_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id::text, to_jsonb(k) - 'key_hash'
+	`, req.Name, key[:len(apiKeyPrefix)+8], hashAPIKey(key), req.Permissions, creatorID, expiresAt).Scan(&id, &after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditAPIKeyCreate, TargetType: "api_key", TargetID: id, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          id,
+		"name":        req.Name,
+		"key":         key,
+		"permissions": req.Permissions,
+		"expires_at":  expiresAt,
+		"message":     "Copy this key now. It can't be shown again.",
+	})
+}
+
+// RevokeAdminAPIKey disables a key (?id=) immediately
+func RevokeAdminAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		UPDATE public.api_keys k SET revoked_at = NOW() WHERE id::text = $1 AND revoked_at IS NULL
+		RETURNING to_jsonb(k) - 'key_hash'
+	`, id).Scan(&after)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "API key not found or already revoked", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditAPIKeyRevoke, TargetType: "api_key", TargetID: id, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "API key revoked"})
+}
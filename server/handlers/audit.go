@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+)
+
+// Audited actions
+const (
+	auditUserCreate            = "user.create"
+	auditUserDelete            = "user.delete"
+	auditUserRestore           = "user.restore"
+	auditUserEmailChange       = "user.email_change"
+	auditProgressReset         = "progress.reset"
+	auditModuleDelete          = "module.delete"
+	auditModuleRestore         = "module.restore"
+	auditLessonDelete          = "lesson.delete"
+	auditLessonRestore         = "lesson.restore"
+	auditCommentDelete         = "comment.delete"
+	auditCommentRestore        = "comment.restore"
+	auditSubmissionFeedback    = "submission.feedback"
+	auditQuizAttemptGrant      = "quiz.grant_attempt"
+	auditQuestionBankDelete    = "question_bank.delete"
+	auditAccessRuleSave        = "access_rule.save"
+	auditAccessRuleDelete      = "access_rule.delete"
+	auditProgramSettingsUpdate = "program_settings.update"
+	auditProgramSave           = "program.save"
+	auditCohortSave            = "cohort.save"
+	auditClanCreate            = "clan.create"
+	auditCohortScheduleSave    = "cohort_schedule.save"
+	auditOutboxReplay          = "outbox.replay"
+	auditRoleGrant             = "role.grant"
+	auditRoleRevoke            = "role.revoke"
+	auditAPIKeyCreate          = "api_key.create"
+	auditAPIKeyRevoke          = "api_key.revoke"
+	auditUserErase             = "user.erase"
+	auditErasureReject         = "erasure.reject"
+)
+
+// auditEntry describes one privileged change. Before and After are row snapshots,
+// usually json.RawMessage from to_jsonb(); nil means "didn't exist" / "gone".
+type auditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+}
+
+func auditJSON(v any) []byte {
+	if v == nil {
+		return nil
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		if len(raw) == 0 {
+			return nil
+		}
+		return raw
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// recordAudit appends to the audit log. Pass the transaction that made the
+// change so the record exists if and only if the change does.
+func recordAudit(r *http.Request, q execer, e auditEntry) error {
+	var actorID, actorLabel *string
+	if uid, ok := r.Context().Value(userIDKey).(string); ok && uid != "" {
+		actorID = &uid
+	} else if key := apiKeyFrom(r.Context()); key != nil {
+		label := "api_key:" + key.Name + " (" + key.Prefix + ")"
+		actorLabel = &label
+	}
+
+	_, err := q.Exec(r.Context(), `
+		INSERT INTO public.audit_log (actor_id, actor_label, action, target_type, target_id, before, after, ip)
+		VALUES ($1::uuid, COALESCE($2, (SELECT email FROM auth.users WHERE id = $1::uuid)), $3, $4, $5, $6, $7, $8)
+	`, actorID, actorLabel, e.Action, e.TargetType, e.TargetID, auditJSON(e.Before), auditJSON(e.After), clientIP(r))
+	return err
+}
+
+// auditRetention is how long records are kept; AUDIT_RETENTION_DAYS=0 keeps them forever
+func auditRetention() time.Duration {
+	if v := os.Getenv("AUDIT_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return 365 * 24 * time.Hour
+}
+
+// purgeAuditLog deletes records past retention. The table refuses deletes
+// unless audit.allow_purge is set for the transaction.
+func purgeAuditLog(ctx context.Context) (int64, error) {
+	retention := auditRetention()
+	if retention == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SET LOCAL audit.allow_purge = 'on'"); err != nil {
+		return 0, err
+	}
+	tag, err := tx.Exec(ctx, "DELETE FROM public.audit_log WHERE created_at < $1", time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), tx.Commit(ctx)
+}
+
+// StartAuditRetention purges expired audit records every interval until ctx is cancelled
+func StartAuditRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := purgeAuditLog(ctx); err != nil {
+			log.Printf("[Audit] Retention purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[Audit] Purged %d records past retention", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// --- Admin: audit log ---
+
+type AuditRecord struct {
+	ID         int64           `json:"id"`
+	ActorID    *string         `json:"actor_id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IP         string          `json:"ip"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// GetAdminAuditLog lists audit records, newest first. Filters (all optional):
+// ?actor= (user id, email or API key label), ?action=, ?target_type=, ?target_id=,
+// ?from= and ?to= (RFC 3339 or YYYY-MM-DD), ?before_id= to page, ?limit= (max 500).
+func GetAdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	parseTime := func(field, v string) (*time.Time, bool) {
+		if v == "" {
+			return nil, true
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return &t, true
+			}
+		}
+		http.Error(w, field+" must be RFC 3339 or YYYY-MM-DD", http.StatusBadRequest)
+		return nil, false
+	}
+	from, ok := parseTime("from", q.Get("from"))
+	if !ok {
+		return
+	}
+	to, ok := parseTime("to", q.Get("to"))
+	if !ok {
+		return
+	}
+	if to != nil && len(q.Get("to")) == len("2006-01-02") {
+		end := to.Add(24 * time.Hour) // a bare date includes the whole day
+		to = &end
+	}
+
+	limit := 100
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 {
+		limit = min(v, 500)
+	}
+	var beforeID int64
+	if v := q.Get("before_id"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "before_id must be a number", http.StatusBadRequest)
+			return
+		}
+		beforeID = n
+	}
+
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT id, actor_id::text, COALESCE(actor_label, ''), action, target_type, COALESCE(target_id, ''),
+		       before, after, COALESCE(ip, ''), created_at
+		FROM public.audit_log
+		WHERE ($1 = '' OR actor_id::text = $1 OR lower(actor_label) = lower($1))
+		  AND ($2 = '' OR action = $2)
+		  AND ($3 = '' OR target_type = $3)
+		  AND ($4 = '' OR target_id = $4)
+		  AND ($5::timestamptz IS NULL OR created_at >= $5)
+		  AND ($6::timestamptz IS NULL OR created_at < $6)
+		  AND ($7 = 0 OR id < $7)
+		ORDER BY id DESC
+		LIMIT $8
+	`, q.Get("actor"), q.Get("action"), q.Get("target_type"), q.Get("target_id"), from, to, beforeID, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch audit log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	records := []AuditRecord{}
+	for rows.Next() {
+		var a AuditRecord
+		if err := rows.Scan(&a.ID, &a.ActorID, &a.Actor, &a.Action, &a.TargetType, &a.TargetID, &a.Before, &a.After, &a.IP, &a.CreatedAt); err == nil {
+			records = append(records, a)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
@@ -1,29 +1,24 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"math/rand"
+	"errors"
+	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
-	"github.com/asejik/soulmate-reg/server/services"
+	"github.com/jackc/pgx/v5"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 type ClaimAccountRequest struct {
 	Email       string `json:"email"`
 	Password    string `json:"password"`
 	SpouseEmail string `json:"spouseEmail,omitempty"`
 	Code        string `json:"code,omitempty"`
+	ClaimToken  string `json:"claimToken,omitempty"` // from the emailed RFASM claim link
 }
 
 type RequestOTPRequest struct {
@@ -40,34 +35,76 @@ func RequestOTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var exists bool
-	err := db.Pool.QueryRow(r.Context(), `SELECT EXISTS (SELECT 1 FROM public.couples_launchpad WHERE lower(email) = lower($1))`, req.SpouseEmail).Scan(&exists)
+	err := db.Pool.QueryRow(r.Context(), `SELECT EXISTS (SELECT 1 FROM public.couples_launchpad WHERE lower(email) = lower($1) AND deleted_at IS NULL)`, req.SpouseEmail).Scan(&exists)
 	if err != nil || !exists {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Spouse email not found in registration."})
 		return
 	}
 
-	code := fmt.Sprintf("%06d", rand.Intn(1000000))
-
-	_, err = db.Pool.Exec(r.Context(), `
-		INSERT INTO public.verification_codes (spouse_email, code, expires_at)
-		VALUES ($1, $2, NOW() + INTERVAL '15 minutes')
-	`, req.SpouseEmail, code)
+	ip := clientIP(r)
+	wait, err := sendThrottle(r.Context(), db.Pool, "verification_codes", "spouse_email", req.SpouseEmail, ip)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to generate code."})
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Too many code requests. Please wait before requesting another."})
+		return
+	}
 
+	code, err := generateOTP()
+	var codeHash string
+	if err == nil {
+		codeHash, err = hashOTP(req.SpouseEmail, code)
+	}
 	if err != nil {
+		log.Printf("[OTP] Failed to generate code: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to generate code."})
 		return
 	}
 
-	// Send email
-	go services.SendOTPEmail(req.SpouseEmail, code)
+	// Store the code and queue its email together so a code is never issued without being sent.
+	// Only the hash is kept; a new code replaces any still outstanding.
+	tx, err := db.Pool.Begin(r.Context())
+	if err == nil {
+		defer tx.Rollback(r.Context())
+		_, err = tx.Exec(r.Context(), `
+			UPDATE public.verification_codes SET used = true
+			WHERE lower(spouse_email) = lower($1) AND used = false
+		`, req.SpouseEmail)
+	}
+	if err == nil {
+		_, err = tx.Exec(r.Context(), `
+			INSERT INTO public.verification_codes (spouse_email, code_hash, requester_ip, expires_at)
+			VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		`, req.SpouseEmail, codeHash, ip, int(otpTTL.Seconds()))
+	}
+	if err == nil {
+		err = enqueueOutbox(r.Context(), tx, outboxOTPEmail, otpEmailPayload{Email: req.SpouseEmail, Code: code})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to generate code."})
+		return
+	}
+	notifyOutbox()
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Code sent to spouse's email."})
 }
 
-// ClaimAccount verifies the email against registrations and creates the Auth user
+// ClaimAccount verifies the email against registrations and creates the Auth user.
+// Launchpad couples prove themselves with their spouse's OTP; RFASM participants are
+// sent a claim link and the account is created when they redeem it with a password.
 func ClaimAccount(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -78,17 +115,22 @@ func ClaimAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Email == "" || req.Password == "" {
+	if req.ClaimToken != "" {
+		redeemAccountClaim(w, r, req.ClaimToken, req.Password)
+		return
+	}
+
+	if req.Email == "" {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Email and password are required"})
+		json.NewEncoder(w).Encode(map[string]string{"message": "Email is required"})
 		return
 	}
 
 	var isLaunchpad, isRFASM bool
 	err := db.Pool.QueryRow(r.Context(), `
 		SELECT 
-			EXISTS(SELECT 1 FROM public.couples_launchpad WHERE lower(email) = lower($1)),
-			EXISTS(SELECT 1 FROM public.participants WHERE lower(email) = lower($1))
+			EXISTS(SELECT 1 FROM public.couples_launchpad WHERE lower(email) = lower($1) AND deleted_at IS NULL),
+			EXISTS(SELECT 1 FROM public.participants WHERE lower(email) = lower($1) AND deleted_at IS NULL)
 	`, req.Email).Scan(&isLaunchpad, &isRFASM)
 
 	if err != nil {
@@ -105,84 +147,86 @@ func ClaimAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// RFASM participants must prove they own the mailbox first
+	if !isLaunchpad {
+		startAccountClaim(w, r, req.Email)
+		return
+	}
+
 	// For Launchpad, enforce the Spouse OTP Flow
-	if isLaunchpad && req.Code == "" {
+	if req.Code == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"message": "Launchpad users must use the OTP verification flow."})
 		return
 	}
 
-	if isLaunchpad && req.Code != "" {
-		// Enforce that they didn't input their own email as spouseEmail
-		if strings.EqualFold(req.Email, req.SpouseEmail) {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"message": "You cannot use your own email to verify your account."})
-			return
-		}
-
-		// Enforce that spouseEmail is actually linked to req.Email in couples_launchpad
-		connected, err := verifySpouseConnection(r.Context(), req.Email, req.SpouseEmail)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"message": "Database verification failed during spouse connection check"})
-			return
-		}
-		if !connected {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"message": "The provided spouse email is not registered as your partner."})
-			return
-		}
-
-		var valid bool
-		err = db.Pool.QueryRow(r.Context(), `
-			SELECT EXISTS(
-				SELECT 1 FROM public.verification_codes 
-				WHERE lower(spouse_email) = lower($1) AND code = $2 AND used = false AND expires_at > NOW()
-			)
-		`, req.SpouseEmail, req.Code).Scan(&valid)
-
-		if err != nil || !valid {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"message": "Invalid or expired verification code."})
-			return
-		}
-
-		// Mark code as used
-		db.Pool.Exec(r.Context(), "UPDATE public.verification_codes SET used = true WHERE lower(spouse_email) = lower($1) AND code = $2", req.SpouseEmail, req.Code)
+	if req.Password == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Email and password are required"})
+		return
 	}
 
-	// 3. CREATE: The email is valid. Create the user via Supabase Admin API.
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	serviceKey := os.Getenv("SUPABASE_SERVICE_ROLE_KEY")
+	// Enforce that they didn't input their own email as spouseEmail
+	if strings.EqualFold(req.Email, req.SpouseEmail) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "You cannot use your own email to verify your account."})
+		return
+	}
 
-	adminReqBody, _ := json.Marshal(map[string]interface{}{
-		"email":         req.Email,
-		"password":      req.Password,
-		"email_confirm": true,
-	})
+	// Enforce that spouseEmail is actually linked to req.Email in couples_launchpad
+	connected, err := verifySpouseConnection(r.Context(), req.Email, req.SpouseEmail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Database verification failed during spouse connection check"})
+		return
+	}
+	if !connected {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "The provided spouse email is not registered as your partner."})
+		return
+	}
 
-	httpReq, err := http.NewRequest("POST", supabaseURL+"/auth/v1/admin/users", bytes.NewBuffer(adminReqBody))
+	codeHash, err := hashOTP(req.SpouseEmail, req.Code)
 	if err != nil {
+		log.Printf("[OTP] Cannot check code: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to build auth request"})
+		json.NewEncoder(w).Encode(map[string]string{"message": "Database verification failed"})
 		return
 	}
 
-	httpReq.Header.Set("apikey", serviceKey)
-	httpReq.Header.Set("Authorization", "Bearer "+serviceKey)
-	httpReq.Header.Set("Content-Type", "application/json")
+	// Count the attempt, check the code and consume it in one statement. The row lock
+	// makes parallel guesses queue, so each sees the attempts counted before it and the
+	// code dies at the limit however many arrive at once.
+	var matched bool
+	var attempts int
+	err = db.Pool.QueryRow(r.Context(), `
+		UPDATE public.verification_codes
+		SET attempts = attempts + 1, used = (code_hash = $2 OR attempts + 1 >= $3)
+		WHERE lower(spouse_email) = lower($1) AND used = false AND expires_at > NOW() AND attempts < $3
+		RETURNING code_hash = $2, attempts
+	`, req.SpouseEmail, codeHash, otpMaxAttempts).Scan(&matched, &attempts)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Database verification failed"})
+		return
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
+	if !matched {
+		msg := "Invalid or expired verification code."
+		if attempts >= otpMaxAttempts {
+			msg = "Too many incorrect attempts. Please request a new code."
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": msg})
+		return
+	}
 
-	if err != nil || resp.StatusCode >= 400 {
+	// 3. CREATE: The email is valid. Create the user via Supabase Admin API.
+	if err := createAuthUser(r.Context(), req.Email, req.Password); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"message": "Failed to create account. You may have already claimed it, or your password is too weak (min 6 characters).",
-		})
+		json.NewEncoder(w).Encode(map[string]string{"message": claimFailedMessage(err)})
 		return
 	}
-	defer resp.Body.Close()
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -196,28 +240,28 @@ func verifySpouseConnection(ctx context.Context, email, spouseEmail string) (boo
 		return false, nil
 	}
 
-	var wa1, swa1, name1, sname1 string
+	var wa1, swa1, name1, sname1, country1 string
 	err := db.Pool.QueryRow(ctx, `
-		SELECT COALESCE(whatsapp_number, ''), COALESCE(spouse_whatsapp, ''), COALESCE(full_name, ''), COALESCE(spouse_name, '') 
+		SELECT COALESCE(whatsapp_number, ''), COALESCE(spouse_whatsapp, ''), COALESCE(full_name, ''), COALESCE(spouse_name, ''), COALESCE(country_city, '')
 		FROM public.couples_launchpad 
-		WHERE lower(email) = lower($1)
-	`, email).Scan(&wa1, &swa1, &name1, &sname1)
+		WHERE lower(email) = lower($1) AND deleted_at IS NULL
+	`, email).Scan(&wa1, &swa1, &name1, &sname1, &country1)
 	if err != nil {
 		return false, err
 	}
 
-	var wa2, swa2, name2, sname2 string
+	var wa2, swa2, name2, sname2, country2 string
 	err = db.Pool.QueryRow(ctx, `
-		SELECT COALESCE(whatsapp_number, ''), COALESCE(spouse_whatsapp, ''), COALESCE(full_name, ''), COALESCE(spouse_name, '') 
+		SELECT COALESCE(whatsapp_number, ''), COALESCE(spouse_whatsapp, ''), COALESCE(full_name, ''), COALESCE(spouse_name, ''), COALESCE(country_city, '')
 		FROM public.couples_launchpad 
-		WHERE lower(email) = lower($1)
-	`, spouseEmail).Scan(&wa2, &swa2, &name2, &sname2)
+		WHERE lower(email) = lower($1) AND deleted_at IS NULL
+	`, spouseEmail).Scan(&wa2, &swa2, &name2, &sname2, &country2)
 	if err != nil {
 		return false, err
 	}
 
-	// 1. Phone match check
-	if isPhoneMatch(swa1, wa2) || isPhoneMatch(wa1, swa2) {
+	// 1. Phone match check (each side's country disambiguates national-format numbers)
+	if isPhoneMatch(swa1, country1, wa2, country2) || isPhoneMatch(wa1, country1, swa2, country2) {
 		return true, nil
 	}
 
@@ -229,24 +273,12 @@ func verifySpouseConnection(ctx context.Context, email, spouseEmail string) (boo
 	return false, nil
 }
 
-func cleanPhone(p string) string {
-	var digits []rune
-	for _, r := range p {
-		if r >= '0' && r <= '9' {
-			digits = append(digits, r)
-		}
-	}
-	s := string(digits)
-	if len(s) > 10 {
-		return s[len(s)-10:]
-	}
-	return s
-}
-
-func isPhoneMatch(p1, p2 string) bool {
-	c1 := cleanPhone(p1)
-	c2 := cleanPhone(p2)
-	return c1 != "" && c1 == c2
+// isPhoneMatch compares two numbers in E.164 so the same digits under different
+// country codes don't collide
+func isPhoneMatch(p1, hint1, p2, hint2 string) bool {
+	e1, err1 := normalizePhone(p1, hint1)
+	e2, err2 := normalizePhone(p2, hint2)
+	return err1 == nil && err2 == nil && e1 == e2
 }
 
 func checkNameMatch(spouseName, fullName string) bool {
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// clanCandidate is the demographic profile a strategy may use to place someone
+type clanCandidate struct {
+	Gender   string
+	Country  string
+	State    string
+	AgeGroup string
+}
+
+// assignedClan is the clan a strategy picked (and locked) for a new participant
+type assignedClan struct {
+	ID           int64
+	Name         string
+	WhatsAppLink string
+}
+
+// clanAssigner selects an open clan of a cohort inside the caller's transaction.
+// Implementations must lock the chosen row with FOR UPDATE so two registrations
+// can never take the last seat at the same time, and return pgx.ErrNoRows when
+// every clan is full. The caller is responsible for incrementing current_count.
+type clanAssigner interface {
+	pickClan(ctx context.Context, tx pgx.Tx, cohortID string, c clanCandidate) (assignedClan, error)
+}
+
+const defaultClanStrategy = "first_fit"
+
+// clanAssigners is the registry of strategies selectable via cohorts.clan_strategy
+// (or program_settings.clan_strategy as the program-wide default)
+var clanAssigners = map[string]clanAssigner{
+	"first_fit":             firstFitAssigner{},
+	"round_robin":           roundRobinAssigner{},
+	"least_filled":          leastFilledAssigner{},
+	"balanced_demographics": demographicAssigner{},
+}
+
+func isValidClanStrategy(name string) bool {
+	_, ok := clanAssigners[name]
+	return ok
+}
+
+// clanAssignerFor loads the strategy configured on the cohort, then the program default, then first-fit
+func clanAssignerFor(ctx context.Context, tx pgx.Tx, cohortID string) clanAssigner {
+	var name string
+	tx.QueryRow(ctx, `
+		SELECT COALESCE(c.clan_strategy, ps.clan_strategy, '')
+		FROM public.cohorts c
+		LEFT JOIN public.program_settings ps ON ps.program_name = c.program_name
+		WHERE c.id = $1
+	`, cohortID).Scan(&name)
+	if a, ok := clanAssigners[name]; ok {
+		return a
+	}
+	return clanAssigners[defaultClanStrategy]
+}
+
+func scanAssignedClan(row pgx.Row) (assignedClan, error) {
+	var c assignedClan
+	err := row.Scan(&c.ID, &c.Name, &c.WhatsAppLink)
+	return c, err
+}
+
+// firstFitAssigner fills clans one at a time in id order (the original behaviour)
+type firstFitAssigner struct{}
+
+func (firstFitAssigner) pickClan(ctx context.Context, tx pgx.Tx, cohortID string, _ clanCandidate) (assignedClan, error) {
+	return scanAssignedClan(tx.QueryRow(ctx, `
+		SELECT id, name, whatsapp_link
+		FROM clans
+		WHERE cohort_id = $1 AND current_count < max_capacity
+		ORDER BY id ASC
+		LIMIT 1
+		FOR UPDATE
+	`, cohortID))
+}
+
+// roundRobinAssigner hands out seats to the next clan after the one that received
+// the most recent participant, wrapping around to the lowest id.
+type roundRobinAssigner struct{}
+
+func (roundRobinAssigner) pickClan(ctx context.Context, tx pgx.Tx, cohortID string, _ clanCandidate) (assignedClan, error) {
+	return scanAssignedClan(tx.QueryRow(ctx, `
+		SELECT id, name, whatsapp_link
+		FROM clans
+		WHERE cohort_id = $1 AND current_count < max_capacity
+		ORDER BY id <= COALESCE((
+			SELECT clan_id FROM participants
+			WHERE cohort_id = $1 AND clan_id IS NOT NULL AND deleted_at IS NULL
+			ORDER BY created_at DESC LIMIT 1
+		), 0), id ASC
+		LIMIT 1
+		FOR UPDATE
+	`, cohortID))
+}
+
+// leastFilledAssigner keeps every clan at roughly the same fill ratio
+type leastFilledAssigner struct{}
+
+func (leastFilledAssigner) pickClan(ctx context.Context, tx pgx.Tx, cohortID string, _ clanCandidate) (assignedClan, error) {
+	return scanAssignedClan(tx.QueryRow(ctx, `
+		SELECT id, name, whatsapp_link
+		FROM clans
+		WHERE cohort_id = $1 AND current_count < max_capacity
+		ORDER BY current_count::float / GREATEST(max_capacity, 1) ASC, id ASC
+		LIMIT 1
+		FOR UPDATE
+	`, cohortID))
+}
+
+// demographicAssigner places a participant in the open clan that has the fewest
+// members sharing their gender, age group and location, so each clan ends up mixed.
+// Gender and age group weigh more than location; ties fall back to the emptiest clan.
+type demographicAssigner struct{}
+
+func (demographicAssigner) pickClan(ctx context.Context, tx pgx.Tx, cohortID string, c clanCandidate) (assignedClan, error) {
+	return scanAssignedClan(tx.QueryRow(ctx, `
+		SELECT c.id, c.name, c.whatsapp_link
+		FROM clans c
+		WHERE c.cohort_id = $5 AND c.current_count < c.max_capacity
+		ORDER BY (
+			SELECT
+				3 * COUNT(*) FILTER (WHERE lower(p.gender) = lower($1) AND $1 <> '') +
+				2 * COUNT(*) FILTER (WHERE lower(p.age_group) = lower($4) AND $4 <> '') +
+				COUNT(*) FILTER (WHERE lower(p.country) = lower($2) AND $2 <> '') +
+				COUNT(*) FILTER (WHERE lower(p.state) = lower($3) AND $3 <> '')
+			FROM participants p
+			WHERE p.clan_id = c.id AND p.deleted_at IS NULL
+		) ASC, c.current_count ASC, c.id ASC
+		LIMIT 1
+		FOR UPDATE
+	`, c.Gender, c.Country, c.State, c.AgeGroup, cohortID))
+}
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// Cohort is one intake of a program. ProgramName matches modules.program_name.
+type Cohort struct {
+	ID           string     `json:"id"`
+	ProgramName  string     `json:"program_name"`
+	Name         string     `json:"name"`
+	Slug         string     `json:"slug"`
+	OpensAt      *time.Time `json:"opens_at"`
+	ClosesAt     *time.Time `json:"closes_at"`
+	ClanStrategy string     `json:"clan_strategy"`
+	WhatsAppLink string     `json:"whatsapp_link"`
+	TelegramLink string     `json:"telegram_link"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// queryRower is satisfied by both db.Pool and a pgx.Tx
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+const cohortColumns = `id::text, program_name, name, slug, opens_at, closes_at,
+	COALESCE(clan_strategy, ''), COALESCE(whatsapp_link, ''), COALESCE(telegram_link, ''), created_at`
+
+func scanCohort(row pgx.Row) (Cohort, error) {
+	var c Cohort
+	err := row.Scan(&c.ID, &c.ProgramName, &c.Name, &c.Slug, &c.OpensAt, &c.ClosesAt,
+		&c.ClanStrategy, &c.WhatsAppLink, &c.TelegramLink, &c.CreatedAt)
+	return c, err
+}
+
+// openCohort returns the cohort of a program currently accepting registrations.
+// Returns pgx.ErrNoRows when registration is closed.
+func openCohort(ctx context.Context, q queryRower, programName string) (Cohort, error) {
+	return scanCohort(q.QueryRow(ctx, `
+		SELECT `+cohortColumns+`
+		FROM public.cohorts
+		WHERE program_name = $1 AND opens_at <= NOW() AND (closes_at IS NULL OR closes_at > NOW())
+		ORDER BY opens_at DESC
+		LIMIT 1
+	`, programName))
+}
+
+// waitlistCohort picks the cohort new waitlist entries belong to: the open cohort
+// if there is one (its clans are full), otherwise the next one to open.
+func waitlistCohort(ctx context.Context, q queryRower, programName string) (Cohort, error) {
+	c, err := openCohort(ctx, q, programName)
+	if err != pgx.ErrNoRows {
+		return c, err
+	}
+	return scanCohort(q.QueryRow(ctx, `
+		SELECT `+cohortColumns+`
+		FROM public.cohorts
+		WHERE program_name = $1 AND (opens_at IS NULL OR opens_at > NOW())
+		ORDER BY opens_at ASC NULLS LAST, created_at ASC
+		LIMIT 1
+	`, programName))
+}
+
+func cohortBySlug(ctx context.Context, q queryRower, slug string) (Cohort, error) {
+	return scanCohort(q.QueryRow(ctx, `SELECT `+cohortColumns+` FROM public.cohorts WHERE slug = $1`, slug))
+}
+
+// userCohort returns the cohort a logged-in user most recently registered into for a program
+func userCohort(ctx context.Context, userID string, program Program) (Cohort, error) {
+	return scanCohort(db.Pool.QueryRow(ctx, `
+		SELECT `+cohortColumns+`
+		FROM public.cohorts
+		WHERE id = (
+			SELECT cohort_id FROM public.user_cohorts
+			WHERE user_id = $1 AND program_name = $2
+			ORDER BY registered_at DESC
+			LIMIT 1
+		)
+	`, userID, program.ModuleName))
+}
+
+// userCohortJoin joins the latest cohort of user $2 for the module m, then that
+// cohort's schedule for lesson l, so queries can read COALESCE(ls.*, l.*).
+const userCohortJoin = `
+	LEFT JOIN LATERAL (
+		SELECT uc.cohort_id FROM public.user_cohorts uc
+		WHERE uc.user_id = $2 AND uc.program_name = m.program_name
+		ORDER BY uc.registered_at DESC
+		LIMIT 1
+	) ucoh ON true
+	LEFT JOIN public.lesson_schedules ls ON ls.lesson_id = l.id AND ls.cohort_id = ucoh.cohort_id`
+
+// --- Admin: Cohort management ---
+
+// GetAdminCohorts lists every cohort with its clan and registration counts
+func GetAdminCohorts(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT `+cohortColumns+`,
+			(SELECT COUNT(*) FROM public.clans cl WHERE cl.cohort_id = c.id),
+			(SELECT COUNT(*) FROM public.participants p WHERE p.cohort_id = c.id AND p.deleted_at IS NULL) +
+			(SELECT COUNT(*) FROM public.couples_launchpad lp WHERE lp.cohort_id = c.id AND lp.deleted_at IS NULL),
+			(SELECT COUNT(*) FROM public.waitlist wl WHERE wl.cohort_id = c.id AND wl.status = 'waiting')
+		FROM public.cohorts c
+		ORDER BY c.program_name, c.opens_at DESC NULLS FIRST
+	`)
+	if err != nil {
+		fmt.Println("💥 DB QUERY ERROR (Cohorts):", err)
+		http.Error(w, "Failed to fetch cohorts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type AdminCohort struct {
+		Cohort
+		ClanCount         int `json:"clan_count"`
+		RegistrationCount int `json:"registration_count"`
+		WaitlistCount     int `json:"waitlist_count"`
+	}
+
+	cohorts := []AdminCohort{}
+	for rows.Next() {
+		var c AdminCohort
+		if err := rows.Scan(&c.ID, &c.ProgramName, &c.Name, &c.Slug, &c.OpensAt, &c.ClosesAt,
+			&c.ClanStrategy, &c.WhatsAppLink, &c.TelegramLink, &c.CreatedAt,
+			&c.ClanCount, &c.RegistrationCount, &c.WaitlistCount); err != nil {
+			fmt.Println("💥 DB SCAN ERROR (Cohorts):", err)
+			continue
+		}
+		cohorts = append(cohorts, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cohorts)
+}
+
+// SaveAdminCohort creates a cohort (POST) or updates one (PUT ?id=...)
+func SaveAdminCohort(w http.ResponseWriter, r *http.Request) {
+	var c Cohort
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil || c.ProgramName == "" || c.Name == "" || c.Slug == "" {
+		http.Error(w, "program_name, name and slug are required", http.StatusBadRequest)
+		return
+	}
+	program, ok := lookupProgram(r.Context(), c.ProgramName)
+	if !ok {
+		http.Error(w, "Unknown program", http.StatusBadRequest)
+		return
+	}
+	c.ProgramName = program.ModuleName
+	if c.ClanStrategy != "" && !isValidClanStrategy(c.ClanStrategy) {
+		http.Error(w, "Unknown clan_strategy", http.StatusBadRequest)
+		return
+	}
+	if c.OpensAt != nil && c.ClosesAt != nil && !c.ClosesAt.After(*c.OpensAt) {
+		http.Error(w, "closes_at must be after opens_at", http.StatusBadRequest)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if r.Method == http.MethodPut && id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	if r.Method == http.MethodPut {
+		err = tx.QueryRow(r.Context(), "SELECT to_jsonb(c) FROM public.cohorts c WHERE id::text = $1 FOR UPDATE", id).Scan(&before)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Cohort not found", http.StatusNotFound)
+			return
+		}
+		if err == nil {
+			err = tx.QueryRow(r.Context(), `
+				UPDATE public.cohorts c
+				SET program_name = $1, name = $2, slug = $3, opens_at = $4, closes_at = $5,
+				    clan_strategy = NULLIF($6, ''), whatsapp_link = NULLIF($7, ''), telegram_link = NULLIF($8, '')
+				WHERE id::text = $9
+				RETURNING to_jsonb(c)`,
+				c.ProgramName, c.Name, c.Slug, c.OpensAt, c.ClosesAt, c.ClanStrategy, c.WhatsAppLink, c.TelegramLink, id).Scan(&after)
+		}
+	} else {
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO public.cohorts AS c (program_name, name, slug, opens_at, closes_at, clan_strategy, whatsapp_link, telegram_link)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''))
+			RETURNING c.id::text, to_jsonb(c)`,
+			c.ProgramName, c.Name, c.Slug, c.OpensAt, c.ClosesAt, c.ClanStrategy, c.WhatsAppLink, c.TelegramLink).Scan(&id, &after)
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditCohortSave, TargetType: "cohorts", TargetID: id, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DB SAVE ERROR (Cohort):", err)
+		http.Error(w, "Failed to save cohort", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Cohort saved"})
+}
+
+// CreateCohortClan adds a clan to a cohort (POST ?cohort_id=...)
+func CreateCohortClan(w http.ResponseWriter, r *http.Request) {
+	cohortID := r.URL.Query().Get("cohort_id")
+	var req struct {
+		Name         string `json:"name"`
+		WhatsAppLink string `json:"whatsapp_link"`
+		MaxCapacity  int    `json:"max_capacity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || cohortID == "" || req.Name == "" || req.MaxCapacity <= 0 {
+		http.Error(w, "cohort_id, name and a positive max_capacity are required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var clanID string
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		INSERT INTO clans AS c (name, whatsapp_link, max_capacity, current_count, cohort_id)
+		VALUES ($1, $2, $3, 0, $4)
+		RETURNING c.id::text, to_jsonb(c)`,
+		req.Name, req.WhatsAppLink, req.MaxCapacity, cohortID).Scan(&clanID, &after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditClanCreate, TargetType: "clans", TargetID: clanID, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DB INSERT ERROR (Clan):", err)
+		http.Error(w, "Failed to create clan", http.StatusInternalServerError)
+		return
+	}
+
+	// New seats may be claimable by people already waiting for this cohort
+	go RunWaitlistPromotion(context.Background())
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Clan created"})
+}
+
+// SaveCohortSchedule sets the live schedule of lessons for one cohort (PUT ?cohort_id=...)
+func SaveCohortSchedule(w http.ResponseWriter, r *http.Request) {
+	cohortID := r.URL.Query().Get("cohort_id")
+	var entries []struct {
+		LessonID            string     `json:"lesson_id"`
+		ScheduledStartTime  *time.Time `json:"scheduled_start_time"`
+		LiveDurationMinutes *int       `json:"live_duration_minutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil || cohortID == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before json.RawMessage
+	if err := tx.QueryRow(r.Context(), `
+		SELECT COALESCE(jsonb_agg(to_jsonb(s) ORDER BY s.lesson_id), '[]') FROM public.lesson_schedules s WHERE s.cohort_id::text = $1
+	`, cohortID).Scan(&before); err != nil {
+		http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+		return
+	}
+
+	for _, e := range entries {
+		if e.LessonID == "" {
+			continue
+		}
+		_, err := tx.Exec(r.Context(), `
+			INSERT INTO public.lesson_schedules (cohort_id, lesson_id, scheduled_start_time, live_duration_minutes)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (cohort_id, lesson_id) DO UPDATE SET
+				scheduled_start_time = EXCLUDED.scheduled_start_time,
+				live_duration_minutes = EXCLUDED.live_duration_minutes`,
+			cohortID, e.LessonID, e.ScheduledStartTime, e.LiveDurationMinutes)
+		if err != nil {
+			fmt.Println("💥 DB SAVE ERROR (Schedule):", err)
+			http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		SELECT COALESCE(jsonb_agg(to_jsonb(s) ORDER BY s.lesson_id), '[]') FROM public.lesson_schedules s WHERE s.cohort_id::text = $1
+	`, cohortID).Scan(&after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditCohortScheduleSave, TargetType: "cohorts", TargetID: cohortID, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DB SAVE ERROR (Schedule):", err)
+		http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Schedule saved"})
+}
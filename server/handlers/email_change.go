@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/asejik/soulmate-reg/server/services"
+	"github.com/asejik/soulmate-reg/server/supabase"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// emailChangeTTL is how long an emailed confirmation link stays usable
+const emailChangeTTL = time.Hour
+
+// errEmailTaken means another login or another person's registration already uses the address
+var errEmailTaken = errors.New("email already in use")
+
+// emailChangeURL builds the link that proves the participant can read their new mailbox
+func emailChangeURL(token string) string {
+	return clientURL("/change-email?token=" + token)
+}
+
+// validNewEmail trims and checks an address a login is being moved to
+func validNewEmail(w http.ResponseWriter, raw string) (string, bool) {
+	email := strings.TrimSpace(raw)
+	errs := fieldErrors{}
+	errs.required("email", email, "Email")
+	errs.maxLen("email", email, maxEmailLen)
+	errs.email("email", email)
+	if len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return "", false
+	}
+	return email, true
+}
+
+// emailInUse reports whether email belongs to a login other than userID, or to a registration
+// not linked to userID. Trashed registrations count, since they still hold the address.
+func emailInUse(ctx context.Context, userID, email string) (bool, error) {
+	var taken bool
+	err := db.Pool.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM auth.users WHERE lower(email) = lower($1) AND id::text <> $2)`, email, userID).Scan(&taken)
+	if err != nil || taken {
+		return taken, err
+	}
+
+	programs, err := loadPrograms(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range programs {
+		err := db.Pool.QueryRow(ctx, `
+			SELECT EXISTS(SELECT 1 FROM `+p.registrationIdent()+` WHERE lower(email) = lower($1) AND user_id IS DISTINCT FROM $2::uuid)
+		`, email, nullableUUID(userID)).Scan(&taken)
+		if err != nil || taken {
+			return taken, err
+		}
+	}
+	return false, nil
+}
+
+// nullableUUID turns "" into NULL for optional uuid parameters
+func nullableUUID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
+
+// changeLoginEmail moves a login and every registration linked to it to newEmail, audited,
+// and tells the old address. Supabase is updated last inside the transaction so a refused
+// change leaves the registrations untouched.
+func changeLoginEmail(r *http.Request, userID, newEmail string) error {
+	ctx := r.Context()
+	programs, err := loadPrograms(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldEmail, name string
+	err = tx.QueryRow(ctx, `
+		SELECT au.email, COALESCE(cl.full_name, p.full_name, '')
+		FROM auth.users au
+		LEFT JOIN public.participants p ON p.user_id = au.id
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		WHERE au.id::text = $1
+		LIMIT 1
+	`, userID).Scan(&oldEmail, &name)
+	if err != nil {
+		return err
+	}
+
+	moved := map[string]int64{}
+	seen := map[string]bool{}
+	for _, p := range programs {
+		if seen[p.RegistrationTable] {
+			continue
+		}
+		seen[p.RegistrationTable] = true
+		tag, err := tx.Exec(ctx, "UPDATE "+p.registrationIdent()+" SET email = $2 WHERE user_id::text = $1", userID, newEmail)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return errEmailTaken
+			}
+			return err
+		}
+		moved[p.RegistrationTable] = tag.RowsAffected()
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE public.email_changes SET expires_at = NOW() WHERE user_id::text = $1 AND used_at IS NULL AND expires_at > NOW()`, userID)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{
+			Action:     auditUserEmailChange,
+			TargetType: "user",
+			TargetID:   userID,
+			Before:     map[string]any{"email": oldEmail},
+			After:      map[string]any{"email": newEmail, "registrations": moved},
+		})
+	}
+	if err == nil {
+		err = enqueueOutbox(ctx, tx, outboxEmailChanged, services.EmailChangedEmailData{Name: name, Email: oldEmail, NewEmail: newEmail})
+	}
+	if err != nil {
+		return err
+	}
+
+	confirmed := true
+	auth := supabase.Default()
+	if _, err := auth.UpdateUser(ctx, userID, supabase.UpdateUserParams{Email: newEmail, EmailConfirm: &confirmed}); err != nil {
+		if supabase.IsEmailExists(err) {
+			return errEmailTaken
+		}
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		// Put the login back so it still matches the registrations
+		auth.UpdateUser(ctx, userID, supabase.UpdateUserParams{Email: oldEmail, EmailConfirm: &confirmed})
+		return err
+	}
+	notifyOutbox()
+	return nil
+}
+
+// RequestEmailChange emails a confirmation link to the address the caller wants to sign in
+// with. Nothing changes until the link is opened.
+func RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(string)
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	email, ok := validNewEmail(w, req.Email)
+	if !ok {
+		return
+	}
+
+	var oldEmail, name string
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT au.email, COALESCE(cl.full_name, p.full_name, '')
+		FROM auth.users au
+		LEFT JOIN public.participants p ON p.user_id = au.id
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		WHERE au.id = $1
+		LIMIT 1
+	`, userID).Scan(&oldEmail, &name)
+	if err != nil {
+		http.Error(w, "Failed to start email change", http.StatusInternalServerError)
+		return
+	}
+	if strings.EqualFold(oldEmail, email) {
+		http.Error(w, "That is already your login email", http.StatusBadRequest)
+		return
+	}
+
+	taken, err := emailInUse(r.Context(), userID, email)
+	if err != nil {
+		http.Error(w, "Failed to start email change", http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		http.Error(w, "That email is already used by another account", http.StatusConflict)
+		return
+	}
+
+	ip := clientIP(r)
+	wait, err := sendThrottle(r.Context(), db.Pool, "email_changes", "new_email", email, ip)
+	if err != nil {
+		http.Error(w, "Failed to start email change", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "A confirmation link was sent recently. Please check that inbox or wait before trying again.", http.StatusTooManyRequests)
+		return
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		http.Error(w, "Failed to start email change", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(emailChangeTTL)
+
+	// A new link replaces any still outstanding, and is only stored if its email is queued
+	tx, err := db.Pool.Begin(r.Context())
+	if err == nil {
+		defer tx.Rollback(r.Context())
+		_, err = tx.Exec(r.Context(), `
+			UPDATE public.email_changes SET expires_at = NOW()
+			WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+		`, userID)
+	}
+	if err == nil {
+		_, err = tx.Exec(r.Context(), `
+			INSERT INTO public.email_changes (user_id, new_email, token_hash, requester_ip, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, userID, email, hashLinkToken(token), ip, expiresAt)
+	}
+	if err == nil {
+		err = enqueueOutbox(r.Context(), tx, outboxEmailChange, services.EmailChangeEmailData{
+			Name:        name,
+			Email:       email,
+			OldEmail:    oldEmail,
+			ConfirmLink: emailChangeURL(token),
+			ExpiresAt:   expiresAt,
+		})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 EMAIL CHANGE ERROR:", err)
+		http.Error(w, "Failed to start email change", http.StatusInternalServerError)
+		return
+	}
+	notifyOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "We've emailed a confirmation link to " + email + ". Your login changes once you open it.",
+	})
+}
+
+// ConfirmEmailChange redeems a confirmation link. The token is the credential, so this
+// runs without a session; the audit record names the login owner as the actor.
+func ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Invalid request format"})
+		return
+	}
+
+	// Validate and consume in one statement so a link can only ever be redeemed once
+	var id, userID, email string
+	err := db.Pool.QueryRow(r.Context(), `
+		UPDATE public.email_changes SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id::text, user_id::text, new_email
+	`, hashLinkToken(req.Token)).Scan(&id, &userID, &email)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"message": "This link is invalid, expired or already used. Please request a new one."})
+		return
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), userIDKey, userID))
+	if err := changeLoginEmail(r, userID, email); err != nil {
+		if errors.Is(err, errEmailTaken) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"message": "That email is now used by another account."})
+			return
+		}
+		// Hand the link back so a transient failure doesn't cost the participant their link
+		db.Pool.Exec(r.Context(), "UPDATE public.email_changes SET used_at = NULL WHERE id::text = $1", id)
+		fmt.Println("💥 EMAIL CHANGE CONFIRM ERROR:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Failed to change your email. Please try the link again."})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Your email has been changed. Please sign in with your new address.",
+		"email":   email,
+	})
+}
+
+// ChangeAdminUserEmail corrects a participant's email for them. A claimed registration moves
+// the whole login (every program plus Supabase); an unclaimed one only changes that row.
+func ChangeAdminUserEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string `json:"id"`
+		Source string `json:"source"`
+		Email  string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" || req.Source == "" {
+		http.Error(w, "id, source and email are required", http.StatusBadRequest)
+		return
+	}
+	program, ok := lookupProgram(r.Context(), req.Source)
+	if !ok {
+		http.Error(w, "Invalid source", http.StatusBadRequest)
+		return
+	}
+	email, ok := validNewEmail(w, req.Email)
+	if !ok {
+		return
+	}
+
+	var currentEmail, userID string
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT email, COALESCE(user_id::text, '') FROM `+program.registrationIdent()+` WHERE id::text = $1 AND deleted_at IS NULL
+	`, req.ID).Scan(&currentEmail, &userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to change email", http.StatusInternalServerError)
+		return
+	}
+	if strings.EqualFold(currentEmail, email) {
+		http.Error(w, "That is already the user's email", http.StatusBadRequest)
+		return
+	}
+
+	// Moving a staff login lets whoever controls the new address reset its password, so
+	// only admins who could grant those roles themselves may do it
+	if userID != "" && !callerCan(r.Context(), PermRolesManage) {
+		var isStaff bool
+		err = db.Pool.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM public.user_roles WHERE user_id::text = $1)", userID).Scan(&isStaff)
+		if err != nil {
+			http.Error(w, "Failed to change email", http.StatusInternalServerError)
+			return
+		}
+		if isStaff {
+			http.Error(w, "Only admins who manage roles can change a staff member's email", http.StatusForbidden)
+			return
+		}
+	}
+
+	taken, err := emailInUse(r.Context(), userID, email)
+	if err == nil && taken {
+		err = errEmailTaken
+	}
+	if err == nil && userID != "" {
+		err = changeLoginEmail(r, userID, email)
+	} else if err == nil {
+		err = changeRegistrationEmail(r, program, req.ID, email)
+	}
+	if errors.Is(err, errEmailTaken) {
+		http.Error(w, "That email is already used by another account", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		fmt.Println("💥 ADMIN EMAIL CHANGE ERROR:", err)
+		http.Error(w, "Failed to change email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email updated"})
+}
+
+// changeRegistrationEmail corrects the email on one registration that has no login yet
+func changeRegistrationEmail(r *http.Request, program Program, id, email string) error {
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		WITH old AS (SELECT id, to_jsonb(t) AS snapshot FROM `+program.registrationIdent()+` t WHERE id::text = $1 FOR UPDATE)
+		UPDATE `+program.registrationIdent()+` t SET email = $2 FROM old WHERE t.id = old.id
+		RETURNING old.snapshot, to_jsonb(t)
+	`, id, email).Scan(&before, &after)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return errEmailTaken
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditUserEmailChange, TargetType: program.RegistrationTable, TargetID: id, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	return err
+}
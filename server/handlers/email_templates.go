@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/asejik/soulmate-reg/server/services"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetAdminEmailTemplates lists the email templates that can be previewed
+func GetAdminEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services.EmailTemplateNames())
+}
+
+// PreviewAdminEmailTemplate renders a template with sample data. ?format=html or
+// ?format=text returns just that part so it can be opened directly in a browser;
+// the default is JSON with the subject, HTML and text.
+func PreviewAdminEmailTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	known := false
+	for _, n := range services.EmailTemplateNames() {
+		known = known || n == name
+	}
+	if !known {
+		http.Error(w, "Unknown email template", http.StatusNotFound)
+		return
+	}
+
+	rendered, err := services.PreviewEmail(name)
+	if err != nil {
+		// Surface template errors so whoever edited the copy can fix them
+		http.Error(w, "Failed to render template: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(rendered.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(rendered.Text))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rendered)
+	}
+}
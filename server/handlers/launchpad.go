@@ -28,6 +28,9 @@ type LaunchpadRequest struct {
 	AttendedBefore     bool   `json:"attended_before"`
 	AgreedToFeedback   bool   `json:"agreed_to_feedback"`
 	AgreedToParticipation bool `json:"agreed_to_participation"`
+
+	WhatsAppE164       string `json:"-"` // set by validate()
+	SpouseWhatsAppE164 string `json:"-"`
 }
 
 func RegisterLaunchpad(w http.ResponseWriter, r *http.Request) {
@@ -37,28 +40,59 @@ func RegisterLaunchpad(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. Logic Check: Rejection Criteria
-	// If they have attended before OR refuse to commit, we reject (though frontend should catch this too)
-	if req.AttendedBefore || !req.AgreedToFeedback || !req.AgreedToParticipation {
-		http.Error(w, "Eligibility criteria not met", http.StatusForbidden)
+	// 1. Validate fields and eligibility (attended before / refused to commit are rejected here too)
+	if errs := req.validate(); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+
+	// 2. Find the Launchpad cohort currently accepting registrations
+	program, err := mustProgram(r.Context(), programLaunchpad)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	cohort, err := openCohort(r.Context(), db.Pool, program.ModuleName)
+	if err != nil {
+		http.Error(w, "Registration is currently closed", http.StatusForbidden)
+		return
+	}
+
+	// 3. Save to Database, queueing the sheet backup and email in the same transaction
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Reject anyone already registered in either program (by email or normalized phone)
+	conflict, err := findRegistrationConflict(r.Context(), tx, req.Email, req.WhatsAppE164, false, "")
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if conflict != nil {
+		writeRegistrationConflict(w, conflict)
 		return
 	}
 
-	// 2. Save to Database
 	query := `
 		INSERT INTO couples_launchpad (
 			full_name, gender, email, whatsapp_number, country_city,
 			religion, denomination, referral_source, instagram_handle,
 			wedding_date, partner_registered, spouse_name, spouse_whatsapp,
-			attended_before, agreed_to_feedback, agreed_to_participation
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			attended_before, agreed_to_feedback, agreed_to_participation, cohort_id,
+			whatsapp_e164, spouse_whatsapp_e164
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 	`
 
-	_, err := db.Pool.Exec(r.Context(), query,
+	_, err = tx.Exec(r.Context(), query,
 		req.FullName, req.Gender, req.Email, req.WhatsAppNumber, req.CountryCity,
 		req.Religion, req.Denomination, req.ReferralSource, req.InstagramHandle,
 		req.WeddingDate, req.PartnerRegistered, req.SpouseName, req.SpouseWhatsApp,
-		req.AttendedBefore, req.AgreedToFeedback, req.AgreedToParticipation,
+		req.AttendedBefore, req.AgreedToFeedback, req.AgreedToParticipation, cohort.ID,
+		req.WhatsAppE164, req.SpouseWhatsAppE164,
 	)
 
 	if err != nil {
@@ -66,38 +100,52 @@ func RegisterLaunchpad(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// --- NEW: 3. Backup to Google Sheets (Async) ---
-    go services.SendToLaunchpadSheet(services.SheetPayload{
-        FullName:          req.FullName,
-        Gender:            req.Gender,
-        Email:             req.Email,
-        WhatsAppNumber:    req.WhatsAppNumber,
-        CountryCity:       req.CountryCity,
-        Religion:          req.Religion,
-        Denomination:      req.Denomination,
-        InstagramHandle:   req.InstagramHandle,
-        WeddingDate:       req.WeddingDate,
-        PartnerRegistered: req.PartnerRegistered,
-        SpouseName:        req.SpouseName,
-        SpouseWhatsApp:    req.SpouseWhatsApp,
-        ReferralSource:    req.ReferralSource,
-    })
-
-	// 4. Send Email (Async)
-	// We reuse the existing email service but arguably should make a specific template later.
-	// For now, we send a generic confirmation.
-	go services.SendLaunchpadEmail(services.LaunchpadEmailData{
+	// 4. Backup to Google Sheets
+	err = enqueueOutbox(r.Context(), tx, outboxLaunchpadSheet, services.SheetPayload{
+		FullName:          req.FullName,
+		Gender:            req.Gender,
+		Email:             req.Email,
+		WhatsAppNumber:    req.WhatsAppNumber,
+		CountryCity:       req.CountryCity,
+		Religion:          req.Religion,
+		Denomination:      req.Denomination,
+		InstagramHandle:   req.InstagramHandle,
+		WeddingDate:       req.WeddingDate,
+		PartnerRegistered: req.PartnerRegistered,
+		SpouseName:        req.SpouseName,
+		SpouseWhatsApp:    req.SpouseWhatsApp,
+		ReferralSource:    req.ReferralSource,
+	})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// 5. Send Email
+	err = enqueueOutbox(r.Context(), tx, outboxLaunchpadEmail, services.LaunchpadEmailData{
 		Name:         req.FullName,
 		Email:        req.Email,
-		WhatsAppLink: "https://chat.whatsapp.com/JpuFSjuo8lBHRSUtDtDwsT?mode=gi_t",
-		TelegramLink: "https://t.me/+Ybj-HVY5KLw5MzY0",
+		WhatsAppLink: cohort.WhatsAppLink,
+		TelegramLink: cohort.TelegramLink,
+		CohortName:   cohort.Name,
 	})
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(r.Context()); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	notifyOutbox()
 
-	// 4. Success Response
+	// 6. Success Response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"whatsapp_link": "https://chat.whatsapp.com/JpuFSjuo8lBHRSUtDtDwsT?mode=gi_t",
-		"telegram_link": "https://t.me/+Ybj-HVY5KLw5MzY0",
+		"whatsapp_link": cohort.WhatsAppLink,
+		"telegram_link": cohort.TelegramLink,
+		"cohort": cohort.Name,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,703 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lesson access rule kinds
+const (
+	accessPrerequisite   = "prerequisite"    // lessons to complete first
+	accessReview         = "review"          // a program review to submit first
+	accessQuiz           = "quiz"            // lesson quizzes to submit (and score on) first
+	accessAbsoluteWindow = "absolute_window" // open between fixed dates
+	accessRelativeWindow = "relative_window" // open around the lesson's live session
+	accessDrip           = "drip"            // opens some time after the learner's start
+)
+
+// Which lessons of a program a rule covers. For each kind, a lesson follows only its most
+// specific rules: its own replace the midpoint ones, which replace the program-wide ones.
+const (
+	accessAllLessons    = "all"
+	accessMidpoint      = "midpoint"       // the lesson at (total+1)/2, the last before the checkpoint
+	accessAfterMidpoint = "after_midpoint" // every lesson after it
+	accessOneLesson     = "lesson"
+)
+
+// Anchors of relative windows and drip schedules
+const (
+	anchorLiveStart  = "live_start"
+	anchorLiveEnd    = "live_end"
+	anchorEnrolled   = "enrolled"
+	anchorCohortOpen = "cohort_open"
+)
+
+// maxAccessHours keeps relative offsets within a year
+const maxAccessHours = 24 * 366
+
+// AccessRuleConfig holds the settings of every kind; each kind reads its own.
+// A rule with nothing to require (no lessons, no review types, no window) exempts the
+// lessons it covers from less specific rules of its kind.
+type AccessRuleConfig struct {
+	// prerequisite, quiz: these lessons, and/or the lesson right before
+	LessonIDs []string `json:"lesson_ids,omitempty"`
+	Previous  bool     `json:"previous,omitempty"`
+	// quiz: the counted attempt must score at least this percentage
+	MinPercent int `json:"min_percent,omitempty"`
+	// review: any one of these review types unlocks
+	ReviewTypes []string `json:"review_types,omitempty"`
+	// absolute_window
+	OpensAt  *time.Time `json:"opens_at,omitempty"`
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+	// relative_window (live_start, live_end) and drip (enrolled, cohort_open)
+	Anchor string `json:"anchor,omitempty"`
+	// relative_window: open and close this many hours from the anchor; unset means no limit
+	OpenHours  *int `json:"open_hours,omitempty"`
+	CloseHours *int `json:"close_hours,omitempty"`
+	// drip: the first covered lesson opens StartHours after the anchor, each next one IntervalHours later
+	StartHours    int `json:"start_hours,omitempty"`
+	IntervalHours int `json:"interval_hours,omitempty"`
+	// Shown to the learner instead of the default explanation
+	Message string `json:"message,omitempty"`
+}
+
+// AccessRule is one row of public.lesson_access_rules. ProgramName matches modules.program_name.
+type AccessRule struct {
+	ID          string           `json:"id"`
+	ProgramName string           `json:"program_name"`
+	AppliesTo   string           `json:"applies_to"`
+	LessonID    *string          `json:"lesson_id"`
+	Kind        string           `json:"kind"`
+	Config      AccessRuleConfig `json:"config"`
+}
+
+func (r AccessRule) specificity() int {
+	switch r.AppliesTo {
+	case accessOneLesson:
+		return 3
+	case accessMidpoint, accessAfterMidpoint:
+		return 2
+	}
+	return 1
+}
+
+func validHours(h *int) bool {
+	return h == nil || (*h >= -maxAccessHours && *h <= maxAccessHours)
+}
+
+// normalize checks a rule an admin sent; lessons must belong to the rule's program
+func (r *AccessRule) normalize(lessons map[string]bool) error {
+	c := &r.Config
+	c.Message = strings.TrimSpace(c.Message)
+	c.LessonIDs = trimNonEmpty(c.LessonIDs)
+	c.ReviewTypes = trimNonEmpty(c.ReviewTypes)
+
+	switch r.AppliesTo {
+	case accessAllLessons, accessMidpoint, accessAfterMidpoint:
+		r.LessonID = nil
+	case accessOneLesson:
+		if r.LessonID == nil || !lessons[*r.LessonID] {
+			return errors.New("lesson_id must be a lesson of the rule's program")
+		}
+	default:
+		return errors.New("applies_to must be all, midpoint, after_midpoint or lesson")
+	}
+	for _, id := range c.LessonIDs {
+		if !lessons[id] {
+			return errors.New("lesson_ids must be lessons of the rule's program")
+		}
+	}
+
+	switch r.Kind {
+	case accessPrerequisite, accessReview:
+	case accessQuiz:
+		if c.MinPercent < 0 || c.MinPercent > 100 {
+			return errors.New("min_percent must be between 0 and 100")
+		}
+	case accessAbsoluteWindow:
+		if c.OpensAt != nil && c.ClosesAt != nil && !c.ClosesAt.After(*c.OpensAt) {
+			return errors.New("closes_at must be after opens_at")
+		}
+	case accessRelativeWindow:
+		if c.Anchor == "" {
+			c.Anchor = anchorLiveEnd
+		}
+		if c.Anchor != anchorLiveStart && c.Anchor != anchorLiveEnd {
+			return errors.New("a relative window's anchor must be live_start or live_end")
+		}
+		if !validHours(c.OpenHours) || !validHours(c.CloseHours) {
+			return errors.New("open_hours and close_hours must be within a year")
+		}
+		if c.OpenHours != nil && c.CloseHours != nil && *c.CloseHours <= *c.OpenHours {
+			return errors.New("close_hours must be after open_hours")
+		}
+	case accessDrip:
+		if c.Anchor == "" {
+			c.Anchor = anchorEnrolled
+		}
+		if c.Anchor != anchorEnrolled && c.Anchor != anchorCohortOpen {
+			return errors.New("a drip schedule's anchor must be enrolled or cohort_open")
+		}
+		if c.StartHours < 0 || c.StartHours > maxAccessHours || c.IntervalHours < 0 || c.IntervalHours > maxAccessHours {
+			return errors.New("start_hours and interval_hours must be between 0 and a year")
+		}
+	default:
+		return errors.New("kind must be prerequisite, review, quiz, absolute_window, relative_window or drip")
+	}
+	return nil
+}
+
+// LessonLock is one reason a lesson is locked. Until is when a time lock lifts.
+type LessonLock struct {
+	Kind    string     `json:"kind"`
+	Message string     `json:"message"`
+	Until   *time.Time `json:"until,omitempty"`
+}
+
+// LessonAccess is what the rules decide for one learner and lesson. ClosingAt counts down
+// to a window closing once the lesson's live session is over.
+type LessonAccess struct {
+	IsLocked    bool         `json:"is_locked"`
+	LockReasons []LessonLock `json:"lock_reasons"`
+	ClosingAt   *time.Time   `json:"closing_at"`
+}
+
+// accessLesson is a lesson as the rules see it, for one learner
+type accessLesson struct {
+	ID              string
+	Title           string
+	StartsAt        *time.Time
+	DurationMinutes *int
+	Completed       bool
+	HasQuiz         bool
+	QuizSubmitted   bool
+	QuizScore       int
+	QuizTotal       int
+}
+
+// liveEnd is when the lesson's live session ends, or nil without a schedule
+func (l accessLesson) liveEnd() *time.Time {
+	if l.StartsAt == nil {
+		return nil
+	}
+	end := *l.StartsAt
+	if l.DurationMinutes != nil && *l.DurationMinutes > 0 {
+		end = end.Add(time.Duration(*l.DurationMinutes) * time.Minute)
+	}
+	return &end
+}
+
+// accessLearner is everything the rules read for one learner in one program
+type accessLearner struct {
+	lessons    []accessLesson
+	position   map[string]int // lesson id -> index in lessons
+	rules      []AccessRule
+	reviews    map[string]bool
+	enrolledAt *time.Time
+	cohortOpen *time.Time
+}
+
+func loadAccessRules(ctx context.Context, programName string) ([]AccessRule, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id::text, program_name, applies_to, lesson_id::text, kind, config
+		FROM public.lesson_access_rules WHERE program_name = $1
+		ORDER BY created_at
+	`, programName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []AccessRule{}
+	for rows.Next() {
+		var rule AccessRule
+		var config json.RawMessage
+		if err := rows.Scan(&rule.ID, &rule.ProgramName, &rule.AppliesTo, &rule.LessonID, &rule.Kind, &config); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(config, &rule.Config); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func loadAccessLearner(ctx context.Context, userID string, program Program) (*accessLearner, error) {
+	a := &accessLearner{position: map[string]int{}, reviews: map[string]bool{}}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT l.id::text, l.title, COALESCE(ls.scheduled_start_time, l.scheduled_start_time),
+			   COALESCE(ls.live_duration_minutes, l.live_duration_minutes),
+			   COALESCE(lp.is_completed OR lp.highest_watched_pct >= 80, false),
+			   q.id IS NOT NULL, kq.score IS NOT NULL, COALESCE(kq.score, 0), COALESCE(kq.total_questions, 0)
+		FROM public.lessons l
+		JOIN public.modules m ON l.module_id = m.id
+		LEFT JOIN public.lesson_progress lp ON l.id = lp.lesson_id AND lp.user_id = $2
+		LEFT JOIN public.quizzes q ON q.lesson_id = l.id
+		LEFT JOIN LATERAL (
+			SELECT COALESCE(qs.score, 0) AS score, qs.total_questions FROM public.quiz_submissions qs
+			WHERE qs.quiz_id = q.id AND qs.user_id = $2
+			ORDER BY `+quizKeptAttemptOrder+`
+			LIMIT 1
+		) kq ON true
+		`+userCohortJoin+`
+		WHERE m.program_name = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
+		ORDER BY m.sort_order ASC, l.sort_order ASC
+	`, program.ModuleName, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var l accessLesson
+		var total *int
+		if err := rows.Scan(&l.ID, &l.Title, &l.StartsAt, &l.DurationMinutes, &l.Completed,
+			&l.HasQuiz, &l.QuizSubmitted, &l.QuizScore, &total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if total != nil {
+			l.QuizTotal = *total
+		}
+		a.position[l.ID] = len(a.lessons)
+		a.lessons = append(a.lessons, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if a.rules, err = loadAccessRules(ctx, program.ModuleName); err != nil {
+		return nil, err
+	}
+
+	// Reviews are stored under the program key
+	reviewRows, err := db.Pool.Query(ctx, `SELECT DISTINCT review_type FROM public.program_reviews WHERE user_id = $1 AND program_name = $2`, userID, program.Key)
+	if err != nil {
+		return nil, err
+	}
+	for reviewRows.Next() {
+		var t string
+		if reviewRows.Scan(&t) == nil {
+			a.reviews[t] = true
+		}
+	}
+	reviewRows.Close()
+
+	err = db.Pool.QueryRow(ctx, `
+		SELECT uc.registered_at, c.opens_at FROM public.user_cohorts uc
+		JOIN public.cohorts c ON c.id = uc.cohort_id
+		WHERE uc.user_id = $1 AND uc.program_name = $2
+		ORDER BY uc.registered_at DESC
+		LIMIT 1
+	`, userID, program.ModuleName).Scan(&a.enrolledAt, &a.cohortOpen)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	return a, nil
+}
+
+// covers reports whether a rule applies to the lesson at index i, and the lesson's index among
+// the lessons the rule covers (drip schedules step through them)
+func (a *accessLearner) covers(rule AccessRule, i int) (bool, int) {
+	mid := (len(a.lessons)+1)/2 - 1
+	switch rule.AppliesTo {
+	case accessAllLessons:
+		return true, i
+	case accessMidpoint:
+		return i == mid, 0
+	case accessAfterMidpoint:
+		return i > mid, i - mid - 1
+	case accessOneLesson:
+		return rule.LessonID != nil && *rule.LessonID == a.lessons[i].ID, 0
+	}
+	return false, 0
+}
+
+// required lists the lessons a prerequisite or quiz rule points at
+func (a *accessLearner) required(c AccessRuleConfig, i int) []accessLesson {
+	var out []accessLesson
+	if c.Previous && i > 0 {
+		out = append(out, a.lessons[i-1])
+	}
+	for _, id := range c.LessonIDs {
+		if p, ok := a.position[id]; ok && p != i {
+			out = append(out, a.lessons[p])
+		}
+	}
+	return out
+}
+
+func quotedTitles(lessons []accessLesson) string {
+	titles := make([]string, len(lessons))
+	for i, l := range lessons {
+		titles[i] = `"` + l.Title + `"`
+	}
+	if len(titles) == 1 {
+		return titles[0]
+	}
+	return strings.Join(titles[:len(titles)-1], ", ") + " and " + titles[len(titles)-1]
+}
+
+func ruleMessage(c AccessRuleConfig, fallback string) string {
+	if c.Message != "" {
+		return c.Message
+	}
+	return fallback
+}
+
+// evaluate applies the rules to the lesson at index i. Completing a lesson keeps it past
+// prerequisite, review and quiz rules; windows and drip schedules apply regardless.
+func (a *accessLearner) evaluate(i int, now time.Time) LessonAccess {
+	lesson := a.lessons[i]
+	access := LessonAccess{LockReasons: []LessonLock{}}
+
+	type coveredRule struct {
+		rule  AccessRule
+		index int
+	}
+	best := map[string]int{}
+	byKind := map[string][]coveredRule{}
+	for _, rule := range a.rules {
+		ok, index := a.covers(rule, i)
+		if !ok {
+			continue
+		}
+		switch s := rule.specificity(); {
+		case s > best[rule.Kind]:
+			best[rule.Kind] = s
+			byKind[rule.Kind] = []coveredRule{{rule, index}}
+		case s == best[rule.Kind]:
+			byKind[rule.Kind] = append(byKind[rule.Kind], coveredRule{rule, index})
+		}
+	}
+
+	lock := func(kind, message string, until *time.Time) {
+		access.IsLocked = true
+		access.LockReasons = append(access.LockReasons, LessonLock{Kind: kind, Message: message, Until: until})
+	}
+	// A window about to close counts down once the live session is over
+	closes := func(at time.Time) {
+		if end := lesson.liveEnd(); end != nil && now.Before(*end) {
+			return
+		}
+		if access.ClosingAt == nil || at.Before(*access.ClosingAt) {
+			access.ClosingAt = &at
+		}
+	}
+	window := func(kind string, c AccessRuleConfig, opensAt, closesAt *time.Time) {
+		switch {
+		case opensAt != nil && now.Before(*opensAt):
+			lock(kind, ruleMessage(c, "This lesson isn't open yet."), opensAt)
+		case closesAt != nil && !now.Before(*closesAt):
+			lock(kind, ruleMessage(c, "This lesson's viewing window has closed."), nil)
+		case closesAt != nil:
+			closes(*closesAt)
+		}
+	}
+
+	for _, kind := range []string{accessPrerequisite, accessQuiz, accessReview, accessDrip, accessAbsoluteWindow, accessRelativeWindow} {
+		for _, cr := range byKind[kind] {
+			c := cr.rule.Config
+			switch kind {
+			case accessPrerequisite:
+				var missing []accessLesson
+				for _, l := range a.required(c, i) {
+					if !l.Completed {
+						missing = append(missing, l)
+					}
+				}
+				if len(missing) > 0 && !lesson.Completed {
+					lock(kind, ruleMessage(c, "Complete "+quotedTitles(missing)+" first."), nil)
+				}
+
+			case accessQuiz:
+				var missing []accessLesson
+				for _, l := range a.required(c, i) {
+					passed := l.QuizSubmitted && (l.QuizTotal == 0 || l.QuizScore*100 >= c.MinPercent*l.QuizTotal)
+					if l.HasQuiz && !passed {
+						missing = append(missing, l)
+					}
+				}
+				if len(missing) > 0 && !lesson.Completed {
+					fallback := "Submit the quiz for " + quotedTitles(missing) + " first."
+					if c.MinPercent > 0 {
+						fallback = fmt.Sprintf("Score at least %d%% on the quiz for %s first.", c.MinPercent, quotedTitles(missing))
+					}
+					lock(kind, ruleMessage(c, fallback), nil)
+				}
+
+			case accessReview:
+				reviewed := len(c.ReviewTypes) == 0
+				for _, t := range c.ReviewTypes {
+					reviewed = reviewed || a.reviews[t]
+				}
+				if !reviewed && !lesson.Completed {
+					lock(kind, ruleMessage(c, "Submit your program review to unlock this lesson."), nil)
+				}
+
+			case accessDrip:
+				// Without a known start the schedule can't be placed, so it doesn't lock
+				anchor := a.enrolledAt
+				if c.Anchor == anchorCohortOpen {
+					anchor = a.cohortOpen
+				}
+				if anchor != nil {
+					opensAt := anchor.Add(time.Duration(c.StartHours+cr.index*c.IntervalHours) * time.Hour)
+					if now.Before(opensAt) {
+						lock(kind, ruleMessage(c, "This lesson unlocks on your course schedule."), &opensAt)
+					}
+				}
+
+			case accessAbsoluteWindow:
+				window(kind, c, c.OpensAt, c.ClosesAt)
+
+			case accessRelativeWindow:
+				// Live-end windows need a session length, as the hardcoded rewatch window did
+				anchor := lesson.StartsAt
+				if c.Anchor == anchorLiveEnd {
+					anchor = nil
+					if lesson.DurationMinutes != nil && *lesson.DurationMinutes > 0 {
+						anchor = lesson.liveEnd()
+					}
+				}
+				if anchor == nil {
+					continue
+				}
+				var opensAt, closesAt *time.Time
+				if c.OpenHours != nil {
+					t := anchor.Add(time.Duration(*c.OpenHours) * time.Hour)
+					opensAt = &t
+				}
+				if c.CloseHours != nil {
+					t := anchor.Add(time.Duration(*c.CloseHours) * time.Hour)
+					closesAt = &t
+				}
+				window(kind, c, opensAt, closesAt)
+			}
+		}
+	}
+	if access.IsLocked {
+		access.ClosingAt = nil
+	}
+	return access
+}
+
+// programLessonAccess evaluates a learner's access to every lesson of a program, keyed by
+// lesson id. GetLesson and RequireLessonAccess enforce it and GetDashboard shows it.
+func programLessonAccess(ctx context.Context, userID string, program Program) (map[string]LessonAccess, error) {
+	a, err := loadAccessLearner(ctx, userID, program)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	access := make(map[string]LessonAccess, len(a.lessons))
+	for i, l := range a.lessons {
+		access[l.ID] = a.evaluate(i, now)
+	}
+	return access, nil
+}
+
+// refusesWrites reports whether the lock also refuses recording progress on the lesson.
+// Every lock does except a window that has closed (closed windows carry no Until): the
+// lesson was open, and late work on it is left to the quiz's own window and attempt grants.
+func (l LessonLock) refusesWrites() bool {
+	closedWindow := (l.Kind == accessAbsoluteWindow || l.Kind == accessRelativeWindow) && l.Until == nil
+	return !closedWindow
+}
+
+// RequireLessonAccess guards lesson actions that record progress (watching, assignments,
+// quizzes). Completing a lesson lifts its prerequisite, quiz and review locks and counts
+// towards later prerequisites and the certificate, so while the lesson is locked, or not
+// open yet, the actions are refused. Staff bypass it as they do enrollment.
+// It must run after RequireLessonEnrollment.
+func RequireLessonAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rolesFrom(r.Context()).isStaff() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		userID := r.Context().Value(userIDKey).(string)
+		lessonID := chi.URLParam(r, "id")
+
+		var programName string
+		err := db.Pool.QueryRow(r.Context(), `
+			SELECT m.program_name FROM public.lessons l JOIN public.modules m ON l.module_id = m.id
+			WHERE l.id::text = $1
+		`, lessonID).Scan(&programName)
+		program, ok := lookupProgram(r.Context(), programName)
+		if err != nil || !ok {
+			fmt.Println("💥 LESSON ACCESS ERROR: program lookup failed for lesson", lessonID, err)
+			http.Error(w, "Failed to check lesson access", http.StatusInternalServerError)
+			return
+		}
+		program.ModuleName = programName
+
+		access, err := programLessonAccess(r.Context(), userID, program)
+		if err != nil {
+			fmt.Println("💥 LESSON ACCESS ERROR:", err)
+			http.Error(w, "Failed to check lesson access", http.StatusInternalServerError)
+			return
+		}
+		for _, reason := range access[lessonID].LockReasons {
+			if reason.refusesWrites() {
+				http.Error(w, reason.Message, http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// --- Admin: Access rules ---
+
+// GetAccessRules lists the access rules of ?program= (any of its names)
+func GetAccessRules(w http.ResponseWriter, r *http.Request) {
+	program, ok := lookupProgram(r.Context(), r.URL.Query().Get("program"))
+	if !ok {
+		http.Error(w, "Unknown program", http.StatusBadRequest)
+		return
+	}
+	rules, err := loadAccessRules(r.Context(), program.ModuleName)
+	if err != nil {
+		fmt.Println("💥 ACCESS RULES ERROR:", err)
+		http.Error(w, "Failed to fetch access rules", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// SaveAccessRule creates a rule (POST) or replaces one (PUT ?id=)
+func SaveAccessRule(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	var rule AccessRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil || (r.Method == http.MethodPut && id == "") {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	program, ok := lookupProgram(r.Context(), rule.ProgramName)
+	if !ok {
+		http.Error(w, "Unknown program", http.StatusBadRequest)
+		return
+	}
+	rule.ProgramName = program.ModuleName
+
+	lessons := map[string]bool{}
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT l.id::text FROM public.lessons l JOIN public.modules m ON l.module_id = m.id
+		WHERE m.program_name = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
+	`, program.ModuleName)
+	if err == nil {
+		for rows.Next() {
+			var lessonID string
+			if rows.Scan(&lessonID) == nil {
+				lessons[lessonID] = true
+			}
+		}
+		rows.Close()
+		err = rows.Err()
+	}
+	if err != nil {
+		fmt.Println("💥 SAVE ACCESS RULE ERROR:", err)
+		http.Error(w, "Failed to save access rule", http.StatusInternalServerError)
+		return
+	}
+	if err := rule.normalize(lessons); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	config, _ := json.Marshal(rule.Config)
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to save access rule", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	if id == "" {
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO public.lesson_access_rules AS ar (program_name, applies_to, lesson_id, kind, config)
+			VALUES ($1, $2, $3, $4, $5::jsonb)
+			RETURNING ar.id::text, to_jsonb(ar)
+		`, rule.ProgramName, rule.AppliesTo, rule.LessonID, rule.Kind, string(config)).Scan(&id, &after)
+	} else {
+		err = tx.QueryRow(r.Context(), "SELECT to_jsonb(ar) FROM public.lesson_access_rules ar WHERE id::text = $1 FOR UPDATE", id).Scan(&before)
+		if err == nil {
+			err = tx.QueryRow(r.Context(), `
+				UPDATE public.lesson_access_rules ar
+				SET program_name = $2, applies_to = $3, lesson_id = $4, kind = $5, config = $6::jsonb
+				WHERE id::text = $1
+				RETURNING to_jsonb(ar)
+			`, id, rule.ProgramName, rule.AppliesTo, rule.LessonID, rule.Kind, string(config)).Scan(&after)
+		}
+	}
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Access rule not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditAccessRuleSave, TargetType: "access_rule", TargetID: id, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 SAVE ACCESS RULE ERROR:", err)
+		http.Error(w, "Failed to save access rule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id": id, "message": "Access rule saved"})
+}
+
+// DeleteAccessRule removes a rule (?id=)
+func DeleteAccessRule(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to delete access rule", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before json.RawMessage
+	err = tx.QueryRow(r.Context(), "DELETE FROM public.lesson_access_rules ar WHERE id::text = $1 RETURNING to_jsonb(ar)", id).Scan(&before)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Access rule not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditAccessRuleDelete, TargetType: "access_rule", TargetID: id, Before: before})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DELETE ACCESS RULE ERROR:", err)
+		http.Error(w, "Failed to delete access rule", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Access rule deleted"})
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+var accessNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func hoursFromNow(h int) *time.Time {
+	t := accessNow.Add(time.Duration(h) * time.Hour)
+	return &t
+}
+
+func intPtr(n int) *int { return &n }
+
+// newAccessLearner indexes lessons the way loadAccessLearner does
+func newAccessLearner(lessons []accessLesson, rules ...AccessRule) *accessLearner {
+	a := &accessLearner{lessons: lessons, position: map[string]int{}, rules: rules, reviews: map[string]bool{}}
+	for i, l := range lessons {
+		a.position[l.ID] = i
+	}
+	return a
+}
+
+// threeLessons is a program of three lessons; the midpoint is the second
+func threeLessons() []accessLesson {
+	return []accessLesson{
+		{ID: "l1", Title: "One", HasQuiz: true},
+		{ID: "l2", Title: "Two", HasQuiz: true},
+		{ID: "l3", Title: "Three", HasQuiz: true},
+	}
+}
+
+func TestAccessEvaluate(t *testing.T) {
+	previous := AccessRule{AppliesTo: accessAllLessons, Kind: accessPrerequisite, Config: AccessRuleConfig{Previous: true}}
+	two := "l2"
+
+	for _, tc := range []struct {
+		name      string
+		learner   func() *accessLearner
+		lesson    int
+		wantKinds []string
+		wantUntil *time.Time
+		closingAt *time.Time
+	}{
+		{
+			name:    "first lesson has no previous lesson",
+			learner: func() *accessLearner { return newAccessLearner(threeLessons(), previous) },
+			lesson:  0,
+		},
+		{
+			name:      "previous lesson not completed",
+			learner:   func() *accessLearner { return newAccessLearner(threeLessons(), previous) },
+			lesson:    1,
+			wantKinds: []string{accessPrerequisite},
+		},
+		{
+			name: "previous lesson completed",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[0].Completed = true
+				return newAccessLearner(lessons, previous)
+			},
+			lesson: 1,
+		},
+		{
+			name: "completing the lesson keeps it past its prerequisite",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[1].Completed = true
+				return newAccessLearner(lessons, previous)
+			},
+			lesson: 1,
+		},
+		{
+			name: "a lesson rule with nothing to require exempts it from an all-lessons rule",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(), previous,
+					AccessRule{AppliesTo: accessOneLesson, LessonID: &two, Kind: accessPrerequisite})
+			},
+			lesson: 1,
+		},
+		{
+			name: "quiz scored below the minimum",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[0].QuizSubmitted, lessons[0].QuizScore, lessons[0].QuizTotal = true, 5, 10
+				return newAccessLearner(lessons,
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessQuiz, Config: AccessRuleConfig{Previous: true, MinPercent: 60}})
+			},
+			lesson:    1,
+			wantKinds: []string{accessQuiz},
+		},
+		{
+			name: "quiz scored at the minimum",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[0].QuizSubmitted, lessons[0].QuizScore, lessons[0].QuizTotal = true, 6, 10
+				return newAccessLearner(lessons,
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessQuiz, Config: AccessRuleConfig{Previous: true, MinPercent: 60}})
+			},
+			lesson: 1,
+		},
+		{
+			name: "review missing after the midpoint",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAfterMidpoint, Kind: accessReview, Config: AccessRuleConfig{ReviewTypes: []string{"midpoint"}}})
+			},
+			lesson:    2,
+			wantKinds: []string{accessReview},
+		},
+		{
+			name: "review rule does not cover the midpoint itself",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAfterMidpoint, Kind: accessReview, Config: AccessRuleConfig{ReviewTypes: []string{"midpoint"}}})
+			},
+			lesson: 1,
+		},
+		{
+			name: "review submitted",
+			learner: func() *accessLearner {
+				a := newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAfterMidpoint, Kind: accessReview, Config: AccessRuleConfig{ReviewTypes: []string{"midpoint"}}})
+				a.reviews["midpoint"] = true
+				return a
+			},
+			lesson: 2,
+		},
+		{
+			name: "drip opens the first lesson at the start",
+			learner: func() *accessLearner {
+				a := newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessDrip, Config: AccessRuleConfig{Anchor: anchorEnrolled, IntervalHours: 24}})
+				a.enrolledAt = hoursFromNow(-1)
+				return a
+			},
+			lesson: 0,
+		},
+		{
+			name: "drip holds the next lesson an interval later",
+			learner: func() *accessLearner {
+				a := newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessDrip, Config: AccessRuleConfig{Anchor: anchorEnrolled, IntervalHours: 24}})
+				a.enrolledAt = hoursFromNow(-1)
+				return a
+			},
+			lesson:    1,
+			wantKinds: []string{accessDrip},
+			wantUntil: hoursFromNow(23),
+		},
+		{
+			name: "drip without a known start does not lock",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessDrip, Config: AccessRuleConfig{Anchor: anchorCohortOpen, StartHours: 48}})
+			},
+			lesson: 0,
+		},
+		{
+			name: "absolute window not open yet",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessAbsoluteWindow, Config: AccessRuleConfig{OpensAt: hoursFromNow(2)}})
+			},
+			lesson:    0,
+			wantKinds: []string{accessAbsoluteWindow},
+			wantUntil: hoursFromNow(2),
+		},
+		{
+			name: "absolute window closed",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessAbsoluteWindow, Config: AccessRuleConfig{ClosesAt: hoursFromNow(-1)}})
+			},
+			lesson:    0,
+			wantKinds: []string{accessAbsoluteWindow},
+		},
+		{
+			name: "absolute window counts down to closing",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(),
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessAbsoluteWindow, Config: AccessRuleConfig{OpensAt: hoursFromNow(-2), ClosesAt: hoursFromNow(5)}})
+			},
+			lesson:    0,
+			closingAt: hoursFromNow(5),
+		},
+		{
+			name: "relative window counts down only after the live session",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[0].StartsAt, lessons[0].DurationMinutes = hoursFromNow(-1), intPtr(120)
+				return newAccessLearner(lessons,
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessRelativeWindow, Config: AccessRuleConfig{Anchor: anchorLiveStart, CloseHours: intPtr(48)}})
+			},
+			lesson: 0,
+		},
+		{
+			name: "relative window closed after the live session",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[0].StartsAt, lessons[0].DurationMinutes = hoursFromNow(-30), intPtr(60)
+				return newAccessLearner(lessons,
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessRelativeWindow, Config: AccessRuleConfig{Anchor: anchorLiveEnd, CloseHours: intPtr(24)}})
+			},
+			lesson:    0,
+			wantKinds: []string{accessRelativeWindow},
+		},
+		{
+			name: "live-end window needs a session length",
+			learner: func() *accessLearner {
+				lessons := threeLessons()
+				lessons[0].StartsAt = hoursFromNow(-30)
+				return newAccessLearner(lessons,
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessRelativeWindow, Config: AccessRuleConfig{Anchor: anchorLiveEnd, CloseHours: intPtr(24)}})
+			},
+			lesson: 0,
+		},
+		{
+			name: "every lock is reported in kind order",
+			learner: func() *accessLearner {
+				return newAccessLearner(threeLessons(), previous,
+					AccessRule{AppliesTo: accessAllLessons, Kind: accessAbsoluteWindow, Config: AccessRuleConfig{OpensAt: hoursFromNow(2), ClosesAt: hoursFromNow(5)}})
+			},
+			lesson:    1,
+			wantKinds: []string{accessPrerequisite, accessAbsoluteWindow},
+			wantUntil: hoursFromNow(2),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.learner().evaluate(tc.lesson, accessNow)
+
+			if got.IsLocked != (len(tc.wantKinds) > 0) || len(got.LockReasons) != len(tc.wantKinds) {
+				t.Fatalf("got locked=%v with %+v, want locks %v", got.IsLocked, got.LockReasons, tc.wantKinds)
+			}
+			var until *time.Time
+			for i, reason := range got.LockReasons {
+				if reason.Kind != tc.wantKinds[i] {
+					t.Fatalf("lock %d is %q, want %q", i, reason.Kind, tc.wantKinds[i])
+				}
+				if reason.Message == "" {
+					t.Fatalf("lock %d has no message", i)
+				}
+				if reason.Until != nil {
+					until = reason.Until
+				}
+			}
+			if !sameTime(until, tc.wantUntil) {
+				t.Fatalf("locked until %v, want %v", until, tc.wantUntil)
+			}
+			if !sameTime(got.ClosingAt, tc.closingAt) {
+				t.Fatalf("closing at %v, want %v", got.ClosingAt, tc.closingAt)
+			}
+		})
+	}
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestLessonLockRefusesWrites(t *testing.T) {
+	for _, tc := range []struct {
+		lock LessonLock
+		want bool
+	}{
+		{LessonLock{Kind: accessPrerequisite}, true},
+		{LessonLock{Kind: accessQuiz}, true},
+		{LessonLock{Kind: accessReview}, true},
+		{LessonLock{Kind: accessDrip, Until: hoursFromNow(3)}, true},
+		{LessonLock{Kind: accessAbsoluteWindow, Until: hoursFromNow(3)}, true},
+		{LessonLock{Kind: accessRelativeWindow, Until: hoursFromNow(3)}, true},
+		{LessonLock{Kind: accessAbsoluteWindow}, false},
+		{LessonLock{Kind: accessRelativeWindow}, false},
+	} {
+		if got := tc.lock.refusesWrites(); got != tc.want {
+			t.Errorf("%s lock (until %v) refusesWrites = %v, want %v", tc.lock.Kind, tc.lock.Until, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/asejik/soulmate-reg/server/db"
+)
+
+// GetAnnouncements returns the live announcements for the caller's cohort in the
+// requested program, plus any program-wide announcements not tied to a cohort.
+func GetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(string)
+	program, ok := lookupProgram(r.Context(), r.URL.Query().Get("program"))
+	if !ok {
+		program, _, _ = resolveActiveProgram(r.Context(), userID, "")
+	}
+
+	var cohortID *string
+	if c, err := userCohort(r.Context(), userID, program); err == nil {
+		cohortID = &c.ID
+	}
+
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT id, title, image_url 
+		FROM public.announcements 
+		WHERE (cohort_id = $1 OR (cohort_id IS NULL AND target_program = $2))
+		AND (scheduled_start IS NULL OR NOW() >= scheduled_start)
+		AND (scheduled_end IS NULL OR NOW() <= scheduled_end)
+	`, cohortID, program.ModuleName)
+	if err != nil {
+		http.Error(w, "Failed to fetch announcements", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var anns []map[string]interface{}
+	for rows.Next() {
+		var id, title, url string
+		rows.Scan(&id, &title, &url)
+		anns = append(anns, map[string]interface{}{"id": id, "title": title, "image_url": url})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anns)
+}
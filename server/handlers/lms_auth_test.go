@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/supabase"
+	"github.com/asejik/soulmate-reg/server/supabase/supabasetest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newAuthFake points the Supabase client and the expected issuer at a fresh fake
+func newAuthFake(t *testing.T) *supabasetest.Server {
+	t.Helper()
+	srv := supabasetest.NewServer()
+	t.Cleanup(srv.Close)
+	supabase.SetDefault(srv.AuthClient())
+	t.Setenv("SUPABASE_URL", srv.URL)
+	t.Setenv("SUPABASE_JWT_AUDIENCE", "")
+	t.Setenv("SUPABASE_JWT_ISSUER", "")
+	t.Setenv("SUPABASE_JWT_SECRET", "")
+	return srv
+}
+
+// callLMSAuth runs token through LMSAuth and returns the status and the user id
+// the wrapped handler saw
+func callLMSAuth(token string) (int, string) {
+	var seen string
+	h := LMSAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = r.Context().Value(userIDKey).(string)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/api/lms/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, seen
+}
+
+func TestLMSAuthAcceptsSignedTokens(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		rsa  bool
+	}{
+		{"ES256", false},
+		{"RS256", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newAuthFake(t)
+			if tc.rsa {
+				srv.RotateRSAKey()
+			}
+			u := srv.AddUser("ada@example.com", "secret1")
+
+			code, userID := callLMSAuth(srv.SignToken(u.ID, time.Hour))
+			if code != http.StatusOK || userID != u.ID {
+				t.Fatalf("got %d for user %q, want 200 for %q", code, userID, u.ID)
+			}
+		})
+	}
+}
+
+func TestLMSAuthRejectsBadClaims(t *testing.T) {
+	srv := newAuthFake(t)
+	u := srv.AddUser("ada@example.com", "secret1")
+	now := time.Now()
+	claims := func(aud, iss string, exp time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"sub": u.ID, "aud": aud, "iss": iss, "iat": now.Unix(), "exp": exp.Unix()}
+	}
+	issuer := srv.URL + "/auth/v1"
+
+	for _, tc := range []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"audience", claims("anon", issuer, now.Add(time.Hour))},
+		{"issuer", claims("authenticated", "https://other.supabase.co/auth/v1", now.Add(time.Hour))},
+		{"expired", claims("authenticated", issuer, now.Add(-time.Minute))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, userID := callLMSAuth(srv.SignClaims(tc.claims))
+			if code != http.StatusUnauthorized || userID != "" {
+				t.Fatalf("got %d for user %q, want 401", code, userID)
+			}
+		})
+	}
+}
+
+func TestLMSAuthFallsBackToUserEndpoint(t *testing.T) {
+	srv := newAuthFake(t)
+	u := srv.AddUser("ada@example.com", "secret1")
+
+	code, userID := callLMSAuth(srv.IssueToken(u.ID))
+	if code != http.StatusOK || userID != u.ID {
+		t.Fatalf("got %d for user %q, want 200 for %q", code, userID, u.ID)
+	}
+
+	code, _ = callLMSAuth("not-a-token")
+	if code != http.StatusUnauthorized {
+		t.Fatalf("got %d for an unknown token, want 401", code)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"math"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
@@ -16,22 +15,15 @@ import (
 func GenerateCertificate(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 	requestedProgram := r.URL.Query().Get("program")
-	programName, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
-
-	displayProgramName := "Ready for a Soulmate"
-	if programName == "launchpad" || strings.Contains(strings.ToLower(programName), "launchpad") {
-		displayProgramName = "Couples' Launchpad"
-	}
-
-	// dbProgramName is the exact name stored in the modules table, e.g. "Couples Launchpad"
-	dbProgramName := "Ready for a Soulmate"
-	if programName == "launchpad" || strings.Contains(strings.ToLower(programName), "launchpad") {
-		dbProgramName = "Couples Launchpad"
+	program, _, err := resolveActiveProgram(r.Context(), userID, requestedProgram)
+	if err != nil || program.Slug == "" {
+		http.Error(w, "Program access denied", http.StatusForbidden)
+		return
 	}
 
-	// 1. Eligibility Check: 66% Completion + Final Review Submitted
+	// 1. Eligibility Check: the program's completion threshold (+ final review where required)
 	var totalLessons, completedLessons int
-	err := db.Pool.QueryRow(r.Context(), "SELECT COUNT(l.id) FROM public.lessons l JOIN public.modules m ON l.module_id = m.id WHERE m.program_name = $1", dbProgramName).Scan(&totalLessons)
+	err = db.Pool.QueryRow(r.Context(), "SELECT COUNT(l.id) FROM public.lessons l JOIN public.modules m ON l.module_id = m.id WHERE m.program_name = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL", program.ModuleName).Scan(&totalLessons)
 	if err != nil {
 		http.Error(w, "Failed to check course curriculum. Please try again.", http.StatusInternalServerError)
 		return
@@ -41,8 +33,8 @@ func GenerateCertificate(w http.ResponseWriter, r *http.Request) {
 		SELECT COUNT(lp.lesson_id) FROM public.lesson_progress lp 
 		JOIN public.lessons l ON lp.lesson_id = l.id 
 		JOIN public.modules m ON l.module_id = m.id 
-		WHERE lp.user_id = $1 AND (lp.is_completed = true OR lp.highest_watched_pct >= 80) AND m.program_name = $2
-	`, userID, dbProgramName).Scan(&completedLessons)
+		WHERE lp.user_id = $1 AND (lp.is_completed = true OR lp.highest_watched_pct >= 80) AND m.program_name = $2 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
+	`, userID, program.ModuleName).Scan(&completedLessons)
 	if err != nil {
 		http.Error(w, "Failed to verify completion progress. Please try again.", http.StatusInternalServerError)
 		return
@@ -55,7 +47,7 @@ func GenerateCertificate(w http.ResponseWriter, r *http.Request) {
 			WHERE user_id = $1 AND program_name = $2 
 			AND review_type IN ('final', 'final_video', 'final_google', 'final_instagram')
 		)
-	`, userID, programName).Scan(&hasCompletedFinalReview)
+	`, userID, program.Key).Scan(&hasCompletedFinalReview)
 	if err != nil {
 		http.Error(w, "Failed to verify review status. Please try again.", http.StatusInternalServerError)
 		return
@@ -66,18 +58,14 @@ func GenerateCertificate(w http.ResponseWriter, r *http.Request) {
 		completionRate = float64(completedLessons) / float64(totalLessons)
 	}
 
-	requiredRate := 0.66
-	isLaunchpad := programName == "launchpad" || strings.Contains(strings.ToLower(programName), "launchpad")
-	if isLaunchpad {
-		requiredRate = 1.0
-	}
+	requiredRate := program.CertificateMinCompletion
 
 	requiredLessons := int(math.Ceil(float64(totalLessons) * requiredRate))
 	if requiredLessons < 1 && totalLessons > 0 {
 		requiredLessons = 1
 	}
 
-	if (!hasCompletedFinalReview && !isLaunchpad) || completionRate < requiredRate {
+	if (!hasCompletedFinalReview && program.CertificateRequiresFinalReview) || completionRate < requiredRate {
 		http.Error(w, fmt.Sprintf("You are not yet eligible for a certificate. Please ensure you have at least %d%% completion (%d of %d lessons) and have submitted your final cohort review.", int(requiredRate*100), requiredLessons, totalLessons), http.StatusForbidden)
 		return
 	}
@@ -85,7 +73,7 @@ func GenerateCertificate(w http.ResponseWriter, r *http.Request) {
 	var userName string
 	db.Pool.QueryRow(r.Context(), `
 		SELECT COALESCE(cl.full_name, p.full_name, 'Participant') FROM auth.users au
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email) LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id LEFT JOIN public.participants p ON p.user_id = au.id
 		WHERE au.id = $1 LIMIT 1
 	`, userID).Scan(&userName)
 
@@ -153,7 +141,7 @@ func GenerateCertificate(w http.ResponseWriter, r *http.Request) {
 	pdf.CellFormat(277, 10, "has successfully completed the curriculum for", "", 1, "C", false, 0, "")
 
 	pdf.SetFont("Arial", "B", 24); pdf.SetTextColor(15, 23, 42); pdf.SetY(150)
-	pdf.CellFormat(277, 10, displayProgramName, "", 1, "C", false, 0, "")
+	pdf.CellFormat(277, 10, program.DisplayName, "", 1, "C", false, 0, "")
 
 	// 4. Footer Section
 	pdf.SetY(175); pdf.SetX(30); pdf.SetFont("Arial", "B", 12); pdf.SetTextColor(15, 23, 42)
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
 type CommentResponse struct {
@@ -55,8 +57,8 @@ func GetLessonComments(w http.ResponseWriter, r *http.Request) {
 	rows, _ := db.Pool.Query(r.Context(), `
 		SELECT c.id, c.lesson_id, l.title, c.content, c.created_at, COALESCE(cl.full_name, p.full_name, 'Participant') AS user_name
 		FROM public.lesson_comments c JOIN public.lessons l ON c.lesson_id = l.id JOIN auth.users au ON c.user_id = au.id
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email) LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
-		WHERE c.lesson_id = $1 ORDER BY c.created_at DESC LIMIT 500
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id LEFT JOIN public.participants p ON p.user_id = au.id
+		WHERE c.lesson_id = $1 AND c.deleted_at IS NULL ORDER BY c.created_at DESC LIMIT 500
 	`, lessonID)
 	defer rows.Close()
 
@@ -81,16 +83,14 @@ func GetLessonComments(w http.ResponseWriter, r *http.Request) {
 func GetGlobalDiscussions(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 	requestedProgram := r.URL.Query().Get("program")
-	programName, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
-
-	// Admin Override: Admins might not be enrolled in any program
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if isAdminEmail(adminEmail) {
-		if requestedProgram == "launchpad" {
-			programName = "launchpad"
-		} else {
-			programName = "Ready for a Soulmate"
+	program, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
+
+	// Staff Override: staff might not be enrolled in any program
+	if rolesFrom(r.Context()).isStaff() {
+		if p, ok := lookupProgram(r.Context(), requestedProgram); ok {
+			program = p
+		} else if programs, err := loadPrograms(r.Context()); err == nil && len(programs) > 0 {
+			program = programs[0]
 		}
 	}
 
@@ -98,6 +98,7 @@ func GetGlobalDiscussions(w http.ResponseWriter, r *http.Request) {
 		WITH LatestComments AS (
 			SELECT DISTINCT ON (lesson_id) *
 			FROM public.lesson_comments
+			WHERE deleted_at IS NULL
 			ORDER BY lesson_id, created_at DESC
 		)
 		SELECT lc.id, lc.lesson_id, l.title, lc.content, lc.created_at, 
@@ -106,11 +107,11 @@ func GetGlobalDiscussions(w http.ResponseWriter, r *http.Request) {
 		JOIN public.lessons l ON lc.lesson_id = l.id
 		JOIN public.modules m ON l.module_id = m.id
 		JOIN auth.users au ON lc.user_id = au.id 
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email) 
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
-		WHERE m.program_name = $1
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id 
+		LEFT JOIN public.participants p ON p.user_id = au.id
+		WHERE m.program_name = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
 		ORDER BY lc.created_at DESC
-	`, programName)
+	`, program.ModuleName)
 	defer rows.Close()
 
 	var comments []CommentResponse
@@ -126,7 +127,7 @@ func GetGlobalDiscussions(w http.ResponseWriter, r *http.Request) {
 func SubmitReview(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 	requestedProgram := r.URL.Query().Get("program")
-	programName, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
+	program, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
 
 	var req struct { 
 		ReviewType string `json:"reviewType"`
@@ -142,9 +143,9 @@ func SubmitReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if programName == "launchpad" && (req.ReviewType == "mid_google" || req.ReviewType == "mid_video" || req.ReviewType == "mid_cohort") {
-		if len(req.Content) < 20 {
-			http.Error(w, "Please type a detailed response (at least 20 characters) or provide a valid link.", http.StatusBadRequest)
+	if minChars := program.CheckpointMinReviewChars; minChars > 0 && (req.ReviewType == "mid_google" || req.ReviewType == "mid_video" || req.ReviewType == "mid_cohort") {
+		if len(req.Content) < minChars {
+			http.Error(w, fmt.Sprintf("Please type a detailed response (at least %d characters) or provide a valid link.", minChars), http.StatusBadRequest)
 			return
 		}
 	}
@@ -155,7 +156,7 @@ func SubmitReview(w http.ResponseWriter, r *http.Request) {
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (user_id, program_name, review_type) 
 		DO UPDATE SET content = $4
-	`, userID, programName, req.ReviewType, req.Content)
+	`, userID, program.Key, req.ReviewType, req.Content)
 
 	if err != nil {
 		http.Error(w, "Failed to submit review", http.StatusInternalServerError)
@@ -165,19 +166,34 @@ func SubmitReview(w http.ResponseWriter, r *http.Request) {
 }
 
 func DeleteLessonComment(w http.ResponseWriter, r *http.Request) {
-	// Verify Admin JWT
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	if err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail); err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		http.Error(w, "Missing ID", http.StatusBadRequest)
 		return
 	}
-	db.Pool.Exec(r.Context(), "DELETE FROM public.lesson_comments WHERE id::text = $1", id)
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		UPDATE public.lesson_comments c SET deleted_at = NOW() WHERE id::text = $1 AND deleted_at IS NULL
+		RETURNING to_jsonb(c) || '{"deleted_at": null}', to_jsonb(c)
+	`, id).Scan(&before, &after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditCommentDelete, TargetType: "comment", TargetID: id, Before: before, After: after})
+	} else if err == pgx.ErrNoRows {
+		err = nil
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
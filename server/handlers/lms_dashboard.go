@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
@@ -18,6 +18,7 @@ type DashLesson struct {
 	Progress           int        `json:"progress"`
 	LastWatchedSeconds float64    `json:"last_watched_seconds"`
 	HasFeedback        bool       `json:"has_feedback"`
+	LessonAccess
 }
 
 type DashModule struct {
@@ -30,15 +31,20 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 
 	requestedProgram := r.URL.Query().Get("program")
-	programName, enrolledPrograms, err := resolveActiveProgram(r.Context(), userID, requestedProgram)
-	if err != nil || programName == "" {
+	program, enrolledPrograms, err := resolveActiveProgram(r.Context(), userID, requestedProgram)
+	if err != nil || program.Slug == "" {
 		http.Error(w, "Program access denied", http.StatusForbidden)
 		return
 	}
 
-	programNameDisplay := "Ready for a Soulmate"
-	if programName == "launchpad" || strings.Contains(strings.ToLower(programName), "launchpad") {
-		programNameDisplay = "Couples Launchpad"
+	programName, programNameDisplay := program.Key, program.ModuleName
+
+	// The user's intake drives the cohort label and its live schedule
+	cohortName := programNameDisplay
+	var cohortID *string
+	if c, err := userCohort(r.Context(), userID, program); err == nil {
+		cohortName = c.Name
+		cohortID = &c.ID
 	}
 
 	// PARALLEL EXECUTION: Run independent count/exists queries in parallel
@@ -50,14 +56,21 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	checkpointVideoChan := make(chan result, 1)
 	introVideoChan := make(chan result, 1)
 	fullNameChan := make(chan result, 1)
+	accessChan := make(chan result, 1)
+
+	// Locked state and reasons come from the same rules GetLesson enforces
+	go func() {
+		access, err := programLessonAccess(r.Context(), userID, program)
+		accessChan <- result{access, err}
+	}()
 
 	go func() {
 		var fullName string
 		err := db.Pool.QueryRow(r.Context(), `
 			SELECT COALESCE(cl.full_name, p.full_name, '')
 			FROM auth.users au
-			LEFT JOIN public.participants p ON au.email = p.email
-			LEFT JOIN public.couples_launchpad cl ON au.email = cl.email
+			LEFT JOIN public.participants p ON p.user_id = au.id
+			LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
 			WHERE au.id = $1
 		`, userID).Scan(&fullName)
 		fullNameChan <- result{fullName, err}
@@ -65,7 +78,7 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 
 	go func() {
 		var count int
-		err := db.Pool.QueryRow(r.Context(), "SELECT COUNT(l.id) FROM public.lessons l JOIN public.modules m ON l.module_id = m.id WHERE m.program_name = $1", programNameDisplay).Scan(&count)
+		err := db.Pool.QueryRow(r.Context(), "SELECT COUNT(l.id) FROM public.lessons l JOIN public.modules m ON l.module_id = m.id WHERE m.program_name = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL", programNameDisplay).Scan(&count)
 		totalLessonsChan <- result{count, err}
 	}()
 
@@ -75,7 +88,7 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 			SELECT COUNT(lp.lesson_id) FROM public.lesson_progress lp 
 			JOIN public.lessons l ON lp.lesson_id = l.id 
 			JOIN public.modules m ON l.module_id = m.id 
-			WHERE lp.user_id = $1 AND (lp.is_completed = true OR lp.highest_watched_pct >= 80) AND m.program_name = $2
+			WHERE lp.user_id = $1 AND (lp.is_completed = true OR lp.highest_watched_pct >= 80) AND m.program_name = $2 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
 		`, userID, programNameDisplay).Scan(&count)
 		completedLessonsChan <- result{count, err}
 	}()
@@ -101,9 +114,6 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		var videoID string
 		db.Pool.QueryRow(r.Context(), "SELECT COALESCE(mid_checkpoint_video_id, '') FROM public.program_settings WHERE program_name = $1", programNameDisplay).Scan(&videoID)
-		if videoID == "" {
-			db.Pool.QueryRow(r.Context(), "SELECT COALESCE(mid_checkpoint_video_id, '') FROM public.program_settings WHERE program_name ILIKE $1 LIMIT 1", "%"+programName+"%").Scan(&videoID)
-		}
 		checkpointVideoChan <- result{videoID, nil}
 	}()
 
@@ -111,9 +121,6 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 		var videoID string
 		var introDesc string
 		db.Pool.QueryRow(r.Context(), "SELECT COALESCE(intro_video_id, ''), COALESCE(intro_video_description, '') FROM public.program_settings WHERE program_name = $1", programNameDisplay).Scan(&videoID, &introDesc)
-		if videoID == "" && introDesc == "" {
-			db.Pool.QueryRow(r.Context(), "SELECT COALESCE(intro_video_id, ''), COALESCE(intro_video_description, '') FROM public.program_settings WHERE program_name ILIKE $1 LIMIT 1", "%"+programName+"%").Scan(&videoID, &introDesc)
-		}
 		introVideoChan <- result{map[string]string{"id": videoID, "desc": introDesc}, nil}
 	}()
 
@@ -121,16 +128,17 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	rows, err := db.Pool.Query(r.Context(), `
 		SELECT
 			m.id, m.title, l.id, l.title,
-			COALESCE(l.estimated_time, ''), COALESCE(lp.is_completed OR lp.highest_watched_pct >= 80, false), l.scheduled_start_time,
+			COALESCE(l.estimated_time, ''), COALESCE(lp.is_completed OR lp.highest_watched_pct >= 80, false), COALESCE(ls.scheduled_start_time, l.scheduled_start_time),
 			COALESCE(lp.highest_watched_pct, 0)::int, COALESCE(lp.last_watched_seconds, 0.0)::float,
 			EXISTS(SELECT 1 FROM public.assignment_submissions WHERE user_id = $2 AND lesson_id = l.id AND admin_feedback IS NOT NULL AND admin_feedback <> ''),
 			lp.updated_at
 		FROM public.modules m
 		JOIN public.lessons l ON m.id = l.module_id
 		LEFT JOIN public.lesson_progress lp ON l.id = lp.lesson_id AND lp.user_id = $2
-		WHERE m.program_name = $1
+		LEFT JOIN public.lesson_schedules ls ON ls.lesson_id = l.id AND ls.cohort_id = $3
+		WHERE m.program_name = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
 		ORDER BY m.sort_order ASC, l.sort_order ASC
-	`, programNameDisplay, userID)
+	`, programNameDisplay, userID, cohortID)
 
 	modules := []DashModule{}
 	var currentModule *DashModule
@@ -199,6 +207,18 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	resVideo := <-checkpointVideoChan
 	resIntro := <-introVideoChan
 	resFullName := <-fullNameChan
+	resAccess := <-accessChan
+
+	if resAccess.err != nil {
+		fmt.Println("💥 LESSON ACCESS ERROR (Dashboard):", resAccess.err)
+	}
+	if access, ok := resAccess.val.(map[string]LessonAccess); ok {
+		for mi := range modules {
+			for li := range modules[mi].Lessons {
+				modules[mi].Lessons[li].LessonAccess = access[modules[mi].Lessons[li].ID]
+			}
+		}
+	}
 
 	totalCount := 0
 	if val, ok := resTotal.val.(int); ok {
@@ -223,7 +243,7 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 		"intro_video_description": introVideoDesc,
 		"has_reached_midway": hasReachedMidway,
 		"active_program": programName, "enrolled_programs": enrolledPrograms,
-		"cohort": map[string]interface{}{"name": programNameDisplay, "total_lessons": resTotal.val, "completed_lessons": resCompleted.val},
+		"cohort": map[string]interface{}{"name": cohortName, "program_name": programNameDisplay, "total_lessons": resTotal.val, "completed_lessons": resCompleted.val},
 		"curriculum": modules, "next_lesson": map[string]interface{}{"id": nextLessonID},
 	})
 }
@@ -235,8 +255,8 @@ func GetProfile(w http.ResponseWriter, r *http.Request) {
 	err := db.Pool.QueryRow(r.Context(), `
 		SELECT COALESCE(cl.full_name, p.full_name, ''), au.email
 		FROM auth.users au
-		LEFT JOIN public.participants p ON au.email = p.email
-		LEFT JOIN public.couples_launchpad cl ON au.email = cl.email
+		LEFT JOIN public.participants p ON p.user_id = au.id
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
 		WHERE au.id = $1
 	`, userID).Scan(&fullName, &email)
 
@@ -63,23 +63,14 @@ func GetGivingCommitment(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetAdminGivingCommitments(w http.ResponseWriter, r *http.Request) {
-	// Security check: verify admin email
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
 	rows, err := db.Pool.Query(r.Context(), `
 		SELECT gc.commitment_text, gc.created_at, gc.program_name, 
 			   COALESCE(cl.full_name, p.full_name, 'Participant') as user_name,
 			   au.email
 		FROM public.giving_commitments gc
 		JOIN auth.users au ON gc.user_id = au.id
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		LEFT JOIN public.participants p ON p.user_id = au.id
 		ORDER BY gc.created_at DESC
 	`)
 
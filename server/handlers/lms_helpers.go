@@ -2,15 +2,15 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/asejik/soulmate-reg/server/db"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/asejik/soulmate-reg/server/supabase"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
 type contextKey string
@@ -19,11 +19,12 @@ const userIDKey contextKey = "user_id"
 
 // LMSAuth Middleware verifies the Supabase Bearer Token.
 //
-// Strategy 1 (Fast Path): Attempts local cryptographic JWT verification.
-// If successful, it eliminates network egress to Supabase.
+// Strategy 1 (Fast Path): Verifies the JWT locally: HS256 with SUPABASE_JWT_SECRET,
+// RS256/ES256 against the project's cached JWKS. aud, iss and exp are enforced.
 //
-// Strategy 2 (Fallback): If local verification fails for any reason
-// it seamlessly falls back to original HTTP verification via the Supabase API.
+// Strategy 2 (Fallback): If the token can't be checked locally (no secret, unknown
+// key, JWKS unreachable) it asks Supabase via /auth/v1/user, remembering the answer
+// briefly so a slow auth provider doesn't add latency to every call.
 func LMSAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -33,120 +34,134 @@ func LMSAuth(next http.Handler) http.Handler {
 		}
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		jwtSecret := strings.TrimSpace(os.Getenv("SUPABASE_JWT_SECRET"))
 
-		// ── STRATEGY 1: Attempt Local JWT Verification ────────────────────────
-		if jwtSecret != "" {
-			token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v (alg=%v)", token.Method.Alg(), token.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			})
-
-			if err == nil && token.Valid {
-				if claims, ok := token.Claims.(jwt.MapClaims); ok {
-					if userID, ok := claims["sub"].(string); ok && userID != "" {
-						ctx := context.WithValue(r.Context(), userIDKey, userID)
-						next.ServeHTTP(w, r.WithContext(ctx))
-						return
-					} else {
-						err = fmt.Errorf("missing or invalid sub claim")
-					}
-				} else {
-					err = fmt.Errorf("failed to cast claims to MapClaims")
-				}
-			}
-			
-			// Local validation failed. Log the exact reason to server console
-			isValid := false
-			if token != nil {
-				isValid = token.Valid
-			}
-			log.Printf("[LMSAuth] Local JWT validation skipped/failed, falling back to HTTP. Error: %v, TokenValid: %v", err, isValid)
+		// ── STRATEGY 1: Local JWT Verification ────────────────────────────────
+		userID, err := verifyAccessTokenLocally(r.Context(), tokenStr)
+		if err == nil {
+			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		if errors.Is(err, errTokenRejected) {
+			http.Error(w, "LMS Session Expired or Unauthorized. Please log in again.", http.StatusUnauthorized)
+			return
 		}
 
 		// ── STRATEGY 2: Fallback to HTTP Verification ─────────────────────────
-		supabaseURL := strings.TrimSuffix(os.Getenv("SUPABASE_URL"), "/")
-		serviceKey := strings.TrimSpace(os.Getenv("SUPABASE_SERVICE_ROLE_KEY"))
-
-		if supabaseURL == "" || serviceKey == "" {
-			http.Error(w, "Background Configuration Error: Supabase credentials missing on server", http.StatusInternalServerError)
+		if cached, ok := cachedSession(tokenStr); ok {
+			ctx := context.WithValue(r.Context(), userIDKey, cached)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
+		log.Printf("[LMSAuth] Local JWT validation failed, falling back to HTTP. Error: %v", err)
 
-		userURL := supabaseURL + "/auth/v1/user"
-		req, err := http.NewRequest("GET", userURL, nil)
-		if err != nil {
-			http.Error(w, "Server error creating auth request", http.StatusInternalServerError)
+		auth := supabase.Default()
+		if !auth.Configured() {
+			http.Error(w, "Background Configuration Error: Supabase credentials missing on server", http.StatusInternalServerError)
 			return
 		}
-		req.Header.Set("Authorization", authHeader)
-		req.Header.Set("apikey", serviceKey)
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
 
+		user, err := auth.UserFromToken(r.Context(), tokenStr)
 		if err != nil {
+			var apiErr *supabase.Error
+			if errors.As(err, &apiErr) {
+				http.Error(w, "LMS Session Expired or Unauthorized. Please log in again.", http.StatusUnauthorized)
+				return
+			}
 			http.Error(w, "Failed to reach Auth Provider", http.StatusInternalServerError)
 			return
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			http.Error(w, "LMS Session Expired or Unauthorized. Please log in again.", http.StatusUnauthorized)
-			return
-		}
 
-		var result map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&result)
-
-		userID, ok := result["id"].(string)
-		if !ok {
+		if user.ID == "" {
 			http.Error(w, "User ID not found in token", http.StatusUnauthorized)
 			return
 		}
+		rememberSession(tokenStr, user.ID)
 
-		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		ctx := context.WithValue(r.Context(), userIDKey, user.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// resolveActiveProgram detects if a user is in RFASM, CLP, or both
-func resolveActiveProgram(ctx context.Context, userID string, requestedProgram string) (string, []string, error) {
-	var isLaunchpad, isSoulmate bool
-	err := db.Pool.QueryRow(ctx, `
-		SELECT cl.email IS NOT NULL, p.email IS NOT NULL
-		FROM auth.users au
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
-		WHERE au.id = $1 LIMIT 1
-	`, userID).Scan(&isLaunchpad, &isSoulmate)
-
+// resolveActiveProgram detects which registered programs a user is enrolled in
+// (by a registration linked to their login in each program's table) and picks the
+// requested one, or the first by sort order. enrolled holds the program keys.
+func resolveActiveProgram(ctx context.Context, userID string, requestedProgram string) (Program, []string, error) {
+	programs, err := loadPrograms(ctx)
 	if err != nil {
-		return "", nil, err
+		return Program{}, nil, err
 	}
 
+	var enrolledPrograms []Program
 	var enrolled []string
-	if isLaunchpad {
-		enrolled = append(enrolled, "launchpad")
-	}
-	if isSoulmate {
-		enrolled = append(enrolled, "Ready for a Soulmate")
+	for _, p := range programs {
+		var isEnrolled bool
+		err := db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM "+p.registrationIdent()+" WHERE user_id = $1 AND deleted_at IS NULL)", userID).Scan(&isEnrolled)
+		if err != nil {
+			return Program{}, nil, err
+		}
+		if isEnrolled {
+			enrolledPrograms = append(enrolledPrograms, p)
+			enrolled = append(enrolled, p.Key)
+		}
 	}
 
-	if len(enrolled) == 0 {
-		return "", nil, nil
+	if len(enrolledPrograms) == 0 {
+		return Program{}, nil, nil
 	}
 
-	active := enrolled[0]
-	if requestedProgram != "" {
-		for _, p := range enrolled {
-			if p == requestedProgram {
+	active := enrolledPrograms[0]
+	if requested, ok := lookupProgram(ctx, requestedProgram); ok {
+		for _, p := range enrolledPrograms {
+			if p.Slug == requested.Slug {
 				active = p
 				break
 			}
 		}
 	}
 	return active, enrolled, nil
-}
\ No newline at end of file
+}
+
+// RequireLessonEnrollment guards the /api/lms/lessons/{id}/... routes: the caller must be
+// enrolled in the program the lesson belongs to. Staff bypass the check, as they do on the
+// discussion board. It must run after LMSAuth and LoadRoles.
+func RequireLessonEnrollment(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var programName string
+		err := db.Pool.QueryRow(r.Context(), `
+			SELECT m.program_name
+			FROM public.lessons l
+			JOIN public.modules m ON l.module_id = m.id
+			WHERE l.id::text = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
+		`, chi.URLParam(r, "id")).Scan(&programName)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "Lesson not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[LMSAuth] Lesson enrollment lookup failed: %v", err)
+			http.Error(w, "Failed to check enrollment", http.StatusInternalServerError)
+			return
+		}
+
+		if !rolesFrom(r.Context()).isStaff() {
+			userID := r.Context().Value(userIDKey).(string)
+			program, ok := lookupProgram(r.Context(), programName)
+			if ok {
+				var active Program
+				active, _, err = resolveActiveProgram(r.Context(), userID, program.Key)
+				if err != nil {
+					log.Printf("[LMSAuth] Enrollment check failed for %s: %v", userID, err)
+					http.Error(w, "Failed to check enrollment", http.StatusInternalServerError)
+					return
+				}
+				ok = active.Slug == program.Slug
+			}
+			if !ok {
+				http.Error(w, "You are not enrolled in the program this lesson belongs to", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 
@@ -17,38 +16,38 @@ func GetLesson(w http.ResponseWriter, r *http.Request) {
 	lessonID := chi.URLParam(r, "id")
 
 	var lesson struct {
-		ID                  string     `json:"id"`
-		Title               string     `json:"title"`
-		Description         string     `json:"description"`
-		VideoID             string     `json:"videoId"`
-		EstimatedTime       string     `json:"estimatedTime"`
-		AssignmentPrompt    string     `json:"assignmentPrompt"`
-		IsCompleted         bool       `json:"is_completed"`
-		IsLocked            bool       `json:"is_locked"`
-		ScheduledStartTime  *time.Time `json:"scheduled_start_time"`
-		LastWatchedSeconds  float64    `json:"last_watched_seconds"`
-		Progress            int        `json:"progress"`
-		DurationMinutes     *int       `json:"-"`          // server-side only; drives 48h locking
-		ClosingAt           *time.Time `json:"closing_at"` // non-nil during the 48h rewatch window
-		HasQuiz             bool       `json:"has_quiz"`
+		ID                  string       `json:"id"`
+		Title               string       `json:"title"`
+		Description         string       `json:"description"`
+		VideoID             string       `json:"videoId"`
+		EstimatedTime       string       `json:"estimatedTime"`
+		AssignmentPrompt    string       `json:"assignmentPrompt"`
+		IsCompleted         bool         `json:"is_completed"`
+		IsLocked            bool         `json:"is_locked"`
+		LockReasons         []LessonLock `json:"lock_reasons"`
+		ScheduledStartTime  *time.Time   `json:"scheduled_start_time"`
+		LastWatchedSeconds  float64      `json:"last_watched_seconds"`
+		Progress            int          `json:"progress"`
+		ClosingAt           *time.Time   `json:"closing_at"` // non-nil while a viewing window counts down to closing
+		HasQuiz             bool         `json:"has_quiz"`
 	}
 
 	var programName string
 	err := db.Pool.QueryRow(r.Context(), `
 		SELECT l.id, l.title, COALESCE(l.description, ''), l.video_id, COALESCE(l.estimated_time, ''), COALESCE(l.assignment_prompt, ''),
-			   m.program_name, l.scheduled_start_time, COALESCE(lp.is_completed OR lp.highest_watched_pct >= 80, false), COALESCE(lp.last_watched_seconds, 0.0)::float,
+			   m.program_name, COALESCE(ls.scheduled_start_time, l.scheduled_start_time), COALESCE(lp.is_completed OR lp.highest_watched_pct >= 80, false), COALESCE(lp.last_watched_seconds, 0.0)::float,
 			   COALESCE(lp.highest_watched_pct, 0)::int,
-			   l.live_duration_minutes,
 			   EXISTS(SELECT 1 FROM public.quizzes q WHERE q.lesson_id = l.id)
 		FROM public.lessons l
 		JOIN public.modules m ON l.module_id = m.id
 		LEFT JOIN public.lesson_progress lp ON l.id = lp.lesson_id AND lp.user_id = $2
-		WHERE l.id = $1
+		`+userCohortJoin+`
+		WHERE l.id = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
 	`, lessonID, userID).Scan(
 		&lesson.ID, &lesson.Title, &lesson.Description, &lesson.VideoID, &lesson.EstimatedTime,
 		&lesson.AssignmentPrompt, &programName, &lesson.ScheduledStartTime, &lesson.IsCompleted,
 		&lesson.LastWatchedSeconds, &lesson.Progress,
-		&lesson.DurationMinutes, &lesson.HasQuiz,
+		&lesson.HasQuiz,
 	)
 
 	if err != nil {
@@ -57,61 +56,27 @@ func GetLesson(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Mid-Program Checkpoint Enforcement
-	var totalLessons, lessonPosition int
-	db.Pool.QueryRow(r.Context(), `
-		WITH AllLessons AS (
-			SELECT l.id, ROW_NUMBER() OVER (ORDER BY m.sort_order ASC, l.sort_order ASC) as pos
-			FROM public.lessons l
-			JOIN public.modules m ON l.module_id = m.id
-			WHERE m.program_name = $1
-		)
-		SELECT (SELECT count(*) FROM AllLessons), pos
-		FROM AllLessons WHERE id = $2
-	`, programName, lessonID).Scan(&totalLessons, &lessonPosition)
-
-	if lessonPosition > (totalLessons+1)/2 {
-		// Derive the review key the same way resolveActiveProgram does:
-		// "Couples Launchpad" (module display name) → "launchpad" (stored in program_reviews)
-		reviewKey := programName
-		if strings.ToLower(programName) == "couples launchpad" || strings.Contains(strings.ToLower(programName), "launchpad") {
-			reviewKey = "launchpad"
-		}
-
-		// Post-checkpoint lesson: Check if review is submitted
-		var hasReviewed bool
-		db.Pool.QueryRow(r.Context(), "SELECT EXISTS(SELECT 1 FROM public.program_reviews WHERE user_id = $1 AND program_name = $2 AND review_type IN ('mid_cohort', 'mid_video', 'mid_google'))", userID, reviewKey).Scan(&hasReviewed)
-		if !hasReviewed {
-			http.Error(w, "Checkpoint Required. Please complete your Mid-Program Review to unlock the second half of the curriculum.", http.StatusForbidden)
-			return
-		}
+	// Lessons live in modules keyed by the program's module name
+	program, ok := lookupProgram(r.Context(), programName)
+	if !ok {
+		fmt.Println("💥 LESSON ACCESS ERROR: program lookup failed for lesson", lessonID)
+		http.Error(w, "Failed to check lesson access", http.StatusInternalServerError)
+		return
 	}
+	program.ModuleName = programName
 
-	// All valid lessons are now unlocked to allow access to waitrooms and countdowns
-	lesson.IsLocked = false
-
-	// Post-live access window: lock lesson after a certain duration from live session end.
-	// Only applies to lessons that have both a scheduled start time AND a duration set.
-	if lesson.ScheduledStartTime != nil && lesson.DurationMinutes != nil && *lesson.DurationMinutes > 0 {
-		liveEnd := lesson.ScheduledStartTime.Add(time.Duration(*lesson.DurationMinutes) * time.Minute)
-		
-		lockDuration := 48 * time.Hour
-		isLaunchpad := programName == "launchpad" || programName == "Couples Launchpad" || strings.Contains(strings.ToLower(programName), "launchpad")
-		
-		// The lesson right before the mid-program checkpoint stays open for 5 days
-		if isLaunchpad && lessonPosition == (totalLessons+1)/2 {
-			lockDuration = 120 * time.Hour // 5 days
-		}
-
-		lockAt := liveEnd.Add(lockDuration)
-		now := time.Now().UTC()
-		if now.After(lockAt) {
-			// window has expired — deny access
-			lesson.IsLocked = true
-		} else if now.After(liveEnd) {
-			// Session ended but still within window — show countdown to closure
-			lesson.ClosingAt = &lockAt
-		}
+	// Prerequisites, the checkpoint review and viewing windows all come from the access rules.
+	// Lessons without a lock stay open before their premiere for waitrooms and countdowns.
+	access, err := programLessonAccess(r.Context(), userID, program)
+	if err != nil {
+		fmt.Println("💥 LESSON ACCESS ERROR:", err)
+		http.Error(w, "Failed to check lesson access", http.StatusInternalServerError)
+		return
+	}
+	a := access[lesson.ID]
+	lesson.IsLocked, lesson.LockReasons, lesson.ClosingAt = a.IsLocked, a.LockReasons, a.ClosingAt
+	if lesson.IsLocked {
+		lesson.VideoID, lesson.AssignmentPrompt = "", ""
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -166,15 +131,6 @@ func SubmitAssignment(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Assignment submitted!"})
 }
 func ResetUserProgress(w http.ResponseWriter, r *http.Request) {
-	// 0. Double-Lock Security: Verify the requester is actually the admin
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
 	// 1. Inputs: This ID is from public.participants or couples_launchpad
 	targetParticipantID := r.URL.Query().Get("user_id") 
 	lessonID := r.URL.Query().Get("lesson_id")
@@ -185,15 +141,13 @@ func ResetUserProgress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 2. IMPORTANT: We MUST find the corresponding Auth User ID (UUID) 
+	// 2. IMPORTANT: We MUST find the linked Auth User ID (UUID) 
 	// This ensures we clean up the tables linked to the actual login identity.
 	var authID string
-	err = db.Pool.QueryRow(r.Context(), `
-		SELECT id FROM auth.users WHERE email IN (
-			SELECT email FROM public.participants WHERE id::text = $1
-			UNION
-			SELECT email FROM public.couples_launchpad WHERE id::text = $1
-		)
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT user_id::text FROM public.participants WHERE id::text = $1 AND user_id IS NOT NULL
+		UNION
+		SELECT user_id::text FROM public.couples_launchpad WHERE id::text = $1 AND user_id IS NOT NULL
 	`, targetParticipantID).Scan(&authID)
 
 	if err != nil {
@@ -202,26 +156,51 @@ func ResetUserProgress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 3. Selective Deletions
+	// 3. Selective Deletions, in one transaction so the audit record lists exactly what went
+	scope := "all"
+	filter := ""
+	args := []any{authID}
+	tables := []string{"lesson_progress", "assignment_submissions", "program_reviews"}
 	if lessonID != "" {
 		// Reset a single lesson
-		db.Pool.Exec(r.Context(), "DELETE FROM public.lesson_progress WHERE user_id = $1 AND lesson_id = $2", authID, lessonID)
-		db.Pool.Exec(r.Context(), "DELETE FROM public.assignment_submissions WHERE user_id = $1 AND lesson_id = $2", authID, lessonID)
+		scope, filter, args = "lesson", " AND lesson_id = $2", append(args, lessonID)
+		tables = tables[:2]
 	} else if moduleID != "" {
 		// Reset a whole module
-		db.Pool.Exec(r.Context(), `
-			DELETE FROM public.lesson_progress 
-			WHERE user_id = $1 AND lesson_id IN (SELECT id FROM public.lessons WHERE module_id = $2)
-		`, authID, moduleID)
-		db.Pool.Exec(r.Context(), `
-			DELETE FROM public.assignment_submissions
-			WHERE user_id = $1 AND lesson_id IN (SELECT id FROM public.lessons WHERE module_id = $2)
-		`, authID, moduleID)
-	} else {
-		// FULL RESET: Clear EVERYTHING to give a blank slate
-		db.Pool.Exec(r.Context(), "DELETE FROM public.lesson_progress WHERE user_id = $1", authID)
-		db.Pool.Exec(r.Context(), "DELETE FROM public.program_reviews WHERE user_id = $1", authID)
-		db.Pool.Exec(r.Context(), "DELETE FROM public.assignment_submissions WHERE user_id = $1", authID)
+		scope, filter, args = "module", " AND lesson_id IN (SELECT id FROM public.lessons WHERE module_id = $2)", append(args, moduleID)
+		tables = tables[:2]
+	}
+	// else FULL RESET: Clear EVERYTHING to give a blank slate
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to reset progress", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	before := map[string]any{"scope": scope, "lesson_id": lessonID, "module_id": moduleID}
+	for _, table := range tables {
+		var rows json.RawMessage
+		err = tx.QueryRow(r.Context(), `
+			WITH d AS (DELETE FROM public.`+table+` WHERE user_id = $1`+filter+` RETURNING *)
+			SELECT COALESCE(jsonb_agg(to_jsonb(d)), '[]'::jsonb) FROM d
+		`, args...).Scan(&rows)
+		if err != nil {
+			break
+		}
+		before[table] = rows
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditProgressReset, TargetType: "user", TargetID: authID, Before: before})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 RESET PROGRESS ERROR:", err)
+		http.Error(w, "Failed to reset progress", http.StatusInternalServerError)
+		return
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -249,8 +228,8 @@ func GetLessonActivity(w http.ResponseWriter, r *http.Request) {
 		SELECT DISTINCT COALESCE(cl.full_name, p.full_name, 'Participant') as name
 		FROM public.lesson_progress lp
 		JOIN auth.users au ON lp.user_id = au.id
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		LEFT JOIN public.participants p ON p.user_id = au.id
 		WHERE lp.lesson_id = $1 AND lp.updated_at > NOW() - INTERVAL '1 minute'
 		ORDER BY name ASC
 	`, lessonID)
@@ -24,14 +24,14 @@ type Question struct {
 func GetUserQuestions(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 	requestedProgram := r.URL.Query().Get("program")
-	programName, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
+	program, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
 
 	rows, err := db.Pool.Query(r.Context(), `
 		SELECT id, user_id, program_name, question_text, answer_text, is_answered, answered_at, created_at
 		FROM public.questions
 		WHERE user_id = $1 AND program_name = $2
 		ORDER BY created_at DESC LIMIT 500
-	`, userID, programName)
+	`, userID, program.Key)
 
 	if err != nil {
 		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
@@ -56,7 +56,7 @@ func GetUserQuestions(w http.ResponseWriter, r *http.Request) {
 func AskQuestion(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 	requestedProgram := r.URL.Query().Get("program")
-	programName, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
+	program, _, _ := resolveActiveProgram(r.Context(), userID, requestedProgram)
 
 	var req struct {
 		Text string `json:"text"`
@@ -69,7 +69,7 @@ func AskQuestion(w http.ResponseWriter, r *http.Request) {
 	_, err := db.Pool.Exec(r.Context(), `
 		INSERT INTO public.questions (user_id, program_name, question_text)
 		VALUES ($1, $2, $3)
-	`, userID, programName, req.Text)
+	`, userID, program.Key, req.Text)
 
 	if err != nil {
 		http.Error(w, "Failed to submit question", http.StatusInternalServerError)
@@ -81,21 +81,13 @@ func AskQuestion(w http.ResponseWriter, r *http.Request) {
 
 // GetAllQuestionsForAdmin returns all questions for all users (Admin only)
 func GetAllQuestionsForAdmin(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
 	rows, err := db.Pool.Query(r.Context(), `
 		SELECT q.id, q.user_id, q.program_name, q.question_text, q.answer_text, q.is_answered, q.answered_at, q.created_at,
 		       COALESCE(cl.full_name, p.full_name, 'Unknown Student') as user_name
 		FROM public.questions q
 		LEFT JOIN auth.users au ON q.user_id = au.id
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		LEFT JOIN public.participants p ON p.user_id = au.id
 		ORDER BY q.is_answered ASC, q.created_at DESC LIMIT 1000
 	`)
 
@@ -120,14 +112,6 @@ func GetAllQuestionsForAdmin(w http.ResponseWriter, r *http.Request) {
 
 // AnswerQuestion allows admin to reply to a question
 func AnswerQuestion(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
 	var req struct {
 		QuestionID string `json:"question_id"`
 		Answer     string `json:"answer"`
@@ -137,7 +121,7 @@ func AnswerQuestion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = db.Pool.Exec(r.Context(), `
+	_, err := db.Pool.Exec(r.Context(), `
 		UPDATE public.questions
 		SET answer_text = $1, is_answered = true, answered_at = NOW()
 		WHERE id = $2
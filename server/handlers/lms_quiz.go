@@ -1,113 +1,452 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
-// GetActiveQuiz returns the quiz if within the 8:00 PM - 8:10 PM WAT window
-func GetActiveQuiz(w http.ResponseWriter, r *http.Request) {
-	lessonID := chi.URLParam(r, "id")
-	
-	var quiz struct {
-		ID        string    `json:"id"`
-		LessonID  string    `json:"lesson_id"`
-		Title     string    `json:"title"`
-		Question  string    `json:"question"`
-		CreatedAt time.Time `json:"created_at"`
-		ExpiresAt time.Time `json:"expires_at"`
+// How the kept score is chosen when a quiz allows several attempts
+const (
+	quizScoreBest   = "best"
+	quizScoreLatest = "latest"
+)
+
+// Where a learner stands relative to a quiz's window
+const (
+	quizWaiting = "waiting"
+	quizOpen    = "open"
+	quizLate    = "late"
+	quizClosed  = "expired"
+)
+
+// quizSubmitGrace covers the auto-submit a browser sends as the timer hits zero
+const quizSubmitGrace = 30 * time.Second
+
+// QuizPolicy is when a quiz opens, for how long, and how attempts count. The window opens
+// OpenOffsetMinutes after the lesson's scheduled start (negative opens before it). During the
+// late window that follows, scores lose LatePenaltyPercent.
+type QuizPolicy struct {
+	OpenOffsetMinutes  int    `json:"open_offset_minutes"`
+	DurationMinutes    int    `json:"duration_minutes"`
+	MaxAttempts        int    `json:"max_attempts"`
+	AttemptScoring     string `json:"attempt_scoring"`
+	LateMinutes        int    `json:"late_minutes"`
+	LatePenaltyPercent int    `json:"late_penalty_percent"`
+}
+
+// normalize fills in the defaults (3 minutes, one attempt, best score) and checks the ranges
+func (p *QuizPolicy) normalize() error {
+	if p.DurationMinutes == 0 {
+		p.DurationMinutes = 3
+	}
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 1
+	}
+	if p.AttemptScoring == "" {
+		p.AttemptScoring = quizScoreBest
 	}
-	var scheduledStartTime *time.Time
 
-	err := db.Pool.QueryRow(r.Context(), `
-		SELECT q.id, q.lesson_id, q.title, COALESCE(q.question, ''), q.created_at, l.scheduled_start_time
+	switch {
+	case p.OpenOffsetMinutes < -7*24*60 || p.OpenOffsetMinutes > 7*24*60:
+		return errors.New("quiz open offset must be within a week of the lesson start")
+	case p.DurationMinutes < 1 || p.DurationMinutes > 7*24*60:
+		return errors.New("quiz duration must be between 1 minute and a week")
+	case p.MaxAttempts < 1 || p.MaxAttempts > 20:
+		return errors.New("max attempts must be between 1 and 20")
+	case p.AttemptScoring != quizScoreBest && p.AttemptScoring != quizScoreLatest:
+		return errors.New("attempt scoring must be best or latest")
+	case p.LateMinutes < 0 || p.LateMinutes > 30*24*60:
+		return errors.New("late window must be between 0 minutes and 30 days")
+	case p.LatePenaltyPercent < 0 || p.LatePenaltyPercent > 100:
+		return errors.New("late penalty must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// phase reports where at falls in the quiz window and when that phase ends. reopenUntil,
+// from an admin grant, keeps a closed quiz open for one learner.
+func (p QuizPolicy) phase(start time.Time, reopenUntil *time.Time, at time.Time) (string, time.Time) {
+	opens := start.Add(time.Duration(p.OpenOffsetMinutes) * time.Minute)
+	closes := opens.Add(time.Duration(p.DurationMinutes) * time.Minute)
+	lateUntil := closes.Add(time.Duration(p.LateMinutes) * time.Minute)
+
+	switch {
+	case at.Before(opens):
+		return quizWaiting, opens
+	case !at.After(closes):
+		return quizOpen, closes
+	case reopenUntil != nil && !at.After(*reopenUntil):
+		return quizOpen, *reopenUntil
+	case !at.After(lateUntil):
+		return quizLate, lateUntil
+	}
+	return quizClosed, lateUntil
+}
+
+// applyLatePenalty takes LatePenaltyPercent off a raw score, rounding to the nearest point
+func (p QuizPolicy) applyLatePenalty(raw int) int {
+	return int(math.Round(float64(raw) * float64(100-p.LatePenaltyPercent) / 100))
+}
+
+// learnerQuiz is a lesson's quiz as scheduled for one learner's cohort
+type learnerQuiz struct {
+	ID        string
+	LessonID  string
+	Title     string
+	CreatedAt time.Time
+	StartsAt  *time.Time
+	Policy    QuizPolicy
+	BankID    *string
+	DrawCount int
+}
+
+func loadLearnerQuiz(ctx context.Context, lessonID, userID string) (learnerQuiz, error) {
+	var q learnerQuiz
+	err := db.Pool.QueryRow(ctx, `
+		SELECT q.id, q.lesson_id, q.title, q.created_at, COALESCE(ls.scheduled_start_time, l.scheduled_start_time),
+			   q.open_offset_minutes, q.duration_minutes, q.max_attempts, q.attempt_scoring, q.late_minutes, q.late_penalty_percent,
+			   q.bank_id::text, q.draw_count
 		FROM public.quizzes q
 		JOIN public.lessons l ON q.lesson_id = l.id
-		WHERE q.lesson_id = $1
-	`, lessonID).Scan(&quiz.ID, &quiz.LessonID, &quiz.Title, &quiz.Question, &quiz.CreatedAt, &scheduledStartTime)
+		JOIN public.modules m ON l.module_id = m.id
+		`+userCohortJoin+`
+		WHERE q.lesson_id = $1 AND l.deleted_at IS NULL AND m.deleted_at IS NULL
+	`, lessonID, userID).Scan(&q.ID, &q.LessonID, &q.Title, &q.CreatedAt, &q.StartsAt,
+		&q.Policy.OpenOffsetMinutes, &q.Policy.DurationMinutes, &q.Policy.MaxAttempts, &q.Policy.AttemptScoring,
+		&q.Policy.LateMinutes, &q.Policy.LatePenaltyPercent, &q.BankID, &q.DrawCount)
+	return q, err
+}
+
+// quizAttempts is how many attempts a learner has used and is allowed, counting grants
+type quizAttempts struct {
+	Used        int
+	Allowed     int
+	ReopenUntil *time.Time
+}
+
+func loadQuizAttempts(ctx context.Context, quiz learnerQuiz, userID string) (quizAttempts, error) {
+	a := quizAttempts{Allowed: quiz.Policy.MaxAttempts}
+	var extra int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT (SELECT COUNT(*) FROM public.quiz_submissions WHERE quiz_id = $1 AND user_id = $2),
+			   COALESCE(SUM(g.extra_attempts), 0),
+			   MAX(g.reopen_until)
+		FROM public.quiz_attempt_grants g WHERE g.quiz_id = $1 AND g.user_id = $2
+	`, quiz.ID, userID).Scan(&a.Used, &extra, &a.ReopenUntil)
+	a.Allowed += extra
+	return a, err
+}
+
+// keptQuizScore is the score that counts for a learner under the quiz's attempt scoring
+func keptQuizScore(ctx context.Context, quiz learnerQuiz, userID string) (int, int, error) {
+	var score, total int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(score, 0), COALESCE(total_questions, 0) FROM public.quiz_submissions
+		WHERE quiz_id = $1 AND user_id = $2
+		ORDER BY CASE WHEN $3::text = 'best' THEN score END DESC NULLS LAST, attempt DESC
+		LIMIT 1
+	`, quiz.ID, userID, quiz.Policy.AttemptScoring).Scan(&score, &total)
+	return score, total, err
+}
+
+// GetActiveQuiz returns the quiz while its window (or late window) is open and the learner has attempts left
+func GetActiveQuiz(w http.ResponseWriter, r *http.Request) {
+	lessonID := chi.URLParam(r, "id")
+	userID := r.Context().Value(userIDKey).(string)
 
+	quiz, err := loadLearnerQuiz(r.Context(), lessonID, userID)
 	if err != nil {
 		http.Error(w, "No active quiz for this lesson.", http.StatusNotFound)
 		return
 	}
 
-	if scheduledStartTime == nil {
+	if quiz.StartsAt == nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{"status": "no_schedule"})
 		return
 	}
 
-	now := time.Now().UTC()
-	start := scheduledStartTime.UTC()
-	end := start.Add(3 * time.Minute)
-	quiz.ExpiresAt = end
-
-	if now.Before(start) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"status": "waiting"})
+	attempts, err := loadQuizAttempts(r.Context(), quiz, userID)
+	if err != nil {
+		fmt.Println("💥 QUIZ ATTEMPTS ERROR:", err)
+		http.Error(w, "Failed to load quiz", http.StatusInternalServerError)
 		return
 	}
 
-	if now.After(end) {
+	phase, phaseEnds := quiz.Policy.phase(quiz.StartsAt.UTC(), attempts.ReopenUntil, time.Now().UTC())
+	if phase == quizWaiting || phase == quizClosed {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"status": "expired"})
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": phase})
 		return
 	}
 
-	// Check if user already submitted the quiz
-	var submitted bool
-	db.Pool.QueryRow(r.Context(), "SELECT EXISTS(SELECT 1 FROM quiz_submissions WHERE quiz_id = $1 AND user_id = $2)", quiz.ID, r.Context().Value(userIDKey)).Scan(&submitted)
-	
-	if submitted {
+	if attempts.Used >= attempts.Allowed {
+		score, total, _ := keptQuizScore(r.Context(), quiz, userID)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"already_submitted": true,
+			"score":             score,
+			"total_questions":   total,
 		})
 		return
 	}
 
+	// Participants only ever see prompts and options; answer keys stay on the server
+	questions, err := quizVariant(r.Context(), quiz, userID, attempts.Used+1)
+	if err != nil {
+		fmt.Println("💥 QUIZ VARIANT ERROR:", err)
+		http.Error(w, "Failed to load quiz", http.StatusInternalServerError)
+		return
+	}
+	public := make([]PublicQuizQuestion, 0, len(questions))
+	for _, q := range questions {
+		public = append(public, q.public())
+	}
+
+	resp := map[string]interface{}{
+		"id":           quiz.ID,
+		"lesson_id":    quiz.LessonID,
+		"title":        quiz.Title,
+		"questions":    public,
+		"created_at":   quiz.CreatedAt,
+		"expires_at":   phaseEnds,
+		"attempt":      attempts.Used + 1,
+		"max_attempts": attempts.Allowed,
+		"is_late":      phase == quizLate,
+	}
+	if phase == quizLate {
+		resp["late_penalty_percent"] = quiz.Policy.LatePenaltyPercent
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(quiz)
+	json.NewEncoder(w).Encode(resp)
 }
 
 func SubmitQuiz(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(userIDKey).(string)
 	lessonID := chi.URLParam(r, "id")
 
+	// Answers are keyed by question id; the score is always worked out here
 	var req struct {
-		Answers        map[string]interface{} `json:"answers"`
-		Score          int                    `json:"score"`
-		TotalQuestions int                    `json:"total_questions"`
+		Answers map[string]json.RawMessage `json:"answers"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	var quizID string
-	err := db.Pool.QueryRow(r.Context(), "SELECT id FROM public.quizzes WHERE lesson_id = $1", lessonID).Scan(&quizID)
+	quiz, err := loadLearnerQuiz(r.Context(), lessonID, userID)
 	if err != nil {
 		http.Error(w, "Quiz not found", http.StatusNotFound)
 		return
 	}
+	attempts, err := loadQuizAttempts(r.Context(), quiz, userID)
+	if err != nil {
+		fmt.Println("💥 QUIZ ATTEMPTS ERROR:", err)
+		http.Error(w, "Failed to submit quiz", http.StatusInternalServerError)
+		return
+	}
 
-	answersJSON, _ := json.Marshal(req.Answers)
+	now := time.Now().UTC()
+	if quiz.StartsAt == nil {
+		http.Error(w, "This quiz has not been scheduled", http.StatusForbidden)
+		return
+	}
+	if phase, _ := quiz.Policy.phase(quiz.StartsAt.UTC(), attempts.ReopenUntil, now); phase == quizWaiting {
+		http.Error(w, "This quiz has not opened yet", http.StatusForbidden)
+		return
+	}
+	phase, _ := quiz.Policy.phase(quiz.StartsAt.UTC(), attempts.ReopenUntil, now.Add(-quizSubmitGrace))
+	if phase == quizClosed {
+		http.Error(w, "This quiz has closed", http.StatusForbidden)
+		return
+	}
+	isLate := phase == quizLate
 
-	_, err = db.Pool.Exec(r.Context(), `
-		INSERT INTO public.quiz_submissions (quiz_id, user_id, answers, score, total_questions)
-		VALUES ($1, $2, $3, $4, $5)
-	`, quizID, userID, string(answersJSON), req.Score, req.TotalQuestions)
+	// Grade against the variant this attempt was shown
+	attempt := attempts.Used + 1
+	questions, err := quizVariant(r.Context(), quiz, userID, attempt)
+	if err != nil {
+		fmt.Println("💥 QUIZ VARIANT ERROR:", err)
+		http.Error(w, "Failed to submit quiz", http.StatusInternalServerError)
+		return
+	}
+
+	rawScore, total, answers := gradeQuiz(questions, req.Answers)
+	score := rawScore
+	if isLate {
+		score = quiz.Policy.applyLatePenalty(rawScore)
+	}
+	answersJSON, _ := json.Marshal(answers)
+
+	// The attempts check is taken in the same statement as the insert; the
+	// (quiz_id, user_id, attempt) key catches two submissions racing for the same slot
+	err = db.Pool.QueryRow(r.Context(), `
+		INSERT INTO public.quiz_submissions (quiz_id, user_id, answers, score, raw_score, total_questions, is_late, attempt)
+		SELECT $1, $2, $3::jsonb, $4::int, $5::int, $6::int, $7::boolean, $9::int
+		FROM public.quiz_submissions WHERE quiz_id = $1 AND user_id = $2
+		HAVING COUNT(*) < $8::int + (SELECT COALESCE(SUM(extra_attempts), 0) FROM public.quiz_attempt_grants WHERE quiz_id = $1 AND user_id = $2)
+		RETURNING attempt
+	`, quiz.ID, userID, string(answersJSON), score, rawScore, total, isLate, quiz.Policy.MaxAttempts, attempt).Scan(&attempt)
 
+	var pgErr *pgconn.PgError
+	if err == pgx.ErrNoRows || (errors.As(err, &pgErr) && pgErr.Code == "23505") {
+		http.Error(w, "You have no attempts left for this quiz", http.StatusConflict)
+		return
+	}
 	if err != nil {
+		fmt.Println("💥 QUIZ SUBMIT ERROR:", err)
 		http.Error(w, "Failed to submit quiz", http.StatusInternalServerError)
 		return
 	}
 
+	keptScore, keptTotal, err := keptQuizScore(r.Context(), quiz, userID)
+	if err != nil {
+		keptScore, keptTotal = score, total
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Quiz submitted successfully!"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":              "Quiz submitted successfully!",
+		"score":                score,
+		"raw_score":            rawScore,
+		"total_questions":      total,
+		"is_late":              isLate,
+		"late_penalty_percent": quiz.Policy.LatePenaltyPercent,
+		"attempt":              attempt,
+		"attempts_remaining":   max(0, attempts.Allowed-attempt),
+		"kept_score":           keptScore,
+		"kept_total_questions": keptTotal,
+	})
+}
+
+// quizGrantRequest is the body of both grant endpoints. reopen_hours reopens the quiz for
+// the learner for that long, so the attempt can be used after the window has closed.
+type quizGrantRequest struct {
+	LessonID    string `json:"lesson_id"`
+	QuizID      string `json:"quiz_id"`
+	UserID      string `json:"user_id"`
+	Email       string `json:"email"`
+	ReopenHours int    `json:"reopen_hours"`
+}
+
+func decodeQuizGrant(w http.ResponseWriter, r *http.Request) (quizGrantRequest, bool) {
+	var req quizGrantRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid payload", http.StatusBadRequest)
+			return req, false
+		}
+	}
+	if req.ReopenHours < 0 || req.ReopenHours > 24*30 {
+		http.Error(w, "reopen_hours must be between 0 and 720", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// insertQuizAttemptGrant records one extra attempt for a learner, audited against target
+func insertQuizAttemptGrant(w http.ResponseWriter, r *http.Request, quizID, userID string, reopenHours int, targetType, targetID string) {
+	callerID, _ := r.Context().Value(userIDKey).(string)
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to grant attempt", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		INSERT INTO public.quiz_attempt_grants AS g (quiz_id, user_id, extra_attempts, reopen_until, granted_by)
+		VALUES ($1::uuid, $2::uuid, 1, CASE WHEN $3::int > 0 THEN NOW() + make_interval(hours => $3::int) END, $4)
+		RETURNING to_jsonb(g)
+	`, quizID, userID, reopenHours, nullableUUID(callerID)).Scan(&after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditQuizAttemptGrant, TargetType: targetType, TargetID: targetID, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 QUIZ GRANT ERROR:", err)
+		http.Error(w, "Failed to grant attempt", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Extra attempt granted"})
+}
+
+// GrantQuizAttempt gives the learner behind a quiz submission one more attempt
+func GrantQuizAttempt(w http.ResponseWriter, r *http.Request) {
+	submissionID := chi.URLParam(r, "id")
+	req, ok := decodeQuizGrant(w, r)
+	if !ok {
+		return
+	}
+
+	var quizID, userID string
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT quiz_id::text, user_id::text FROM public.quiz_submissions WHERE id::text = $1
+	`, submissionID).Scan(&quizID, &userID)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println("💥 QUIZ GRANT ERROR:", err)
+		http.Error(w, "Failed to grant attempt", http.StatusInternalServerError)
+		return
+	}
+	insertQuizAttemptGrant(w, r, quizID, userID, req.ReopenHours, "submission", submissionID)
+}
+
+// GrantQuizAttemptForLearner gives a learner one more attempt at a quiz (lesson_id or quiz_id)
+// whether or not they have submitted it, e.g. after missing the window. The learner is
+// user_id (their login id) or email.
+func GrantQuizAttemptForLearner(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeQuizGrant(w, r)
+	if !ok {
+		return
+	}
+	if (req.LessonID == "") == (req.QuizID == "") || (req.UserID == "") == (req.Email == "") {
+		http.Error(w, "Provide lesson_id or quiz_id, and user_id or email", http.StatusBadRequest)
+		return
+	}
+
+	var quizID string
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT id::text FROM public.quizzes WHERE id::text = $1 OR lesson_id::text = $2 LIMIT 1
+	`, req.QuizID, req.LessonID).Scan(&quizID)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Quiz not found", http.StatusNotFound)
+		return
+	}
+	var userID string
+	if err == nil {
+		err = db.Pool.QueryRow(r.Context(), `
+			SELECT id::text FROM auth.users WHERE id::text = $1 OR lower(email) = lower($2) LIMIT 1
+		`, req.UserID, req.Email).Scan(&userID)
+		if err == pgx.ErrNoRows {
+			http.Error(w, "Learner not found", http.StatusNotFound)
+			return
+		}
+	}
+	if err != nil {
+		fmt.Println("💥 QUIZ GRANT ERROR:", err)
+		http.Error(w, "Failed to grant attempt", http.StatusInternalServerError)
+		return
+	}
+	insertQuizAttemptGrant(w, r, quizID, userID, req.ReopenHours, "quiz", quizID)
 }
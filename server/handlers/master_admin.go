@@ -1,32 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
 
-// isAdminEmail checks if the provided email belongs to one of the master administrators
-func isAdminEmail(email string) bool {
-	allowedAdmins := []string{
-		"asejik@gmail.com",
-		"temitopeayenigba@gmail.com",
-		"winneridigbe@gmail.com",
-		"adedejiolaide11@gmail.com",
-	}
-	for _, admin := range allowedAdmins {
-		if email == admin {
-			return true
-		}
-	}
-	return false
-}
-
 type MasterAdminUser struct {
 	ID                   string    `json:"id"`
 	FullName             string    `json:"full_name"`
@@ -61,17 +48,7 @@ type MasterAdminUser struct {
 
 // GetMasterAdminUsers fetches all registered users across both programs
 func GetMasterAdminUsers(w http.ResponseWriter, r *http.Request) {
-	// 1. Double-Lock Security: Verify the requester is actually the admin via JWT
-	userID := r.Context().Value(userIDKey).(string)
-
-	var email string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&email)
-	if err != nil || !isAdminEmail(email) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
-	// 2. Query BOTH tables with all fields mapped correctly (using COALESCE to handle legacy NULLs)
+	// 1. Query BOTH tables with all fields mapped correctly (using COALESCE to handle legacy NULLs)
 	rows, err := db.Pool.Query(r.Context(), `
 		SELECT 
 			p.id::text, p.full_name, p.email, COALESCE(p.whatsapp_number, ''), COALESCE(p.gender, ''), COALESCE(p.country, ''), 
@@ -79,8 +56,9 @@ func GetMasterAdminUsers(w http.ResponseWriter, r *http.Request) {
 			COALESCE(p.state, ''), COALESCE(p.age_group, ''), COALESCE(p.church_name, ''), COALESCE(p.relationship_status, ''), COALESCE(p.clan_id::text, ''),
 			'' as denomination, '' as referral_source, '' as wedding_date, '' as partner_registered,
 			'' as spouse_name, '' as spouse_whatsapp, false as attended_before, false as agreed_to_feedback, false as agreed_to_participation,
-			EXISTS (SELECT 1 FROM auth.users u WHERE u.email = p.email) as is_activated
+			p.user_id IS NOT NULL as is_activated
 		FROM public.participants p
+		WHERE p.deleted_at IS NULL
 		UNION ALL
 		SELECT 
 			c.id::text, c.full_name, c.email, COALESCE(c.whatsapp_number, ''), COALESCE(c.gender, ''), COALESCE(c.country_city, ''),
@@ -88,8 +66,9 @@ func GetMasterAdminUsers(w http.ResponseWriter, r *http.Request) {
 			'' as state, '' as age_group, '' as church_name, '' as relationship_status, '' as clan_id,
 			COALESCE(c.denomination, ''), COALESCE(referral_source, ''), COALESCE(c.wedding_date::text, ''), COALESCE(c.partner_registered, ''),
 			COALESCE(c.spouse_name, ''), COALESCE(c.spouse_whatsapp, ''), COALESCE(c.attended_before, false), COALESCE(c.agreed_to_feedback, false), COALESCE(c.agreed_to_participation, false),
-			EXISTS (SELECT 1 FROM auth.users u WHERE u.email = c.email) as is_activated
+			c.user_id IS NOT NULL as is_activated
 		FROM public.couples_launchpad c
+		WHERE c.deleted_at IS NULL
 		ORDER BY created_at DESC
 	`)
 
@@ -117,7 +96,7 @@ func GetMasterAdminUsers(w http.ResponseWriter, r *http.Request) {
 		users = append(users, u)
 	}
 
-	// 3. Return the data to the React frontend
+	// 2. Return the data to the React frontend
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
 }
@@ -134,19 +113,14 @@ type AdminSubmission struct {
 	Type          string     `json:"type"`
 	Score         *int       `json:"score"`
 	TotalQuestions *int      `json:"total_questions"`
+	RawScore      *int       `json:"raw_score"`
+	Attempt       *int       `json:"attempt"`
+	IsLate        *bool      `json:"is_late"`
+	Counted       *bool      `json:"counted"`
 }
 
 // GetAdminSubmissions fetches all assignment submissions for review
 func GetAdminSubmissions(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-
-	var adminEmail string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
 	rows, err := db.Pool.Query(r.Context(), `
 		SELECT
 			sub.id::text,
@@ -159,12 +133,16 @@ func GetAdminSubmissions(w http.ResponseWriter, r *http.Request) {
 			sub.feedback_at,
 			'assignment' AS type,
 			NULL::INT AS score,
-			NULL::INT AS total_questions
+			NULL::INT AS total_questions,
+			NULL::INT AS raw_score,
+			NULL::INT AS attempt,
+			NULL::BOOLEAN AS is_late,
+			NULL::BOOLEAN AS counted
 		FROM public.assignment_submissions sub
 		JOIN auth.users au ON sub.user_id = au.id
 		JOIN public.lessons l ON sub.lesson_id = l.id
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		LEFT JOIN public.participants p ON p.user_id = au.id
 
 		UNION ALL
 
@@ -173,19 +151,28 @@ func GetAdminSubmissions(w http.ResponseWriter, r *http.Request) {
 			COALESCE(cl.full_name, p.full_name, 'Unknown User') AS student_name,
 			au.email,
 			l.title AS lesson_title,
-			qs.answers::text AS submission_url,
+			-- With the variant the attempt was shown, so review matches what was graded
+			CASE WHEN v.questions IS NULL THEN qs.answers::text
+			     ELSE jsonb_build_object('questions', v.questions, 'answers', qs.answers)::text END AS submission_url,
 			qs.submitted_at,
 			NULL AS admin_feedback,
 			NULL AS feedback_at,
 			'quiz' AS type,
 			qs.score,
-			qs.total_questions
+			qs.total_questions,
+			COALESCE(qs.raw_score, qs.score),
+			qs.attempt,
+			qs.is_late,
+			-- The attempt that counts under the quiz's best/latest scoring
+			row_number() OVER (PARTITION BY qs.quiz_id, qs.user_id
+				ORDER BY CASE WHEN q.attempt_scoring = 'best' THEN qs.score END DESC NULLS LAST, qs.attempt DESC) = 1
 		FROM public.quiz_submissions qs
 		JOIN public.quizzes q ON qs.quiz_id = q.id
 		JOIN public.lessons l ON q.lesson_id = l.id
 		JOIN auth.users au ON qs.user_id = au.id
-		LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-		LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+		LEFT JOIN public.quiz_variants v ON v.quiz_id = qs.quiz_id AND v.user_id = qs.user_id AND v.attempt = qs.attempt
+		LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+		LEFT JOIN public.participants p ON p.user_id = au.id
 
 		ORDER BY submitted_at DESC
 	`)
@@ -200,7 +187,7 @@ func GetAdminSubmissions(w http.ResponseWriter, r *http.Request) {
 	var submissions []AdminSubmission
 	for rows.Next() {
 		var s AdminSubmission
-		if err := rows.Scan(&s.ID, &s.StudentName, &s.Email, &s.LessonTitle, &s.SubmissionURL, &s.SubmittedAt, &s.AdminFeedback, &s.FeedbackAt, &s.Type, &s.Score, &s.TotalQuestions); err != nil {
+		if err := rows.Scan(&s.ID, &s.StudentName, &s.Email, &s.LessonTitle, &s.SubmissionURL, &s.SubmittedAt, &s.AdminFeedback, &s.FeedbackAt, &s.Type, &s.Score, &s.TotalQuestions, &s.RawScore, &s.Attempt, &s.IsLate, &s.Counted); err != nil {
 			fmt.Println("💥 DB SCAN ERROR:", err)
 			continue
 		}
@@ -214,14 +201,8 @@ func GetAdminSubmissions(w http.ResponseWriter, r *http.Request) {
 
 // UpdateSubmissionFeedback allows admins to leave feedback on a student's submission
 func UpdateSubmissionFeedback(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
 	submissionID := chi.URLParam(r, "id")
 
-	var adminEmail string
-	if err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail); err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
 
 	var req struct {
 		Feedback string `json:"feedback"`
@@ -231,9 +212,30 @@ func UpdateSubmissionFeedback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := db.Pool.Exec(r.Context(),
-		"UPDATE public.assignment_submissions SET admin_feedback = $1, feedback_at = NOW() WHERE id = $2",
-		req.Feedback, submissionID)
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to save feedback", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), "SELECT to_jsonb(s) FROM public.assignment_submissions s WHERE id = $1 FOR UPDATE", submissionID).Scan(&before)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Submission not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = tx.QueryRow(r.Context(),
+			"UPDATE public.assignment_submissions s SET admin_feedback = $1, feedback_at = NOW() WHERE id = $2 RETURNING to_jsonb(s)",
+			req.Feedback, submissionID).Scan(&after)
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditSubmissionFeedback, TargetType: "submission", TargetID: submissionID, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
 	if err != nil {
 		fmt.Println("💥 FEEDBACK UPDATE ERROR:", err)
 		http.Error(w, "Failed to save feedback", http.StatusInternalServerError)
@@ -243,19 +245,9 @@ func UpdateSubmissionFeedback(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// DeleteAdminUser removes a user from either the CLP or RFASM tables based on the provided source
+// DeleteAdminUser moves a registration to the trash, from the program table named by source
 func DeleteAdminUser(w http.ResponseWriter, r *http.Request) {
-	// 1. Verify Admin JWT
-	userID := r.Context().Value(userIDKey).(string)
-
-	var adminEmail string
-	err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if err != nil || !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
-	// 2. Grab the query parameters sent by React
+	// 1. Grab the query parameters sent by React
 	targetID := r.URL.Query().Get("id")
 	source := r.URL.Query().Get("source")
 
@@ -264,43 +256,60 @@ func DeleteAdminUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 3. Determine which table to delete from based on the source
-	var query string
-	if source == "Ready for a Soulmate" {
-		query = "DELETE FROM public.participants WHERE id = $1"
-	} else if source == "Couples Launchpad" {
-		query = "DELETE FROM public.couples_launchpad WHERE id = $1"
-	} else {
+	// 2. Determine which table to delete from based on the source program
+	program, ok := lookupProgram(r.Context(), source)
+	if !ok {
 		http.Error(w, "Invalid source", http.StatusBadRequest)
 		return
 	}
 
-	// 4. Execute the deletion
-	_, err = db.Pool.Exec(r.Context(), query, targetID)
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	var clanID *int64
+	if program.Slug == programSoulmate {
+		// RFASM participants hold a clan seat, so release it back to the waitlist
+		err = tx.QueryRow(r.Context(), `
+			UPDATE public.participants t SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL
+			RETURNING clan_id, to_jsonb(t) || '{"deleted_at": null}', to_jsonb(t)
+		`, targetID).Scan(&clanID, &before, &after)
+	} else {
+		err = tx.QueryRow(r.Context(), `
+			UPDATE `+program.registrationIdent()+` t SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL
+			RETURNING to_jsonb(t) || '{"deleted_at": null}', to_jsonb(t)
+		`, targetID).Scan(&before, &after)
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditUserDelete, TargetType: program.RegistrationTable, TargetID: targetID, Before: before, After: after})
+	} else if err == pgx.ErrNoRows {
+		err = nil // already gone
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
 	if err != nil {
 		fmt.Println("💥 DB DELETE ERROR:", err)
 		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 		return
 	}
+	if clanID != nil {
+		releaseClanSeat(r.Context(), *clanID)
+	}
 
-	// 5. Return success
+	// 3. Return success
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User successfully deleted"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "User moved to trash"})
 }
 
 // CreateAdminUser allows manual insertion of users into specific programs
 func CreateAdminUser(w http.ResponseWriter, r *http.Request) {
-	// 1. Verify Admin
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
-	// 2. Parse payload
+	// Parse payload
 	var req struct {
 		Source             string `json:"source"`
 		FullName           string `json:"full_name"`
@@ -316,48 +325,100 @@ func CreateAdminUser(w http.ResponseWriter, r *http.Request) {
 		RelationshipStatus string `json:"relationship_status"`
 		WeddingDate        string `json:"wedding_date"`
 		PartnerRegistered  string `json:"partner_registered"`
+		CohortID           string `json:"cohort_id"` // optional, defaults to the open cohort
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid Input", http.StatusBadRequest)
 		return
 	}
 
-	if req.Source == "Ready for a Soulmate" {
-		// Assign to first available clan
-		var clanID int64
-		err := db.Pool.QueryRow(r.Context(), "SELECT id FROM clans WHERE current_count < max_capacity ORDER BY id ASC LIMIT 1").Scan(&clanID)
+	program, ok := lookupProgram(r.Context(), req.Source)
+	if !ok {
+		http.Error(w, "Invalid source program", http.StatusBadRequest)
+		return
+	}
+
+	if req.CohortID == "" {
+		cohort, err := openCohort(r.Context(), db.Pool, program.ModuleName)
+		if err != nil {
+			http.Error(w, "No open cohort for this program. Pass cohort_id explicitly.", http.StatusBadRequest)
+			return
+		}
+		req.CohortID = cohort.ID
+	}
+
+	// Admins may enter numbers the public form would reject; keep the raw value and leave E.164 empty then
+	var whatsappE164 *string
+	if e164, err := normalizePhone(req.WhatsAppNumber, req.CountryCity); err == nil {
+		whatsappE164 = &e164
+	}
+
+	// The clan row lock is held until its count is updated, and the audit record commits with the row
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var id string
+	var after json.RawMessage
+	switch program.Slug {
+	case programSoulmate:
+		// Assign a clan with the configured strategy
+		clan, err := clanAssignerFor(r.Context(), tx, req.CohortID).pickClan(r.Context(), tx, req.CohortID, clanCandidate{
+			Gender:   req.Gender,
+			Country:  req.CountryCity,
+			State:    req.State,
+			AgeGroup: req.AgeGroup,
+		})
 		if err != nil {
 			http.Error(w, "No available clans/cohorts found", http.StatusInternalServerError)
 			return
 		}
 
-		_, err = db.Pool.Exec(r.Context(), `
-			INSERT INTO public.participants (
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO public.participants AS p (
 				full_name, email, whatsapp_number, gender, country, state, 
-				age_group, religion, church_name, instagram_handle, relationship_status, clan_id
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+				age_group, religion, church_name, instagram_handle, relationship_status, clan_id, cohort_id, whatsapp_e164
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			RETURNING p.id::text, to_jsonb(p)`,
 			req.FullName, req.Email, req.WhatsAppNumber, req.Gender, req.CountryCity, req.State,
-			req.AgeGroup, req.Religion, req.ChurchName, req.InstagramHandle, req.RelationshipStatus, clanID)
+			req.AgeGroup, req.Religion, req.ChurchName, req.InstagramHandle, req.RelationshipStatus, clan.ID, req.CohortID, whatsappE164).Scan(&id, &after)
 		if err != nil {
 			fmt.Println("💥 DB INSERT ERROR (Manual Soulmate):", err)
 			http.Error(w, "Failed to create user", http.StatusInternalServerError)
 			return
 		}
 		// Update clan count
-		db.Pool.Exec(r.Context(), "UPDATE clans SET current_count = current_count + 1 WHERE id = $1", clanID)
+		if _, err = tx.Exec(r.Context(), "UPDATE clans SET current_count = current_count + 1 WHERE id = $1", clan.ID); err != nil {
+			http.Error(w, "Failed to update clan count", http.StatusInternalServerError)
+			return
+		}
 
-	} else if req.Source == "Couples Launchpad" {
-		_, err := db.Pool.Exec(r.Context(), `
-			INSERT INTO public.couples_launchpad (full_name, email, whatsapp_number, gender, country_city, religion, instagram_handle, wedding_date, partner_registered)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-			req.FullName, req.Email, req.WhatsAppNumber, req.Gender, req.CountryCity, req.Religion, req.InstagramHandle, req.WeddingDate, req.PartnerRegistered)
+	case programLaunchpad:
+		err := tx.QueryRow(r.Context(), `
+			INSERT INTO public.couples_launchpad AS cl (full_name, email, whatsapp_number, gender, country_city, religion, instagram_handle, wedding_date, partner_registered, cohort_id, whatsapp_e164)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING cl.id::text, to_jsonb(cl)`,
+			req.FullName, req.Email, req.WhatsAppNumber, req.Gender, req.CountryCity, req.Religion, req.InstagramHandle, req.WeddingDate, req.PartnerRegistered, req.CohortID, whatsappE164).Scan(&id, &after)
 		if err != nil {
 			fmt.Println("💥 DB INSERT ERROR (Manual CLP):", err)
 			http.Error(w, "Failed to create user", http.StatusInternalServerError)
 			return
 		}
-	} else {
-		http.Error(w, "Invalid source program", http.StatusBadRequest)
+	default:
+		http.Error(w, "Manual registration is not supported for this program", http.StatusBadRequest)
+		return
+	}
+
+	err = recordAudit(r, tx, auditEntry{Action: auditUserCreate, TargetType: program.RegistrationTable, TargetID: id, After: after})
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DB INSERT ERROR (Manual User):", err)
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 
@@ -383,22 +444,40 @@ type CurriculumLesson struct {
 	SortOrder           int        `json:"sort_order"`
 	ScheduledStartTime  *time.Time `json:"scheduled_start_time"`
 	LiveDurationMinutes int        `json:"live_duration_minutes"`
-	QuizTitle           string     `json:"quiz_title"`
-	QuizQuestion        string     `json:"quiz_question"`
+	QuizTitle           string         `json:"quiz_title"`
+	QuizQuestions       []QuizQuestion `json:"quiz_questions"`
+	QuizPolicy          QuizPolicy     `json:"quiz_policy"`
+	QuizBankID          *string        `json:"quiz_bank_id"`
+	QuizDrawCount       int            `json:"quiz_draw_count"`
 }
 
-// GetAdminModules fetches modules for the dropdown in the UI
-func GetAdminModules(w http.ResponseWriter, r *http.Request) {
-	// Verify Admin
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
+// normalizeQuiz validates the quiz sent with a lesson; a lesson without a quiz title has no
+// quiz. A quiz either has its own questions or draws QuizDrawCount from a question bank.
+func (l *CurriculumLesson) normalizeQuiz(ctx context.Context) error {
+	l.QuizTitle = strings.TrimSpace(l.QuizTitle)
+	if l.QuizTitle == "" {
+		return nil
+	}
+	if err := l.QuizPolicy.normalize(); err != nil {
+		return err
+	}
+	if l.QuizBankID != nil && *l.QuizBankID == "" {
+		l.QuizBankID = nil
+	}
+	if l.QuizBankID != nil {
+		return checkQuizBank(ctx, *l.QuizBankID, l.QuizDrawCount)
+	}
+	l.QuizDrawCount = 0
+	questions, err := normalizeQuizQuestions(l.QuizQuestions)
+	if err == nil {
+		l.QuizQuestions = questions
 	}
+	return err
+}
 
-	rows, err := db.Pool.Query(r.Context(), "SELECT id::text, program_name, title, sort_order FROM public.modules ORDER BY program_name, sort_order ASC")
+// GetAdminModules fetches modules for the dropdown in the UI
+func GetAdminModules(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Pool.Query(r.Context(), "SELECT id::text, program_name, title, sort_order FROM public.modules WHERE deleted_at IS NULL ORDER BY program_name, sort_order ASC")
 	if err != nil {
 		http.Error(w, "Failed to fetch modules", http.StatusInternalServerError)
 		return
@@ -418,15 +497,6 @@ func GetAdminModules(w http.ResponseWriter, r *http.Request) {
 
 // CreateAdminModule injects a new module into the database
 func CreateAdminModule(w http.ResponseWriter, r *http.Request) {
-	// Verify Admin
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	var m CurriculumModule
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
@@ -450,20 +520,15 @@ func CreateAdminModule(w http.ResponseWriter, r *http.Request) {
 
 // CreateAdminLesson injects a new lesson into the database
 func CreateAdminLesson(w http.ResponseWriter, r *http.Request) {
-	// Verify Admin
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	var l CurriculumLesson
 	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	if err := l.normalizeQuiz(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var newLessonID string
 	err := db.Pool.QueryRow(r.Context(), `
@@ -478,10 +543,7 @@ func CreateAdminLesson(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if l.QuizTitle != "" {
-		_, err = db.Pool.Exec(r.Context(), `
-			INSERT INTO public.quizzes (lesson_id, title, question) VALUES ($1, $2, $3)
-		`, newLessonID, l.QuizTitle, l.QuizQuestion)
-		if err != nil {
+		if err = saveLessonQuiz(r.Context(), newLessonID, l); err != nil {
 			fmt.Println("💥 DB INSERT ERROR (Quiz):", err)
 		}
 	}
@@ -492,14 +554,6 @@ func CreateAdminLesson(w http.ResponseWriter, r *http.Request) {
 
 // GetAdminLessons fetches all lessons across all modules for the admin curriculum view
 func GetAdminLessons(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	type AdminLesson struct {
 		ID                  string     `json:"id"`
 		ModuleID            string     `json:"module_id"`
@@ -513,8 +567,11 @@ func GetAdminLessons(w http.ResponseWriter, r *http.Request) {
 		SortOrder           int        `json:"sort_order"`
 		ScheduledStartTime  *time.Time `json:"scheduled_start_time"`
 		LiveDurationMinutes int        `json:"live_duration_minutes"`
-		QuizTitle           string     `json:"quiz_title"`
-		QuizQuestion        string     `json:"quiz_question"`
+		QuizTitle           string          `json:"quiz_title"`
+		QuizQuestions       json.RawMessage `json:"quiz_questions"`
+		QuizPolicy          json.RawMessage `json:"quiz_policy"`
+		QuizBankID          *string         `json:"quiz_bank_id"`
+		QuizDrawCount       int             `json:"quiz_draw_count"`
 	}
 
 	rows, err := db.Pool.Query(r.Context(), `
@@ -523,9 +580,19 @@ func GetAdminLessons(w http.ResponseWriter, r *http.Request) {
 			   COALESCE(l.estimated_time,''), COALESCE(l.assignment_prompt,''), l.sort_order,
 			   l.scheduled_start_time, COALESCE(l.live_duration_minutes, 0)::int,
 			   COALESCE((SELECT title FROM public.quizzes q WHERE q.lesson_id = l.id LIMIT 1), '') as quiz_title,
-			   COALESCE((SELECT question FROM public.quizzes q WHERE q.lesson_id = l.id LIMIT 1), '') as quiz_question
+			   COALESCE((SELECT jsonb_agg(jsonb_build_object('id', qq.id, 'kind', qq.kind, 'prompt', qq.prompt,
+			                 'options', qq.options, 'answer_key', qq.answer_key) ORDER BY qq.sort_order, qq.created_at)
+			             FROM public.quiz_questions qq JOIN public.quizzes q ON qq.quiz_id = q.id
+			             WHERE q.lesson_id = l.id), '[]'::jsonb) as quiz_questions,
+			   (SELECT jsonb_build_object('open_offset_minutes', q.open_offset_minutes, 'duration_minutes', q.duration_minutes,
+			                 'max_attempts', q.max_attempts, 'attempt_scoring', q.attempt_scoring,
+			                 'late_minutes', q.late_minutes, 'late_penalty_percent', q.late_penalty_percent)
+			    FROM public.quizzes q WHERE q.lesson_id = l.id LIMIT 1) as quiz_policy,
+			   (SELECT q.bank_id::text FROM public.quizzes q WHERE q.lesson_id = l.id LIMIT 1) as quiz_bank_id,
+			   COALESCE((SELECT q.draw_count FROM public.quizzes q WHERE q.lesson_id = l.id LIMIT 1), 0) as quiz_draw_count
 		FROM public.lessons l
 		JOIN public.modules m ON l.module_id = m.id
+		WHERE l.deleted_at IS NULL AND m.deleted_at IS NULL
 		ORDER BY m.program_name, m.sort_order, l.sort_order ASC
 	`)
 	if err != nil {
@@ -539,7 +606,7 @@ func GetAdminLessons(w http.ResponseWriter, r *http.Request) {
 		var l AdminLesson
 		if err := rows.Scan(&l.ID, &l.ModuleID, &l.ModuleTitle, &l.ProgramName, &l.Title,
 			&l.Description, &l.VideoID, &l.EstimatedTime, &l.AssignmentPrompt, &l.SortOrder,
-			&l.ScheduledStartTime, &l.LiveDurationMinutes, &l.QuizTitle, &l.QuizQuestion); err == nil {
+			&l.ScheduledStartTime, &l.LiveDurationMinutes, &l.QuizTitle, &l.QuizQuestions, &l.QuizPolicy, &l.QuizBankID, &l.QuizDrawCount); err == nil {
 			lessons = append(lessons, l)
 		}
 	}
@@ -549,14 +616,6 @@ func GetAdminLessons(w http.ResponseWriter, r *http.Request) {
 
 // UpdateAdminModule updates a module's title, program, or sort_order
 func UpdateAdminModule(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	id := r.URL.Query().Get("id")
 	var m CurriculumModule
 	if err := json.NewDecoder(r.Body).Decode(&m); err != nil || id == "" {
@@ -576,48 +635,55 @@ func UpdateAdminModule(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Module updated"})
 }
 
-// DeleteAdminModule deletes a module (and cascades to its lessons via FK if set, else fails)
+// DeleteAdminModule moves a module to the trash; its lessons are hidden along with it
 func DeleteAdminModule(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		http.Error(w, "Missing id", http.StatusBadRequest)
 		return
 	}
 
-	_, err := db.Pool.Exec(r.Context(), "DELETE FROM public.modules WHERE id=$1", id)
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to delete module", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		UPDATE public.modules m SET deleted_at = NOW() WHERE id=$1 AND deleted_at IS NULL
+		RETURNING to_jsonb(m) || '{"deleted_at": null}', to_jsonb(m)
+	`, id).Scan(&before, &after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditModuleDelete, TargetType: "module", TargetID: id, Before: before, After: after})
+	} else if err == pgx.ErrNoRows {
+		err = nil
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
 	if err != nil {
 		fmt.Println("💥 DELETE MODULE ERROR:", err)
 		http.Error(w, "Failed to delete module", http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Module deleted"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Module moved to trash"})
 }
 
 // UpdateAdminLesson updates all editable fields on a lesson
 func UpdateAdminLesson(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	id := r.URL.Query().Get("id")
 	var l CurriculumLesson
 	if err := json.NewDecoder(r.Body).Decode(&l); err != nil || id == "" {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	if err := l.normalizeQuiz(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	_, err := db.Pool.Exec(r.Context(), `
 		UPDATE public.lessons
@@ -634,11 +700,7 @@ func UpdateAdminLesson(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if l.QuizTitle != "" {
-		_, err = db.Pool.Exec(r.Context(), `
-			INSERT INTO public.quizzes (lesson_id, title, question) VALUES ($1, $2, $3)
-			ON CONFLICT (lesson_id) DO UPDATE SET title = EXCLUDED.title, question = EXCLUDED.question
-		`, id, l.QuizTitle, l.QuizQuestion)
-		if err != nil {
+		if err = saveLessonQuiz(r.Context(), id, l); err != nil {
 			fmt.Println("💥 DB UPDATE ERROR (Quiz):", err)
 		}
 	} else {
@@ -649,51 +711,55 @@ func UpdateAdminLesson(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Lesson updated"})
 }
 
-// DeleteAdminLesson removes a single lesson
+// DeleteAdminLesson moves a single lesson to the trash
 func DeleteAdminLesson(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		http.Error(w, "Missing id", http.StatusBadRequest)
 		return
 	}
 
-	_, err := db.Pool.Exec(r.Context(), "DELETE FROM public.lessons WHERE id=$1", id)
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to delete lesson", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		UPDATE public.lessons l SET deleted_at = NOW() WHERE id=$1 AND deleted_at IS NULL
+		RETURNING to_jsonb(l) || '{"deleted_at": null}', to_jsonb(l)
+	`, id).Scan(&before, &after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditLessonDelete, TargetType: "lesson", TargetID: id, Before: before, After: after})
+	} else if err == pgx.ErrNoRows {
+		err = nil
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
 	if err != nil {
 		fmt.Println("💥 DELETE LESSON ERROR:", err)
 		http.Error(w, "Failed to delete lesson", http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Lesson deleted"})
+	json.NewEncoder(w).Encode(map[string]string{"message": "Lesson moved to trash"})
 }
 func GetProgramSettings(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-		return
-	}
-
 	settings := []map[string]interface{}{}
-	rows, _ := db.Pool.Query(r.Context(), "SELECT program_name, COALESCE(mid_checkpoint_video_id, ''), COALESCE(intro_video_id, ''), COALESCE(intro_video_description, '') FROM public.program_settings")
+	rows, _ := db.Pool.Query(r.Context(), "SELECT program_name, COALESCE(mid_checkpoint_video_id, ''), COALESCE(intro_video_id, ''), COALESCE(intro_video_description, ''), COALESCE(clan_strategy, '') FROM public.program_settings")
 	defer rows.Close()
 	for rows.Next() {
-		var pName, checkpointID, introID, introDesc string
-		rows.Scan(&pName, &checkpointID, &introID, &introDesc)
+		var pName, checkpointID, introID, introDesc, clanStrategy string
+		rows.Scan(&pName, &checkpointID, &introID, &introDesc, &clanStrategy)
 		settings = append(settings, map[string]interface{}{
 			"program_name": pName, 
 			"mid_checkpoint_video_id": checkpointID,
 			"intro_video_id": introID,
 			"intro_video_description": introDesc,
+			"clan_strategy": clanStrategy,
 		})
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -701,33 +767,55 @@ func GetProgramSettings(w http.ResponseWriter, r *http.Request) {
 }
 
 func UpdateProgramSettings(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userIDKey).(string)
-	var adminEmail string
-	db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-	if !isAdminEmail(adminEmail) {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
-		return
-	}
-
 	var req struct {
 		ProgramName           string `json:"program_name"`
 		MidCheckpointVideoID string `json:"mid_checkpoint_video_id"`
 		IntroVideoID         string `json:"intro_video_id"`
 		IntroVideoDescription string `json:"intro_video_description"`
+		ClanStrategy         string `json:"clan_strategy"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	_, err := db.Pool.Exec(r.Context(), `
-		INSERT INTO public.program_settings (program_name, mid_checkpoint_video_id, intro_video_id, intro_video_description) 
-		VALUES ($1, $2, $3, $4) ON CONFLICT (program_name) 
-		DO UPDATE SET 
-			mid_checkpoint_video_id = EXCLUDED.mid_checkpoint_video_id,
-			intro_video_id = EXCLUDED.intro_video_id,
-			intro_video_description = EXCLUDED.intro_video_description
-	`, req.ProgramName, req.MidCheckpointVideoID, req.IntroVideoID, req.IntroVideoDescription)
+	if req.ClanStrategy != "" && !isValidClanStrategy(req.ClanStrategy) {
+		http.Error(w, "Unknown clan_strategy. Use first_fit, round_robin, least_filled or balanced_demographics.", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), "SELECT to_jsonb(ps) FROM public.program_settings ps WHERE program_name = $1 FOR UPDATE", req.ProgramName).Scan(&before)
+	if err == pgx.ErrNoRows {
+		err = nil // first save for this program
+	}
+
+	// An empty clan_strategy leaves the current strategy untouched
+	if err == nil {
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO public.program_settings AS ps (program_name, mid_checkpoint_video_id, intro_video_id, intro_video_description, clan_strategy) 
+			VALUES ($1, $2, $3, $4, NULLIF($5, '')) ON CONFLICT (program_name) 
+			DO UPDATE SET 
+				mid_checkpoint_video_id = EXCLUDED.mid_checkpoint_video_id,
+				intro_video_id = EXCLUDED.intro_video_id,
+				intro_video_description = EXCLUDED.intro_video_description,
+				clan_strategy = COALESCE(EXCLUDED.clan_strategy, ps.clan_strategy)
+			RETURNING to_jsonb(ps)
+		`, req.ProgramName, req.MidCheckpointVideoID, req.IntroVideoID, req.IntroVideoDescription, req.ClanStrategy).Scan(&after)
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditProgramSettingsUpdate, TargetType: "program_settings", TargetID: req.ProgramName, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
 
 	if err != nil {
 		fmt.Printf("💥 PROGRAM SETTINGS SAVE ERROR [%s]: %v\n", req.ProgramName, err)
@@ -749,14 +837,6 @@ CreatedAt   time.Time `json:"created_at"`
 }
 
 func GetAdminReviews(w http.ResponseWriter, r *http.Request) {
-userID := r.Context().Value(userIDKey).(string)
-var adminEmail string
-err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&adminEmail)
-if err != nil || !isAdminEmail(adminEmail) {
-http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
-return
-}
-
 rows, err := db.Pool.Query(r.Context(), `
 SELECT
 pr.id::text,
@@ -768,8 +848,8 @@ pr.content,
 pr.created_at
 FROM public.program_reviews pr
 JOIN auth.users au ON pr.user_id = au.id
-LEFT JOIN public.couples_launchpad cl ON lower(au.email) = lower(cl.email)
-LEFT JOIN public.participants p ON lower(au.email) = lower(p.email)
+LEFT JOIN public.couples_launchpad cl ON cl.user_id = au.id
+LEFT JOIN public.participants p ON p.user_id = au.id
 ORDER BY pr.created_at DESC
 `)
 
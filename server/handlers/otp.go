@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Spouse OTP limits. The send limits also cover account claim links.
+const (
+	otpTTL             = 15 * time.Minute
+	otpMaxAttempts     = 5                // wrong guesses before a code is dead
+	otpResendCooldown  = 60 * time.Second // between sends to the same email
+	otpMaxPerEmailHour = 5
+	otpMaxPerIPHour    = 10
+)
+
+// generateOTP returns a uniformly random 6-digit code
+func generateOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// errOTPKeyMissing refuses codes while OTP_HASH_KEY is unset
+var errOTPKeyMissing = errors.New("OTP_HASH_KEY is not set")
+
+// hashOTP keys the hash with a server secret: a 6-digit code has so few values
+// that a plain digest could be reversed by trying them all. Without OTP_HASH_KEY
+// there is no secret, so no code may be issued or checked.
+func hashOTP(spouseEmail, code string) (string, error) {
+	key := strings.TrimSpace(os.Getenv("OTP_HASH_KEY"))
+	if key == "" {
+		return "", errOTPKeyMissing
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(spouseEmail)) + ":" + strings.TrimSpace(code)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// clientIP is the caller's address. Behind Render's proxy that is the last
+// X-Forwarded-For entry (earlier entries are supplied by the client).
+func clientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		parts := strings.Split(xff, ",")
+		return strings.TrimSpace(parts[len(parts)-1])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// sendThrottle returns how long the caller must wait before another code or link
+// may be sent to email from ip, or 0 if one may be sent now. table is one of the
+// request logs with (emailColumn, requester_ip, created_at).
+func sendThrottle(ctx context.Context, q queryRower, table, emailColumn, email, ip string) (time.Duration, error) {
+	var lastForEmail *time.Time
+	var perEmail, perIP int
+	err := q.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+			(SELECT MAX(created_at) FROM public.%[1]s WHERE lower(%[2]s) = lower($1)),
+			(SELECT COUNT(*) FROM public.%[1]s WHERE lower(%[2]s) = lower($1) AND created_at > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(*) FROM public.%[1]s WHERE requester_ip = $2 AND created_at > NOW() - INTERVAL '1 hour')
+	`, table, emailColumn), email, ip).Scan(&lastForEmail, &perEmail, &perIP)
+	if err != nil {
+		return 0, err
+	}
+
+	if perEmail >= otpMaxPerEmailHour || perIP >= otpMaxPerIPHour {
+		return time.Hour, nil
+	}
+	if lastForEmail != nil {
+		if wait := otpResendCooldown - time.Since(*lastForEmail); wait > 0 {
+			return wait, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/asejik/soulmate-reg/server/services"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Outbox message kinds. Emails carry the services.*Data struct as payload;
+// sheet kinds carry the exact JSON body posted to the Apps Script webhook.
+const (
+	outboxConfirmationEmail = "email.confirmation"
+	outboxLaunchpadEmail    = "email.launchpad"
+	outboxOTPEmail          = "email.otp"
+	outboxAccountClaimEmail = "email.account_claim"
+	outboxWaitlistOffer     = "email.waitlist_offer"
+	outboxEmailChange       = "email.email_change"
+	outboxEmailChanged      = "email.email_changed"
+	outboxParticipantSheet  = "sheet.participants"
+	outboxLaunchpadSheet    = "sheet.launchpad"
+	outboxWaitlistSheet     = "sheet.waitlist"
+)
+
+const (
+	outboxMaxAttempts = 8
+	outboxBaseBackoff = time.Minute
+	outboxMaxBackoff  = 6 * time.Hour
+	outboxLease       = 5 * time.Minute // a claimed message is retried after this if the process dies mid-delivery
+	outboxBatchSize   = 20
+)
+
+// outboxSecretFields names the payload field of each kind that grants access (an OTP
+// or a one-time link). It is needed only until delivery: it is blanked once the
+// message is delivered and never shown in the admin listing.
+var outboxSecretFields = map[string]string{
+	outboxOTPEmail:          "code",
+	outboxAccountClaimEmail: "ClaimLink",
+	outboxWaitlistOffer:     "ClaimLink",
+	outboxEmailChange:       "ConfirmLink",
+}
+
+const outboxRedacted = "[redacted]"
+
+// execer is satisfied by both db.Pool and pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+type otpEmailPayload struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// outboxWake nudges the dispatcher so fresh messages go out without waiting for the next tick
+var outboxWake = make(chan struct{}, 1)
+
+// enqueueOutbox records a message for delivery. Call it inside the transaction that
+// creates the row the message is about, so the message exists if and only if the row does.
+func enqueueOutbox(ctx context.Context, q execer, kind string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, `INSERT INTO public.outbox (kind, payload) VALUES ($1, $2)`, kind, body)
+	return err
+}
+
+// redactOutboxPayload returns payload with the kind's secret field replaced
+func redactOutboxPayload(kind string, payload []byte) []byte {
+	field, ok := outboxSecretFields[kind]
+	if !ok {
+		return payload
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(payload, &fields) != nil {
+		return []byte(`{}`)
+	}
+	if _, ok := fields[field]; !ok {
+		return payload
+	}
+	fields[field], _ = json.Marshal(outboxRedacted)
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return []byte(`{}`)
+	}
+	return redacted
+}
+
+// notifyOutbox wakes the dispatcher; call it after the enqueuing transaction commits
+func notifyOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// sheetWebhookURL resolves the Apps Script endpoint for a sheet kind at delivery time
+func sheetWebhookURL(kind string) string {
+	switch kind {
+	case outboxParticipantSheet:
+		return os.Getenv("GOOGLE_SHEETS_WEBHOOK_URL")
+	case outboxLaunchpadSheet:
+		return os.Getenv("LAUNCHPAD_SHEETS_WEBHOOK_URL")
+	case outboxWaitlistSheet:
+		if u := os.Getenv("WAITLIST_WEBHOOK_URL"); u != "" {
+			return u
+		}
+		return os.Getenv("COHORT4_WAITLIST_WEBHOOK_URL") // legacy name
+	}
+	return ""
+}
+
+// deliverOutbox performs one delivery attempt for a message
+func deliverOutbox(kind string, payload []byte) error {
+	switch kind {
+	case outboxConfirmationEmail:
+		var data services.EmailData
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendConfirmationEmail(data)
+	case outboxLaunchpadEmail:
+		var data services.LaunchpadEmailData
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendLaunchpadEmail(data)
+	case outboxOTPEmail:
+		var data otpEmailPayload
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendOTPEmail(data.Email, data.Code)
+	case outboxAccountClaimEmail:
+		var data services.AccountClaimEmailData
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendAccountClaimEmail(data)
+	case outboxWaitlistOffer:
+		var data services.WaitlistOfferEmailData
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendWaitlistOfferEmail(data)
+	case outboxEmailChange:
+		var data services.EmailChangeEmailData
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendEmailChangeEmail(data)
+	case outboxEmailChanged:
+		var data services.EmailChangedEmailData
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return err
+		}
+		return services.SendEmailChangedEmail(data)
+	case outboxParticipantSheet, outboxLaunchpadSheet, outboxWaitlistSheet:
+		url := sheetWebhookURL(kind)
+		if url == "" {
+			return nil // sheet backups are optional
+		}
+		return services.PostSheetWebhook(url, payload)
+	}
+	return fmt.Errorf("unknown outbox kind %q", kind)
+}
+
+// outboxBackoff is the wait before retry number `attempts` (1-based), doubling each time
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBaseBackoff << (attempts - 1)
+	if d <= 0 || d > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return d
+}
+
+// dispatchOutbox claims due messages, delivers them and records the outcome.
+// Claiming pushes next_attempt_at forward by a lease instead of holding a
+// transaction open across slow network calls.
+func dispatchOutbox(ctx context.Context) (int, error) {
+	rows, err := db.Pool.Query(ctx, `
+		UPDATE public.outbox SET next_attempt_at = NOW() + make_interval(secs => $1), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM public.outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at ASC
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, payload, attempts
+	`, outboxLease.Seconds(), outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	type claimed struct {
+		id       string
+		kind     string
+		payload  []byte
+		attempts int
+	}
+	var batch []claimed
+	for rows.Next() {
+		var m claimed
+		if err := rows.Scan(&m.id, &m.kind, &m.payload, &m.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, m := range batch {
+		deliveryErr := deliverOutbox(m.kind, m.payload)
+		switch {
+		case deliveryErr == nil:
+			_, err = db.Pool.Exec(ctx, `UPDATE public.outbox SET status = 'delivered', delivered_at = NOW(), last_error = NULL, payload = $2 WHERE id = $1`, m.id, redactOutboxPayload(m.kind, m.payload))
+		case m.attempts >= outboxMaxAttempts:
+			log.Printf("[Outbox] %s %s dead after %d attempts: %v", m.kind, m.id, m.attempts, deliveryErr)
+			_, err = db.Pool.Exec(ctx, `UPDATE public.outbox SET status = 'dead', last_error = $2 WHERE id = $1`, m.id, deliveryErr.Error())
+		default:
+			retryAt := time.Now().Add(outboxBackoff(m.attempts))
+			log.Printf("[Outbox] %s %s attempt %d failed, retrying at %s: %v", m.kind, m.id, m.attempts, retryAt.Format(time.RFC3339), deliveryErr)
+			_, err = db.Pool.Exec(ctx, `UPDATE public.outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1`, m.id, deliveryErr.Error(), retryAt)
+		}
+		if err != nil {
+			log.Printf("[Outbox] Failed to record outcome for %s: %v", m.id, err)
+		}
+	}
+	return len(batch), nil
+}
+
+// StartOutboxDispatcher delivers outbox messages on a fixed interval (and whenever
+// notifyOutbox is called) until ctx is done
+func StartOutboxDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches before going back to sleep
+		for {
+			n, err := dispatchOutbox(ctx)
+			if err != nil {
+				log.Printf("[Outbox] Dispatch error: %v", err)
+				break
+			}
+			if n < outboxBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-outboxWake:
+		}
+	}
+}
+
+// outboxRetention is how long delivered messages are kept; OUTBOX_RETENTION_DAYS=0 keeps them forever
+func outboxRetention() time.Duration {
+	if v := os.Getenv("OUTBOX_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return 7 * 24 * time.Hour
+}
+
+// purgeOutbox blanks secrets still held by delivered messages (rows delivered
+// before redaction existed) and deletes delivered messages past retention
+func purgeOutbox(ctx context.Context) (int64, error) {
+	for kind, field := range outboxSecretFields {
+		_, err := db.Pool.Exec(ctx, `
+			UPDATE public.outbox SET payload = jsonb_set(payload, ARRAY[$2::text], to_jsonb($3::text))
+			WHERE status = 'delivered' AND kind = $1 AND payload ? $2 AND payload->>$2 <> $3
+		`, kind, field, outboxRedacted)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	retention := outboxRetention()
+	if retention == 0 {
+		return 0, nil
+	}
+	tag, err := db.Pool.Exec(ctx, "DELETE FROM public.outbox WHERE status = 'delivered' AND delivered_at < $1", time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// StartOutboxRetention purges delivered messages every interval until ctx is cancelled
+func StartOutboxRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := purgeOutbox(ctx); err != nil {
+			log.Printf("[Outbox] Retention purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[Outbox] Purged %d delivered messages past retention", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// --- Admin: Failed deliveries ---
+
+type OutboxMessage struct {
+	ID            string          `json:"id"`
+	Kind          string          `json:"kind"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// GetAdminOutbox lists outbox messages by status (dead letters by default), secrets redacted
+func GetAdminOutbox(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "dead"
+	}
+	if status != "dead" && status != "pending" && status != "delivered" {
+		http.Error(w, "status must be dead, pending or delivered", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT id::text, kind, payload, status, attempts, COALESCE(last_error, ''), next_attempt_at, created_at
+		FROM public.outbox
+		WHERE status = $1
+		ORDER BY created_at DESC
+		LIMIT 500
+	`, status)
+	if err != nil {
+		http.Error(w, "Failed to fetch outbox", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	messages := []OutboxMessage{}
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Kind, &m.Payload, &m.Status, &m.Attempts, &m.LastError, &m.NextAttemptAt, &m.CreatedAt); err == nil {
+			m.Payload = redactOutboxPayload(m.Kind, m.Payload) // OTPs and one-time links stay out of admin hands
+			messages = append(messages, m)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+// ReplayAdminOutbox requeues one dead message (?id=) or every dead message (no id)
+func ReplayAdminOutbox(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	// Only ids and kinds are audited; payloads can hold personal data
+	var replayed json.RawMessage
+	var count int
+	err = tx.QueryRow(r.Context(), `
+		WITH requeued AS (
+			UPDATE public.outbox SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+			WHERE status = 'dead' AND ($1 = '' OR id::text = $1)
+			RETURNING id, kind
+		)
+		SELECT COALESCE(jsonb_agg(jsonb_build_object('id', id, 'kind', kind)), '[]'), COUNT(*) FROM requeued
+	`, id).Scan(&replayed, &count)
+	if err == nil && id != "" && count == 0 {
+		http.Error(w, "Message not found or not dead", http.StatusNotFound)
+		return
+	}
+	if err == nil && count > 0 {
+		err = recordAudit(r, tx, auditEntry{Action: auditOutboxReplay, TargetType: "outbox", TargetID: id, After: replayed})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 OUTBOX REPLAY ERROR:", err)
+		http.Error(w, "Failed to replay messages", http.StatusInternalServerError)
+		return
+	}
+
+	notifyOutbox()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"message": "Requeued for delivery", "count": count})
+}
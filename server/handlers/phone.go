@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// defaultCallingCode is assumed for national-format numbers when the country is unknown;
+// most registrations come from Nigeria
+const defaultCallingCode = "234"
+
+var errInvalidPhone = errors.New("invalid phone number")
+
+// callingCodes maps lower-case country names and ISO codes to their calling code.
+// Free-text fields such as "Lagos, Nigeria" are matched word by word; two-letter
+// codes only count when they are the whole hint, so "in" or "no" in a sentence is ignored.
+var callingCodes = map[string]string{
+	"nigeria": "234", "ng": "234",
+	"ghana": "233", "gh": "233",
+	"kenya": "254", "ke": "254",
+	"south africa": "27", "za": "27",
+	"cameroon": "237", "cm": "237",
+	"uganda": "256", "ug": "256",
+	"tanzania": "255", "tz": "255",
+	"rwanda": "250", "rw": "250",
+	"zambia": "260", "zm": "260",
+	"zimbabwe": "263", "zw": "263",
+	"botswana": "267", "bw": "267",
+	"sierra leone": "232", "sl": "232",
+	"liberia": "231", "lr": "231",
+	"gambia": "220", "gm": "220",
+	"senegal": "221", "sn": "221",
+	"ivory coast": "225", "cote d ivoire": "225", "ci": "225",
+	"benin": "229", "bj": "229",
+	"togo": "228", "tg": "228",
+	"niger": "227", "ne": "227",
+	"ethiopia": "251", "et": "251",
+	"egypt": "20", "eg": "20",
+	"united kingdom": "44", "uk": "44", "gb": "44", "england": "44", "scotland": "44", "wales": "44",
+	"ireland": "353", "ie": "353",
+	"united states": "1", "usa": "1", "us": "1", "america": "1",
+	"canada": "1", "ca": "1",
+	"jamaica": "1",
+	"germany": "49", "de": "49",
+	"france": "33", "fr": "33",
+	"netherlands": "31", "nl": "31",
+	"belgium": "32", "be": "32",
+	"italy": "39", "it": "39",
+	"spain": "34", "es": "34",
+	"portugal": "351", "pt": "351",
+	"switzerland": "41", "ch": "41",
+	"austria": "43", "at": "43",
+	"sweden": "46", "se": "46",
+	"norway": "47", "no": "47",
+	"denmark": "45", "dk": "45",
+	"finland": "358", "fi": "358",
+	"poland": "48", "pl": "48",
+	"united arab emirates": "971", "uae": "971", "ae": "971", "dubai": "971",
+	"saudi arabia": "966", "sa": "966",
+	"qatar": "974", "qa": "974",
+	"india": "91", "in": "91",
+	"china": "86", "cn": "86",
+	"japan": "81", "jp": "81",
+	"malaysia": "60", "my": "60",
+	"singapore": "65", "sg": "65",
+	"australia": "61", "au": "61",
+	"new zealand": "64", "nz": "64",
+	"brazil": "55", "br": "55",
+}
+
+// countryNames lists the callingCodes keys longest first so multi-word names are tried before their parts
+var countryNames = func() []string {
+	names := make([]string, 0, len(callingCodes))
+	for n := range callingCodes {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	return names
+}()
+
+// callingCodeFor finds a calling code in a free-text country hint, or "" when none matches
+func callingCodeFor(hint string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(hint) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	text := " " + strings.Join(strings.Fields(b.String()), " ") + " "
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+	for _, name := range countryNames {
+		if len(name) == 2 && text != " "+name+" " {
+			continue
+		}
+		if strings.Contains(text, " "+name+" ") {
+			return callingCodes[name]
+		}
+	}
+	return ""
+}
+
+// normalizePhone parses a phone number into E.164 (+<country code><number>).
+// Numbers written with + or 00 are taken as international; otherwise the
+// country hint (or Nigeria) supplies the calling code and a trunk 0 is dropped.
+func normalizePhone(raw, countryHint string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	var digits strings.Builder
+	for _, r := range raw {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	d := digits.String()
+	if d == "" {
+		return "", errInvalidPhone
+	}
+
+	switch {
+	case strings.HasPrefix(raw, "+"):
+		// already international
+	case strings.HasPrefix(d, "00"):
+		d = d[2:]
+	default:
+		cc := callingCodeFor(countryHint)
+		if cc == "" {
+			cc = defaultCallingCode
+		}
+		national := strings.TrimPrefix(d, "0")
+		// A national number is at most 10 digits here, so anything longer that
+		// already starts with the calling code was typed without the +
+		if !(strings.HasPrefix(d, cc) && len(d) > 10) {
+			d = cc + national
+		}
+	}
+
+	if len(d) < 8 || len(d) > 15 || d[0] == '0' {
+		return "", errInvalidPhone
+	}
+	return "+" + d, nil
+}
+
+// registrationConflict describes an existing record that shares a new registration's email or phone
+type registrationConflict struct {
+	Field   string // "email" or "whatsapp_number"
+	Program string // where the existing record lives, e.g. "Couples Launchpad" or "the waitlist"
+}
+
+func (c registrationConflict) message() string {
+	what := "email address"
+	if c.Field == "whatsapp_number" {
+		what = "WhatsApp number"
+	}
+	if c.Program == "the waitlist" {
+		return fmt.Sprintf("This %s is already on the waitlist.", what)
+	}
+	return fmt.Sprintf("This %s is already registered for %s.", what, c.Program)
+}
+
+// findRegistrationConflict looks for an existing RFASM or Launchpad registration, or
+// an active waitlist entry, with the same case-folded email or normalized phone.
+// Waitlist entries only conflict when includeWaiting is set (joining the waitlist) or
+// when a seat is currently held for them, so waitlisted people can still register
+// once a cohort opens. excludeWaitlistID skips the entry whose offer is being claimed.
+//
+// It must run in the transaction that inserts the new record: it takes transaction
+// locks on the email and phone so a concurrent registration with either waits for
+// this one to commit, then sees it.
+func findRegistrationConflict(ctx context.Context, tx pgx.Tx, email, phoneE164 string, includeWaiting bool, excludeWaitlistID string) (*registrationConflict, error) {
+	// Email locks are always taken before phone locks, so two registrations can't deadlock
+	if _, err := tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(1, hashtext(lower($1))),
+		       CASE WHEN $2 <> '' THEN pg_advisory_xact_lock(2, hashtext($2)) END
+	`, email, phoneE164); err != nil {
+		return nil, err
+	}
+
+	var source, field string
+	err := tx.QueryRow(ctx, `
+		SELECT source, CASE WHEN lower(email) = lower($1) THEN 'email' ELSE 'whatsapp_number' END
+		FROM (
+			SELECT 'participants' AS source, email, whatsapp_e164 FROM public.participants
+			UNION ALL
+			SELECT 'couples_launchpad', email, whatsapp_e164 FROM public.couples_launchpad
+			UNION ALL
+			SELECT 'waitlist', email, whatsapp_e164 FROM public.waitlist
+			WHERE (status = 'offered' OR ($3 AND status = 'waiting')) AND id::text <> $4
+		) existing
+		WHERE lower(email) = lower($1) OR (whatsapp_e164 = $2 AND $2 <> '')
+		ORDER BY (lower(email) = lower($1)) DESC
+		LIMIT 1
+	`, email, phoneE164, includeWaiting, excludeWaitlistID).Scan(&source, &field)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	c := &registrationConflict{Field: field, Program: "the waitlist"}
+	if source != "waitlist" {
+		c.Program = source
+		if programs, err := loadPrograms(ctx); err == nil {
+			for _, p := range programs {
+				if p.RegistrationTable == source {
+					c.Program = p.ModuleName
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// writeRegistrationConflict reports a duplicate against the offending field
+func writeRegistrationConflict(w http.ResponseWriter, c *registrationConflict) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Success: false,
+		Message: c.message(),
+		Errors:  fieldErrors{c.Field: c.message()},
+	})
+}
+
+// BackfillNormalizedPhones fills whatsapp_e164 for registrations made before numbers were normalized
+func BackfillNormalizedPhones(ctx context.Context) {
+	backfill := func(table, phoneCol, hintCol, e164Col string) {
+		rows, err := db.Pool.Query(ctx, fmt.Sprintf(
+			"SELECT id::text, COALESCE(%s, ''), COALESCE(%s, '') FROM public.%s WHERE %s IS NULL AND COALESCE(%s, '') <> ''",
+			phoneCol, hintCol, table, e164Col, phoneCol))
+		if err != nil {
+			fmt.Printf("⚠️  Phone backfill (%s.%s) failed: %v\n", table, phoneCol, err)
+			return
+		}
+		type pending struct{ id, e164 string }
+		var updates []pending
+		for rows.Next() {
+			var id, phone, hint string
+			if rows.Scan(&id, &phone, &hint) != nil {
+				continue
+			}
+			if e164, err := normalizePhone(phone, hint); err == nil {
+				updates = append(updates, pending{id, e164})
+			}
+		}
+		rows.Close()
+
+		for _, u := range updates {
+			db.Pool.Exec(ctx, fmt.Sprintf("UPDATE public.%s SET %s = $1 WHERE id::text = $2", table, e164Col), u.e164, u.id)
+		}
+		if len(updates) > 0 {
+			fmt.Printf("✅ Normalized %d phone numbers in %s.%s\n", len(updates), table, phoneCol)
+		}
+	}
+
+	backfill("participants", "whatsapp_number", "country", "whatsapp_e164")
+	backfill("couples_launchpad", "whatsapp_number", "country_city", "whatsapp_e164")
+	backfill("couples_launchpad", "spouse_whatsapp", "country_city", "spouse_whatsapp_e164")
+	backfill("waitlist", "whatsapp_number", "nationality", "whatsapp_e164")
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	for _, tc := range []struct {
+		raw, country string
+		want         string
+	}{
+		{"08031234567", "", "+2348031234567"},
+		{"0803 123 4567", "Nigeria", "+2348031234567"},
+		{"+234 803 123 4567", "", "+2348031234567"},
+		{"+234 803 123 4567", "Ghana", "+2348031234567"},
+		{"2348031234567", "", "+2348031234567"},
+		{"00447911123456", "", "+447911123456"},
+		{"07911 123456", "United Kingdom", "+447911123456"},
+		{"07911-123456", "uk", "+447911123456"},
+		{"(415) 555-2671", "USA", "+14155552671"},
+		{"0244123456", "Accra, Ghana", "+233244123456"},
+	} {
+		got, err := normalizePhone(tc.raw, tc.country)
+		if err != nil || got != tc.want {
+			t.Errorf("normalizePhone(%q, %q) = %q, %v; want %q", tc.raw, tc.country, got, err, tc.want)
+		}
+	}
+}
+
+func TestNormalizePhoneRejects(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"not a number",
+		"+123 456",             // too short
+		"+1234 5678 9012 3456", // too long
+		"+0803 123 4567",       // no country code starts with 0
+		"0000 803 1234",
+	} {
+		if got, err := normalizePhone(raw, ""); !errors.Is(err, errInvalidPhone) {
+			t.Errorf("normalizePhone(%q) = %q, %v; want errInvalidPhone", raw, got, err)
+		}
+	}
+}
+
+func TestCallingCodeFor(t *testing.T) {
+	for _, tc := range []struct {
+		hint, want string
+	}{
+		{"Nigeria", "234"},
+		{"  united   KINGDOM ", "44"},
+		{"Jos, Plateau State, Nigeria", "234"},
+		{"UAE", "971"},
+		{"in", "91"},
+		// Two-letter codes only count on their own, not as words of a longer hint
+		{"Living in Lagos", ""},
+		{"Atlantis", ""},
+		{"", ""},
+	} {
+		if got := callingCodeFor(tc.hint); got != tc.want {
+			t.Errorf("callingCodeFor(%q) = %q, want %q", tc.hint, got, tc.want)
+		}
+	}
+}
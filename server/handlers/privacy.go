@@ -0,0 +1,479 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/asejik/soulmate-reg/server/supabase"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// personalDataTables hold rows keyed by the participant's auth user id
+var personalDataTables = []string{
+	"lesson_progress", "assignment_submissions", "quiz_submissions", "lesson_comments",
+	"questions", "program_reviews", "giving_commitments", "quiz_attempt_grants",
+	"quiz_variants",
+}
+
+// Erasure request statuses
+const (
+	erasurePending   = "pending"
+	erasureFailed    = "failed" // the data is gone but the Supabase user wasn't deleted; approve again to retry
+	erasureCompleted = "completed"
+	erasureRejected  = "rejected"
+)
+
+// erasureEmailHash identifies an erasure request's subject after their email is cleared
+func erasureEmailHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// --- Self-service export ---
+
+// ExportMyData returns a ZIP of JSON files with everything stored about the caller:
+// their login, registrations in every program, waitlist entries and LMS activity
+func ExportMyData(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(string)
+
+	var email string
+	var account json.RawMessage
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT email, jsonb_build_object(
+			'id', id, 'email', email, 'phone', phone, 'created_at', created_at,
+			'email_confirmed_at', email_confirmed_at, 'last_sign_in_at', last_sign_in_at,
+			'user_metadata', raw_user_meta_data)
+		FROM auth.users WHERE id = $1
+	`, userID).Scan(&email, &account)
+	if err != nil {
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+
+	type file struct {
+		name string
+		data json.RawMessage
+	}
+	files := []file{{"account.json", account}}
+	collect := func(name, sql string, arg any) error {
+		var data json.RawMessage
+		if err := db.Pool.QueryRow(r.Context(), sql, arg).Scan(&data); err != nil {
+			return err
+		}
+		files = append(files, file{name, data})
+		return nil
+	}
+
+	programs, err := loadPrograms(r.Context())
+	seen := map[string]bool{}
+	for _, p := range programs {
+		if err != nil {
+			break
+		}
+		if seen[p.RegistrationTable] {
+			continue
+		}
+		seen[p.RegistrationTable] = true
+		err = collect("registrations/"+p.RegistrationTable+".json",
+			`SELECT COALESCE(jsonb_agg(to_jsonb(t)), '[]'::jsonb) FROM `+p.registrationIdent()+` t WHERE t.user_id::text = $1`, userID)
+	}
+	if err == nil {
+		err = collect("waitlist.json", `SELECT COALESCE(jsonb_agg(to_jsonb(t)), '[]'::jsonb) FROM public.waitlist t WHERE lower(t.email) = lower($1)`, email)
+	}
+	for _, table := range personalDataTables {
+		if err != nil {
+			break
+		}
+		err = collect(table+".json", `SELECT COALESCE(jsonb_agg(to_jsonb(t)), '[]'::jsonb) FROM public.`+table+` t WHERE t.user_id = $1`, userID)
+	}
+	if err != nil {
+		fmt.Println("💥 DATA EXPORT ERROR:", err)
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, f.data, "", "  ") != nil {
+			pretty.Write(f.data)
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		if err == nil {
+			_, err = fw.Write(pretty.Bytes())
+		}
+		if err != nil {
+			http.Error(w, "Failed to export data", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="my-data.zip"`)
+	w.Write(buf.Bytes())
+}
+
+// --- Erasure ---
+
+// RequestMyErasure files an erasure request for the caller; nothing is removed until an admin approves it
+func RequestMyErasure(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(userIDKey).(string)
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	var email string
+	if err := db.Pool.QueryRow(r.Context(), "SELECT email FROM auth.users WHERE id = $1", userID).Scan(&email); err != nil {
+		http.Error(w, "Failed to file request", http.StatusInternalServerError)
+		return
+	}
+
+	id, created, err := fileErasureRequest(r.Context(), email, userID, req.Reason, userID)
+	if err != nil {
+		http.Error(w, "Failed to file request", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Your request has been received. An administrator will review it and your data will then be permanently deleted."
+	if !created {
+		message = "You already have an erasure request awaiting review."
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": id, "status": erasurePending, "message": message})
+}
+
+// fileErasureRequest records a pending request, or returns the one already open for that email
+func fileErasureRequest(ctx context.Context, email, userID, reason, requestedBy string) (string, bool, error) {
+	var id string
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO public.erasure_requests (user_id, email, email_hash, reason, requested_by)
+		VALUES (NULLIF($1, '')::uuid, $2, $3, NULLIF($4, ''), NULLIF($5, '')::uuid)
+		ON CONFLICT (email_hash) WHERE status IN ('pending', 'failed') DO NOTHING
+		RETURNING id::text
+	`, userID, email, erasureEmailHash(email), reason, requestedBy).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = db.Pool.QueryRow(ctx, `
+			SELECT id::text FROM public.erasure_requests WHERE email_hash = $1 AND status IN ('pending', 'failed')
+		`, erasureEmailHash(email)).Scan(&id)
+		return id, false, err
+	}
+	return id, err == nil, err
+}
+
+// erasure is what one approved request removed
+type erasure struct {
+	counts     map[string]int64
+	freedClans int // clans that got seats back for the waitlist
+}
+
+// eraseParticipant deletes everything tied to email and userID inside tx, and redacts
+// audit records that captured it. The Supabase user is deleted by the caller after commit.
+func eraseParticipant(ctx context.Context, tx pgx.Tx, email, userID string) (*erasure, error) {
+	e := &erasure{counts: map[string]int64{}}
+	run := func(label, sql string, args ...any) error {
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err == nil {
+			e.counts[label] += tag.RowsAffected()
+		}
+		return err
+	}
+
+	var err error
+	if userID != "" {
+		for _, table := range personalDataTables {
+			if err = run(table, `DELETE FROM public.`+table+` WHERE user_id = $1`, userID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Registrations: a live RFASM participant and a pending waitlist offer both hold a clan seat
+	programs, err := loadPrograms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, p := range programs {
+		if seen[p.RegistrationTable] {
+			continue
+		}
+		seen[p.RegistrationTable] = true
+		if p.Slug == programSoulmate {
+			err = run("clan_seats", `
+				UPDATE clans c SET current_count = GREATEST(c.current_count - n.seats, 0)
+				FROM (
+					SELECT clan_id, COUNT(*) AS seats FROM public.participants
+					WHERE (user_id::text = $2 OR lower(email) = lower($1)) AND deleted_at IS NULL AND clan_id IS NOT NULL
+					GROUP BY clan_id
+				) n
+				WHERE c.id = n.clan_id
+			`, email, userID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err = run(p.RegistrationTable, `DELETE FROM `+p.registrationIdent()+` WHERE user_id::text = $2 OR lower(email) = lower($1)`, email, userID); err != nil {
+			return nil, err
+		}
+	}
+	err = run("clan_seats", `
+		UPDATE clans c SET current_count = GREATEST(c.current_count - n.seats, 0)
+		FROM (
+			SELECT o.clan_id, COUNT(*) AS seats FROM public.waitlist_offers o
+			JOIN public.waitlist wl ON wl.id = o.waitlist_id
+			WHERE lower(wl.email) = lower($1) AND o.status = 'pending'
+			GROUP BY o.clan_id
+		) n
+		WHERE c.id = n.clan_id
+	`, email)
+	if err == nil {
+		err = run("waitlist", `DELETE FROM public.waitlist WHERE lower(email) = lower($1)`, email) // offers cascade
+	}
+	if err == nil {
+		err = run("verification_codes", `DELETE FROM public.verification_codes WHERE lower(spouse_email) = lower($1)`, email)
+	}
+	if err == nil {
+		err = run("account_claims", `DELETE FROM public.account_claims WHERE lower(email) = lower($1)`, email)
+	}
+	if err == nil {
+		// Queued and delivered emails and Sheets rows carry the address in their payload
+		err = run("outbox", `DELETE FROM public.outbox WHERE strpos(lower(payload::text), lower($1)) > 0`, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	e.freedClans = int(e.counts["clan_seats"])
+	delete(e.counts, "clan_seats")
+
+	// Audit records stay, but not the personal data in their snapshots
+	if _, err = tx.Exec(ctx, "SET LOCAL audit.allow_redact = 'on'"); err != nil {
+		return nil, err
+	}
+	err = run("audit_log_redacted", `
+		UPDATE public.audit_log SET
+			before = CASE WHEN before IS NULL THEN NULL ELSE '{"redacted": true}'::jsonb END,
+			after = CASE WHEN after IS NULL THEN NULL ELSE '{"redacted": true}'::jsonb END,
+			actor_label = CASE WHEN actor_id::text = $2 THEN NULL ELSE actor_label END
+		WHERE action <> $3 AND (
+			strpos(lower(COALESCE(before::text, '') || COALESCE(after::text, '')), lower($1)) > 0
+			OR ($2 <> '' AND (target_id = $2 OR actor_id::text = $2
+				OR strpos(COALESCE(before::text, '') || COALESCE(after::text, ''), $2) > 0)))
+	`, email, userID, auditUserErase)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// --- Admin: Erasure requests ---
+
+type ErasureRequest struct {
+	ID          string          `json:"id"`
+	UserID      *string         `json:"user_id"`
+	Email       *string         `json:"email"` // cleared once the erasure completes
+	Reason      string          `json:"reason"`
+	Status      string          `json:"status"`
+	RequestedBy string          `json:"requested_by"`
+	DecidedBy   string          `json:"decided_by"`
+	DecidedAt   *time.Time      `json:"decided_at"`
+	CompletedAt *time.Time      `json:"completed_at"`
+	Summary     json.RawMessage `json:"summary"`
+	LastError   string          `json:"last_error"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// GetAdminErasureRequests lists erasure requests, open ones first
+func GetAdminErasureRequests(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT e.id::text, e.user_id::text, e.email, COALESCE(e.reason, ''), e.status,
+			COALESCE(ru.email, ''), COALESCE(du.email, ''), e.decided_at, e.completed_at, e.summary,
+			COALESCE(e.last_error, ''), e.created_at
+		FROM public.erasure_requests e
+		LEFT JOIN auth.users ru ON ru.id = e.requested_by AND e.requested_by IS DISTINCT FROM e.user_id
+		LEFT JOIN auth.users du ON du.id = e.decided_by
+		ORDER BY (e.status IN ('pending', 'failed')) DESC, e.created_at DESC
+		LIMIT 500
+	`)
+	if err != nil {
+		http.Error(w, "Failed to fetch erasure requests", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	requests := []ErasureRequest{}
+	for rows.Next() {
+		var e ErasureRequest
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Email, &e.Reason, &e.Status, &e.RequestedBy, &e.DecidedBy,
+			&e.DecidedAt, &e.CompletedAt, &e.Summary, &e.LastError, &e.CreatedAt); err == nil {
+			requests = append(requests, e)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// CreateAdminErasureRequest files a request on a participant's behalf, e.g. one received by email.
+// It still has to be approved like any other.
+func CreateAdminErasureRequest(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := r.Context().Value(userIDKey).(string)
+
+	var req struct {
+		Email  string `json:"email"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !strings.Contains(req.Email, "@") {
+		http.Error(w, "A valid email is required", http.StatusBadRequest)
+		return
+	}
+	email := strings.TrimSpace(req.Email)
+
+	var userID string
+	db.Pool.QueryRow(r.Context(), "SELECT id::text FROM auth.users WHERE lower(email) = lower($1)", email).Scan(&userID)
+
+	id, created, err := fileErasureRequest(r.Context(), email, userID, req.Reason, adminID)
+	if err != nil {
+		http.Error(w, "Failed to file request", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "created": created})
+}
+
+// ApproveErasureRequest runs the erasure: every table in one transaction with its audit record,
+// then the Supabase user. If that last step fails the request is marked failed and can be approved again.
+func ApproveErasureRequest(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	adminID, _ := r.Context().Value(userIDKey).(string)
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to run erasure", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var email, userID, status string
+	err = tx.QueryRow(r.Context(), `
+		SELECT COALESCE(e.email, ''), COALESCE(e.user_id::text, u.id::text, ''), e.status
+		FROM public.erasure_requests e
+		LEFT JOIN auth.users u ON lower(u.email) = lower(e.email)
+		WHERE e.id::text = $1
+		FOR UPDATE OF e
+	`, id).Scan(&email, &userID, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Erasure request not found", http.StatusNotFound)
+		return
+	}
+	if err == nil && status != erasurePending && status != erasureFailed {
+		http.Error(w, "This request is already "+status, http.StatusConflict)
+		return
+	}
+
+	var e *erasure
+	if err == nil {
+		e, err = eraseParticipant(r.Context(), tx, email, userID)
+	}
+	if err == nil {
+		// On a retry the first run's counts are kept; by now they would all be zero
+		_, err = tx.Exec(r.Context(), `
+			UPDATE public.erasure_requests SET user_id = NULLIF($2, '')::uuid, decided_by = NULLIF($3, '')::uuid,
+				decided_at = COALESCE(decided_at, NOW()), summary = COALESCE(summary, $4)
+			WHERE id::text = $1
+		`, id, userID, adminID, e.counts)
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditUserErase, TargetType: "erasure_request", TargetID: id,
+			After: map[string]interface{}{"user_id": userID, "deleted": e.counts}})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 ERASURE ERROR:", err)
+		http.Error(w, "Failed to run erasure", http.StatusInternalServerError)
+		return
+	}
+	if e.freedClans > 0 {
+		go RunWaitlistPromotion(context.Background())
+	}
+
+	// The login goes last: once it's gone the participant can't sign in to see a half-erased account
+	status, lastError := erasureCompleted, ""
+	if userID != "" {
+		if err := supabase.Default().DeleteUser(r.Context(), userID); err != nil && !supabase.IsNotFound(err) {
+			status, lastError = erasureFailed, err.Error()
+		}
+	}
+	_, err = db.Pool.Exec(r.Context(), `
+		UPDATE public.erasure_requests SET status = $2, last_error = NULLIF($3, ''),
+			completed_at = CASE WHEN $2 = 'completed' THEN NOW() END,
+			email = CASE WHEN $2 = 'completed' THEN NULL ELSE email END
+		WHERE id::text = $1
+	`, id, status, lastError)
+	if err != nil || status == erasureFailed {
+		fmt.Println("💥 ERASURE AUTH DELETE ERROR:", lastError, err)
+		http.Error(w, "Data was erased but the login could not be deleted. Approve the request again to retry.", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "deleted": e.counts})
+}
+
+// RejectErasureRequest closes a pending request without touching any data
+func RejectErasureRequest(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	adminID, _ := r.Context().Value(userIDKey).(string)
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to reject request", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		UPDATE public.erasure_requests e SET status = 'rejected', decided_by = NULLIF($2, '')::uuid, decided_at = NOW()
+		WHERE id::text = $1 AND status = 'pending'
+		RETURNING jsonb_build_object('status', e.status, 'reason', e.reason)
+	`, id, adminID).Scan(&after)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "No pending request with that id", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditErasureReject, TargetType: "erasure_request", TargetID: id, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, "Failed to reject request", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": erasureRejected})
+}
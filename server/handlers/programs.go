@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// Program is a row of the public.programs registry. Each name a program has been
+// stored under elsewhere in the schema is captured here so handlers never have to
+// translate between "launchpad", "Couples Launchpad" and "Couples' Launchpad 5.0".
+type Program struct {
+	Slug string `json:"slug"` // canonical id, e.g. "launchpad", "soulmate"
+	// Key is what enrolled_programs / ?program= carry and what program_reviews,
+	// questions and giving_commitments store in their program_name column.
+	Key               string `json:"key"`
+	DisplayName       string `json:"display_name"`       // shown on certificates
+	ModuleName        string `json:"module_name"`        // modules / cohorts / program_settings program_name
+	RegistrationTable string `json:"registration_table"` // public table holding sign-ups
+
+	CertificateMinCompletion       float64 `json:"certificate_min_completion"`
+	CertificateRequiresFinalReview bool    `json:"certificate_requires_final_review"`
+
+	// Lesson locking (checkpoint, rewatch windows) lives in lesson_access_rules
+	CheckpointMinReviewChars int `json:"checkpoint_min_review_chars"` // 0 = no minimum for mid reviews
+
+	SortOrder int      `json:"sort_order"`
+	Aliases   []string `json:"aliases"`
+}
+
+// Slugs of the programs whose registration flows have dedicated handlers
+const (
+	programSoulmate  = "soulmate"
+	programLaunchpad = "launchpad"
+)
+
+// names returns every string this program is known by
+func (p Program) names() []string {
+	return append([]string{p.Slug, p.Key, p.DisplayName, p.ModuleName}, p.Aliases...)
+}
+
+// registrationIdent is the sanitized, schema-qualified registration table name
+func (p Program) registrationIdent() string {
+	return pgx.Identifier{"public", p.RegistrationTable}.Sanitize()
+}
+
+// prepareRegistrationTable adds what every registration table needs: the trash marker
+// (admin deletes are soft) and the link to the participant's login, backfilled by email
+func prepareRegistrationTable(ctx context.Context, q execer, p Program) error {
+	table := p.registrationIdent()
+	for _, stmt := range []string{
+		"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ",
+		"ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES auth.users(id) ON DELETE SET NULL",
+		"CREATE INDEX IF NOT EXISTS " + pgx.Identifier{p.RegistrationTable + "_user_id_idx"}.Sanitize() + " ON " + table + " (user_id)",
+		"DROP TRIGGER IF EXISTS link_registration_user ON " + table,
+		"CREATE TRIGGER link_registration_user BEFORE INSERT ON " + table + " FOR EACH ROW EXECUTE FUNCTION public.link_registration_user()",
+		"UPDATE " + table + " r SET user_id = au.id FROM auth.users au WHERE r.user_id IS NULL AND lower(r.email) = lower(au.email)",
+	} {
+		if _, err := q.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	programsCache   []Program
+	programsCacheTs time.Time
+	programsMutex   sync.Mutex
+)
+
+// loadPrograms returns the registry ordered by sort_order, cached for a minute
+func loadPrograms(ctx context.Context) ([]Program, error) {
+	programsMutex.Lock()
+	defer programsMutex.Unlock()
+	if programsCache != nil && time.Since(programsCacheTs) < time.Minute {
+		return programsCache, nil
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT slug, enrollment_key, display_name, module_name, registration_table,
+		       certificate_min_completion::float, certificate_requires_final_review,
+		       checkpoint_min_review_chars, sort_order, COALESCE(aliases, '{}')
+		FROM public.programs
+		ORDER BY sort_order ASC, slug ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var programs []Program
+	for rows.Next() {
+		var p Program
+		if err := rows.Scan(&p.Slug, &p.Key, &p.DisplayName, &p.ModuleName, &p.RegistrationTable,
+			&p.CertificateMinCompletion, &p.CertificateRequiresFinalReview,
+			&p.CheckpointMinReviewChars, &p.SortOrder, &p.Aliases); err != nil {
+			return nil, err
+		}
+		programs = append(programs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	programsCache = programs
+	programsCacheTs = time.Now()
+	return programs, nil
+}
+
+func invalidateProgramsCache() {
+	programsMutex.Lock()
+	programsCache = nil
+	programsMutex.Unlock()
+}
+
+// lookupProgram finds a program by any of its names (case-insensitive)
+func lookupProgram(ctx context.Context, name string) (Program, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Program{}, false
+	}
+	programs, err := loadPrograms(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  Program registry load failed: %v\n", err)
+		return Program{}, false
+	}
+	for _, p := range programs {
+		for _, n := range p.names() {
+			if strings.EqualFold(n, name) {
+				return p, true
+			}
+		}
+	}
+	return Program{}, false
+}
+
+// mustProgram looks up a program that is expected to be seeded, such as the ones
+// with dedicated registration handlers
+func mustProgram(ctx context.Context, slug string) (Program, error) {
+	if p, ok := lookupProgram(ctx, slug); ok {
+		return p, nil
+	}
+	return Program{}, fmt.Errorf("program %q is not configured", slug)
+}
+
+// systemTables are the app's own tables. Saving a program ALTERs its registration
+// table and purges and erasure delete from it, so none of these may be used as one.
+// participants and couples_launchpad are registration tables and stay allowed.
+var systemTables = map[string]bool{
+	"account_claims": true, "announcements": true, "api_keys": true, "assignment_submissions": true,
+	"audit_log": true, "clans": true, "cohorts": true, "email_changes": true, "erasure_requests": true,
+	"giving_commitments": true, "lesson_access_rules": true, "lesson_comments": true, "lesson_progress": true,
+	"lesson_schedules": true, "lessons": true, "modules": true, "outbox": true, "program_reviews": true,
+	"program_settings": true, "programs": true, "question_banks": true, "questions": true,
+	"quiz_attempt_grants": true, "quiz_questions": true, "quiz_submissions": true, "quiz_variants": true,
+	"quizzes": true, "user_cohorts": true, "user_roles": true, "verification_codes": true, "waitlist": true,
+	"waitlist_offers": true,
+}
+
+// --- Admin: Program registry ---
+
+// GetAdminPrograms lists the program registry
+func GetAdminPrograms(w http.ResponseWriter, r *http.Request) {
+	programs, err := loadPrograms(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch programs", http.StatusInternalServerError)
+		return
+	}
+	if programs == nil {
+		programs = []Program{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(programs)
+}
+
+// SaveAdminProgram creates or updates a program by slug
+func SaveAdminProgram(w http.ResponseWriter, r *http.Request) {
+	var p Program
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Slug == "" || p.Key == "" || p.ModuleName == "" || p.RegistrationTable == "" {
+		http.Error(w, "slug, key, module_name and registration_table are required", http.StatusBadRequest)
+		return
+	}
+	if p.DisplayName == "" {
+		p.DisplayName = p.ModuleName
+	}
+	if p.Aliases == nil {
+		p.Aliases = []string{}
+	}
+	if systemTables[strings.ToLower(p.RegistrationTable)] {
+		http.Error(w, "registration_table can't be one of the app's own tables", http.StatusBadRequest)
+		return
+	}
+	if p.CertificateMinCompletion < 0 || p.CertificateMinCompletion > 1 {
+		http.Error(w, "certificate_min_completion must be between 0 and 1", http.StatusBadRequest)
+		return
+	}
+
+	// The registration table must exist and look like a registration table
+	var hasEmail bool
+	db.Pool.QueryRow(r.Context(), `
+		SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_schema = 'public' AND table_name = $1 AND column_name = 'email')
+	`, p.RegistrationTable).Scan(&hasEmail)
+	if !hasEmail {
+		http.Error(w, "registration_table must be an existing public table with an email column", http.StatusBadRequest)
+		return
+	}
+
+	// The table changes, the registry row and its audit record commit together
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var before, after json.RawMessage
+	err = prepareRegistrationTable(r.Context(), tx, p)
+	if err == nil {
+		err = tx.QueryRow(r.Context(), "SELECT to_jsonb(p) FROM public.programs p WHERE slug = $1 FOR UPDATE", p.Slug).Scan(&before)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO public.programs AS p (slug, enrollment_key, display_name, module_name, registration_table,
+				certificate_min_completion, certificate_requires_final_review,
+				checkpoint_min_review_chars, sort_order, aliases)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (slug) DO UPDATE SET
+				enrollment_key = EXCLUDED.enrollment_key,
+				display_name = EXCLUDED.display_name,
+				module_name = EXCLUDED.module_name,
+				registration_table = EXCLUDED.registration_table,
+				certificate_min_completion = EXCLUDED.certificate_min_completion,
+				certificate_requires_final_review = EXCLUDED.certificate_requires_final_review,
+				checkpoint_min_review_chars = EXCLUDED.checkpoint_min_review_chars,
+				sort_order = EXCLUDED.sort_order,
+				aliases = EXCLUDED.aliases
+			RETURNING to_jsonb(p)
+		`, p.Slug, p.Key, p.DisplayName, p.ModuleName, p.RegistrationTable,
+			p.CertificateMinCompletion, p.CertificateRequiresFinalReview,
+			p.CheckpointMinReviewChars, p.SortOrder, p.Aliases).Scan(&after)
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditProgramSave, TargetType: "programs", TargetID: p.Slug, Before: before, After: after})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DB SAVE ERROR (Program):", err)
+		http.Error(w, "Failed to save program", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateProgramsCache()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Program saved"})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// QuestionBank is a reusable pool of quiz questions, tagged by module and topic
+type QuestionBank struct {
+	Name      string         `json:"name"`
+	ModuleID  *string        `json:"module_id"`
+	Topic     string         `json:"topic"`
+	Questions []QuizQuestion `json:"questions"`
+}
+
+func (b *QuestionBank) normalize() error {
+	b.Name = strings.TrimSpace(b.Name)
+	b.Topic = strings.TrimSpace(b.Topic)
+	if b.ModuleID != nil && *b.ModuleID == "" {
+		b.ModuleID = nil
+	}
+	if b.Name == "" {
+		return errors.New("a question bank needs a name")
+	}
+	questions, err := normalizeQuizQuestions(b.Questions)
+	if err == nil {
+		b.Questions = questions
+	}
+	return err
+}
+
+// checkQuizBank makes sure a bank exists and holds enough questions for a quiz's draw
+func checkQuizBank(ctx context.Context, bankID string, drawCount int) error {
+	if drawCount < 1 {
+		return errors.New("a quiz drawn from a bank must draw at least one question")
+	}
+	var size int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COUNT(qq.id) FROM public.question_banks b
+		LEFT JOIN public.quiz_questions qq ON qq.bank_id = b.id
+		WHERE b.id::text = $1 GROUP BY b.id
+	`, bankID).Scan(&size)
+	if err == pgx.ErrNoRows {
+		return errors.New("question bank not found")
+	}
+	if err != nil {
+		fmt.Println("💥 QUESTION BANK CHECK ERROR:", err)
+		return errors.New("could not check the question bank")
+	}
+	if drawCount > size {
+		return fmt.Errorf("the question bank only has %d questions", size)
+	}
+	return nil
+}
+
+// GetQuestionBanks lists question banks with their questions (answer keys included).
+// ?module_id= and ?topic= narrow the list.
+func GetQuestionBanks(w http.ResponseWriter, r *http.Request) {
+	type bankRow struct {
+		ID          string          `json:"id"`
+		Name        string          `json:"name"`
+		ModuleID    *string         `json:"module_id"`
+		ModuleTitle *string         `json:"module_title"`
+		ProgramName *string         `json:"program_name"`
+		Topic       string          `json:"topic"`
+		Questions   json.RawMessage `json:"questions"`
+		QuizCount   int             `json:"quiz_count"`
+	}
+
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT b.id::text, b.name, b.module_id::text, m.title, m.program_name, b.topic,
+			   COALESCE((SELECT jsonb_agg(jsonb_build_object('id', qq.id, 'kind', qq.kind, 'prompt', qq.prompt,
+			                 'options', qq.options, 'answer_key', qq.answer_key) ORDER BY qq.sort_order, qq.created_at)
+			             FROM public.quiz_questions qq WHERE qq.bank_id = b.id), '[]'::jsonb),
+			   (SELECT COUNT(*) FROM public.quizzes q WHERE q.bank_id = b.id)::int
+		FROM public.question_banks b
+		LEFT JOIN public.modules m ON b.module_id = m.id
+		WHERE ($1 = '' OR b.module_id::text = $1) AND ($2 = '' OR lower(b.topic) = lower($2))
+		ORDER BY m.program_name NULLS LAST, m.sort_order NULLS LAST, b.topic, b.name
+	`, r.URL.Query().Get("module_id"), strings.TrimSpace(r.URL.Query().Get("topic")))
+	if err != nil {
+		fmt.Println("💥 QUESTION BANKS ERROR:", err)
+		http.Error(w, "Failed to fetch question banks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	banks := []bankRow{}
+	for rows.Next() {
+		var b bankRow
+		if err := rows.Scan(&b.ID, &b.Name, &b.ModuleID, &b.ModuleTitle, &b.ProgramName, &b.Topic, &b.Questions, &b.QuizCount); err == nil {
+			banks = append(banks, b)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(banks)
+}
+
+// SaveQuestionBank creates a bank (POST) or replaces one's details and questions (PUT ?id=)
+func SaveQuestionBank(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	var b QuestionBank
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil || (r.Method == http.MethodPut && id == "") {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if err := b.normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to save question bank", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	if id == "" {
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO public.question_banks (name, module_id, topic) VALUES ($1, $2, $3) RETURNING id::text
+		`, b.Name, b.ModuleID, b.Topic).Scan(&id)
+	} else {
+		// Quizzes already drawing from the bank must still find enough questions
+		var largestDraw int
+		err = tx.QueryRow(r.Context(), `
+			UPDATE public.question_banks SET name = $2, module_id = $3, topic = $4 WHERE id::text = $1
+			RETURNING (SELECT COALESCE(MAX(draw_count), 0) FROM public.quizzes WHERE bank_id::text = $1)
+		`, id, b.Name, b.ModuleID, b.Topic).Scan(&largestDraw)
+		if err == pgx.ErrNoRows {
+			http.Error(w, "Question bank not found", http.StatusNotFound)
+			return
+		}
+		if err == nil && largestDraw > len(b.Questions) {
+			http.Error(w, fmt.Sprintf("A quiz draws %d questions from this bank; keep at least that many", largestDraw), http.StatusBadRequest)
+			return
+		}
+	}
+	if err == nil {
+		err = saveQuestions(r.Context(), tx, ownerBank, id, b.Questions)
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 SAVE QUESTION BANK ERROR:", err)
+		http.Error(w, "Failed to save question bank", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id": id, "message": "Question bank saved"})
+}
+
+// DeleteQuestionBank permanently removes a bank and its questions. Banks a quiz still draws
+// from can't be deleted.
+func DeleteQuestionBank(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "Missing id", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to delete question bank", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var inUse int
+	err = tx.QueryRow(r.Context(), `SELECT COUNT(*) FROM public.quizzes WHERE bank_id::text = $1`, id).Scan(&inUse)
+	if err == nil && inUse > 0 {
+		http.Error(w, fmt.Sprintf("%d quizzes draw from this bank; point them elsewhere first", inUse), http.StatusConflict)
+		return
+	}
+
+	var before json.RawMessage
+	if err == nil {
+		err = tx.QueryRow(r.Context(), `
+			DELETE FROM public.question_banks b WHERE id::text = $1
+			RETURNING to_jsonb(b) || jsonb_build_object('questions',
+				(SELECT COALESCE(jsonb_agg(to_jsonb(qq)), '[]'::jsonb) FROM public.quiz_questions qq WHERE qq.bank_id = b.id))
+		`, id).Scan(&before)
+	}
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Question bank not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditQuestionBankDelete, TargetType: "question_bank", TargetID: id, Before: before})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 DELETE QUESTION BANK ERROR:", err)
+		http.Error(w, "Failed to delete question bank", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Question bank deleted"})
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Quiz analytics look at the attempt that counts for each learner (best or latest, per the
+// quiz's attempt scoring), so a learner who retries shows up once.
+
+// defaultQuizThreshold is the score percentage below which learners are flagged
+const defaultQuizThreshold = 50
+
+// quizLearnerCohortJoin joins the latest cohort of the submitting learner qs.user_id for the module m
+const quizLearnerCohortJoin = `
+	LEFT JOIN LATERAL (
+		SELECT uc.cohort_id, uc.cohort_name FROM public.user_cohorts uc
+		WHERE uc.user_id = qs.user_id AND uc.program_name = m.program_name
+		ORDER BY uc.registered_at DESC
+		LIMIT 1
+	) coh ON true`
+
+// quizKeptAttemptOrder ranks a learner's attempts so the one that counts comes first
+const quizKeptAttemptOrder = `CASE WHEN q.attempt_scoring = 'best' THEN qs.score END DESC NULLS LAST, qs.attempt DESC`
+
+func quizThreshold(r *http.Request) (float64, error) {
+	raw := r.URL.Query().Get("threshold")
+	if raw == "" {
+		return defaultQuizThreshold, nil
+	}
+	t, err := strconv.ParseFloat(raw, 64)
+	if err != nil || t < 0 || t > 100 {
+		return 0, fmt.Errorf("threshold must be a percentage between 0 and 100")
+	}
+	return t, nil
+}
+
+func roundPercent(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// percentile picks the nearest-rank percentile p (0-100) of values
+func percentile(values []float64, p float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+func average(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// GetQuizAnalyticsOverview lists every lesson quiz with how many learners took it, their
+// average score and how many fall below ?threshold= (percent, default 50). ?cohort_id= narrows
+// it to one cohort.
+func GetQuizAnalyticsOverview(w http.ResponseWriter, r *http.Request) {
+	threshold, err := quizThreshold(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := db.Pool.Query(r.Context(), `
+		WITH kept AS (
+			SELECT DISTINCT ON (qs.quiz_id, qs.user_id) qs.quiz_id, qs.score, qs.total_questions, coh.cohort_id
+			FROM public.quiz_submissions qs
+			JOIN public.quizzes q ON qs.quiz_id = q.id
+			JOIN public.lessons l ON q.lesson_id = l.id
+			JOIN public.modules m ON l.module_id = m.id
+			`+quizLearnerCohortJoin+`
+			ORDER BY qs.quiz_id, qs.user_id, `+quizKeptAttemptOrder+`
+		)
+		SELECT l.id::text, l.title, m.title, m.program_name, q.title,
+			   COUNT(k.quiz_id)::int,
+			   ROUND(AVG(k.score * 100.0 / NULLIF(k.total_questions, 0)), 1)::float8,
+			   COUNT(k.quiz_id) FILTER (WHERE k.total_questions > 0 AND k.score * 100.0 / k.total_questions < $2::float8)::int
+		FROM public.quizzes q
+		JOIN public.lessons l ON q.lesson_id = l.id
+		JOIN public.modules m ON l.module_id = m.id
+		LEFT JOIN kept k ON k.quiz_id = q.id AND ($1 = '' OR k.cohort_id::text = $1)
+		WHERE l.deleted_at IS NULL AND m.deleted_at IS NULL
+		GROUP BY l.id, l.title, m.title, m.program_name, m.sort_order, l.sort_order, q.title
+		ORDER BY m.program_name, m.sort_order, l.sort_order
+	`, r.URL.Query().Get("cohort_id"), threshold)
+	if err != nil {
+		fmt.Println("💥 QUIZ ANALYTICS ERROR:", err)
+		http.Error(w, "Failed to fetch quiz analytics", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type quizSummary struct {
+		LessonID       string   `json:"lesson_id"`
+		LessonTitle    string   `json:"lesson_title"`
+		ModuleTitle    string   `json:"module_title"`
+		ProgramName    string   `json:"program_name"`
+		QuizTitle      string   `json:"quiz_title"`
+		Learners       int      `json:"learners"`
+		AveragePercent *float64 `json:"average_percent"`
+		BelowThreshold int      `json:"below_threshold"`
+	}
+
+	quizzes := []quizSummary{}
+	for rows.Next() {
+		var s quizSummary
+		if err := rows.Scan(&s.LessonID, &s.LessonTitle, &s.ModuleTitle, &s.ProgramName, &s.QuizTitle,
+			&s.Learners, &s.AveragePercent, &s.BelowThreshold); err != nil {
+			fmt.Println("💥 DB SCAN ERROR (Quiz analytics):", err)
+			continue
+		}
+		quizzes = append(quizzes, s)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quizzes)
+}
+
+type quizAnswerCount struct {
+	Answer  string `json:"answer"`
+	Count   int    `json:"count"`
+	Correct bool   `json:"correct"`
+}
+
+type quizQuestionStats struct {
+	ID          string            `json:"id"`
+	Kind        string            `json:"kind"`
+	Prompt      string            `json:"prompt"`
+	Shown       int               `json:"shown"`
+	Answered    int               `json:"answered"`
+	Graded      int               `json:"graded"`
+	Correct     int               `json:"correct"`
+	CorrectRate *float64          `json:"correct_rate"`
+	Answers     []quizAnswerCount `json:"answers"`
+
+	// Short answers are counted by their normalized form
+	answerIndex map[string]int
+}
+
+func (s *quizQuestionStats) count(q QuizQuestion, answer string) {
+	key := answer
+	if q.Kind == quizShortAnswer {
+		key = normalizeShortAnswer(answer)
+	}
+	i, ok := s.answerIndex[key]
+	if !ok {
+		i = len(s.Answers)
+		s.answerIndex[key] = i
+		s.Answers = append(s.Answers, quizAnswerCount{Answer: answer, Correct: q.graded() && q.answeredCorrectly([]string{answer})})
+	}
+	s.Answers[i].Count++
+}
+
+type quizTimeStats struct {
+	Samples        int     `json:"samples"`
+	MedianSeconds  float64 `json:"median_seconds"`
+	P90Seconds     float64 `json:"p90_seconds"`
+	AverageSeconds float64 `json:"average_seconds"`
+}
+
+func newQuizTimeStats(seconds []float64) *quizTimeStats {
+	if len(seconds) == 0 {
+		return nil
+	}
+	return &quizTimeStats{
+		Samples:        len(seconds),
+		MedianSeconds:  math.Round(percentile(seconds, 50)),
+		P90Seconds:     math.Round(percentile(seconds, 90)),
+		AverageSeconds: math.Round(average(seconds)),
+	}
+}
+
+type quizScoreBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Count int `json:"count"`
+}
+
+type quizCohortStats struct {
+	CohortID       *string        `json:"cohort_id"`
+	CohortName     string         `json:"cohort_name"`
+	Learners       int            `json:"learners"`
+	AveragePercent *float64       `json:"average_percent"`
+	TimeToSubmit   *quizTimeStats `json:"time_to_submit"`
+
+	percents []float64
+	seconds  []float64
+}
+
+type quizLearnerScore struct {
+	UserID         string    `json:"user_id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	CohortName     *string   `json:"cohort_name"`
+	Score          int       `json:"score"`
+	TotalQuestions int       `json:"total_questions"`
+	Percent        float64   `json:"percent"`
+	Attempts       int       `json:"attempts"`
+	SubmittedAt    time.Time `json:"submitted_at"`
+}
+
+// GetQuizAnalytics breaks down one lesson's quiz: per-question correctness and answer counts
+// (including distractors nobody picked), the score distribution, time from the attempt being
+// served to its submission, a per-cohort summary and the learners below ?threshold= (percent,
+// default 50). ?cohort_id= narrows everything to one cohort.
+func GetQuizAnalytics(w http.ResponseWriter, r *http.Request) {
+	lessonID := chi.URLParam(r, "id")
+	cohortID := r.URL.Query().Get("cohort_id")
+	threshold, err := quizThreshold(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var quizID, quizTitle, lessonTitle string
+	err = db.Pool.QueryRow(r.Context(), `
+		SELECT q.id::text, q.title, l.title FROM public.quizzes q
+		JOIN public.lessons l ON q.lesson_id = l.id
+		WHERE q.lesson_id::text = $1
+	`, lessonID).Scan(&quizID, &quizTitle, &lessonTitle)
+	if err == pgx.ErrNoRows {
+		http.Error(w, "This lesson has no quiz", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println("💥 QUIZ ANALYTICS ERROR:", err)
+		http.Error(w, "Failed to fetch quiz analytics", http.StatusInternalServerError)
+		return
+	}
+
+	// Submissions from before variants were recorded are read against the quiz's current questions
+	current, err := loadQuizQuestions(r.Context(), quizID)
+	if err != nil {
+		fmt.Println("💥 QUIZ ANALYTICS ERROR:", err)
+		http.Error(w, "Failed to fetch quiz analytics", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT user_id, name, email, cohort_id, cohort_name, score, total_questions, answers,
+			   submitted_at, served_at, questions, attempts
+		FROM (
+			SELECT qs.user_id::text,
+				   COALESCE((SELECT full_name FROM public.couples_launchpad WHERE user_id = qs.user_id LIMIT 1),
+				            (SELECT full_name FROM public.participants WHERE user_id = qs.user_id LIMIT 1), 'Unknown User') AS name,
+				   au.email,
+				   coh.cohort_id::text, coh.cohort_name,
+				   COALESCE(qs.score, 0) AS score, COALESCE(qs.total_questions, 0) AS total_questions, qs.answers,
+				   qs.submitted_at, v.created_at AS served_at, v.questions,
+				   row_number() OVER (PARTITION BY qs.user_id ORDER BY `+quizKeptAttemptOrder+`) AS kept_rank,
+				   COUNT(*) OVER (PARTITION BY qs.user_id)::int AS attempts
+			FROM public.quiz_submissions qs
+			JOIN public.quizzes q ON qs.quiz_id = q.id
+			JOIN public.lessons l ON q.lesson_id = l.id
+			JOIN public.modules m ON l.module_id = m.id
+			JOIN auth.users au ON qs.user_id = au.id
+			LEFT JOIN public.quiz_variants v ON v.quiz_id = qs.quiz_id AND v.user_id = qs.user_id AND v.attempt = qs.attempt
+			`+quizLearnerCohortJoin+`
+			WHERE qs.quiz_id = $1
+		) kept
+		WHERE kept_rank = 1 AND ($2 = '' OR cohort_id = $2)
+	`, quizID, cohortID)
+	if err != nil {
+		fmt.Println("💥 QUIZ ANALYTICS ERROR:", err)
+		http.Error(w, "Failed to fetch quiz analytics", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var (
+		learners, attempts int
+		percents, seconds  []float64
+		buckets            = make([]quizScoreBucket, 10)
+		questions          = []*quizQuestionStats{}
+		questionIndex      = map[string]*quizQuestionStats{}
+		cohorts            = []*quizCohortStats{}
+		cohortIndex        = map[string]*quizCohortStats{}
+		below              = []quizLearnerScore{}
+	)
+	for i := range buckets {
+		buckets[i] = quizScoreBucket{From: i * 10, To: i*10 + 9}
+	}
+	buckets[9].To = 100
+
+	for rows.Next() {
+		var (
+			l        quizLearnerScore
+			cohort   *string
+			answers  json.RawMessage
+			servedAt *time.Time
+			variant  json.RawMessage
+		)
+		if err := rows.Scan(&l.UserID, &l.Name, &l.Email, &cohort, &l.CohortName, &l.Score, &l.TotalQuestions,
+			&answers, &l.SubmittedAt, &servedAt, &variant, &l.Attempts); err != nil {
+			fmt.Println("💥 DB SCAN ERROR (Quiz analytics):", err)
+			continue
+		}
+		learners++
+		attempts += l.Attempts
+
+		key := ""
+		if cohort != nil {
+			key = *cohort
+		}
+		c, ok := cohortIndex[key]
+		if !ok {
+			c = &quizCohortStats{CohortID: cohort, CohortName: "No cohort"}
+			if l.CohortName != nil {
+				c.CohortName = *l.CohortName
+			}
+			cohortIndex[key] = c
+			cohorts = append(cohorts, c)
+		}
+		c.Learners++
+
+		if l.TotalQuestions > 0 {
+			l.Percent = roundPercent(float64(l.Score) * 100 / float64(l.TotalQuestions))
+			percents = append(percents, l.Percent)
+			c.percents = append(c.percents, l.Percent)
+			buckets[min(int(l.Percent)/10, 9)].Count++
+			if l.Percent < threshold {
+				below = append(below, l)
+			}
+		}
+		if servedAt != nil && l.SubmittedAt.After(*servedAt) {
+			s := l.SubmittedAt.Sub(*servedAt).Seconds()
+			seconds = append(seconds, s)
+			c.seconds = append(c.seconds, s)
+		}
+
+		// Answers are recorded per question id; submissions from the old free-text quizzes
+		// don't decode or don't match any question, and are left out of the question stats
+		var recorded map[string][]string
+		if json.Unmarshal(answers, &recorded) != nil {
+			continue
+		}
+		shown := current
+		if variant != nil {
+			shown = nil
+			if json.Unmarshal(variant, &shown) != nil {
+				continue
+			}
+		} else if !slices.ContainsFunc(current, func(q QuizQuestion) bool { _, ok := recorded[q.ID]; return ok }) {
+			continue
+		}
+
+		for _, q := range shown {
+			s, ok := questionIndex[q.ID]
+			if !ok {
+				s = &quizQuestionStats{ID: q.ID, Kind: q.Kind, Prompt: q.Prompt, Answers: []quizAnswerCount{}, answerIndex: map[string]int{}}
+				for _, option := range q.Options {
+					s.answerIndex[option] = len(s.Answers)
+					s.Answers = append(s.Answers, quizAnswerCount{Answer: option, Correct: containsString(q.AnswerKey, option)})
+				}
+				questionIndex[q.ID] = s
+				questions = append(questions, s)
+			}
+			s.Shown++
+			given := recorded[q.ID]
+			if len(given) > 0 {
+				s.Answered++
+			}
+			for _, g := range given {
+				s.count(q, g)
+			}
+			if q.graded() {
+				s.Graded++
+				if q.answeredCorrectly(given) {
+					s.Correct++
+				}
+			}
+		}
+	}
+
+	for _, s := range questions {
+		if s.Graded > 0 {
+			rate := roundPercent(float64(s.Correct) * 100 / float64(s.Graded))
+			s.CorrectRate = &rate
+		}
+		slices.SortStableFunc(s.Answers, func(a, b quizAnswerCount) int { return b.Count - a.Count })
+		if s.Kind == quizShortAnswer && len(s.Answers) > 20 {
+			s.Answers = s.Answers[:20]
+		}
+	}
+	// The questions that landed worst come first; free-response questions go last
+	rateOrder := func(s *quizQuestionStats) float64 {
+		if s.CorrectRate == nil {
+			return math.Inf(1)
+		}
+		return *s.CorrectRate
+	}
+	slices.SortStableFunc(questions, func(a, b *quizQuestionStats) int { return cmp.Compare(rateOrder(a), rateOrder(b)) })
+	for _, c := range cohorts {
+		if len(c.percents) > 0 {
+			avg := roundPercent(average(c.percents))
+			c.AveragePercent = &avg
+		}
+		c.TimeToSubmit = newQuizTimeStats(c.seconds)
+	}
+	slices.SortStableFunc(below, func(a, b quizLearnerScore) int { return cmp.Compare(a.Percent, b.Percent) })
+
+	resp := map[string]interface{}{
+		"quiz_id":            quizID,
+		"quiz_title":         quizTitle,
+		"lesson_id":          lessonID,
+		"lesson_title":       lessonTitle,
+		"cohort_id":          cohortID,
+		"threshold_percent":  threshold,
+		"learners":           learners,
+		"attempts":           attempts,
+		"average_percent":    nil,
+		"median_percent":     nil,
+		"score_distribution": buckets,
+		"time_to_submit":     newQuizTimeStats(seconds),
+		"questions":          questions,
+		"cohorts":            cohorts,
+		"below_threshold":    below,
+	}
+	if len(percents) > 0 {
+		resp["average_percent"] = roundPercent(average(percents))
+		resp["median_percent"] = percentile(percents, 50)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// Quiz question kinds
+const (
+	quizSingleChoice   = "single_choice"
+	quizMultipleChoice = "multiple_choice"
+	quizTrueFalse      = "true_false"
+	quizShortAnswer    = "short_answer"
+)
+
+var trueFalseOptions = []string{"True", "False"}
+
+// QuizQuestion is one question of a lesson quiz. AnswerKey holds the correct option(s), or
+// the accepted short answers; it never leaves the admin API. A short answer question with
+// no accepted answers is a free response: it is recorded but not scored.
+type QuizQuestion struct {
+	ID        string   `json:"id,omitempty"`
+	Kind      string   `json:"kind"`
+	Prompt    string   `json:"prompt"`
+	Options   []string `json:"options"`
+	AnswerKey []string `json:"answer_key"`
+}
+
+// PublicQuizQuestion is a question as participants see it, without the answer key
+type PublicQuizQuestion struct {
+	ID      string   `json:"id"`
+	Kind    string   `json:"kind"`
+	Prompt  string   `json:"prompt"`
+	Options []string `json:"options"`
+}
+
+func (q QuizQuestion) public() PublicQuizQuestion {
+	return PublicQuizQuestion{ID: q.ID, Kind: q.Kind, Prompt: q.Prompt, Options: q.Options}
+}
+
+// graded reports whether the question counts towards the score
+func (q QuizQuestion) graded() bool {
+	return len(q.AnswerKey) > 0
+}
+
+// normalizeQuizQuestions trims what an admin sent and checks each question makes sense for its kind
+func normalizeQuizQuestions(qs []QuizQuestion) ([]QuizQuestion, error) {
+	if len(qs) == 0 {
+		return nil, errors.New("a quiz needs at least one question")
+	}
+	out := make([]QuizQuestion, 0, len(qs))
+	for i, q := range qs {
+		n := i + 1
+		q.Prompt = strings.TrimSpace(q.Prompt)
+		if q.Prompt == "" {
+			return nil, fmt.Errorf("question %d has no prompt", n)
+		}
+		q.Options = trimNonEmpty(q.Options)
+		q.AnswerKey = trimNonEmpty(q.AnswerKey)
+
+		switch q.Kind {
+		case quizTrueFalse:
+			q.Options = trueFalseOptions
+			fallthrough
+		case quizSingleChoice, quizMultipleChoice:
+			if len(q.Options) < 2 {
+				return nil, fmt.Errorf("question %d needs at least two options", n)
+			}
+			if len(q.AnswerKey) == 0 {
+				return nil, fmt.Errorf("question %d has no correct answer", n)
+			}
+			if q.Kind != quizMultipleChoice && len(q.AnswerKey) != 1 {
+				return nil, fmt.Errorf("question %d must have exactly one correct answer", n)
+			}
+			for _, key := range q.AnswerKey {
+				if !containsString(q.Options, key) {
+					return nil, fmt.Errorf("question %d: correct answer %q is not one of its options", n, key)
+				}
+			}
+		case quizShortAnswer:
+			q.Options = []string{}
+		default:
+			return nil, fmt.Errorf("question %d: kind must be single_choice, multiple_choice, true_false or short_answer", n)
+		}
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+func trimNonEmpty(values []string) []string {
+	out := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Questions belong either to one quiz or to a question bank
+const (
+	ownerQuiz = "quiz_id"
+	ownerBank = "bank_id"
+)
+
+// loadQuizQuestions returns a quiz's own questions, answer keys included, in display order
+func loadQuizQuestions(ctx context.Context, quizID string) ([]QuizQuestion, error) {
+	return loadQuestions(ctx, ownerQuiz, quizID)
+}
+
+// loadQuestions returns the questions of a quiz or bank; owner is ownerQuiz or ownerBank
+func loadQuestions(ctx context.Context, owner, ownerID string) ([]QuizQuestion, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id::text, kind, prompt, options, answer_key
+		FROM public.quiz_questions WHERE `+owner+`::text = $1
+		ORDER BY sort_order, created_at
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []QuizQuestion{}
+	for rows.Next() {
+		var q QuizQuestion
+		if err := rows.Scan(&q.ID, &q.Kind, &q.Prompt, &q.Options, &q.AnswerKey); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// saveQuizQuestions makes a quiz's questions match qs
+func saveQuizQuestions(ctx context.Context, tx pgx.Tx, quizID string, qs []QuizQuestion) error {
+	return saveQuestions(ctx, tx, ownerQuiz, quizID, qs)
+}
+
+// saveQuestions makes a quiz's or bank's questions match qs. Questions that keep their id
+// are updated in place so existing submissions still line up with them.
+func saveQuestions(ctx context.Context, tx pgx.Tx, owner, ownerID string, qs []QuizQuestion) error {
+	keep := []string{}
+	for _, q := range qs {
+		if q.ID != "" {
+			keep = append(keep, q.ID)
+		}
+	}
+	_, err := tx.Exec(ctx, `DELETE FROM public.quiz_questions WHERE `+owner+`::text = $1 AND NOT (id::text = ANY($2))`, ownerID, keep)
+	if err != nil {
+		return err
+	}
+
+	for i, q := range qs {
+		options, _ := json.Marshal(q.Options)
+		key, _ := json.Marshal(q.AnswerKey)
+		tag, err := tx.Exec(ctx, `
+			UPDATE public.quiz_questions SET sort_order = $3, kind = $4, prompt = $5, options = $6, answer_key = $7
+			WHERE id::text = $1 AND `+owner+`::text = $2
+		`, q.ID, ownerID, i, q.Kind, q.Prompt, options, key)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO public.quiz_questions (`+owner+`, sort_order, kind, prompt, options, answer_key)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, ownerID, i, q.Kind, q.Prompt, options, key)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saveLessonQuiz creates or updates a lesson's quiz and its policy. A quiz with its own
+// questions has them replaced; one drawn from a bank keeps any old questions untouched.
+func saveLessonQuiz(ctx context.Context, lessonID string, l CurriculumLesson) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	p := l.QuizPolicy
+	var quizID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO public.quizzes (lesson_id, title, question, open_offset_minutes, duration_minutes,
+			max_attempts, attempt_scoring, late_minutes, late_penalty_percent, bank_id, draw_count)
+		VALUES ($1, $2, '', $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (lesson_id) DO UPDATE SET title = EXCLUDED.title,
+			open_offset_minutes = EXCLUDED.open_offset_minutes, duration_minutes = EXCLUDED.duration_minutes,
+			max_attempts = EXCLUDED.max_attempts, attempt_scoring = EXCLUDED.attempt_scoring,
+			late_minutes = EXCLUDED.late_minutes, late_penalty_percent = EXCLUDED.late_penalty_percent,
+			bank_id = EXCLUDED.bank_id, draw_count = EXCLUDED.draw_count
+		RETURNING id::text
+	`, lessonID, l.QuizTitle, p.OpenOffsetMinutes, p.DurationMinutes, p.MaxAttempts,
+		p.AttemptScoring, p.LateMinutes, p.LatePenaltyPercent, l.QuizBankID, l.QuizDrawCount).Scan(&quizID)
+	if err == nil && l.QuizBankID == nil {
+		err = saveQuizQuestions(ctx, tx, quizID, l.QuizQuestions)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	return err
+}
+
+// decodeQuizAnswer accepts a single answer ("B) Love") or several (["A", "C"])
+func decodeQuizAnswer(raw json.RawMessage) []string {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return trimNonEmpty([]string{one})
+	}
+	var many []string
+	if json.Unmarshal(raw, &many) == nil {
+		return trimNonEmpty(many)
+	}
+	return nil
+}
+
+// normalizeShortAnswer makes short answer comparison ignore case and spacing
+func normalizeShortAnswer(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// gradeQuiz scores answers (keyed by question id) against the answer keys. It returns the
+// number answered correctly, the number of graded questions, and the answers as recorded.
+func gradeQuiz(questions []QuizQuestion, answers map[string]json.RawMessage) (int, int, map[string][]string) {
+	score, graded := 0, 0
+	recorded := map[string][]string{}
+	for _, q := range questions {
+		given := decodeQuizAnswer(answers[q.ID])
+		if q.Kind != quizMultipleChoice && len(given) > 1 {
+			given = given[:1]
+		}
+		if len(given) > 0 {
+			recorded[q.ID] = given
+		}
+		if !q.graded() {
+			continue
+		}
+		graded++
+		if q.answeredCorrectly(given) {
+			score++
+		}
+	}
+	return score, graded, recorded
+}
+
+// answeredCorrectly checks a recorded answer against a graded question's answer key
+func (q QuizQuestion) answeredCorrectly(given []string) bool {
+	switch q.Kind {
+	case quizShortAnswer:
+		if len(given) == 1 {
+			for _, accepted := range q.AnswerKey {
+				if normalizeShortAnswer(given[0]) == normalizeShortAnswer(accepted) {
+					return true
+				}
+			}
+		}
+		return false
+	case quizMultipleChoice:
+		// Every correct option and nothing else
+		correct := len(given) == len(q.AnswerKey)
+		for _, g := range given {
+			correct = correct && containsString(q.AnswerKey, g)
+		}
+		for _, key := range q.AnswerKey {
+			correct = correct && containsString(given, key)
+		}
+		return correct
+	default:
+		return len(given) == 1 && given[0] == q.AnswerKey[0]
+	}
+}
+
+// --- Legacy quiz text ---
+
+// legacyOptionPattern matches "A) ..." / "b. ..." option lines of the old free-text quiz format
+var legacyOptionPattern = regexp.MustCompile(`^[A-Ea-e][.)]\s`)
+
+// defaultQuizPrompt is what the LMS asked when a quiz had no question text
+const defaultQuizPrompt = "What is the main expectation for today's class?"
+
+// parseLegacyQuiz reads the old quizzes.question format: blank-line separated blocks, each a
+// prompt optionally followed by "A) ..." options with the correct one marked by a trailing *
+func parseLegacyQuiz(text string) []QuizQuestion {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return []QuizQuestion{{Kind: quizShortAnswer, Prompt: defaultQuizPrompt, Options: []string{}, AnswerKey: []string{}}}
+	}
+
+	var questions []QuizQuestion
+	for _, block := range regexp.MustCompile(`\n\s*\n`).Split(text, -1) {
+		var lines []string
+		for _, l := range strings.Split(block, "\n") {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		first := -1
+		for i, l := range lines {
+			if legacyOptionPattern.MatchString(l) {
+				first = i
+				break
+			}
+		}
+		if first <= 0 {
+			questions = append(questions, QuizQuestion{Kind: quizShortAnswer, Prompt: strings.Join(lines, " "), Options: []string{}, AnswerKey: []string{}})
+			continue
+		}
+
+		q := QuizQuestion{Kind: quizSingleChoice, Prompt: strings.Join(lines[:first], " "), AnswerKey: []string{}}
+		for _, opt := range lines[first:] {
+			if strings.HasSuffix(opt, "*") {
+				opt = strings.TrimSpace(strings.TrimSuffix(opt, "*"))
+				q.AnswerKey = []string{opt}
+			}
+			q.Options = append(q.Options, opt)
+		}
+		if len(q.AnswerKey) == 0 {
+			// Options without a marked answer were never scored; keep them unscored
+			q.Kind, q.Prompt = quizShortAnswer, strings.Join(lines, " ")
+			q.Options = []string{}
+		}
+		questions = append(questions, q)
+	}
+	return questions
+}
+
+// BackfillQuizQuestions converts quizzes written in the old free-text format into quiz_questions rows
+func BackfillQuizQuestions(ctx context.Context) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT q.id::text, COALESCE(q.question, '') FROM public.quizzes q
+		WHERE q.bank_id IS NULL AND NOT EXISTS (SELECT 1 FROM public.quiz_questions qq WHERE qq.quiz_id = q.id)
+	`)
+	if err != nil {
+		fmt.Printf("⚠️  Quiz backfill failed: %v\n", err)
+		return
+	}
+	type pending struct{ id, text string }
+	var quizzes []pending
+	for rows.Next() {
+		var p pending
+		if rows.Scan(&p.id, &p.text) == nil {
+			quizzes = append(quizzes, p)
+		}
+	}
+	rows.Close()
+
+	converted := 0
+	for _, p := range quizzes {
+		tx, err := db.Pool.Begin(ctx)
+		if err != nil {
+			continue
+		}
+		if saveQuizQuestions(ctx, tx, p.id, parseLegacyQuiz(p.text)) == nil && tx.Commit(ctx) == nil {
+			converted++
+		}
+		tx.Rollback(ctx)
+	}
+	if converted > 0 {
+		fmt.Printf("✅ Converted %d quizzes to structured questions\n", converted)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeShortAnswer(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"Love", "love"},
+		{"  Love  ", "love"},
+		{"Patient   and\tKind", "patient and kind"},
+		{"\n", ""},
+	} {
+		if got := normalizeShortAnswer(tc.in); got != tc.want {
+			t.Errorf("normalizeShortAnswer(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestAnsweredCorrectly(t *testing.T) {
+	single := QuizQuestion{Kind: quizSingleChoice, Options: []string{"A", "B", "C"}, AnswerKey: []string{"B"}}
+	multiple := QuizQuestion{Kind: quizMultipleChoice, Options: []string{"A", "B", "C"}, AnswerKey: []string{"A", "C"}}
+	short := QuizQuestion{Kind: quizShortAnswer, AnswerKey: []string{"Love", "Agape love"}}
+
+	for _, tc := range []struct {
+		name  string
+		q     QuizQuestion
+		given []string
+		want  bool
+	}{
+		{"single correct", single, []string{"B"}, true},
+		{"single wrong", single, []string{"A"}, false},
+		{"single blank", single, nil, false},
+		{"single is case sensitive", single, []string{"b"}, false},
+		{"multiple exact", multiple, []string{"C", "A"}, true},
+		{"multiple missing one", multiple, []string{"A"}, false},
+		{"multiple extra option", multiple, []string{"A", "B", "C"}, false},
+		{"multiple repeated option", multiple, []string{"A", "A"}, false},
+		{"short matches ignoring case", short, []string{"LOVE"}, true},
+		{"short matches ignoring spacing", short, []string{"  agape   love "}, true},
+		{"short wrong", short, []string{"hope"}, false},
+		{"short several answers", short, []string{"love", "agape love"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.q.answeredCorrectly(tc.given); got != tc.want {
+				t.Fatalf("answeredCorrectly(%q) = %v, want %v", tc.given, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGradeQuiz(t *testing.T) {
+	questions := []QuizQuestion{
+		{ID: "q1", Kind: quizSingleChoice, Options: []string{"A", "B"}, AnswerKey: []string{"A"}},
+		{ID: "q2", Kind: quizMultipleChoice, Options: []string{"A", "B", "C"}, AnswerKey: []string{"B", "C"}},
+		{ID: "q3", Kind: quizShortAnswer, AnswerKey: []string{"grace"}},
+		{ID: "q4", Kind: quizShortAnswer, AnswerKey: []string{}},
+	}
+	answers := map[string]json.RawMessage{
+		"q1": json.RawMessage(`["A", "B"]`),
+		"q2": json.RawMessage(`["C", "B"]`),
+		"q3": json.RawMessage(`" Grace "`),
+		"q4": json.RawMessage(`"anything goes"`),
+	}
+
+	score, graded, recorded := gradeQuiz(questions, answers)
+	if score != 3 || graded != 3 {
+		t.Fatalf("got %d of %d, want 3 of 3", score, graded)
+	}
+	// A single choice question keeps only the first answer given
+	if got := recorded["q1"]; len(got) != 1 || got[0] != "A" {
+		t.Fatalf("recorded q1 = %q, want [A]", got)
+	}
+	if got := recorded["q4"]; len(got) != 1 || got[0] != "anything goes" {
+		t.Fatalf("recorded free response = %q, want it kept", got)
+	}
+
+	score, graded, recorded = gradeQuiz(questions, nil)
+	if score != 0 || graded != 3 || len(recorded) != 0 {
+		t.Fatalf("unanswered quiz got %d of %d with %d recorded, want 0 of 3 with none", score, graded, len(recorded))
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/asejik/soulmate-reg/server/db"
+)
+
+// quizVariantSeed identifies one learner's attempt; the same attempt always draws the same variant
+func quizVariantSeed(quizID, userID string, attempt int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%d", quizID, userID, attempt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// seededShuffle is a Fisher-Yates shuffle driven by PCG, so a seed gives the same order on
+// every server and Go version
+func seededShuffle(rng *rand.PCG, n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, int(rng.Uint64()%uint64(i+1)))
+	}
+}
+
+// assembleQuizVariant draws drawCount questions from a bank (or all of a quiz's own
+// questions) in a seeded order and shuffles the options of choice questions. True/false keeps its order. Answer keys hold option text,
+// so they stay valid after shuffling.
+func assembleQuizVariant(seed string, bank []QuizQuestion, drawCount int) []QuizQuestion {
+	raw, _ := hex.DecodeString(seed)
+	rng := rand.NewPCG(binary.BigEndian.Uint64(raw[:8]), binary.BigEndian.Uint64(raw[8:16]))
+
+	questions := append([]QuizQuestion(nil), bank...)
+	seededShuffle(rng, len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	if drawCount < len(questions) {
+		questions = questions[:drawCount]
+	}
+
+	for i, q := range questions {
+		if q.Kind != quizSingleChoice && q.Kind != quizMultipleChoice {
+			continue
+		}
+		options := append([]string(nil), q.Options...)
+		seededShuffle(rng, len(options), func(a, b int) { options[a], options[b] = options[b], options[a] })
+		questions[i].Options = options
+	}
+	return questions
+}
+
+// quizVariant returns the questions a learner gets for an attempt, recording them the first
+// time so grading and review see exactly what was shown even if the quiz or bank is edited
+// later. Quizzes without a bank shuffle all of their own questions the same way.
+func quizVariant(ctx context.Context, quiz learnerQuiz, userID string, attempt int) ([]QuizQuestion, error) {
+	var stored json.RawMessage
+	err := db.Pool.QueryRow(ctx, `
+		SELECT questions FROM public.quiz_variants WHERE quiz_id = $1 AND user_id = $2 AND attempt = $3
+	`, quiz.ID, userID, attempt).Scan(&stored)
+	if err == nil {
+		var questions []QuizQuestion
+		err = json.Unmarshal(stored, &questions)
+		return questions, err
+	}
+
+	seed := quizVariantSeed(quiz.ID, userID, attempt)
+	var questions []QuizQuestion
+	if quiz.BankID != nil {
+		var bank []QuizQuestion
+		if bank, err = loadQuestions(ctx, ownerBank, *quiz.BankID); err == nil {
+			questions = assembleQuizVariant(seed, bank, quiz.DrawCount)
+		}
+	} else {
+		var own []QuizQuestion
+		if own, err = loadQuizQuestions(ctx, quiz.ID); err == nil {
+			questions = assembleQuizVariant(seed, own, len(own))
+		}
+	}
+	if err != nil || len(questions) == 0 {
+		return questions, err
+	}
+
+	// A concurrent request may have recorded the attempt first; its variant wins
+	snapshot, _ := json.Marshal(questions)
+	_, err = db.Pool.Exec(ctx, `
+		INSERT INTO public.quiz_variants (quiz_id, user_id, attempt, seed, questions)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (quiz_id, user_id, attempt) DO NOTHING
+	`, quiz.ID, userID, attempt, seed, snapshot)
+	if err == nil {
+		err = db.Pool.QueryRow(ctx, `
+			SELECT questions FROM public.quiz_variants WHERE quiz_id = $1 AND user_id = $2 AND attempt = $3
+		`, quiz.ID, userID, attempt).Scan(&stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+	questions = nil
+	err = json.Unmarshal(stored, &questions)
+	return questions, err
+}
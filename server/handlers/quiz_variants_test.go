@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// variantBank is a bank of ten questions of every kind
+func variantBank() []QuizQuestion {
+	bank := make([]QuizQuestion, 10)
+	for i := range bank {
+		id := fmt.Sprintf("q%d", i)
+		switch i % 4 {
+		case 0:
+			bank[i] = QuizQuestion{ID: id, Kind: quizSingleChoice, Options: []string{"A", "B", "C", "D"}, AnswerKey: []string{"C"}}
+		case 1:
+			bank[i] = QuizQuestion{ID: id, Kind: quizMultipleChoice, Options: []string{"A", "B", "C", "D"}, AnswerKey: []string{"A", "D"}}
+		case 2:
+			bank[i] = QuizQuestion{ID: id, Kind: quizTrueFalse, Options: trueFalseOptions, AnswerKey: []string{"True"}}
+		default:
+			bank[i] = QuizQuestion{ID: id, Kind: quizShortAnswer, Options: []string{}, AnswerKey: []string{"grace"}}
+		}
+	}
+	return bank
+}
+
+func TestAssembleQuizVariantIsDeterministic(t *testing.T) {
+	seed := quizVariantSeed("quiz-1", "user-1", 1)
+	first := assembleQuizVariant(seed, variantBank(), 6)
+	if again := assembleQuizVariant(seed, variantBank(), 6); !reflect.DeepEqual(first, again) {
+		t.Fatalf("same seed gave different variants:\n%+v\n%+v", first, again)
+	}
+
+	// Another attempt or learner gets another draw
+	for _, other := range []string{quizVariantSeed("quiz-1", "user-1", 2), quizVariantSeed("quiz-1", "user-2", 1)} {
+		if reflect.DeepEqual(first, assembleQuizVariant(other, variantBank(), 6)) {
+			t.Fatalf("seed %s gave the same variant as %s", other, seed)
+		}
+	}
+}
+
+func TestAssembleQuizVariant(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		drawCount int
+		want      int
+	}{
+		{"draws a subset", 4, 4},
+		{"draws the whole bank", 10, 10},
+		{"draw count above the bank size", 25, 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			bank := variantBank()
+			byID := map[string]QuizQuestion{}
+			for _, q := range bank {
+				byID[q.ID] = q
+			}
+
+			got := assembleQuizVariant(quizVariantSeed("quiz-1", "user-1", 1), bank, tc.drawCount)
+			if len(got) != tc.want {
+				t.Fatalf("drew %d questions, want %d", len(got), tc.want)
+			}
+			seen := map[string]bool{}
+			for _, q := range got {
+				orig, ok := byID[q.ID]
+				if !ok || seen[q.ID] {
+					t.Fatalf("question %q is not in the bank or was drawn twice", q.ID)
+				}
+				seen[q.ID] = true
+
+				if q.Kind == quizTrueFalse || q.Kind == quizShortAnswer {
+					if !reflect.DeepEqual(q.Options, orig.Options) {
+						t.Fatalf("%s options were reordered: %q", q.Kind, q.Options)
+					}
+				}
+				sorted, want := slices.Clone(q.Options), slices.Clone(orig.Options)
+				slices.Sort(sorted)
+				slices.Sort(want)
+				if !slices.Equal(sorted, want) {
+					t.Fatalf("question %q options %q are not a permutation of %q", q.ID, q.Options, orig.Options)
+				}
+				for _, key := range q.AnswerKey {
+					if q.Kind != quizShortAnswer && !containsString(q.Options, key) {
+						t.Fatalf("question %q answer %q is missing from its options", q.ID, key)
+					}
+				}
+			}
+			if !reflect.DeepEqual(bank, variantBank()) {
+				t.Fatal("assembling a variant changed the bank")
+			}
+		})
+	}
+}
@@ -1,12 +1,10 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
@@ -26,6 +24,8 @@ type RegistrationRequest struct {
 	ChurchName         string `json:"church_name"`
 	InstagramHandle    string `json:"instagram_handle"`
 	RelationshipStatus string `json:"relationship_status"`
+
+	WhatsAppE164 string `json:"-"` // set by validate()
 }
 
 // Response Struct
@@ -59,6 +59,10 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if errs := req.validate(); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -72,19 +76,42 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 	defer tx.Rollback(ctx) // Rollback if not committed
 
-	// 3. Find the first available Clan and LOCK it (FOR UPDATE)
+	// Reject anyone already registered in either program (by email or normalized phone)
+	conflict, err := findRegistrationConflict(ctx, tx, req.Email, req.WhatsAppE164, false, "")
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		log.Printf("Duplicate Check Error: %v", err)
+		return
+	}
+	if conflict != nil {
+		writeRegistrationConflict(w, conflict)
+		return
+	}
+
+	// 3. Find the cohort currently accepting registrations
+	program, err := mustProgram(ctx, programSoulmate)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		log.Printf("Program Lookup Error: %v", err)
+		return
+	}
+	cohort, err := openCohort(ctx, tx, program.ModuleName)
+	if err != nil {
+		json.NewEncoder(w).Encode(RegistrationResponse{
+			Success: false,
+			Message: "Registration is currently closed. Please join the waitlist for the next cohort.",
+		})
+		return
+	}
+
+	// 4. Pick an open Clan with the cohort's strategy and LOCK it (FOR UPDATE)
 	// This prevents two people from grabbing the 20th slot at the same time.
-	var clanID int64
-	var clanName, whatsappLink string
-
-	err = tx.QueryRow(ctx, `
-		SELECT id, name, whatsapp_link
-		FROM clans
-		WHERE current_count < max_capacity
-		ORDER BY id ASC
-		LIMIT 1
-		FOR UPDATE
-	`).Scan(&clanID, &clanName, &whatsappLink)
+	clan, err := clanAssignerFor(ctx, tx, cohort.ID).pickClan(ctx, tx, cohort.ID, clanCandidate{
+		Gender:   req.Gender,
+		Country:  req.Country,
+		State:    req.State,
+		AgeGroup: req.AgeGroup,
+	})
 
 	if err != nil {
 		// If no rows found, it means ALL clans are full
@@ -94,18 +121,19 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	clanID, clanName, whatsappLink := clan.ID, clan.Name, clan.WhatsAppLink
 
-	// 4. Register the Participant
+	// 5. Register the Participant
 	_, err = tx.Exec(ctx, `
 		INSERT INTO participants (
 			full_name, email, whatsapp_number, gender, country, state,
-			age_group, religion, church_name, instagram_handle, relationship_status, clan_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			age_group, religion, church_name, instagram_handle, relationship_status, clan_id, cohort_id, whatsapp_e164
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`, req.FullName, req.Email, req.WhatsAppNumber, req.Gender, req.Country, req.State,
-		req.AgeGroup, req.Religion, req.ChurchName, req.InstagramHandle, req.RelationshipStatus, clanID)
+		req.AgeGroup, req.Religion, req.ChurchName, req.InstagramHandle, req.RelationshipStatus, clanID, cohort.ID, req.WhatsAppE164)
 
 	if err != nil {
-		// Handle duplicate email/phone error
+		// A concurrent registration can still trip the unique constraints
 		log.Printf("Insert Error: %v", err)
 		json.NewEncoder(w).Encode(RegistrationResponse{
 			Success: false,
@@ -114,56 +142,48 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 5. Increment the Clan Count
+	// 6. Increment the Clan Count
 	_, err = tx.Exec(ctx, `UPDATE clans SET current_count = current_count + 1 WHERE id = $1`, clanID)
 	if err != nil {
 		http.Error(w, "Failed to update clan count", http.StatusInternalServerError)
 		return
 	}
 
-	// 6. Commit Transaction
-	if err := tx.Commit(ctx); err != nil {
-		http.Error(w, "Transaction commit failed", http.StatusInternalServerError)
+	// Registering directly settles any waiting waitlist entry, so the promoter won't hold a seat for it
+	_, err = tx.Exec(ctx, `
+		UPDATE public.waitlist SET status = 'promoted'
+		WHERE status = 'waiting' AND (lower(email) = lower($1) OR (whatsapp_e164 = $2 AND $2 <> ''))
+	`, req.Email, req.WhatsAppE164)
+	if err != nil {
+		http.Error(w, "Failed to update waitlist", http.StatusInternalServerError)
 		return
 	}
 
-	// 7. Send Confirmation Email (Async)
-	// We run this in a goroutine so it doesn't block the API response time
-	go services.SendConfirmationEmail(services.EmailData{
+	// 7. Queue the confirmation email and Google Sheets backup with the registration,
+	// so they are delivered (with retries) exactly when the registration commits
+	if err := enqueueOutbox(ctx, tx, outboxConfirmationEmail, services.EmailData{
 		Name:         req.FullName,
 		Email:        req.Email,
 		ClanName:     clanName,
 		WhatsAppLink: whatsappLink,
-	})
+		CohortName:   cohort.Name,
+	}); err != nil {
+		http.Error(w, "Failed to queue confirmation email", http.StatusInternalServerError)
+		return
+	}
+	if err := enqueueOutbox(ctx, tx, outboxParticipantSheet, participantSheetPayload(req, clanName)); err != nil {
+		http.Error(w, "Failed to queue sheet sync", http.StatusInternalServerError)
+		return
+	}
 
-	// --- NEW: Sync to Google Sheets (Async) ---
-	go func() {
-		webhookURL := os.Getenv("GOOGLE_SHEETS_WEBHOOK_URL")
-		if webhookURL == "" {
-			return
-		}
-
-		payload := GoogleSheetPayload{
-			FullName:           req.FullName,
-			Email:              req.Email,
-			WhatsAppNumber:     req.WhatsAppNumber,
-			Gender:             req.Gender,
-			Country:            req.Country,
-			State:              req.State,
-			AgeGroup:           req.AgeGroup,
-			Religion:           req.Religion,
-			ChurchName:         req.ChurchName,
-			InstagramHandle:    req.InstagramHandle,
-			RelationshipStatus: req.RelationshipStatus,
-			ClanName:           clanName,
-		}
-
-		jsonData, _ := json.Marshal(payload)
-		http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
-	}()
-	// ------------------------------------------
-
-	// 8. Success Response
+	// 8. Commit Transaction
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "Transaction commit failed", http.StatusInternalServerError)
+		return
+	}
+	notifyOutbox()
+
+	// 9. Success Response
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(RegistrationResponse{
 		Success:      true,
@@ -172,3 +192,21 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		WhatsAppLink: whatsappLink,
 	})
 }
+
+// participantSheetPayload is the RFASM row sent to the Google Sheets backup
+func participantSheetPayload(req RegistrationRequest, clanName string) GoogleSheetPayload {
+	return GoogleSheetPayload{
+		FullName:           req.FullName,
+		Email:              req.Email,
+		WhatsAppNumber:     req.WhatsAppNumber,
+		Gender:             req.Gender,
+		Country:            req.Country,
+		State:              req.State,
+		AgeGroup:           req.AgeGroup,
+		Religion:           req.Religion,
+		ChurchName:         req.ChurchName,
+		InstagramHandle:    req.InstagramHandle,
+		RelationshipStatus: req.RelationshipStatus,
+		ClanName:           clanName,
+	}
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// Staff roles, stored in public.user_roles
+const (
+	roleSuperAdmin   = "super_admin"
+	roleProgramAdmin = "program_admin"
+	roleFacilitator  = "facilitator"
+	roleClanLeader   = "clan_leader"
+	roleGrader       = "grader"
+)
+
+// Permissions declared by admin routes in main.go
+const (
+	PermUsersView         = "users.view"
+	PermUsersManage       = "users.manage"
+	PermCurriculumView    = "curriculum.view"
+	PermCurriculumManage  = "curriculum.manage"
+	PermSubmissionsView   = "submissions.view"
+	PermSubmissionsGrade  = "submissions.grade"
+	PermReviewsView       = "reviews.view"
+	PermCommunityModerate = "community.moderate"
+	PermProgressReset     = "progress.reset"
+	PermQAAnswer          = "qa.answer"
+	PermGivingView        = "giving.view"
+	PermCohortsManage     = "cohorts.manage"
+	PermProgramsManage    = "programs.manage"
+	PermOutboxManage      = "outbox.manage"
+	PermEmailsPreview     = "emails.preview"
+	PermRolesManage       = "roles.manage"
+	PermAPIKeysManage     = "api_keys.manage"
+	PermAuditView         = "audit.view"
+	PermPrivacyManage     = "privacy.manage"
+)
+
+// rolePermissions lists what each role may do. super_admin may do everything.
+var rolePermissions = map[string][]string{
+	roleSuperAdmin: nil,
+	roleProgramAdmin: {
+		PermUsersView, PermUsersManage, PermCurriculumView, PermCurriculumManage,
+		PermSubmissionsView, PermSubmissionsGrade, PermReviewsView, PermCommunityModerate,
+		PermProgressReset, PermQAAnswer, PermGivingView, PermCohortsManage, PermProgramsManage,
+		PermOutboxManage, PermEmailsPreview,
+	},
+	roleFacilitator: {
+		PermUsersView, PermCurriculumView, PermSubmissionsView, PermReviewsView,
+		PermCommunityModerate, PermQAAnswer,
+	},
+	roleClanLeader: {
+		PermUsersView, PermCurriculumView, PermSubmissionsView, PermQAAnswer,
+	},
+	roleGrader: {
+		PermCurriculumView, PermSubmissionsView, PermSubmissionsGrade,
+	},
+}
+
+const rolesKey contextKey = "roles"
+
+// userRoles is the set of roles held by the caller
+type userRoles []string
+
+func (roles userRoles) can(perm string) bool {
+	for _, role := range roles {
+		if role == roleSuperAdmin {
+			return true
+		}
+		for _, p := range rolePermissions[role] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isStaff reports whether the caller holds any role at all
+func (roles userRoles) isStaff() bool {
+	return len(roles) > 0
+}
+
+func (roles userRoles) permissions() []string {
+	seen := map[string]bool{}
+	perms := []string{}
+	for _, role := range roles {
+		list := rolePermissions[role]
+		if role == roleSuperAdmin {
+			list = allPermissions()
+		}
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, p)
+			}
+		}
+	}
+	return perms
+}
+
+func allPermissions() []string {
+	return []string{
+		PermUsersView, PermUsersManage, PermCurriculumView, PermCurriculumManage,
+		PermSubmissionsView, PermSubmissionsGrade, PermReviewsView, PermCommunityModerate,
+		PermProgressReset, PermQAAnswer, PermGivingView, PermCohortsManage, PermProgramsManage,
+		PermOutboxManage, PermEmailsPreview, PermRolesManage, PermAPIKeysManage, PermAuditView,
+		PermPrivacyManage,
+	}
+}
+
+// rolesFrom returns the roles LoadRoles stored on the request context
+func rolesFrom(ctx context.Context) userRoles {
+	roles, _ := ctx.Value(rolesKey).(userRoles)
+	return roles
+}
+
+// LoadRoles looks up the authenticated caller's roles once per request. It must run after LMSAuth.
+// A failed lookup leaves the caller with no roles, so admin routes fail closed.
+func LoadRoles(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(userIDKey).(string)
+		roles := userRoles{}
+		rows, err := db.Pool.Query(r.Context(), "SELECT role FROM public.user_roles WHERE user_id::text = $1", userID)
+		if err == nil {
+			for rows.Next() {
+				var role string
+				if rows.Scan(&role) == nil {
+					roles = append(roles, role)
+				}
+			}
+			rows.Close()
+		} else {
+			log.Printf("[Roles] Failed to load roles for %s: %v", userID, err)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rolesKey, roles)))
+	})
+}
+
+// RequirePermission rejects callers whose roles (or API key scopes) don't grant perm
+func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !callerCan(r.Context(), perm) {
+				http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAnyPermission lets a caller through with any one of perms; the handler
+// narrows what they see to the permissions they actually hold
+func RequireAnyPermission(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, perm := range perms {
+				if callerCan(r.Context(), perm) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
+		})
+	}
+}
+
+// callerCan reports whether the caller's roles or API key grant perm
+func callerCan(ctx context.Context, perm string) bool {
+	return rolesFrom(ctx).can(perm) || apiKeyFrom(ctx).can(perm)
+}
+
+// GetMyRoles tells the frontend which admin screens to show
+func GetMyRoles(w http.ResponseWriter, r *http.Request) {
+	roles := rolesFrom(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"roles":       roles,
+		"permissions": roles.permissions(),
+	})
+}
+
+// --- Admin: Role management ---
+
+type RoleAssignment struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	GrantedBy string    `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetAdminRoles lists every role assignment along with the roles that can be granted
+func GetAdminRoles(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Pool.Query(r.Context(), `
+		SELECT ur.user_id::text, COALESCE(u.email, ''), ur.role, COALESCE(g.email, ''), ur.created_at
+		FROM public.user_roles ur
+		LEFT JOIN auth.users u ON u.id = ur.user_id
+		LEFT JOIN auth.users g ON g.id = ur.granted_by
+		ORDER BY u.email, ur.role
+	`)
+	if err != nil {
+		http.Error(w, "Failed to fetch roles", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	assignments := []RoleAssignment{}
+	for rows.Next() {
+		var a RoleAssignment
+		if err := rows.Scan(&a.UserID, &a.Email, &a.Role, &a.GrantedBy, &a.CreatedAt); err == nil {
+			assignments = append(assignments, a)
+		}
+	}
+
+	available := map[string][]string{}
+	for role := range rolePermissions {
+		available[role] = userRoles{role}.permissions()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"assignments": assignments,
+		"roles":       available,
+	})
+}
+
+// GrantAdminRole gives a role to an existing account, identified by user_id or email
+func GrantAdminRole(w http.ResponseWriter, r *http.Request) {
+	granterID := r.Context().Value(userIDKey).(string)
+
+	var req struct {
+		UserID string `json:"user_id"`
+		Email  string `json:"email"`
+		Role   string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := rolePermissions[req.Role]; !ok {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+
+	userID := req.UserID
+	if userID == "" {
+		err := db.Pool.QueryRow(r.Context(), "SELECT id::text FROM auth.users WHERE lower(email) = lower($1)", strings.TrimSpace(req.Email)).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "No account with that email. They must sign up before they can be given a role.", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		INSERT INTO public.user_roles AS ur (user_id, role, granted_by) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, role) DO NOTHING
+		RETURNING to_jsonb(ur)
+	`, userID, req.Role, granterID).Scan(&after)
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditRoleGrant, TargetType: "user", TargetID: userID, After: after})
+	} else if errors.Is(err, pgx.ErrNoRows) {
+		err = nil // already held; nothing changed
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, "Failed to grant role", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role granted", "user_id": userID, "role": req.Role})
+}
+
+// RevokeAdminRole removes a role (?user_id=&role=). The last super admin can't be removed.
+func RevokeAdminRole(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	role := r.URL.Query().Get("role")
+	if userID == "" || role == "" {
+		http.Error(w, "user_id and role are required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	if role == roleSuperAdmin {
+		// Lock the super admin rows so two concurrent revokes can't remove the last two
+		var remaining int
+		err = tx.QueryRow(r.Context(), `
+			SELECT COUNT(*) FROM (SELECT 1 FROM public.user_roles WHERE role = 'super_admin' AND user_id::text <> $1 FOR UPDATE) s
+		`, userID).Scan(&remaining)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if remaining == 0 {
+			http.Error(w, "Cannot revoke the last super admin", http.StatusConflict)
+			return
+		}
+	}
+
+	var before json.RawMessage
+	err = tx.QueryRow(r.Context(), "DELETE FROM public.user_roles ur WHERE user_id::text = $1 AND role = $2 RETURNING to_jsonb(ur)", userID, role).Scan(&before)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Role assignment not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = recordAudit(r, tx, auditEntry{Action: auditRoleRevoke, TargetType: "user", TargetID: userID, Before: before})
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		http.Error(w, "Failed to revoke role", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Role revoked"})
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// Kinds of trashed item
+const (
+	trashUser    = "user"
+	trashModule  = "module"
+	trashLesson  = "lesson"
+	trashComment = "comment"
+)
+
+// trashPermissions is what a caller needs to see and restore each kind of item,
+// matching the permission that let them delete it
+var trashPermissions = map[string]string{
+	trashUser:    PermUsersManage,
+	trashModule:  PermCurriculumManage,
+	trashLesson:  PermCurriculumManage,
+	trashComment: PermCommunityModerate,
+}
+
+// trashRetention is how long deleted items can be restored; TRASH_RETENTION_DAYS=0 never purges
+func trashRetention() time.Duration {
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour
+		}
+	}
+	return 30 * 24 * time.Hour
+}
+
+// purgeableLessons selects lessons that are past retention themselves or belong to a module that is
+const purgeableLessons = `SELECT id FROM public.lessons WHERE deleted_at < $1 OR module_id IN (SELECT id FROM public.modules WHERE deleted_at < $1)`
+
+// purgeTrash permanently removes items deleted longer ago than the retention period,
+// along with the progress, submissions, comments and quizzes of purged lessons
+func purgeTrash(ctx context.Context) (int64, error) {
+	retention := trashRetention()
+	if retention == 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-retention)
+
+	programs, err := loadPrograms(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Dependents first, since the lesson foreign keys may not cascade
+	for _, stmt := range []string{
+		`DELETE FROM public.quiz_submissions WHERE quiz_id IN (SELECT id FROM public.quizzes WHERE lesson_id IN (` + purgeableLessons + `))`,
+		`DELETE FROM public.quizzes WHERE lesson_id IN (` + purgeableLessons + `)`,
+		`DELETE FROM public.lesson_progress WHERE lesson_id IN (` + purgeableLessons + `)`,
+		`DELETE FROM public.assignment_submissions WHERE lesson_id IN (` + purgeableLessons + `)`,
+		`DELETE FROM public.lesson_schedules WHERE lesson_id IN (` + purgeableLessons + `)`,
+		`DELETE FROM public.lesson_comments WHERE lesson_id IN (` + purgeableLessons + `)`,
+	} {
+		if _, err := tx.Exec(ctx, stmt, cutoff); err != nil {
+			return 0, err
+		}
+	}
+
+	var purged int64
+	stmts := []string{
+		`DELETE FROM public.lesson_comments WHERE deleted_at < $1`,
+		`DELETE FROM public.lessons WHERE id IN (` + purgeableLessons + `)`,
+		`DELETE FROM public.modules WHERE deleted_at < $1`,
+	}
+	seen := map[string]bool{}
+	for _, p := range programs {
+		if !seen[p.RegistrationTable] {
+			seen[p.RegistrationTable] = true
+			stmts = append(stmts, "DELETE FROM "+p.registrationIdent()+" WHERE deleted_at < $1")
+		}
+	}
+	for _, stmt := range stmts {
+		tag, err := tx.Exec(ctx, stmt, cutoff)
+		if err != nil {
+			return 0, err
+		}
+		purged += tag.RowsAffected()
+	}
+	return purged, tx.Commit(ctx)
+}
+
+// StartTrashPurge empties expired items from the trash every interval until ctx is cancelled
+func StartTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := purgeTrash(ctx); err != nil {
+			log.Printf("[Trash] Purge failed: %v", err)
+		} else if n > 0 {
+			log.Printf("[Trash] Purged %d items past retention", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// --- Admin: Trash ---
+
+type TrashItem struct {
+	Type      string     `json:"type"`
+	ID        string     `json:"id"`
+	Source    string     `json:"source,omitempty"` // program slug, for users
+	Label     string     `json:"label"`
+	Detail    string     `json:"detail"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"` // nil when the trash is never purged
+}
+
+// queryTrash runs a query selecting (id, label, detail, deleted_at) for one kind of item
+func queryTrash(ctx context.Context, kind, source, sql string) ([]TrashItem, error) {
+	rows, err := db.Pool.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []TrashItem
+	for rows.Next() {
+		item := TrashItem{Type: kind, Source: source}
+		if err := rows.Scan(&item.ID, &item.Label, &item.Detail, &item.DeletedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetAdminTrash lists deleted items the caller may restore, newest first. ?type= narrows
+// it to users, modules, lessons or comments.
+func GetAdminTrash(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("type")
+	if _, ok := trashPermissions[kind]; kind != "" && !ok {
+		http.Error(w, "type must be user, module, lesson or comment", http.StatusBadRequest)
+		return
+	}
+	wants := func(k string) bool {
+		return (kind == "" || kind == k) && callerCan(r.Context(), trashPermissions[k])
+	}
+
+	items := []TrashItem{}
+	add := func(found []TrashItem, err error) error {
+		items = append(items, found...)
+		return err
+	}
+
+	var err error
+	if wants(trashUser) {
+		var programs []Program
+		programs, err = loadPrograms(r.Context())
+		for _, p := range programs {
+			if err != nil {
+				break
+			}
+			err = add(queryTrash(r.Context(), trashUser, p.Slug, `
+				SELECT t.id::text, COALESCE(to_jsonb(t)->>'full_name', t.email), t.email, t.deleted_at
+				FROM `+p.registrationIdent()+` t WHERE t.deleted_at IS NOT NULL
+			`))
+		}
+	}
+	if err == nil && wants(trashModule) {
+		err = add(queryTrash(r.Context(), trashModule, "", `
+			SELECT id::text, title, program_name, deleted_at FROM public.modules WHERE deleted_at IS NOT NULL
+		`))
+	}
+	if err == nil && wants(trashLesson) {
+		err = add(queryTrash(r.Context(), trashLesson, "", `
+			SELECT l.id::text, l.title, m.title || CASE WHEN m.deleted_at IS NOT NULL THEN ' (module also in trash)' ELSE '' END, l.deleted_at
+			FROM public.lessons l JOIN public.modules m ON m.id = l.module_id
+			WHERE l.deleted_at IS NOT NULL
+		`))
+	}
+	if err == nil && wants(trashComment) {
+		err = add(queryTrash(r.Context(), trashComment, "", `
+			SELECT c.id::text, left(c.content, 120), COALESCE(l.title, ''), c.deleted_at
+			FROM public.lesson_comments c LEFT JOIN public.lessons l ON l.id = c.lesson_id
+			WHERE c.deleted_at IS NOT NULL
+		`))
+	}
+	if err != nil {
+		fmt.Println("💥 TRASH LIST ERROR:", err)
+		http.Error(w, "Failed to fetch trash", http.StatusInternalServerError)
+		return
+	}
+
+	if retention := trashRetention(); retention > 0 {
+		for i := range items {
+			purgeAt := items[i].DeletedAt.Add(retention)
+			items[i].PurgeAt = &purgeAt
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// RestoreAdminTrash brings an item back out of the trash. A restored RFASM participant
+// takes their clan seat back, even if the clan has filled up in the meantime.
+func RestoreAdminTrash(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Type   string `json:"type"`
+		ID     string `json:"id"`
+		Source string `json:"source"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "type and id are required", http.StatusBadRequest)
+		return
+	}
+	perm, ok := trashPermissions[req.Type]
+	if !ok {
+		http.Error(w, "type must be user, module, lesson or comment", http.StatusBadRequest)
+		return
+	}
+	if !callerCan(r.Context(), perm) {
+		http.Error(w, "Unauthorized Admin Access", http.StatusForbidden)
+		return
+	}
+
+	tx, err := db.Pool.Begin(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback(r.Context())
+
+	var table string
+	entry := auditEntry{TargetID: req.ID}
+	program, isUser := Program{}, req.Type == trashUser
+	switch req.Type {
+	case trashUser:
+		if program, ok = lookupProgram(r.Context(), req.Source); !ok {
+			http.Error(w, "Invalid source", http.StatusBadRequest)
+			return
+		}
+		table, entry.Action, entry.TargetType = program.registrationIdent(), auditUserRestore, program.RegistrationTable
+	case trashModule:
+		table, entry.Action, entry.TargetType = "public.modules", auditModuleRestore, "module"
+	case trashLesson:
+		table, entry.Action, entry.TargetType = "public.lessons", auditLessonRestore, "lesson"
+	case trashComment:
+		table, entry.Action, entry.TargetType = "public.lesson_comments", auditCommentRestore, "comment"
+	}
+
+	var before, after json.RawMessage
+	err = tx.QueryRow(r.Context(), `
+		WITH old AS (SELECT id, to_jsonb(t) AS snapshot FROM `+table+` t WHERE id::text = $1 AND deleted_at IS NOT NULL FOR UPDATE)
+		UPDATE `+table+` t SET deleted_at = NULL FROM old WHERE t.id = old.id
+		RETURNING old.snapshot, to_jsonb(t)
+	`, req.ID).Scan(&before, &after)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "Item not found in trash", http.StatusNotFound)
+		return
+	}
+
+	// RFASM participants gave their clan seat back when they were deleted
+	if err == nil && isUser && program.Slug == programSoulmate {
+		_, err = tx.Exec(r.Context(), `
+			UPDATE clans SET current_count = current_count + 1
+			WHERE id = (SELECT clan_id FROM public.participants WHERE id::text = $1)
+		`, req.ID)
+	}
+	if err == nil {
+		entry.Before, entry.After = before, after
+		err = recordAudit(r, tx, entry)
+	}
+	if err == nil {
+		err = tx.Commit(r.Context())
+	}
+	if err != nil {
+		fmt.Println("💥 TRASH RESTORE ERROR:", err)
+		http.Error(w, "Failed to restore item", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Restored"})
+}
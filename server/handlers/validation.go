@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// fieldErrors maps a JSON field name to a message the frontend shows next to that input
+type fieldErrors map[string]string
+
+// ValidationErrorResponse is returned with 422 when a registration payload fails validation
+type ValidationErrorResponse struct {
+	Success bool        `json:"success"`
+	Message string      `json:"message"`
+	Errors  fieldErrors `json:"errors"`
+}
+
+func writeValidationErrors(w http.ResponseWriter, errs fieldErrors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Success: false,
+		Message: "Please correct the highlighted fields.",
+		Errors:  errs,
+	})
+}
+
+// Length limits shared by the registration forms
+const (
+	maxNameLen  = 120
+	maxEmailLen = 254
+	maxTextLen  = 120
+	maxLongLen  = 500
+)
+
+// Accepted select values (compared case-insensitively)
+var (
+	genderValues             = []string{"male", "female"}
+	ageGroupValues           = []string{"18-25", "26-30", "31-35", "36-40", "40+"}
+	relationshipStatusValues = []string{"single", "single-parent", "divorced", "widowed"}
+	partnerRegisteredValues  = []string{"yes", "no"}
+)
+
+// phonePattern allows an optional leading + and common separators
+var phonePattern = regexp.MustCompile(`^\+?[0-9 ().\-]+$`)
+
+// Each check records the first problem found for a field and leaves earlier errors in place
+
+func (e fieldErrors) add(field, msg string) {
+	if _, exists := e[field]; !exists {
+		e[field] = msg
+	}
+}
+
+func (e fieldErrors) required(field, value, label string) {
+	if strings.TrimSpace(value) == "" {
+		e.add(field, label+" is required.")
+	}
+}
+
+func (e fieldErrors) maxLen(field, value string, limit int) {
+	if utf8.RuneCountInString(value) > limit {
+		e.add(field, fmt.Sprintf("Must be %d characters or fewer.", limit))
+	}
+}
+
+func (e fieldErrors) email(field, value string) {
+	if value == "" {
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value || !strings.Contains(value[strings.LastIndex(value, "@")+1:], ".") {
+		e.add(field, "Enter a valid email address.")
+	}
+}
+
+// phone checks a phone number and returns it in E.164, using the country hint for national numbers
+func (e fieldErrors) phone(field, value, countryHint string) string {
+	if value == "" {
+		return ""
+	}
+	e164, err := normalizePhone(value, countryHint)
+	if !phonePattern.MatchString(value) || err != nil {
+		e.add(field, "Enter a valid phone number, including the country code.")
+		return ""
+	}
+	return e164
+}
+
+func (e fieldErrors) oneOf(field, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(value, a) {
+			return
+		}
+	}
+	e.add(field, "Choose one of: "+strings.Join(allowed, ", ")+".")
+}
+
+// date parses a YYYY-MM-DD value, returning it normalized
+func (e fieldErrors) date(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		e.add(field, "Enter a valid date (YYYY-MM-DD).")
+		return value
+	}
+	return t.Format("2006-01-02")
+}
+
+func trimAll(fields ...*string) {
+	for _, f := range fields {
+		*f = strings.TrimSpace(*f)
+	}
+}
+
+// validate normalizes and checks an RFASM registration
+func (req *RegistrationRequest) validate() fieldErrors {
+	trimAll(&req.FullName, &req.Email, &req.WhatsAppNumber, &req.Gender, &req.Country, &req.State,
+		&req.AgeGroup, &req.Religion, &req.ChurchName, &req.InstagramHandle, &req.RelationshipStatus)
+	req.Email = strings.ToLower(req.Email)
+	req.Gender = strings.ToLower(req.Gender)
+	req.RelationshipStatus = strings.ToLower(req.RelationshipStatus)
+
+	errs := fieldErrors{}
+	errs.required("full_name", req.FullName, "Full name")
+	errs.required("email", req.Email, "Email")
+	errs.required("whatsapp_number", req.WhatsAppNumber, "WhatsApp number")
+	errs.required("gender", req.Gender, "Gender")
+	errs.required("country", req.Country, "Country")
+	errs.required("state", req.State, "State")
+	errs.required("age_group", req.AgeGroup, "Age group")
+	errs.required("religion", req.Religion, "Religion")
+	errs.required("instagram_handle", req.InstagramHandle, "Instagram handle")
+	errs.required("relationship_status", req.RelationshipStatus, "Relationship status")
+
+	errs.maxLen("full_name", req.FullName, maxNameLen)
+	errs.maxLen("email", req.Email, maxEmailLen)
+	errs.maxLen("country", req.Country, maxTextLen)
+	errs.maxLen("state", req.State, maxTextLen)
+	errs.maxLen("religion", req.Religion, maxTextLen)
+	errs.maxLen("church_name", req.ChurchName, maxTextLen)
+	errs.maxLen("instagram_handle", req.InstagramHandle, maxTextLen)
+
+	errs.email("email", req.Email)
+	req.WhatsAppE164 = errs.phone("whatsapp_number", req.WhatsAppNumber, req.Country)
+	errs.oneOf("gender", req.Gender, genderValues)
+	errs.oneOf("age_group", req.AgeGroup, ageGroupValues)
+	errs.oneOf("relationship_status", req.RelationshipStatus, relationshipStatusValues)
+	return errs
+}
+
+// validate normalizes and checks a Couples' Launchpad registration, including the
+// eligibility answers the form used to enforce only on the client
+func (req *LaunchpadRequest) validate() fieldErrors {
+	trimAll(&req.FullName, &req.Gender, &req.Email, &req.WhatsAppNumber, &req.CountryCity, &req.Religion,
+		&req.Denomination, &req.ReferralSource, &req.InstagramHandle, &req.WeddingDate,
+		&req.PartnerRegistered, &req.SpouseName, &req.SpouseWhatsApp)
+	req.Email = strings.ToLower(req.Email)
+
+	errs := fieldErrors{}
+	errs.required("full_name", req.FullName, "Full name")
+	errs.required("gender", req.Gender, "Gender")
+	errs.required("email", req.Email, "Email")
+	errs.required("whatsapp_number", req.WhatsAppNumber, "WhatsApp number")
+	errs.required("country_city", req.CountryCity, "Country / city")
+	errs.required("wedding_date", req.WeddingDate, "Wedding date")
+	errs.required("spouse_name", req.SpouseName, "Spouse name")
+	errs.required("partner_registered", req.PartnerRegistered, "Partner registration status")
+
+	errs.maxLen("full_name", req.FullName, maxNameLen)
+	errs.maxLen("email", req.Email, maxEmailLen)
+	errs.maxLen("country_city", req.CountryCity, maxTextLen)
+	errs.maxLen("religion", req.Religion, maxTextLen)
+	errs.maxLen("denomination", req.Denomination, maxTextLen)
+	errs.maxLen("referral_source", req.ReferralSource, maxLongLen)
+	errs.maxLen("instagram_handle", req.InstagramHandle, maxTextLen)
+	errs.maxLen("spouse_name", req.SpouseName, maxNameLen)
+
+	errs.email("email", req.Email)
+	req.WhatsAppE164 = errs.phone("whatsapp_number", req.WhatsAppNumber, req.CountryCity)
+	req.SpouseWhatsAppE164 = errs.phone("spouse_whatsapp", req.SpouseWhatsApp, req.CountryCity)
+	errs.oneOf("gender", req.Gender, genderValues)
+	errs.oneOf("partner_registered", req.PartnerRegistered, partnerRegisteredValues)
+	req.WeddingDate = errs.date("wedding_date", req.WeddingDate)
+
+	if req.AttendedBefore {
+		errs.add("attended_before", "Couples' Launchpad is for couples attending for the first time.")
+	}
+	if !req.AgreedToFeedback {
+		errs.add("agreed_to_feedback", "You must agree to give feedback to register.")
+	}
+	if !req.AgreedToParticipation {
+		errs.add("agreed_to_participation", "You must agree to participate fully to register.")
+	}
+	return errs
+}
+
+// validate normalizes and checks a waitlist entry
+func (req *WaitlistRequest) validate() fieldErrors {
+	trimAll(&req.FullName, &req.Nationality, &req.WhatsApp, &req.Email, &req.Religion, &req.Denomination, &req.Cohort)
+	req.Email = strings.ToLower(req.Email)
+
+	errs := fieldErrors{}
+	errs.required("full_name", req.FullName, "Full name")
+	errs.required("email", req.Email, "Email")
+	errs.required("whatsapp_number", req.WhatsApp, "WhatsApp number")
+
+	errs.maxLen("full_name", req.FullName, maxNameLen)
+	errs.maxLen("email", req.Email, maxEmailLen)
+	errs.maxLen("nationality", req.Nationality, maxTextLen)
+	errs.maxLen("religion", req.Religion, maxTextLen)
+	errs.maxLen("denomination", req.Denomination, maxTextLen)
+	errs.maxLen("cohort", req.Cohort, maxTextLen)
+
+	errs.email("email", req.Email)
+	req.WhatsAppE164 = errs.phone("whatsapp_number", req.WhatsApp, req.Nationality)
+	return errs
+}
@@ -1,18 +1,17 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
 )
 
-// WaitlistRequest matches the Cohort 4 waitlist form fields
+// WaitlistRequest matches the waitlist form fields. Cohort is an optional cohort slug;
+// when empty the entry joins the RFASM cohort that is open (full) or opening next.
 type WaitlistRequest struct {
 	FullName    string `json:"full_name"`
 	Nationality string `json:"nationality"`
@@ -20,6 +19,9 @@ type WaitlistRequest struct {
 	Email       string `json:"email"`
 	Religion    string `json:"religion"`
 	Denomination string `json:"denomination"`
+	Cohort      string `json:"cohort,omitempty"`
+
+	WhatsAppE164 string `json:"-"` // set by validate()
 }
 
 // WaitlistResponse is the JSON response sent back to the client
@@ -36,9 +38,10 @@ type WaitlistSheetPayload struct {
 	Email       string `json:"email"`
 	Religion    string `json:"religion"`
 	Denomination string `json:"denomination"`
+	Cohort      string `json:"cohort"`
 }
 
-// RegisterWaitlist handles POST /api/cohort4/waitlist
+// RegisterWaitlist handles POST /api/waitlist (and the legacy /api/cohort4/waitlist)
 func RegisterWaitlist(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -49,24 +52,59 @@ func RegisterWaitlist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Basic validation
-	if req.FullName == "" || req.Email == "" || req.WhatsApp == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "Full name, email, and WhatsApp number are required."})
+	if errs := req.validate(); len(errs) > 0 {
+		writeValidationErrors(w, errs)
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := db.Pool.Exec(ctx, `
-		INSERT INTO public.cohort4_waitlist (full_name, nationality, whatsapp_number, email, religion, denomination)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, req.FullName, req.Nationality, req.WhatsApp, req.Email, req.Religion, req.Denomination)
+	var cohort Cohort
+	var err error
+	if req.Cohort != "" {
+		cohort, err = cohortBySlug(ctx, db.Pool, req.Cohort)
+	} else {
+		var program Program
+		if program, err = mustProgram(ctx, programSoulmate); err == nil {
+			cohort, err = waitlistCohort(ctx, db.Pool, program.ModuleName)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "There is no upcoming cohort to join the waitlist for."})
+		return
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "Something went wrong. Please try again."})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Already registered, or already waiting, under this email or phone number
+	conflict, err := findRegistrationConflict(ctx, tx, req.Email, req.WhatsAppE164, true, "")
+	if err != nil {
+		log.Printf("Waitlist Duplicate Check Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "Something went wrong. Please try again."})
+		return
+	}
+	if conflict != nil {
+		writeRegistrationConflict(w, conflict)
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO public.waitlist (full_name, nationality, whatsapp_number, email, religion, denomination, cohort_id, whatsapp_e164)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, req.FullName, req.Nationality, req.WhatsApp, req.Email, req.Religion, req.Denomination, cohort.ID, req.WhatsAppE164)
 
 	if err != nil {
 		log.Printf("Waitlist Insert Error: %v", err)
-		// Check for duplicate email
+		// A concurrent submission can still trip the unique index
 		json.NewEncoder(w).Encode(WaitlistResponse{
 			Success: false,
 			Message: "This email address is already on the waitlist.",
@@ -74,31 +112,29 @@ func RegisterWaitlist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Sync to Google Sheets asynchronously
-	go func() {
-		webhookURL := os.Getenv("COHORT4_WAITLIST_WEBHOOK_URL")
-		if webhookURL == "" {
-			return
-		}
-
-		payload := WaitlistSheetPayload{
-			FullName:    req.FullName,
-			Nationality: req.Nationality,
-			WhatsApp:    req.WhatsApp,
-			Email:       req.Email,
-			Religion:    req.Religion,
-			Denomination: req.Denomination,
-		}
-
-		jsonData, _ := json.Marshal(payload)
-		_, postErr := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
-		if postErr != nil {
-			log.Printf("Waitlist Sheets Webhook Error: %v", postErr)
-		}
-	}()
+	// Sync to Google Sheets via the outbox
+	err = enqueueOutbox(ctx, tx, outboxWaitlistSheet, WaitlistSheetPayload{
+		FullName:     req.FullName,
+		Nationality:  req.Nationality,
+		WhatsApp:     req.WhatsApp,
+		Email:        req.Email,
+		Religion:     req.Religion,
+		Denomination: req.Denomination,
+		Cohort:       cohort.Name,
+	})
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("Waitlist Commit Error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "Something went wrong. Please try again."})
+		return
+	}
+	notifyOutbox()
 
 	json.NewEncoder(w).Encode(WaitlistResponse{
 		Success: true,
-		Message: "You've been added to the waitlist!",
+		Message: "You've been added to the waitlist for " + cohort.Name + "!",
 	})
 }
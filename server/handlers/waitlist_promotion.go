@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/asejik/soulmate-reg/server/services"
+	"github.com/jackc/pgx/v5"
+)
+
+// waitlistHoldDuration is how long a freed clan seat is held for a waitlisted person
+// before it moves on to the next entry. Override with WAITLIST_HOLD_HOURS.
+func waitlistHoldDuration() time.Duration {
+	if h, err := strconv.Atoi(os.Getenv("WAITLIST_HOLD_HOURS")); err == nil && h > 0 {
+		return time.Duration(h) * time.Hour
+	}
+	return 48 * time.Hour
+}
+
+// clientURL resolves a path on the frontend for links in emails
+func clientURL(path string) string {
+	base := strings.TrimSuffix(os.Getenv("CLIENT_URL"), "/")
+	if base == "" {
+		base = "https://soulmate-reg.vercel.app"
+	}
+	return base + path
+}
+
+// waitlistClaimURL builds the link emailed to a promoted waitlist entry
+func waitlistClaimURL(token string) string {
+	return clientURL("/waitlist/claim?token=" + token)
+}
+
+// Emailed links (waitlist offers, account claims) carry a random token; only its hash is stored
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// promoteNextWaitlisted holds one free clan seat for the oldest waiting entry whose
+// program has an open cohort with room, skipping people who have since registered
+// directly. Entries usually wait on the next cohort, which has no clans yet, so the
+// seat comes from whichever cohort of the program is currently open. It returns
+// false when there is nobody left to promote.
+func promoteNextWaitlisted(ctx context.Context) (bool, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var waitlistID, fullName, email, nationality, cohortID, cohortName string
+	err = tx.QueryRow(ctx, `
+		SELECT wl.id, wl.full_name, wl.email, COALESCE(wl.nationality, ''), co.id::text, co.name
+		FROM public.waitlist wl
+		JOIN public.cohorts wc ON wc.id = wl.cohort_id
+		JOIN LATERAL (
+			SELECT oc.id, oc.name FROM public.cohorts oc
+			WHERE oc.program_name = wc.program_name
+			  AND oc.opens_at <= NOW() AND (oc.closes_at IS NULL OR oc.closes_at > NOW())
+			  AND EXISTS (SELECT 1 FROM clans c WHERE c.cohort_id = oc.id AND c.current_count < c.max_capacity)
+			ORDER BY oc.opens_at ASC
+			LIMIT 1
+		) co ON TRUE
+		WHERE wl.status = 'waiting'
+		  AND NOT EXISTS (
+			SELECT 1 FROM public.participants p
+			WHERE p.deleted_at IS NULL
+			  AND (lower(p.email) = lower(wl.email) OR (p.whatsapp_e164 = wl.whatsapp_e164 AND wl.whatsapp_e164 <> ''))
+		  )
+		ORDER BY wl.created_at ASC
+		LIMIT 1
+		FOR UPDATE OF wl SKIP LOCKED
+	`).Scan(&waitlistID, &fullName, &email, &nationality, &cohortID, &cohortName)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The waitlist form only collects nationality, so that is all the strategy can balance on
+	clan, err := clanAssignerFor(ctx, tx, cohortID).pickClan(ctx, tx, cohortID, clanCandidate{Country: nationality})
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	clanID := clan.ID
+
+	token, err := newLinkToken()
+	if err != nil {
+		return false, err
+	}
+	expiresAt := time.Now().UTC().Add(waitlistHoldDuration())
+
+	if _, err = tx.Exec(ctx, `
+		INSERT INTO public.waitlist_offers (waitlist_id, clan_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, waitlistID, clanID, hashLinkToken(token), expiresAt); err != nil {
+		return false, err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE clans SET current_count = current_count + 1 WHERE id = $1`, clanID); err != nil {
+		return false, err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE public.waitlist SET status = 'offered' WHERE id = $1`, waitlistID); err != nil {
+		return false, err
+	}
+	if err = enqueueOutbox(ctx, tx, outboxWaitlistOffer, services.WaitlistOfferEmailData{
+		Name:       fullName,
+		Email:      email,
+		CohortName: cohortName,
+		ClaimLink:  waitlistClaimURL(token),
+		ExpiresAt:  expiresAt,
+	}); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	notifyOutbox()
+
+	log.Printf("[Waitlist] Held seat in clan %d for %s until %s", clanID, email, expiresAt.Format(time.RFC3339))
+	return true, nil
+}
+
+// expireWaitlistOffers releases the seats of unclaimed offers past their deadline
+func expireWaitlistOffers(ctx context.Context) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE public.waitlist_offers SET status = 'expired'
+		WHERE status = 'pending' AND expires_at < NOW()
+		RETURNING waitlist_id, clan_id
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	type expired struct {
+		waitlistID string
+		clanID     int64
+	}
+	var released []expired
+	for rows.Next() {
+		var e expired
+		if err := rows.Scan(&e.waitlistID, &e.clanID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		released = append(released, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range released {
+		if _, err := tx.Exec(ctx, `UPDATE clans SET current_count = GREATEST(current_count - 1, 0) WHERE id = $1`, e.clanID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE public.waitlist SET status = 'expired' WHERE id = $1`, e.waitlistID); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(released), nil
+}
+
+// RunWaitlistPromotion expires stale holds and offers every free seat to the waitlist.
+// Safe to call from request handlers after a seat is released.
+func RunWaitlistPromotion(ctx context.Context) {
+	if n, err := expireWaitlistOffers(ctx); err != nil {
+		log.Printf("[Waitlist] Expire offers error: %v", err)
+	} else if n > 0 {
+		log.Printf("[Waitlist] Released %d expired seat hold(s)", n)
+	}
+
+	for {
+		promoted, err := promoteNextWaitlisted(ctx)
+		if err != nil {
+			log.Printf("[Waitlist] Promotion error: %v", err)
+			return
+		}
+		if !promoted {
+			return
+		}
+	}
+}
+
+// StartWaitlistPromoter runs the promotion engine on a fixed interval until ctx is done
+func StartWaitlistPromoter(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	RunWaitlistPromotion(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			RunWaitlistPromotion(ctx)
+		}
+	}
+}
+
+// releaseClanSeat decrements a clan's counter and lets the waitlist take the seat
+func releaseClanSeat(ctx context.Context, clanID int64) {
+	if _, err := db.Pool.Exec(ctx, `UPDATE clans SET current_count = GREATEST(current_count - 1, 0) WHERE id = $1`, clanID); err != nil {
+		log.Printf("[Waitlist] Failed to release seat in clan %d: %v", clanID, err)
+		return
+	}
+	go RunWaitlistPromotion(context.Background())
+}
+
+// GetWaitlistOffer handles GET /api/waitlist/offer?token=... so the claim page can prefill the form
+func GetWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "Missing claim token."})
+		return
+	}
+
+	var fullName, email, whatsapp, nationality, religion, cohortName string
+	var expiresAt time.Time
+	err := db.Pool.QueryRow(r.Context(), `
+		SELECT wl.full_name, wl.email, wl.whatsapp_number, COALESCE(wl.nationality, ''), COALESCE(wl.religion, ''), COALESCE(co.name, ''), o.expires_at
+		FROM public.waitlist_offers o
+		JOIN public.waitlist wl ON wl.id = o.waitlist_id
+		LEFT JOIN clans c ON c.id = o.clan_id
+		LEFT JOIN public.cohorts co ON co.id = c.cohort_id
+		WHERE o.token_hash = $1 AND o.status = 'pending' AND o.expires_at > NOW()
+	`, hashLinkToken(token)).Scan(&fullName, &email, &whatsapp, &nationality, &religion, &cohortName, &expiresAt)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(WaitlistResponse{Success: false, Message: "This offer is invalid or has expired."})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"full_name":       fullName,
+		"email":           email,
+		"whatsapp_number": whatsapp,
+		"country":         nationality,
+		"religion":        religion,
+		"cohort":          cohortName,
+		"expires_at":      expiresAt,
+	})
+}
+
+// WaitlistClaimRequest is the registration form submitted from the emailed claim link
+type WaitlistClaimRequest struct {
+	Token string `json:"token"`
+	RegistrationRequest
+}
+
+// ClaimWaitlistOffer handles POST /api/waitlist/claim and turns a held seat into a registration
+func ClaimWaitlistOffer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req WaitlistClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(RegistrationResponse{Success: false, Message: "Invalid request body."})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		log.Printf("Tx Begin Error: %v", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the offer so a double-click can't register twice
+	var offerID, waitlistID, email, clanName, whatsappLink, cohortID, cohortName string
+	var clanID int64
+	err = tx.QueryRow(ctx, `
+		SELECT o.id, o.waitlist_id, wl.email, o.clan_id, c.name, c.whatsapp_link, co.id::text, co.name
+		FROM public.waitlist_offers o
+		JOIN public.waitlist wl ON wl.id = o.waitlist_id
+		JOIN clans c ON c.id = o.clan_id
+		JOIN public.cohorts co ON co.id = c.cohort_id
+		WHERE o.token_hash = $1 AND o.status = 'pending' AND o.expires_at > NOW()
+		FOR UPDATE OF o
+	`, hashLinkToken(req.Token)).Scan(&offerID, &waitlistID, &email, &clanID, &clanName, &whatsappLink, &cohortID, &cohortName)
+	if err != nil {
+		w.WriteHeader(http.StatusGone)
+		json.NewEncoder(w).Encode(RegistrationResponse{Success: false, Message: "This offer is invalid or has expired."})
+		return
+	}
+
+	// The offer is tied to the waitlisted email, whatever the form sent
+	req.Email = email
+	if errs := req.validate(); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
+	conflict, err := findRegistrationConflict(ctx, tx, req.Email, req.WhatsAppE164, false, waitlistID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if conflict != nil {
+		writeRegistrationConflict(w, conflict)
+		return
+	}
+
+	// The seat was reserved when the offer was made, so the clan count is not incremented again
+	_, err = tx.Exec(ctx, `
+		INSERT INTO participants (
+			full_name, email, whatsapp_number, gender, country, state,
+			age_group, religion, church_name, instagram_handle, relationship_status, clan_id, cohort_id, whatsapp_e164
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, req.FullName, req.Email, req.WhatsAppNumber, req.Gender, req.Country, req.State,
+		req.AgeGroup, req.Religion, req.ChurchName, req.InstagramHandle, req.RelationshipStatus, clanID, cohortID, req.WhatsAppE164)
+	if err != nil {
+		log.Printf("Waitlist Claim Insert Error: %v", err)
+		json.NewEncoder(w).Encode(RegistrationResponse{
+			Success: false,
+			Message: "This email or phone number is already registered.",
+		})
+		return
+	}
+
+	if _, err = tx.Exec(ctx, `UPDATE public.waitlist_offers SET status = 'claimed', claimed_at = NOW() WHERE id = $1`, offerID); err != nil {
+		http.Error(w, "Failed to update offer", http.StatusInternalServerError)
+		return
+	}
+	if _, err = tx.Exec(ctx, `UPDATE public.waitlist SET status = 'promoted' WHERE id = $1`, waitlistID); err != nil {
+		http.Error(w, "Failed to update waitlist", http.StatusInternalServerError)
+		return
+	}
+
+	if err = enqueueOutbox(ctx, tx, outboxConfirmationEmail, services.EmailData{
+		Name:         req.FullName,
+		Email:        req.Email,
+		ClanName:     clanName,
+		WhatsAppLink: whatsappLink,
+		CohortName:   cohortName,
+	}); err != nil {
+		http.Error(w, "Failed to queue confirmation email", http.StatusInternalServerError)
+		return
+	}
+	if err = enqueueOutbox(ctx, tx, outboxParticipantSheet, participantSheetPayload(req.RegistrationRequest, clanName)); err != nil {
+		http.Error(w, "Failed to queue sheet sync", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		http.Error(w, "Transaction commit failed", http.StatusInternalServerError)
+		return
+	}
+	notifyOutbox()
+
+	json.NewEncoder(w).Encode(RegistrationResponse{
+		Success:      true,
+		Message:      "Registration successful!",
+		ClanName:     clanName,
+		WhatsAppLink: whatsappLink,
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/asejik/soulmate-reg/server/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// connectTestDB points db.Pool at TEST_DATABASE_URL, a disposable copy of the app's
+// database, and skips the test when it isn't set
+func connectTestDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	prev := db.Pool
+	db.Pool = pool
+	t.Cleanup(func() {
+		db.Pool = prev
+		pool.Close()
+	})
+	db.InitTables()
+}
+
+// The seed attaches the existing waitlist to the next cohort, which has no clans,
+// while seats free up in the open one
+func TestPromoteNextWaitlistedFillsOpenCohort(t *testing.T) {
+	connectTestDB(t)
+	ctx := context.Background()
+	const program = "Waitlist Promotion Test"
+	token, err := newLinkToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := token[:8]
+
+	var openID, nextID string
+	var clanID int64
+	mustExec := func(sql string, args ...any) {
+		t.Helper()
+		if _, err := db.Pool.Exec(ctx, sql, args...); err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+	}
+	t.Cleanup(func() {
+		db.Pool.Exec(ctx, `DELETE FROM public.outbox WHERE payload->>'Email' LIKE $1`, "%+"+suffix+"@example.com")
+		db.Pool.Exec(ctx, `DELETE FROM public.waitlist WHERE cohort_id::text IN ($1, $2)`, openID, nextID)
+		db.Pool.Exec(ctx, `DELETE FROM clans WHERE id = $1`, clanID)
+		db.Pool.Exec(ctx, `DELETE FROM public.cohorts WHERE program_name = $1`, program)
+	})
+
+	if err := db.Pool.QueryRow(ctx, `
+		INSERT INTO public.cohorts (program_name, name, slug, opens_at) VALUES ($1, 'Current', $2, NOW() - INTERVAL '1 day') RETURNING id::text
+	`, program, "wl-test-current-"+suffix).Scan(&openID); err != nil {
+		t.Fatalf("insert open cohort: %v", err)
+	}
+	if err := db.Pool.QueryRow(ctx, `
+		INSERT INTO public.cohorts (program_name, name, slug) VALUES ($1, 'Next', $2) RETURNING id::text
+	`, program, "wl-test-next-"+suffix).Scan(&nextID); err != nil {
+		t.Fatalf("insert next cohort: %v", err)
+	}
+	// One seat freed by a withdrawal
+	if err := db.Pool.QueryRow(ctx, `
+		INSERT INTO clans (name, whatsapp_link, max_capacity, current_count, cohort_id)
+		VALUES ('Test Clan', 'https://chat.whatsapp.com/test', 2, 1, $1) RETURNING id
+	`, openID).Scan(&clanID); err != nil {
+		t.Fatalf("insert clan: %v", err)
+	}
+	// Older than anything real, so these are the next in line
+	for i, day := range []string{"2000-01-01", "2000-01-02"} {
+		mustExec(`
+			INSERT INTO public.waitlist (full_name, whatsapp_number, email, cohort_id, whatsapp_e164, created_at)
+			VALUES ($1, '', $2, $3, '', $4)
+		`, "Waiting "+day, []string{"first", "second"}[i]+"+"+suffix+"@example.com", nextID, day)
+	}
+
+	promoted, err := promoteNextWaitlisted(ctx)
+	if err != nil || !promoted {
+		t.Fatalf("promoteNextWaitlisted = %v, %v; want a promotion", promoted, err)
+	}
+
+	var offeredEmail string
+	var offeredClan int64
+	if err := db.Pool.QueryRow(ctx, `
+		SELECT wl.email, o.clan_id FROM public.waitlist_offers o JOIN public.waitlist wl ON wl.id = o.waitlist_id
+		WHERE wl.cohort_id = $1 AND wl.status = 'offered'
+	`, nextID).Scan(&offeredEmail, &offeredClan); err != nil {
+		t.Fatalf("load offer: %v", err)
+	}
+	if offeredEmail != "first+"+suffix+"@example.com" || offeredClan != clanID {
+		t.Errorf("offered clan %d to %s, want clan %d to the oldest entry", offeredClan, offeredEmail, clanID)
+	}
+
+	var count int
+	db.Pool.QueryRow(ctx, `SELECT current_count FROM clans WHERE id = $1`, clanID).Scan(&count)
+	if count != 2 {
+		t.Errorf("clan count = %d, want the freed seat held", count)
+	}
+	var waiting int
+	db.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM public.waitlist WHERE cohort_id = $1 AND status = 'waiting'`, nextID).Scan(&waiting)
+	if waiting != 1 {
+		t.Errorf("%d entries still waiting, want 1", waiting)
+	}
+}
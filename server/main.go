@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/asejik/soulmate-reg/server/db"
 	"github.com/asejik/soulmate-reg/server/handlers"
@@ -30,6 +31,27 @@ func main() {
 		port = "8080"
 	}
 
+	// Background: normalize phone numbers stored before E.164 columns existed
+	go handlers.BackfillNormalizedPhones(context.Background())
+
+	// Background: convert free-text quizzes into structured questions
+	go handlers.BackfillQuizQuestions(context.Background())
+
+	// Background: deliver queued emails and Sheets webhooks with retries
+	go handlers.StartOutboxDispatcher(context.Background(), 30*time.Second)
+
+	// Background: blank delivered secrets and purge delivered messages past OUTBOX_RETENTION_DAYS
+	go handlers.StartOutboxRetention(context.Background(), 24*time.Hour)
+
+	// Background: expire unclaimed seat holds and promote the waitlist
+	go handlers.StartWaitlistPromoter(context.Background(), 5*time.Minute)
+
+	// Background: purge audit records past AUDIT_RETENTION_DAYS
+	go handlers.StartAuditRetention(context.Background(), 24*time.Hour)
+
+	// Background: permanently remove trashed items past TRASH_RETENTION_DAYS
+	go handlers.StartTrashPurge(context.Background(), 24*time.Hour)
+
 	// 3. Setup Router
 	r := chi.NewRouter()
 
@@ -40,7 +62,7 @@ func main() {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://soulmate-reg.vercel.app", "http://localhost:5173", "http://localhost:3000", "*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Admin-Secret"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -53,89 +75,138 @@ func main() {
 	// --- ROUTES ---
 	r.Post("/api/register", handlers.RegisterUser)
 	r.Post("/api/launchpad/register", handlers.RegisterLaunchpad)
-	r.Post("/api/cohort4/waitlist", handlers.RegisterWaitlist)
+	r.Post("/api/waitlist", handlers.RegisterWaitlist)
+	r.Post("/api/cohort4/waitlist", handlers.RegisterWaitlist) // legacy path used by the Cohort 4 page
+	r.Get("/api/waitlist/offer", handlers.GetWaitlistOffer)
+	r.Post("/api/waitlist/claim", handlers.ClaimWaitlistOffer)
 	r.Post("/api/auth/claim", handlers.ClaimAccount)
 	r.Post("/api/auth/request-otp", handlers.RequestOTP)
-
-	// Admin Routes
-	r.Get("/api/admin/stats", handlers.AdminAuth(handlers.GetDashboardStats))
-	r.Get("/api/admin/participants", handlers.AdminAuth(handlers.GetClanParticipants))
+	r.Post("/api/auth/email-change/confirm", handlers.ConfirmEmailChange)
 
 	// NEW: Protected LMS Routes
 	r.Group(func(r chi.Router) {
 		// Anything inside this group strictly requires a valid Supabase Auth Token
 		r.Use(handlers.LMSAuth)
+		r.Use(handlers.LoadRoles)
 
-		r.Get("/api/lms/announcements", func(w http.ResponseWriter, req *http.Request) {
-			program := req.URL.Query().Get("program")
-			if program == "launchpad" {
-				program = "Couples' Launchpad 5.0"
-			} else {
-				program = "Ready for a Soulmate"
-			}
-			rows, _ := db.Pool.Query(req.Context(), `
-				SELECT id, title, image_url 
-				FROM public.announcements 
-				WHERE target_program = $1
-				AND (scheduled_start IS NULL OR NOW() >= scheduled_start)
-				AND (scheduled_end IS NULL OR NOW() <= scheduled_end)
-			`, program)
-			defer rows.Close()
-			var anns []map[string]interface{}
-			for rows.Next() {
-				var id, title, url string
-				rows.Scan(&id, &title, &url)
-				anns = append(anns, map[string]interface{}{"id": id, "title": title, "image_url": url})
-			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(anns)
-		})
-
+		r.Get("/api/lms/announcements", handlers.GetAnnouncements)
 		r.Get("/api/lms/dashboard", handlers.GetDashboard)
 		r.Get("/api/lms/profile", handlers.GetProfile)
-		r.Get("/api/lms/lessons/{id}", handlers.GetLesson)
-		r.Get("/api/lms/lessons/{id}/quiz", handlers.GetActiveQuiz)
-		r.Post("/api/lms/lessons/{id}/quiz", handlers.SubmitQuiz)
-		r.Post("/api/lms/lessons/{id}/submit", handlers.SubmitAssignment)
 		r.Get("/api/lms/certificate", handlers.GenerateCertificate)
-		r.Get("/api/admin/users", handlers.GetMasterAdminUsers)
-		r.Post("/api/admin/users", handlers.CreateAdminUser)
-		r.Delete("/api/admin/users", handlers.DeleteAdminUser)
-		r.Get("/api/admin/submissions", handlers.GetAdminSubmissions)
-		r.Get("/api/admin/reviews", handlers.GetAdminReviews)
-		r.Get("/api/admin/modules", handlers.GetAdminModules)
-		r.Post("/api/admin/modules", handlers.CreateAdminModule)
-		r.Put("/api/admin/modules", handlers.UpdateAdminModule)
-		r.Delete("/api/admin/modules", handlers.DeleteAdminModule)
-		r.Get("/api/admin/lessons", handlers.GetAdminLessons)
-		r.Post("/api/admin/lessons", handlers.CreateAdminLesson)
-		r.Put("/api/admin/lessons", handlers.UpdateAdminLesson)
-		r.Delete("/api/admin/lessons", handlers.DeleteAdminLesson)
+		r.Get("/api/lms/roles", handlers.GetMyRoles)
 		r.Post("/api/lms/reviews", handlers.SubmitReview)
 		r.Get("/api/lms/discussions", handlers.GetGlobalDiscussions)
-		r.Get("/api/lms/lessons/{id}/comments", handlers.GetLessonComments)
-		r.Post("/api/lms/lessons/{id}/comments", handlers.PostLessonComment)
-		r.Post("/api/lms/lessons/{id}/progress", handlers.UpdateProgress)
-		r.Get("/api/lms/lessons/{id}/activity", handlers.GetLessonActivity)
-		r.Get("/api/lms/lessons/{id}/my-submission", handlers.GetMySubmission)
 		r.Post("/api/lms/giving-commitment", handlers.SubmitGivingCommitment)
 		r.Get("/api/lms/giving-commitment", handlers.GetGivingCommitment)
-		r.Get("/api/admin/giving-commitments", handlers.GetAdminGivingCommitments)
-
-		// Admin Moderation / Reset
-		r.Delete("/api/admin/comments", handlers.DeleteLessonComment)
-		r.Delete("/api/admin/progress", handlers.ResetUserProgress)
-		r.Get("/api/admin/settings", handlers.GetProgramSettings)
-		r.Post("/api/admin/settings", handlers.UpdateProgramSettings)
-		r.Put("/api/admin/submissions/{id}/feedback", handlers.UpdateSubmissionFeedback)
-		
+
+		// Lessons: only for participants enrolled in the lesson's program (and staff)
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireLessonEnrollment)
+			r.Get("/api/lms/lessons/{id}", handlers.GetLesson)
+			r.Get("/api/lms/lessons/{id}/comments", handlers.GetLessonComments)
+			r.Post("/api/lms/lessons/{id}/comments", handlers.PostLessonComment)
+			r.Get("/api/lms/lessons/{id}/activity", handlers.GetLessonActivity)
+			r.Get("/api/lms/lessons/{id}/my-submission", handlers.GetMySubmission)
+
+			// Actions that complete a lesson are refused while its access rules lock it
+			r.Group(func(r chi.Router) {
+				r.Use(handlers.RequireLessonAccess)
+				r.Get("/api/lms/lessons/{id}/quiz", handlers.GetActiveQuiz)
+				r.Post("/api/lms/lessons/{id}/quiz", handlers.SubmitQuiz)
+				r.Post("/api/lms/lessons/{id}/submit", handlers.SubmitAssignment)
+				r.Post("/api/lms/lessons/{id}/progress", handlers.UpdateProgress)
+			})
+		})
+
+		// Account & personal data
+		r.Post("/api/lms/account/email", handlers.RequestEmailChange)
+		r.Get("/api/lms/privacy/export", handlers.ExportMyData)
+		r.Post("/api/lms/privacy/erasure", handlers.RequestMyErasure)
+
 		// Q&A Routes
 		r.Get("/api/lms/qa", handlers.GetUserQuestions)
 		r.Post("/api/lms/qa", handlers.AskQuestion)
-		r.Get("/api/admin/qa", handlers.GetAllQuestionsForAdmin)
-		r.Post("/api/admin/qa/answer", handlers.AnswerQuestion)
-
+	})
 
+	// Admin Routes: a staff JWT or a scoped API key, then a per-route permission
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.AdminAuth)
+
+		// Users & registrations
+		r.With(handlers.RequirePermission(handlers.PermUsersView)).Get("/api/admin/stats", handlers.GetDashboardStats)
+		r.With(handlers.RequirePermission(handlers.PermUsersView)).Get("/api/admin/participants", handlers.GetClanParticipants)
+		r.With(handlers.RequirePermission(handlers.PermUsersView)).Get("/api/admin/users", handlers.GetMasterAdminUsers)
+		r.With(handlers.RequirePermission(handlers.PermUsersManage)).Post("/api/admin/users", handlers.CreateAdminUser)
+		r.With(handlers.RequirePermission(handlers.PermUsersManage)).Delete("/api/admin/users", handlers.DeleteAdminUser)
+		r.With(handlers.RequirePermission(handlers.PermUsersManage)).Post("/api/admin/users/email", handlers.ChangeAdminUserEmail)
+
+		// Curriculum
+		r.With(handlers.RequirePermission(handlers.PermCurriculumView)).Get("/api/admin/modules", handlers.GetAdminModules)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Post("/api/admin/modules", handlers.CreateAdminModule)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Put("/api/admin/modules", handlers.UpdateAdminModule)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Delete("/api/admin/modules", handlers.DeleteAdminModule)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumView)).Get("/api/admin/lessons", handlers.GetAdminLessons)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Post("/api/admin/lessons", handlers.CreateAdminLesson)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Put("/api/admin/lessons", handlers.UpdateAdminLesson)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Delete("/api/admin/lessons", handlers.DeleteAdminLesson)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumView)).Get("/api/admin/question-banks", handlers.GetQuestionBanks)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Post("/api/admin/question-banks", handlers.SaveQuestionBank)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Put("/api/admin/question-banks", handlers.SaveQuestionBank)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Delete("/api/admin/question-banks", handlers.DeleteQuestionBank)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumView)).Get("/api/admin/access-rules", handlers.GetAccessRules)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Post("/api/admin/access-rules", handlers.SaveAccessRule)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Put("/api/admin/access-rules", handlers.SaveAccessRule)
+		r.With(handlers.RequirePermission(handlers.PermCurriculumManage)).Delete("/api/admin/access-rules", handlers.DeleteAccessRule)
+
+		// Grading & community
+		r.With(handlers.RequirePermission(handlers.PermSubmissionsView)).Get("/api/admin/submissions", handlers.GetAdminSubmissions)
+		r.With(handlers.RequirePermission(handlers.PermSubmissionsView)).Get("/api/admin/quiz-analytics", handlers.GetQuizAnalyticsOverview)
+		r.With(handlers.RequirePermission(handlers.PermSubmissionsView)).Get("/api/admin/quiz-analytics/{id}", handlers.GetQuizAnalytics)
+		r.With(handlers.RequirePermission(handlers.PermReviewsView)).Get("/api/admin/reviews", handlers.GetAdminReviews)
+		r.With(handlers.RequirePermission(handlers.PermGivingView)).Get("/api/admin/giving-commitments", handlers.GetAdminGivingCommitments)
+		r.With(handlers.RequirePermission(handlers.PermCommunityModerate)).Delete("/api/admin/comments", handlers.DeleteLessonComment)
+		r.With(handlers.RequirePermission(handlers.PermProgressReset)).Delete("/api/admin/progress", handlers.ResetUserProgress)
+		r.With(handlers.RequirePermission(handlers.PermSubmissionsGrade)).Put("/api/admin/submissions/{id}/feedback", handlers.UpdateSubmissionFeedback)
+		r.With(handlers.RequirePermission(handlers.PermSubmissionsGrade)).Post("/api/admin/submissions/{id}/extra-attempt", handlers.GrantQuizAttempt)
+		r.With(handlers.RequirePermission(handlers.PermSubmissionsGrade)).Post("/api/admin/quiz-attempt-grants", handlers.GrantQuizAttemptForLearner)
+		r.With(handlers.RequirePermission(handlers.PermQAAnswer)).Get("/api/admin/qa", handlers.GetAllQuestionsForAdmin)
+		r.With(handlers.RequirePermission(handlers.PermQAAnswer)).Post("/api/admin/qa/answer", handlers.AnswerQuestion)
+
+		// Trash: each item kind needs the permission that deletes it
+		r.With(handlers.RequireAnyPermission(handlers.PermUsersManage, handlers.PermCurriculumManage, handlers.PermCommunityModerate)).Get("/api/admin/trash", handlers.GetAdminTrash)
+		r.With(handlers.RequireAnyPermission(handlers.PermUsersManage, handlers.PermCurriculumManage, handlers.PermCommunityModerate)).Post("/api/admin/trash/restore", handlers.RestoreAdminTrash)
+
+		// Programs & cohorts
+		r.With(handlers.RequirePermission(handlers.PermCohortsManage)).Get("/api/admin/cohorts", handlers.GetAdminCohorts)
+		r.With(handlers.RequirePermission(handlers.PermCohortsManage)).Post("/api/admin/cohorts", handlers.SaveAdminCohort)
+		r.With(handlers.RequirePermission(handlers.PermCohortsManage)).Put("/api/admin/cohorts", handlers.SaveAdminCohort)
+		r.With(handlers.RequirePermission(handlers.PermCohortsManage)).Post("/api/admin/cohorts/clans", handlers.CreateCohortClan)
+		r.With(handlers.RequirePermission(handlers.PermCohortsManage)).Put("/api/admin/cohorts/schedule", handlers.SaveCohortSchedule)
+		r.With(handlers.RequirePermission(handlers.PermProgramsManage)).Get("/api/admin/programs", handlers.GetAdminPrograms)
+		r.With(handlers.RequirePermission(handlers.PermProgramsManage)).Put("/api/admin/programs", handlers.SaveAdminProgram)
+		r.With(handlers.RequirePermission(handlers.PermProgramsManage)).Get("/api/admin/settings", handlers.GetProgramSettings)
+		r.With(handlers.RequirePermission(handlers.PermProgramsManage)).Post("/api/admin/settings", handlers.UpdateProgramSettings)
+
+		// Delivery
+		r.With(handlers.RequirePermission(handlers.PermOutboxManage)).Get("/api/admin/outbox", handlers.GetAdminOutbox)
+		r.With(handlers.RequirePermission(handlers.PermOutboxManage)).Post("/api/admin/outbox/replay", handlers.ReplayAdminOutbox)
+		r.With(handlers.RequirePermission(handlers.PermEmailsPreview)).Get("/api/admin/email-templates", handlers.GetAdminEmailTemplates)
+		r.With(handlers.RequirePermission(handlers.PermEmailsPreview)).Get("/api/admin/email-templates/{name}/preview", handlers.PreviewAdminEmailTemplate)
+
+		// Access control
+		r.With(handlers.RequirePermission(handlers.PermRolesManage)).Get("/api/admin/roles", handlers.GetAdminRoles)
+		r.With(handlers.RequirePermission(handlers.PermRolesManage)).Post("/api/admin/roles", handlers.GrantAdminRole)
+		r.With(handlers.RequirePermission(handlers.PermRolesManage)).Delete("/api/admin/roles", handlers.RevokeAdminRole)
+		r.With(handlers.RequirePermission(handlers.PermAPIKeysManage)).Get("/api/admin/api-keys", handlers.GetAdminAPIKeys)
+		r.With(handlers.RequirePermission(handlers.PermAPIKeysManage)).Post("/api/admin/api-keys", handlers.CreateAdminAPIKey)
+		r.With(handlers.RequirePermission(handlers.PermAPIKeysManage)).Delete("/api/admin/api-keys", handlers.RevokeAdminAPIKey)
+		r.With(handlers.RequirePermission(handlers.PermAuditView)).Get("/api/admin/audit-log", handlers.GetAdminAuditLog)
+
+		// Personal data erasure
+		r.With(handlers.RequirePermission(handlers.PermPrivacyManage)).Get("/api/admin/erasure-requests", handlers.GetAdminErasureRequests)
+		r.With(handlers.RequirePermission(handlers.PermPrivacyManage)).Post("/api/admin/erasure-requests", handlers.CreateAdminErasureRequest)
+		r.With(handlers.RequirePermission(handlers.PermPrivacyManage)).Post("/api/admin/erasure-requests/{id}/approve", handlers.ApproveErasureRequest)
+		r.With(handlers.RequirePermission(handlers.PermPrivacyManage)).Post("/api/admin/erasure-requests/{id}/reject", handlers.RejectErasureRequest)
 	})
 
 	// 5. Start Server
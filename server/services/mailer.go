@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+)
+
+// Message is a single outgoing email with an optional plain-text alternative
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers messages. Pick a backend with MAIL_BACKEND:
+//
+//	resend (default) - Resend API; sends fail while RESEND_API_KEY is missing
+//	smtp   - plain SMTP via SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD
+//	file   - writes .eml files to MAIL_FILE_DIR (default "mail"), for local development
+//	memory - keeps messages in memory (see MemoryMailer)
+//
+// file and memory never deliver anything, so they are only used when asked for.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+var (
+	mailer     Mailer
+	mailerOnce sync.Once
+	mailerMu   sync.RWMutex
+)
+
+// getMailer returns the configured backend, building it from the environment on first use
+func getMailer() Mailer {
+	mailerOnce.Do(func() {
+		m := NewMailerFromEnv()
+		mailerMu.Lock()
+		if mailer == nil {
+			mailer = m
+		}
+		mailerMu.Unlock()
+	})
+	mailerMu.RLock()
+	defer mailerMu.RUnlock()
+	return mailer
+}
+
+// SetMailer replaces the backend, e.g. with a MemoryMailer in tests
+func SetMailer(m Mailer) {
+	mailerOnce.Do(func() {}) // don't let a later getMailer overwrite m
+	mailerMu.Lock()
+	mailer = m
+	mailerMu.Unlock()
+}
+
+// NewMailerFromEnv builds the backend named by MAIL_BACKEND
+func NewMailerFromEnv() Mailer {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("MAIL_BACKEND")))
+	if backend == "" {
+		backend = "resend"
+		if os.Getenv("RESEND_API_KEY") == "" {
+			fmt.Println("Warning: RESEND_API_KEY is not set, emails will fail until it is (set MAIL_BACKEND=file for local development)")
+		}
+	}
+
+	switch backend {
+	case "smtp":
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	case "file":
+		dir := os.Getenv("MAIL_FILE_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return &FileMailer{Dir: dir}
+	case "memory":
+		return &MemoryMailer{}
+	default:
+		if backend != "resend" {
+			fmt.Printf("Warning: unknown MAIL_BACKEND %q, using resend\n", backend)
+		}
+		return NewResendMailer(os.Getenv("RESEND_API_KEY"))
+	}
+}
+
+// --- Resend ---
+
+type ResendMailer struct {
+	client *resend.Client
+}
+
+func NewResendMailer(apiKey string) *ResendMailer {
+	if apiKey == "" {
+		return &ResendMailer{}
+	}
+	return &ResendMailer{client: resend.NewClient(apiKey)}
+}
+
+func (m *ResendMailer) Send(msg Message) error {
+	if m.client == nil {
+		return fmt.Errorf("resend: RESEND_API_KEY is missing")
+	}
+	sent, err := m.client.Emails.Send(&resend.SendEmailRequest{
+		From:    msg.From,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+		Text:    msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Email sent successfully:", sent.Id)
+	return nil
+}
+
+// --- SMTP ---
+
+type SMTPMailer struct {
+	Host     string
+	Port     string // defaults to 587
+	Username string
+	Password string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if m.Host == "" {
+		return fmt.Errorf("smtp: SMTP_HOST is missing")
+	}
+	port := m.Port
+	if port == "" {
+		port = "587"
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, port), auth, envelopeAddress(msg.From), msg.To, msg.rfc822())
+}
+
+// --- Local development sinks ---
+
+// FileMailer writes each message to Dir as an .eml file that any mail client can open
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), safeFilename(strings.Join(msg.To, "_")))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, msg.rfc822(), 0o644); err != nil {
+		return err
+	}
+	fmt.Println("Email written to", path)
+	return nil
+}
+
+// MemoryMailer records messages instead of sending them
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, msg)
+	m.mu.Unlock()
+	return nil
+}
+
+// Sent returns a copy of every message recorded so far
+func (m *MemoryMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
+
+// Reset forgets recorded messages
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	m.sent = nil
+	m.mu.Unlock()
+}
+
+// --- Helpers ---
+
+// rfc822 renders the message as a MIME email for SMTP and .eml files; with a
+// Text part it becomes multipart/alternative so clients can pick either
+func (msg Message) rfc822() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", msg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.Text == "" {
+		b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&b, msg.HTML)
+		return b.Bytes()
+	}
+
+	mw := multipart.NewWriter(&b)
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		writeQuotedPrintable(w, part.body)
+	}
+	mw.Close()
+	return b.Bytes()
+}
+
+func writeQuotedPrintable(w io.Writer, s string) {
+	qp := quotedprintable.NewWriter(w)
+	qp.Write([]byte(s))
+	qp.Close()
+}
+
+// envelopeAddress extracts "admin@x.com" from "Name <admin@x.com>"
+func envelopeAddress(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 {
+		return strings.TrimSuffix(from[i+1:], ">")
+	}
+	return from
+}
+
+func safeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' || r == '@' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestSendThroughMemoryMailer(t *testing.T) {
+	t.Setenv("EMAIL_TEMPLATE_DIR", "")
+	m := &MemoryMailer{}
+	SetMailer(m)
+
+	for _, tc := range []struct {
+		name    string
+		send    func() error
+		to      string
+		from    string
+		subject string
+		body    []string // must appear in both the text and the HTML part
+	}{
+		{
+			name: "confirmation",
+			send: func() error {
+				return SendConfirmationEmail(EmailData{
+					Name:         "Ada Obi",
+					Email:        "ada@example.com",
+					ClanName:     "Clan Esther",
+					WhatsAppLink: "https://chat.whatsapp.com/esther",
+					CohortName:   "Ready for a Soulmate (Cohort 5)",
+				})
+			},
+			to:      "ada@example.com",
+			from:    soulmateSender,
+			subject: "Welcome to Ready for a Soulmate (Cohort 5)",
+			body:    []string{"Clan Esther", "https://chat.whatsapp.com/esther"},
+		},
+		{
+			name: "launchpad",
+			send: func() error {
+				return SendLaunchpadEmail(LaunchpadEmailData{
+					Name:         "Tunde & Ada",
+					Email:        "tunde@example.com",
+					WhatsAppLink: "https://chat.whatsapp.com/launchpad",
+					TelegramLink: "https://t.me/launchpad",
+					CohortName:   "Couples' Launchpad 5.0",
+				})
+			},
+			to:      "tunde@example.com",
+			from:    launchpadSender,
+			subject: "Welcome to Couples' Launchpad 5.0! 🚀",
+			body:    []string{"https://chat.whatsapp.com/launchpad", "https://t.me/launchpad"},
+		},
+		{
+			name:    "otp",
+			send:    func() error { return SendOTPEmail("spouse@example.com", "482915") },
+			to:      "spouse@example.com",
+			from:    launchpadSender,
+			subject: "Your Verification Code - Couples' Launchpad",
+			body:    []string{"482915"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m.Reset()
+			if err := tc.send(); err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			sent := m.Sent()
+			if len(sent) != 1 {
+				t.Fatalf("recorded %d messages, want 1", len(sent))
+			}
+			msg := sent[0]
+			if len(msg.To) != 1 || msg.To[0] != tc.to {
+				t.Errorf("To = %v, want [%s]", msg.To, tc.to)
+			}
+
+			parsed, err := mail.ReadMessage(bytes.NewReader(msg.rfc822()))
+			if err != nil {
+				t.Fatalf("parse rfc822: %v", err)
+			}
+			if got := parsed.Header.Get("From"); got != tc.from {
+				t.Errorf("From = %q, want %q", got, tc.from)
+			}
+			if got := parsed.Header.Get("To"); got != tc.to {
+				t.Errorf("To header = %q, want %q", got, tc.to)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			if err != nil || subject != tc.subject {
+				t.Errorf("Subject = %q (%v), want %q", subject, err, tc.subject)
+			}
+
+			parts := readAlternatives(t, parsed)
+			for _, contentType := range []string{"text/plain", "text/html"} {
+				body, ok := parts[contentType]
+				if !ok {
+					t.Errorf("no %s part", contentType)
+					continue
+				}
+				for _, want := range tc.body {
+					if !strings.Contains(body, want) {
+						t.Errorf("%s part is missing %q", contentType, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// readAlternatives returns the decoded parts of a multipart/alternative message by media type
+func readAlternatives(t *testing.T, msg *mail.Message) map[string]string {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+
+	parts := map[string]string{}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		body, err := io.ReadAll(p) // NextPart undoes the quoted-printable encoding
+		if err != nil {
+			t.Fatalf("read %s part: %v", partType, err)
+		}
+		parts[partType] = string(body)
+	}
+	return parts
+}
@@ -2,10 +2,9 @@ package services
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
+	"time"
 )
 
 // Data structure matching the JSON expected by Apps Script
@@ -25,26 +24,22 @@ type SheetPayload struct {
 	ReferralSource    string `json:"referral_source"`
 }
 
-func SendToLaunchpadSheet(data SheetPayload) {
-	webhookURL := os.Getenv("LAUNCHPAD_SHEETS_WEBHOOK_URL")
-	if webhookURL == "" {
-		fmt.Println("Warning: LAUNCHPAD_SHEETS_WEBHOOK_URL is not set")
-		return
-	}
-
-	jsonData, _ := json.Marshal(data)
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
-
+// PostSheetWebhook posts a JSON body to a Google Apps Script webhook. Apps Script
+// answers errors with non-2xx codes, which are returned so the caller can retry.
+func PostSheetWebhook(webhookURL string, jsonData []byte) error {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		fmt.Println("Error sending to Google Sheets (Network):", err)
-		return
+		return fmt.Errorf("sheets webhook: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// NEW: Read the actual response body
 	var bodyBytes bytes.Buffer
 	bodyBytes.ReadFrom(resp.Body)
-	responseString := bodyBytes.String()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sheets webhook: status %d: %s", resp.StatusCode, bodyBytes.String())
+	}
 
-	fmt.Printf("Google Sheets Response: %s\n", responseString)
-}
\ No newline at end of file
+	fmt.Printf("Google Sheets Response: %s\n", bodyBytes.String())
+	return nil
+}
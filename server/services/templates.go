@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email templates live in templates/: layout.html wraps every email, and each
+// <name>.html defines the "subject", "styles", "header" and "content" blocks.
+// Set EMAIL_TEMPLATE_DIR to load them from disk instead, so copy can be edited
+// without a deploy; they are then re-read on every send.
+//
+//go:embed templates/*.html
+var embeddedTemplates embed.FS
+
+const (
+	soulmateSender  = "Ready for a Soulmate <admin@temitopeayenigba.com>"
+	launchpadSender = "Couples' Launchpad <admin@temitopeayenigba.com>"
+	portalSender    = "Temitope Ayenigba Initiative <admin@temitopeayenigba.com>" // mail about the login itself, whichever program
+)
+
+type emailTemplate struct {
+	From   string
+	Sample any // used by the admin preview
+}
+
+var emailTemplates = map[string]emailTemplate{
+	"confirmation": {From: soulmateSender, Sample: EmailData{
+		Name:         "Ada Obi",
+		Email:        "ada@example.com",
+		ClanName:     "Clan Esther",
+		WhatsAppLink: "https://chat.whatsapp.com/example",
+		CohortName:   "Ready for a Soulmate (Cohort 5)",
+	}},
+	"launchpad": {From: launchpadSender, Sample: LaunchpadEmailData{
+		Name:         "Tunde & Ada",
+		Email:        "tunde@example.com",
+		WhatsAppLink: "https://chat.whatsapp.com/example",
+		TelegramLink: "https://t.me/example",
+		CohortName:   "Couples' Launchpad 5.0",
+	}},
+	"otp": {From: launchpadSender, Sample: OTPEmailData{
+		Email: "spouse@example.com",
+		Code:  "482915",
+	}},
+	"account_claim": {From: soulmateSender, Sample: AccountClaimEmailData{
+		Name:      "Ada Obi",
+		Email:     "ada@example.com",
+		ClaimLink: "https://example.com/register?claim=example",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}},
+	"waitlist_offer": {From: soulmateSender, Sample: WaitlistOfferEmailData{
+		Name:       "Ada Obi",
+		Email:      "ada@example.com",
+		CohortName: "Ready for a Soulmate (Cohort 5)",
+		ClaimLink:  "https://example.com/waitlist/claim?token=example",
+		ExpiresAt:  time.Now().Add(48 * time.Hour),
+	}},
+	"email_change": {From: portalSender, Sample: EmailChangeEmailData{
+		Name:        "Ada Obi",
+		Email:       "ada.obi@example.com",
+		OldEmail:    "ada@example.com",
+		ConfirmLink: "https://example.com/change-email?token=example",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}},
+	"email_changed": {From: portalSender, Sample: EmailChangedEmailData{
+		Name:     "Ada Obi",
+		Email:    "ada@example.com",
+		NewEmail: "ada.obi@example.com",
+	}},
+}
+
+var templateFuncs = template.FuncMap{
+	"year": func() int { return time.Now().Year() },
+}
+
+var (
+	templateCache   = map[string]*template.Template{}
+	templateCacheMu sync.Mutex
+)
+
+// RenderedEmail is a template filled in with data, ready to send or preview
+type RenderedEmail struct {
+	Name    string `json:"name"`
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// EmailTemplateNames lists the available templates in alphabetical order
+func EmailTemplateNames() []string {
+	names := make([]string, 0, len(emailTemplates))
+	for n := range emailTemplates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func loadEmailTemplate(name string) (*template.Template, error) {
+	dir := os.Getenv("EMAIL_TEMPLATE_DIR")
+	if dir == "" {
+		templateCacheMu.Lock()
+		defer templateCacheMu.Unlock()
+		if t, ok := templateCache[name]; ok {
+			return t, nil
+		}
+	}
+
+	var fsys fs.FS
+	if dir != "" {
+		fsys = os.DirFS(dir)
+	} else {
+		fsys, _ = fs.Sub(embeddedTemplates, "templates")
+	}
+	t, err := template.New(name).Funcs(templateFuncs).ParseFS(fsys, "layout.html", name+".html")
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		templateCache[name] = t
+	}
+	return t, nil
+}
+
+// RenderEmail fills in a template. The plain-text part is derived from the HTML.
+func RenderEmail(name string, data any) (RenderedEmail, error) {
+	meta, ok := emailTemplates[name]
+	if !ok {
+		return RenderedEmail{}, fmt.Errorf("unknown email template %q", name)
+	}
+	t, err := loadEmailTemplate(name)
+	if err != nil {
+		return RenderedEmail{}, err
+	}
+
+	var subject, body bytes.Buffer
+	if err := t.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return RenderedEmail{}, err
+	}
+	if err := t.ExecuteTemplate(&body, "layout", data); err != nil {
+		return RenderedEmail{}, err
+	}
+
+	return RenderedEmail{
+		Name:    name,
+		From:    meta.From,
+		Subject: html.UnescapeString(strings.TrimSpace(subject.String())),
+		HTML:    body.String(),
+		Text:    htmlToText(body.String()),
+	}, nil
+}
+
+// PreviewEmail renders a template with its built-in sample data
+func PreviewEmail(name string) (RenderedEmail, error) {
+	meta, ok := emailTemplates[name]
+	if !ok {
+		return RenderedEmail{}, fmt.Errorf("unknown email template %q", name)
+	}
+	return RenderEmail(name, meta.Sample)
+}
+
+// sendTemplate renders a template and sends it to one recipient
+func sendTemplate(name, to string, data any) error {
+	rendered, err := RenderEmail(name, data)
+	if err != nil {
+		return err
+	}
+	return getMailer().Send(Message{
+		From:    rendered.From,
+		To:      []string{to},
+		Subject: rendered.Subject,
+		HTML:    rendered.HTML,
+		Text:    rendered.Text,
+	})
+}
+
+var (
+	textDropBlocks = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+	textLinks      = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>(.*?)</a>`)
+	textListItems  = regexp.MustCompile(`(?i)<li\b[^>]*>`)
+	textBreaks     = regexp.MustCompile(`(?i)<br\s*/?>|</?(p|div|h[1-6]|ul|ol|li|tr|hr)\b[^>]*>`)
+	textTags       = regexp.MustCompile(`<[^>]+>`)
+)
+
+// htmlToText produces the text/plain alternative: links become "label (url)",
+// list items become "- item" and block elements become line breaks
+func htmlToText(s string) string {
+	s = textDropBlocks.ReplaceAllString(s, "")
+	s = textLinks.ReplaceAllString(s, "$2 ($1)")
+	s = textListItems.ReplaceAllString(s, "\n- ")
+	s = textBreaks.ReplaceAllString(s, "\n")
+	s = textTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+
+	var lines []string
+	blank := true
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		lines = append(lines, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
@@ -0,0 +1,189 @@
+// Package supabase is a small typed client for the Supabase Auth (GoTrue) API:
+// the admin user endpoints used with the service role key, and resolving a
+// user from their own access token.
+package supabase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds every call so a slow Auth API can't hang a request
+const DefaultTimeout = 10 * time.Second
+
+// ErrNotConfigured is returned when SUPABASE_URL or SUPABASE_SERVICE_ROLE_KEY is missing
+var ErrNotConfigured = errors.New("supabase: SUPABASE_URL and SUPABASE_SERVICE_ROLE_KEY must be set")
+
+type Client struct {
+	BaseURL    string // project URL, e.g. https://xyz.supabase.co
+	ServiceKey string // service role key
+	HTTP       *http.Client
+
+	jwksOnce sync.Once
+	jwks     *JWKS
+}
+
+// New returns a client for the project at baseURL
+func New(baseURL, serviceKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(strings.TrimSpace(baseURL), "/"),
+		ServiceKey: strings.TrimSpace(serviceKey),
+		HTTP:       &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// NewFromEnv reads SUPABASE_URL and SUPABASE_SERVICE_ROLE_KEY
+func NewFromEnv() *Client {
+	return New(os.Getenv("SUPABASE_URL"), os.Getenv("SUPABASE_SERVICE_ROLE_KEY"))
+}
+
+var (
+	defaultClient     *Client
+	defaultClientOnce sync.Once
+	defaultClientMu   sync.RWMutex
+)
+
+// Default returns the shared client, built from the environment on first use
+func Default() *Client {
+	defaultClientOnce.Do(func() {
+		c := NewFromEnv()
+		defaultClientMu.Lock()
+		if defaultClient == nil {
+			defaultClient = c
+		}
+		defaultClientMu.Unlock()
+	})
+	defaultClientMu.RLock()
+	defer defaultClientMu.RUnlock()
+	return defaultClient
+}
+
+// SetDefault replaces the shared client, e.g. with one pointed at supabasetest.Server
+func SetDefault(c *Client) {
+	defaultClientOnce.Do(func() {}) // don't let a later Default overwrite c
+	defaultClientMu.Lock()
+	defaultClient = c
+	defaultClientMu.Unlock()
+}
+
+// Configured reports whether the client has a URL and service key
+func (c *Client) Configured() bool {
+	return c.BaseURL != "" && c.ServiceKey != ""
+}
+
+// Error is a non-2xx response from the Auth API
+type Error struct {
+	Status  int
+	Code    string // GoTrue error_code, e.g. "email_exists", "user_not_found"
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("supabase: %d %s: %s", e.Status, e.Code, e.Message)
+	}
+	return fmt.Sprintf("supabase: %d: %s", e.Status, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the Auth API
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && (e.Status == http.StatusNotFound || e.Code == "user_not_found")
+}
+
+// IsEmailExists reports whether err means a user with that email is already registered
+func IsEmailExists(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	return e.Code == "email_exists" || strings.Contains(e.Message, "already been registered")
+}
+
+// decodeError reads whichever of GoTrue's error shapes the body uses
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var raw struct {
+		ErrorCode        string `json:"error_code"`
+		Msg              string `json:"msg"`
+		Message          string `json:"message"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	json.Unmarshal(body, &raw)
+
+	e := &Error{Status: resp.StatusCode, Code: raw.ErrorCode}
+	for _, m := range []string{raw.Msg, raw.Message, raw.ErrorDescription, raw.Error} {
+		if m != "" {
+			e.Message = m
+			break
+		}
+	}
+	if e.Code == "" && raw.ErrorDescription != "" {
+		e.Code = raw.Error
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+// do sends a request authenticated with bearer (the service key for admin calls)
+// and decodes a JSON response into out when it is non-nil
+func (c *Client) do(ctx context.Context, method, path, bearer string, in, out any) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/auth/v1"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", c.ServiceKey)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("supabase: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("supabase: decoding %s %s: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package supabase
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// JWKSCacheTTL is how long fetched signing keys are trusted before a refetch
+	JWKSCacheTTL = 10 * time.Minute
+	// jwksMinRefetch stops a stream of tokens with an unknown kid from hammering the endpoint
+	jwksMinRefetch = 30 * time.Second
+)
+
+// ErrUnknownKey is returned when no published key has the token's kid
+var ErrUnknownKey = errors.New("supabase: no signing key with that kid")
+
+// JWKS caches the project's published JWT signing keys by kid. Keys are
+// refetched after JWKSCacheTTL, or sooner when a token names a kid we haven't
+// seen, which is how a key rotation shows up.
+type JWKS struct {
+	URL  string
+	HTTP *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// JWKS returns the key cache for this project, created on first use
+func (c *Client) JWKS() *JWKS {
+	c.jwksOnce.Do(func() {
+		c.jwks = &JWKS{URL: c.BaseURL + "/auth/v1/.well-known/jwks.json", HTTP: c.HTTP}
+	})
+	return c.jwks
+}
+
+// Key returns the public key for kid, fetching the key set if needed
+func (k *JWKS) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, known := k.keys[kid]
+	fresh := time.Since(k.fetchedAt) < JWKSCacheTTL
+	if known && fresh {
+		return key, nil
+	}
+	if time.Since(k.lastAttempt) < jwksMinRefetch {
+		if known {
+			return key, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	k.lastAttempt = time.Now()
+	keys, err := k.fetch(ctx)
+	if err != nil {
+		if known {
+			return key, nil // a stale key beats failing every request while the endpoint is down
+		}
+		return nil, err
+	}
+	k.keys = keys
+	k.fetchedAt = time.Now()
+
+	if key, ok := k.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *JWKS) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := k.HTTP
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("supabase: fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, decodeError(resp)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("supabase: decoding JWKS: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, j := range doc.Keys {
+		if j.Use != "" && j.Use != "sig" {
+			continue
+		}
+		// Keys we can't use (e.g. a symmetric "oct" entry) are skipped, not fatal
+		if pub, err := j.publicKey(); err == nil {
+			keys[j.Kid] = pub
+		}
+	}
+	return keys, nil
+}
+
+func (j jwk) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("unsupported RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("bad P-256 coordinates")
+		}
+		// ecdh rejects points that aren't on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
@@ -0,0 +1,505 @@
+// Package supabasetest provides an in-process fake of the Supabase Auth API,
+// in the spirit of net/http/httptest, so code using the supabase package can be
+// exercised offline:
+//
+//	srv := supabasetest.NewServer()
+//	defer srv.Close()
+//	supabase.SetDefault(srv.AuthClient())
+//	u := srv.AddUser("ada@example.com", "secret1")
+//	token := srv.IssueToken(u.ID) // accepted by GET /auth/v1/user
+//	jwt := srv.SignToken(u.ID, time.Hour) // ES256, verifiable against the fake's JWKS
+//	srv.RotateRSAKey()                     // later tokens are RS256
+package supabasetest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asejik/soulmate-reg/server/supabase"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ServiceKey is the service role key the fake accepts on admin endpoints
+const ServiceKey = "supabasetest-service-key"
+
+type fakeUser struct {
+	supabase.User
+	password string
+}
+
+// Server is a fake Supabase Auth API backed by an in-memory user table
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	users  map[string]*fakeUser // by id
+	tokens map[string]string    // access token -> user id
+	links  []supabase.GeneratedLink
+
+	signingKeys []signingKey // published in the JWKS; the last one signs
+	jwksHits    int
+}
+
+type signingKey struct {
+	kid    string
+	key    crypto.Signer // *ecdsa.PrivateKey (ES256) or *rsa.PrivateKey (RS256)
+	method jwt.SigningMethod
+}
+
+// NewServer starts a fake. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		users:  map[string]*fakeUser{},
+		tokens: map[string]string{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /auth/v1/admin/users", s.admin(s.createUser))
+	mux.HandleFunc("GET /auth/v1/admin/users", s.admin(s.listUsers))
+	mux.HandleFunc("GET /auth/v1/admin/users/{id}", s.admin(s.getUser))
+	mux.HandleFunc("PUT /auth/v1/admin/users/{id}", s.admin(s.updateUser))
+	mux.HandleFunc("DELETE /auth/v1/admin/users/{id}", s.admin(s.deleteUser))
+	mux.HandleFunc("POST /auth/v1/admin/generate_link", s.admin(s.generateLink))
+	mux.HandleFunc("GET /auth/v1/user", s.tokenUser)
+	mux.HandleFunc("GET /auth/v1/.well-known/jwks.json", s.serveJWKS)
+
+	s.Server = httptest.NewServer(mux)
+	s.RotateKey()
+	return s
+}
+
+// AuthClient returns a supabase.Client pointed at the fake
+func (s *Server) AuthClient() *supabase.Client {
+	c := supabase.New(s.URL, ServiceKey)
+	c.HTTP = s.Client()
+	return c
+}
+
+// AddUser inserts a confirmed user directly, bypassing the API
+func (s *Server) AddUser(email, password string) supabase.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(email, password, true, nil).User
+}
+
+// IssueToken returns an access token GET /auth/v1/user will resolve to userID.
+// It is an HS256 JWT signed with a secret only the fake knows, so code under test
+// can't verify it locally and has to ask the API, like a project without
+// SUPABASE_JWT_SECRET configured.
+func (s *Server) IssueToken(userID string) string {
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": userID,
+		"aud": "authenticated",
+		"iss": s.URL + "/auth/v1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"jti": randomHex(8),
+	})
+	token, err := t.SignedString([]byte(randomHex(32)))
+	if err != nil {
+		panic(err)
+	}
+	s.mu.Lock()
+	s.tokens[token] = userID
+	s.mu.Unlock()
+	return token
+}
+
+// SignToken returns an access token for userID shaped like Supabase's:
+// aud "authenticated", iss <URL>/auth/v1, signed by the current key
+func (s *Server) SignToken(userID string, ttl time.Duration) string {
+	now := time.Now()
+	return s.SignClaims(jwt.MapClaims{
+		"sub":  userID,
+		"aud":  "authenticated",
+		"iss":  s.URL + "/auth/v1",
+		"role": "authenticated",
+		"iat":  now.Unix(),
+		"exp":  now.Add(ttl).Unix(),
+	})
+}
+
+// SignClaims signs arbitrary claims with the current key, for tokens with a
+// wrong aud, iss or exp
+func (s *Server) SignClaims(claims jwt.MapClaims) string {
+	s.mu.Lock()
+	k := s.signingKeys[len(s.signingKeys)-1]
+	s.mu.Unlock()
+
+	t := jwt.NewWithClaims(k.method, claims)
+	t.Header["kid"] = k.kid
+	signed, err := t.SignedString(k.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+// RotateKey starts signing with a new ES256 key. The previous key stays
+// published, as it does during a real rotation, until DropOldKeys.
+func (s *Server) RotateKey() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	s.addKey(key, jwt.SigningMethodES256)
+}
+
+// RotateRSAKey is RotateKey with an RS256 key
+func (s *Server) RotateRSAKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s.addKey(key, jwt.SigningMethodRS256)
+}
+
+func (s *Server) addKey(key crypto.Signer, method jwt.SigningMethod) {
+	s.mu.Lock()
+	s.signingKeys = append(s.signingKeys, signingKey{kid: "fake-" + randomHex(4), key: key, method: method})
+	s.mu.Unlock()
+}
+
+// DropOldKeys unpublishes every key but the current one
+func (s *Server) DropOldKeys() {
+	s.mu.Lock()
+	s.signingKeys = s.signingKeys[len(s.signingKeys)-1:]
+	s.mu.Unlock()
+}
+
+// JWKSHits counts fetches of the JWKS document, to check caching
+func (s *Server) JWKSHits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksHits
+}
+
+// Users returns every user, oldest first
+func (s *Server) Users() []supabase.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedUsers()
+}
+
+// UserByEmail looks a user up case-insensitively
+func (s *Server) UserByEmail(email string) (supabase.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.byEmail(email); u != nil {
+		return u.User, true
+	}
+	return supabase.User{}, false
+}
+
+// CheckPassword reports whether password is the user's current password
+func (s *Server) CheckPassword(email, password string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.byEmail(email)
+	return u != nil && u.password == password
+}
+
+// Links returns every link produced by generate_link
+func (s *Server) Links() []supabase.GeneratedLink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]supabase.GeneratedLink(nil), s.links...)
+}
+
+// --- handlers ---
+
+// admin rejects calls without the service key, as the real API does
+func (s *Server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("apikey") != ServiceKey || r.Header.Get("Authorization") != "Bearer "+ServiceKey {
+			writeError(w, http.StatusUnauthorized, "no_authorization", "This endpoint requires a valid service role key")
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var p supabase.CreateUserParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Email == "" {
+		writeError(w, http.StatusBadRequest, "validation_failed", "Unable to validate email address: invalid format")
+		return
+	}
+	if p.Password != "" && len(p.Password) < 6 {
+		writeError(w, http.StatusUnprocessableEntity, "weak_password", "Password should be at least 6 characters.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byEmail(p.Email) != nil {
+		writeError(w, http.StatusUnprocessableEntity, "email_exists", "A user with this email address has already been registered")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.insert(p.Email, p.Password, p.EmailConfirm, p.UserMetadata).User)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 50
+	}
+
+	s.mu.Lock()
+	all := s.sortedUsers()
+	s.mu.Unlock()
+
+	start := (page - 1) * perPage
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + perPage
+	if end > len(all) {
+		end = len(all)
+	}
+	writeJSON(w, http.StatusOK, supabase.ListUsersPage{Users: all[start:end]})
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, u.User)
+}
+
+func (s *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	var p supabase.UpdateUserParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_json", "Could not parse request body as JSON")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+	if p.Email != "" && !strings.EqualFold(p.Email, u.Email) {
+		if s.byEmail(p.Email) != nil {
+			writeError(w, http.StatusUnprocessableEntity, "email_exists", "A user with this email address has already been registered")
+			return
+		}
+		u.Email = strings.ToLower(p.Email)
+	}
+	if p.Password != "" {
+		if len(p.Password) < 6 {
+			writeError(w, http.StatusUnprocessableEntity, "weak_password", "Password should be at least 6 characters.")
+			return
+		}
+		u.password = p.Password
+	}
+	if p.EmailConfirm != nil && *p.EmailConfirm && u.EmailConfirmedAt == nil {
+		now := time.Now().UTC()
+		u.EmailConfirmedAt = &now
+	}
+	if p.UserMetadata != nil {
+		u.UserMetadata = p.UserMetadata
+	}
+	u.UpdatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusOK, u.User)
+}
+
+func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	if _, ok := s.users[id]; !ok {
+		writeError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+	delete(s.users, id)
+	for token, uid := range s.tokens {
+		if uid == id {
+			delete(s.tokens, token)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{})
+}
+
+func (s *Server) generateLink(w http.ResponseWriter, r *http.Request) {
+	var p supabase.GenerateLinkParams
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil || p.Email == "" || p.Type == "" {
+		writeError(w, http.StatusBadRequest, "validation_failed", "Missing type or email")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.byEmail(p.Email)
+	switch {
+	case u == nil && (p.Type == supabase.LinkSignup || p.Type == supabase.LinkInvite):
+		u = s.insert(p.Email, p.Password, false, nil)
+	case u == nil:
+		writeError(w, http.StatusNotFound, "user_not_found", "User with this email not found")
+		return
+	}
+
+	hashed := randomHex(28)
+	link := supabase.GeneratedLink{
+		ActionLink:       s.URL + "/auth/v1/verify?token=" + hashed + "&type=" + string(p.Type) + "&redirect_to=" + p.RedirectTo,
+		EmailOTP:         randomDigits(6),
+		HashedToken:      hashed,
+		VerificationType: string(p.Type),
+		RedirectTo:       p.RedirectTo,
+		User:             u.User,
+	}
+	s.links = append(s.links, link)
+	writeJSON(w, http.StatusOK, link)
+}
+
+// tokenUser serves GET /auth/v1/user for tokens made by IssueToken
+func (s *Server) tokenUser(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[s.tokens[token]]
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "bad_jwt", "invalid JWT: unable to parse or verify signature")
+		return
+	}
+	writeJSON(w, http.StatusOK, u.User)
+}
+
+func (s *Server) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwksHits++
+
+	keys := []map[string]string{}
+	for _, k := range s.signingKeys {
+		switch key := k.key.(type) {
+		case *ecdsa.PrivateKey:
+			x, y := make([]byte, 32), make([]byte, 32)
+			key.X.FillBytes(x)
+			key.Y.FillBytes(y)
+			keys = append(keys, map[string]string{
+				"kty": "EC",
+				"crv": "P-256",
+				"alg": "ES256",
+				"use": "sig",
+				"kid": k.kid,
+				"x":   base64.RawURLEncoding.EncodeToString(x),
+				"y":   base64.RawURLEncoding.EncodeToString(y),
+			})
+		case *rsa.PrivateKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": k.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": keys})
+}
+
+// --- helpers; callers hold s.mu ---
+
+func (s *Server) insert(email, password string, confirmed bool, meta map[string]any) *fakeUser {
+	now := time.Now().UTC()
+	u := &fakeUser{
+		User: supabase.User{
+			ID:           newUUID(),
+			Email:        strings.ToLower(email),
+			Role:         "authenticated",
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			AppMetadata:  map[string]any{"provider": "email"},
+			UserMetadata: meta,
+		},
+		password: password,
+	}
+	if confirmed {
+		u.EmailConfirmedAt = &now
+	}
+	s.users[u.ID] = u
+	return u
+}
+
+func (s *Server) byEmail(email string) *fakeUser {
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) sortedUsers() []supabase.User {
+	out := make([]supabase.User, 0, len(s.users))
+	for _, u := range s.users {
+		out = append(out, u.User)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError uses the current GoTrue error shape
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, map[string]any{"code": status, "error_code": code, "msg": msg})
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func randomDigits(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		b[i] = '0' + b[i]%10
+	}
+	return string(b)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}